@@ -2,8 +2,10 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 
+	"github.com/eastygh/webm-nas/pkg/cmd"
 	"github.com/eastygh/webm-nas/pkg/config"
 	"github.com/eastygh/webm-nas/pkg/server"
 	"github.com/eastygh/webm-nas/pkg/version"
@@ -37,6 +39,15 @@ func main() {
 		os.Exit(0)
 	}
 
+	// ctl talks to a running server, it does not need the server config
+	if flag.Arg(0) == "ctl" {
+		if err := cmd.RunCtl(flag.Args()[1:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	logger := logrus.StandardLogger()
 	logger.SetFormatter(&logrus.JSONFormatter{})
 
@@ -45,6 +56,13 @@ func main() {
 		logger.Fatalf("Failed to parse config: %v", err)
 	}
 
+	if flag.NArg() > 0 {
+		if err := runCommand(conf, flag.Args()); err != nil {
+			logger.Fatalf("Run command failed: %v", err)
+		}
+		return
+	}
+
 	s, err := server.New(conf, logger)
 	if err != nil {
 		logger.Fatalf("Init server failed: %v", err)
@@ -54,3 +72,12 @@ func main() {
 		logger.Fatalf("Run server failed: %v", err)
 	}
 }
+
+func runCommand(conf *config.Config, args []string) error {
+	switch args[0] {
+	case "rbac":
+		return cmd.RunRBAC(conf, args[1:], os.Stdout)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
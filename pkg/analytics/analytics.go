@@ -0,0 +1,192 @@
+// Package analytics counts the views and likes of posts. Views of a viewer are counted once per window,
+// counts are aggregated by post and day in memory and written asynchronously in batches.
+package analytics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWindow        = 30 * time.Minute
+	defaultFlushInterval = 10 * time.Second
+	defaultViewers       = 100000
+)
+
+// Options configures the recorder, zero values are the defaults.
+type Options struct {
+	// Window is the duration in which the views of a viewer of a post are counted once, default 30 minutes
+	Window time.Duration
+	// FlushInterval is the interval of writes of the counts, default 10 seconds
+	FlushInterval time.Duration
+	// Viewers is the number of recent views remembered for the window, default 100000
+	Viewers int
+}
+
+type statKey struct {
+	postID uint
+	day    string
+}
+
+// Recorder records the views and likes of posts.
+type Recorder struct {
+	repo   repository.StatsRepository
+	window time.Duration
+	// seen are the times of the counted views by post and viewer
+	seen *lru.Cache[string, time.Time]
+
+	lock    sync.Mutex
+	pending map[statKey]*model.PostStat
+	// views are the pending views by post, the sum of the views of its pending stats
+	views map[uint]int64
+
+	interval time.Duration
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	now      func() time.Time
+}
+
+func New(repo repository.StatsRepository, opts Options) *Recorder {
+	r := newRecorder(repo, opts)
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+func newRecorder(repo repository.StatsRepository, opts Options) *Recorder {
+	if opts.Window <= 0 {
+		opts.Window = defaultWindow
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.Viewers <= 0 {
+		opts.Viewers = defaultViewers
+	}
+	seen, _ := lru.New[string, time.Time](opts.Viewers)
+	return &Recorder{
+		repo:     repo,
+		window:   opts.Window,
+		seen:     seen,
+		pending:  make(map[statKey]*model.PostStat),
+		views:    make(map[uint]int64),
+		interval: opts.FlushInterval,
+		stop:     make(chan struct{}),
+		now:      time.Now,
+	}
+}
+
+// Viewer returns the viewer of a request, the user or the ip of anonymous users.
+func Viewer(user *model.User, ip string) string {
+	if user != nil && user.ID != 0 {
+		return fmt.Sprintf("user:%d", user.ID)
+	}
+	return "ip:" + ip
+}
+
+// View records a view of the post by the viewer, it reports whether the view is counted.
+// Views of a viewer within the window of the last counted view are not counted.
+func (r *Recorder) View(pid uint, viewer string) bool {
+	now := r.now()
+	key := fmt.Sprintf("%d/%s", pid, viewer)
+	if last, ok := r.seen.Get(key); ok && now.Sub(last) < r.window {
+		return false
+	}
+	r.seen.Add(key, now)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stat(pid, now).Views++
+	r.views[pid]++
+	return true
+}
+
+// Pending returns the views of the post which are not written yet.
+func (r *Recorder) Pending(pid uint) int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.views[pid]
+}
+
+// Like records a like of the post, or an unlike for a negative delta.
+func (r *Recorder) Like(pid uint, delta int64) {
+	now := r.now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stat(pid, now).Likes += delta
+}
+
+// stat returns the pending stat of the post on the day of t, r.lock must be held.
+func (r *Recorder) stat(pid uint, t time.Time) *model.PostStat {
+	key := statKey{postID: pid, day: t.UTC().Format(model.StatsDayLayout)}
+	stat, ok := r.pending[key]
+	if !ok {
+		stat = &model.PostStat{PostID: key.postID, Day: key.day}
+		r.pending[key] = stat
+	}
+	return stat
+}
+
+// Flush writes the pending counts, counts which fail to be written are kept for the next flush.
+func (r *Recorder) Flush() error {
+	r.lock.Lock()
+	pending, views := r.pending, r.views
+	r.pending, r.views = make(map[statKey]*model.PostStat), make(map[uint]int64)
+	r.lock.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	stats := make([]model.PostStat, 0, len(pending))
+	for _, stat := range pending {
+		stats = append(stats, *stat)
+	}
+	err := r.repo.Add(stats)
+	if err != nil {
+		r.lock.Lock()
+		for key, stat := range pending {
+			if current, ok := r.pending[key]; ok {
+				current.Views += stat.Views
+				current.Likes += stat.Likes
+			} else {
+				r.pending[key] = stat
+			}
+		}
+		for pid, n := range views {
+			r.views[pid] += n
+		}
+		r.lock.Unlock()
+	}
+	return err
+}
+
+// Close writes the pending counts and stops the writes.
+func (r *Recorder) Close() error {
+	close(r.stop)
+	r.wg.Wait()
+	return r.Flush()
+}
+
+func (r *Recorder) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(); err != nil {
+				logrus.Warnf("failed to write post stats: %v", err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
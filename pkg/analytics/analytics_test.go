@@ -0,0 +1,85 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRecorder(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	repo := repository.NewRepository(db)
+	require.Nil(t, repo.Migrate())
+	alice, err := repo.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	first, err := repo.Post().Create(alice, &model.Post{Name: "first", Content: "one"})
+	require.Nil(t, err)
+	second, err := repo.Post().Create(alice, &model.Post{Name: "second", Content: "two"})
+	require.Nil(t, err)
+
+	now := time.Date(2026, 1, 2, 23, 50, 0, 0, time.UTC)
+	r := newRecorder(repo.Stats(), Options{Window: time.Hour})
+	r.now = func() time.Time { return now }
+
+	bob := Viewer(&model.User{ID: 2}, "10.0.0.1")
+	assert.Equal(t, "user:2", bob)
+	assert.Equal(t, "ip:10.0.0.1", Viewer(&model.User{}, "10.0.0.1"))
+
+	// views of a viewer are counted once per window
+	assert.True(t, r.View(first.ID, bob))
+	assert.False(t, r.View(first.ID, bob))
+	assert.True(t, r.View(second.ID, bob))
+	assert.True(t, r.View(first.ID, Viewer(nil, "10.0.0.1")))
+	now = now.Add(time.Hour)
+	assert.True(t, r.View(first.ID, bob))
+	assert.Equal(t, int64(3), r.Pending(first.ID))
+	r.Like(second.ID, 1)
+	r.Like(second.ID, 1)
+	r.Like(second.ID, -1)
+	require.Nil(t, r.Flush())
+	require.Nil(t, r.Flush())
+	assert.Equal(t, int64(0), r.Pending(first.ID))
+
+	stats, err := repo.Stats().List(first.ID, "2026-01-01", "2026-01-31")
+	require.Nil(t, err)
+	assert.Equal(t, []model.PostStat{
+		{PostID: first.ID, Day: "2026-01-02", Views: 2},
+		{PostID: first.ID, Day: "2026-01-03", Views: 1},
+	}, stats)
+
+	// counters are updated without the update time and version of the post
+	post, err := repo.Post().GetPostByID(first.ID)
+	require.Nil(t, err)
+	assert.Equal(t, uint(3), post.Views)
+	assert.Equal(t, first.Version, post.Version)
+	assert.True(t, first.UpdatedAt.Equal(post.UpdatedAt))
+
+	top, err := repo.Stats().Top("views", "2026-01-01", "2026-01-31", 10, model.PostReader{})
+	require.Nil(t, err)
+	require.Len(t, top, 2)
+	assert.Equal(t, "first", top[0].Post.Name)
+	assert.Equal(t, int64(3), top[0].Views)
+	top, err = repo.Stats().Top("likes", "2026-01-03", "2026-01-03", 10, model.PostReader{})
+	require.Nil(t, err)
+	require.Len(t, top, 1)
+	assert.Equal(t, "second", top[0].Post.Name)
+	assert.Equal(t, int64(1), top[0].Likes)
+
+	// counts which fail to be written are kept
+	sqlDB, err := db.DB()
+	require.Nil(t, err)
+	r.View(first.ID, "ip:10.0.0.2")
+	require.Nil(t, sqlDB.Close())
+	assert.NotNil(t, r.Flush())
+	assert.Equal(t, int64(1), r.pending[statKey{postID: first.ID, day: "2026-01-03"}].Views)
+	assert.Equal(t, int64(1), r.Pending(first.ID))
+	assert.Equal(t, int64(0), r.Pending(second.ID))
+}
@@ -0,0 +1,216 @@
+// Package apierrors defines the errors returned by the api,
+// each error has a http status code and a machine readable reason.
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+
+	"gorm.io/gorm"
+)
+
+// Reason is a machine readable value providing more detail about why a request failed.
+type Reason string
+
+const (
+	ReasonBadRequest           Reason = "BadRequest"
+	ReasonInvalid              Reason = "Invalid"
+	ReasonUnauthorized         Reason = "Unauthorized"
+	ReasonForbidden            Reason = "Forbidden"
+	ReasonNotFound             Reason = "NotFound"
+	ReasonConflict             Reason = "Conflict"
+	ReasonAlreadyExists        Reason = "AlreadyExists"
+	ReasonGone                 Reason = "Gone"
+	ReasonTooLarge             Reason = "RequestEntityTooLarge"
+	ReasonUnsupportedMediaType Reason = "UnsupportedMediaType"
+	ReasonUnprocessableEntity  Reason = "UnprocessableEntity"
+	ReasonTooManyRequests      Reason = "TooManyRequests"
+	ReasonFailedDependency     Reason = "FailedDependency"
+	ReasonInternalError        Reason = "InternalError"
+	ReasonUnknown              Reason = "Unknown"
+)
+
+// ErrModified is the cause of a conflict when the object was changed by others since the version an update is based on.
+var ErrModified = errors.New("the object has been modified, apply your changes to the latest version and try again")
+
+// StatusError is an error with the http status code and reason of the response.
+type StatusError struct {
+	Code    int
+	Reason  Reason
+	Message string
+	// Details is responded as data, like the field errors of Invalid or the current object of Conflict.
+	Details interface{}
+
+	err error
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.err
+}
+
+func newStatusError(code int, reason Reason, err error) *StatusError {
+	return &StatusError{Code: code, Reason: reason, Message: err.Error(), err: err}
+}
+
+// NewBadRequest returns an error of a malformed request, like an invalid id or query.
+func NewBadRequest(err error) *StatusError {
+	return newStatusError(http.StatusBadRequest, ReasonBadRequest, err)
+}
+
+// NewInvalid returns an error of an invalid object, the field errors are the details.
+func NewInvalid(errs field.ErrorList) *StatusError {
+	e := newStatusError(http.StatusBadRequest, ReasonInvalid, errs.ToAggregate())
+	e.Details = errs
+	return e
+}
+
+// NewUnauthorized returns an error of a request without valid credentials.
+func NewUnauthorized(err error) *StatusError {
+	return newStatusError(http.StatusUnauthorized, ReasonUnauthorized, err)
+}
+
+// NewForbidden returns an error of a request the user is not allowed to do.
+func NewForbidden(err error) *StatusError {
+	return newStatusError(http.StatusForbidden, ReasonForbidden, err)
+}
+
+// NewNotFound returns an error of a missing object, resource is like users and name is the id or name of the object.
+func NewNotFound(resource string, name interface{}) *StatusError {
+	e := newStatusError(http.StatusNotFound, ReasonNotFound, gorm.ErrRecordNotFound)
+	e.Message = fmt.Sprintf("%s %q not found", resource, fmt.Sprint(name))
+	return e
+}
+
+// NewAlreadyExists returns an error of an object whose unique name is already used.
+func NewAlreadyExists(resource string, name interface{}) *StatusError {
+	e := newStatusError(http.StatusConflict, ReasonAlreadyExists, gorm.ErrDuplicatedKey)
+	e.Message = fmt.Sprintf("%s %q already exists", resource, fmt.Sprint(name))
+	return e
+}
+
+// NewConflict returns an error of a request conflicting with the current state,
+// current is the current object if it is known.
+func NewConflict(err error, current interface{}) *StatusError {
+	e := newStatusError(http.StatusConflict, ReasonConflict, err)
+	e.Details = current
+	return e
+}
+
+// NewFailedDependency returns an error of an operation which is not done because another operation failed,
+// like the operations of an atomic batch.
+func NewFailedDependency(err error) *StatusError {
+	return newStatusError(http.StatusFailedDependency, ReasonFailedDependency, err)
+}
+
+// NewRequestEntityTooLarge returns an error of a request whose body is larger than allowed, like a large upload.
+func NewRequestEntityTooLarge(err error) *StatusError {
+	return newStatusError(http.StatusRequestEntityTooLarge, ReasonTooLarge, err)
+}
+
+// NewUnsupportedMediaType returns an error of a request whose content has a type which is not accepted.
+func NewUnsupportedMediaType(err error) *StatusError {
+	return newStatusError(http.StatusUnsupportedMediaType, ReasonUnsupportedMediaType, err)
+}
+
+// NewInternalError returns an unexpected error of the server.
+func NewInternalError(err error) *StatusError {
+	return newStatusError(http.StatusInternalServerError, ReasonInternalError, err)
+}
+
+// FromError returns the StatusError of err, field errors, missing records and duplicated keys are converted.
+// It returns nil if err is not a known error.
+func FromError(err error) *StatusError {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status
+	}
+
+	var agg *field.AggregateError
+	if errors.As(err, &agg) {
+		status = NewInvalid(agg.Errors)
+		status.Message = err.Error()
+		return status
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return newStatusError(http.StatusNotFound, ReasonNotFound, err)
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return newStatusError(http.StatusConflict, ReasonAlreadyExists, err)
+	}
+	return nil
+}
+
+// ReasonForError returns the reason of err, ReasonUnknown if it is not a known error.
+func ReasonForError(err error) Reason {
+	if status := FromError(err); status != nil {
+		return status.Reason
+	}
+	return ReasonUnknown
+}
+
+// ReasonForStatus returns the reason of a http status code.
+func ReasonForStatus(code int) Reason {
+	switch code {
+	case http.StatusBadRequest:
+		return ReasonBadRequest
+	case http.StatusUnauthorized:
+		return ReasonUnauthorized
+	case http.StatusForbidden:
+		return ReasonForbidden
+	case http.StatusNotFound:
+		return ReasonNotFound
+	case http.StatusConflict:
+		return ReasonConflict
+	case http.StatusGone:
+		return ReasonGone
+	case http.StatusRequestEntityTooLarge:
+		return ReasonTooLarge
+	case http.StatusUnsupportedMediaType:
+		return ReasonUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return ReasonUnprocessableEntity
+	case http.StatusTooManyRequests:
+		return ReasonTooManyRequests
+	case http.StatusFailedDependency:
+		return ReasonFailedDependency
+	}
+	if code >= http.StatusInternalServerError {
+		return ReasonInternalError
+	}
+	return ReasonUnknown
+}
+
+func IsBadRequest(err error) bool {
+	return ReasonForError(err) == ReasonBadRequest
+}
+
+func IsInvalid(err error) bool {
+	return ReasonForError(err) == ReasonInvalid
+}
+
+func IsUnauthorized(err error) bool {
+	return ReasonForError(err) == ReasonUnauthorized
+}
+
+func IsForbidden(err error) bool {
+	return ReasonForError(err) == ReasonForbidden
+}
+
+func IsNotFound(err error) bool {
+	return ReasonForError(err) == ReasonNotFound
+}
+
+func IsAlreadyExists(err error) bool {
+	return ReasonForError(err) == ReasonAlreadyExists
+}
+
+func IsConflict(err error) bool {
+	return ReasonForError(err) == ReasonConflict
+}
@@ -0,0 +1,69 @@
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   int
+		reason Reason
+	}{
+		{name: "unknown", err: errors.New("some err")},
+		{name: "not found", err: NewNotFound("users", 1), code: http.StatusNotFound, reason: ReasonNotFound},
+		{name: "wrapped", err: fmt.Errorf("get: %w", NewForbidden(errors.New("no"))), code: http.StatusForbidden, reason: ReasonForbidden},
+		{name: "record not found", err: gorm.ErrRecordNotFound, code: http.StatusNotFound, reason: ReasonNotFound},
+		{name: "duplicated key", err: gorm.ErrDuplicatedKey, code: http.StatusConflict, reason: ReasonAlreadyExists},
+		{
+			name:   "field errors",
+			err:    field.ErrorList{field.Required(field.NewPath("name"), "")}.ToAggregate(),
+			code:   http.StatusBadRequest,
+			reason: ReasonInvalid,
+		},
+	}
+
+	for _, test := range tests {
+		status := FromError(test.err)
+		if test.code == 0 {
+			assert.Nil(t, status, test.name)
+			assert.Equal(t, ReasonUnknown, ReasonForError(test.err), test.name)
+			continue
+		}
+		assert.Equal(t, test.code, status.Code, test.name)
+		assert.Equal(t, test.reason, status.Reason, test.name)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	err := NewNotFound("posts", 3)
+	assert.EqualError(t, err, `posts "3" not found`)
+	assert.True(t, IsNotFound(err))
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	errs := field.ErrorList{field.Required(field.NewPath("name"), "")}
+	invalid := NewInvalid(errs)
+	assert.True(t, IsInvalid(invalid))
+	assert.Equal(t, errs, invalid.Details)
+
+	conflict := NewConflict(ErrModified, "current")
+	assert.True(t, IsConflict(conflict))
+	assert.ErrorIs(t, conflict, ErrModified)
+	assert.Equal(t, "current", conflict.Details)
+
+	assert.Equal(t, ReasonGone, ReasonForStatus(http.StatusGone))
+	assert.Equal(t, ReasonTooLarge, ReasonForStatus(NewRequestEntityTooLarge(errors.New("large")).Code))
+	assert.Equal(t, ReasonFailedDependency, ReasonForStatus(http.StatusFailedDependency))
+	assert.Equal(t, ReasonUnprocessableEntity, ReasonForStatus(http.StatusUnprocessableEntity))
+	assert.Equal(t, ReasonInternalError, ReasonForStatus(http.StatusBadGateway))
+	assert.Equal(t, ReasonUnknown, ReasonForStatus(http.StatusTeapot))
+}
@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/config"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxBodySize = 1024
+	bufferSize         = 1024
+	batchSize          = 100
+	flushInterval      = time.Second
+	pruneInterval      = time.Hour
+)
+
+// Sink stores audit events.
+type Sink interface {
+	Write(events []model.AuditEvent) error
+	// Prune removes events which are older than before
+	Prune(before time.Time) error
+	Close() error
+}
+
+// Auditor records audit events asynchronously to all sinks.
+type Auditor struct {
+	sinks       []Sink
+	retention   time.Duration
+	maxBodySize int
+
+	events chan model.AuditEvent
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func New(conf *config.AuditConfig, repo repository.AuditRepository) (*Auditor, error) {
+	if conf == nil || !conf.Enable {
+		return nil, nil
+	}
+
+	var sinks []Sink
+	if conf.DB {
+		sinks = append(sinks, NewDBSink(repo))
+	}
+	if conf.File != "" {
+		sink, err := NewFileSink(conf.File)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	maxBodySize := conf.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxBodySize
+	}
+
+	a := &Auditor{
+		sinks:       sinks,
+		retention:   time.Duration(conf.RetentionDays) * 24 * time.Hour,
+		maxBodySize: maxBodySize,
+		events:      make(chan model.AuditEvent, bufferSize),
+		stop:        make(chan struct{}),
+	}
+
+	a.wg.Add(1)
+	go a.run()
+
+	return a, nil
+}
+
+func (a *Auditor) MaxBodySize() int {
+	return a.maxBodySize
+}
+
+// Record queues an event, the event is dropped if the queue is full,
+// so that a slow sink never blocks requests.
+func (a *Auditor) Record(event model.AuditEvent) {
+	select {
+	case a.events <- event:
+	default:
+		logrus.Warnf("audit queue is full, drop event: %s %s by %s", event.Verb, event.Path, event.UserName)
+	}
+}
+
+// Close flushes queued events and closes all sinks.
+func (a *Auditor) Close() error {
+	close(a.stop)
+	a.wg.Wait()
+
+	var err error
+	for _, sink := range a.sinks {
+		if e := sink.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (a *Auditor) run() {
+	defer a.wg.Done()
+
+	flush := time.NewTicker(flushInterval)
+	defer flush.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	a.prune()
+
+	batch := make([]model.AuditEvent, 0, batchSize)
+	for {
+		select {
+		case event := <-a.events:
+			batch = append(batch, event)
+			if len(batch) >= batchSize {
+				batch = a.write(batch)
+			}
+		case <-flush.C:
+			batch = a.write(batch)
+		case <-prune.C:
+			a.prune()
+		case <-a.stop:
+			for {
+				select {
+				case event := <-a.events:
+					batch = append(batch, event)
+				default:
+					a.write(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (a *Auditor) write(batch []model.AuditEvent) []model.AuditEvent {
+	if len(batch) == 0 {
+		return batch
+	}
+	for _, sink := range a.sinks {
+		if err := sink.Write(batch); err != nil {
+			logrus.Warnf("failed to write %d audit events: %v", len(batch), err)
+		}
+	}
+	return batch[:0]
+}
+
+func (a *Auditor) prune() {
+	if a.retention <= 0 {
+		return
+	}
+	before := time.Now().Add(-a.retention)
+	for _, sink := range a.sinks {
+		if err := sink.Prune(before); err != nil {
+			logrus.Warnf("failed to prune audit events: %v", err)
+		}
+	}
+}
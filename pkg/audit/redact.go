@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const redacted = "******"
+
+var secretKeys = []string{"password", "passwd", "secret", "token", "authcode", "credential", "apikey"}
+
+// SummarizeBody returns the request body with secrets redacted, truncated to maxSize.
+// Only json bodies are kept, other bodies are summarized by their size and type.
+func SummarizeBody(contentType string, body []byte, maxSize int) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var data interface{}
+	if !strings.Contains(contentType, "json") || json.Unmarshal(body, &data) != nil {
+		return fmt.Sprintf("[%d bytes %s]", len(body), contentType)
+	}
+
+	out, err := json.Marshal(redact(data))
+	if err != nil {
+		return fmt.Sprintf("[%d bytes %s]", len(body), contentType)
+	}
+
+	if maxSize > 0 && len(out) > maxSize {
+		return string(out[:maxSize]) + "..."
+	}
+	return string(out)
+}
+
+func redact(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if isSecretKey(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redact(val)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+	}
+	return data
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeBody(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		maxSize     int
+		expected    string
+	}{
+		{"empty body", "application/json", "", 100, ""},
+		{"redact password", "application/json", `{"name":"admin","password":"123456"}`, 100, `{"name":"admin","password":"******"}`},
+		{"redact nested", "application/json", `{"authInfos":[{"accessToken":"abc","url":"x"}]}`, 100, `{"authInfos":[{"accessToken":"******","url":"x"}]}`},
+		{"truncate", "application/json", `{"content":"0123456789"}`, 10, `{"content"...`},
+		{"not json", "multipart/form-data", "some file", 100, "[9 bytes multipart/form-data]"},
+		{"invalid json", "application/json", "{", 100, "[1 bytes application/json]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, SummarizeBody(tc.contentType, []byte(tc.body), tc.maxSize))
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+)
+
+const fileDateLayout = "2006-01-02"
+
+type dbSink struct {
+	repo repository.AuditRepository
+}
+
+func NewDBSink(repo repository.AuditRepository) Sink {
+	return &dbSink{repo: repo}
+}
+
+func (s *dbSink) Write(events []model.AuditEvent) error {
+	return s.repo.Create(events)
+}
+
+func (s *dbSink) Prune(before time.Time) error {
+	_, err := s.repo.DeleteBefore(before)
+	return err
+}
+
+func (s *dbSink) Close() error {
+	return nil
+}
+
+// fileSink writes events as json lines, one file per day,
+// audit/audit.log is written as audit/audit-2006-01-02.log.
+type fileSink struct {
+	dir    string
+	prefix string
+	ext    string
+
+	lock sync.Mutex
+	day  string
+	file *os.File
+}
+
+func NewFileSink(path string) (Sink, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	ext := filepath.Ext(path)
+	return &fileSink{
+		dir:    dir,
+		prefix: strings.TrimSuffix(filepath.Base(path), ext) + "-",
+		ext:    ext,
+	}, nil
+}
+
+func (s *fileSink) Write(events []model.AuditEvent) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, event := range events {
+		file, err := s.open(event.Time.Format(fileDateLayout))
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileSink) open(day string) (*os.File, error) {
+	if s.file != nil && s.day == day {
+		return s.file, nil
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+
+	file, err := os.OpenFile(filepath.Join(s.dir, s.prefix+day+s.ext), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s.file, s.day = file, day
+	return file, nil
+}
+
+func (s *fileSink) Prune(before time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	matches, err := filepath.Glob(filepath.Join(s.dir, s.prefix+"*"+s.ext))
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		day := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), s.prefix), s.ext)
+		t, err := time.ParseInLocation(fileDateLayout, day, before.Location())
+		if err != nil {
+			continue
+		}
+		// a file holds a whole day, remove it only when the entire day is expired
+		if t.AddDate(0, 0, 1).After(before) || day == s.day {
+			continue
+		}
+		if err := os.Remove(match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -10,6 +10,7 @@ var store repository.Repository
 
 func InitAuthorization(repository repository.Repository) error {
 	store = repository
+	registerDefaultOwners()
 	return nil
 }
 
@@ -18,27 +19,89 @@ func Authorize(user *model.User, ri *request.RequestInfo) (bool, error) {
 		return false, nil
 	}
 
-	if user.ID == 0 {
-		group, err := store.Group().GetGroupByName(model.UnAuthenticatedGroup)
+	roles, err := ResolveRoles(user)
+	if err != nil {
+		return false, err
+	}
+	return roles.Authorize(ri)
+}
+
+// AuthorizeVerb checks if the roles of the user allow the verb on the resource of the request,
+// like the moderate operation of posts. Unlike Authorize owners are not allowed by themselves.
+func AuthorizeVerb(user *model.User, ri *request.RequestInfo, verb string) (bool, error) {
+	if user == nil || ri == nil {
+		return false, nil
+	}
+	roles, err := ResolveRoles(user)
+	if err != nil {
+		return false, err
+	}
+	return roles.AuthorizeVerb(ri, verb), nil
+}
+
+// Roles are the roles of a user resolved once, so that many requests of the user,
+// like the events of a watch, can be authorized without loading the roles for each of them.
+type Roles struct {
+	uid   uint
+	roles []model.Role
+}
+
+// ResolveRoles returns the roles of the user, its groups and its system group.
+func ResolveRoles(user *model.User) (*Roles, error) {
+	roles, err := userRoles(user)
+	if err != nil {
+		return nil, err
+	}
+	return &Roles{uid: user.ID, roles: roles}, nil
+}
+
+// Authorize is Authorize with the resolved roles, owners are still resolved for every request.
+func (r *Roles) Authorize(ri *request.RequestInfo) (bool, error) {
+	if r == nil || ri == nil {
+		return false, nil
+	}
+
+	// modifying an owned object is always allowed to its owner,
+	// others need an explicit moderate rule
+	if resolver := getOwnerResolver(ri); resolver != nil {
+		owner, err := resolver(ri)
 		if err != nil {
 			return false, err
 		}
-		user.Groups = append(user.Groups, *group)
-	} else {
-		group, err := store.Group().GetGroupByName(model.AuthenticatedGroup)
-		if err != nil {
-			return false, err
+		if r.uid != 0 && owner == r.uid {
+			return true, nil
 		}
-		user.Groups = append(user.Groups, *group)
+		return allowed(r.roles, ri, string(model.ModerateOperation)), nil
+	}
+
+	return allowed(r.roles, ri, ri.Verb), nil
+}
+
+// AuthorizeVerb is AuthorizeVerb with the resolved roles.
+func (r *Roles) AuthorizeVerb(ri *request.RequestInfo, verb string) bool {
+	if r == nil || ri == nil {
+		return false
 	}
+	return allowed(r.roles, ri, verb)
+}
 
-	var err error
+// userRoles returns the roles of the user, its groups and its system group.
+func userRoles(user *model.User) ([]model.Role, error) {
+	// reload the user, so the roles are up to date
 	if user.ID != 0 {
-		user, err = store.User().GetUserByID(user.ID)
+		var err error
+		if user, err = store.User().GetUserByID(user.ID); err != nil {
+			return nil, err
+		}
 	}
 
+	systemGroup := model.AuthenticatedGroup
+	if user.ID == 0 {
+		systemGroup = model.UnAuthenticatedGroup
+	}
+	group, err := store.Group().GetGroupByName(systemGroup)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	roles := make([]model.Role, 0)
@@ -46,7 +109,10 @@ func Authorize(user *model.User, ri *request.RequestInfo) (bool, error) {
 	for _, g := range user.Groups {
 		roles = append(roles, g.Roles...)
 	}
+	return append(roles, group.Roles...), nil
+}
 
+func allowed(roles []model.Role, ri *request.RequestInfo, verb string) bool {
 	for _, role := range roles {
 		if ri.Namespace == "" && role.Scope == model.NamespaceScope {
 			continue
@@ -57,13 +123,22 @@ func Authorize(user *model.User, ri *request.RequestInfo) (bool, error) {
 		}
 
 		for _, rule := range role.Rules {
-			if (rule.Resource == model.All || rule.Resource == ri.Resource) && rule.Operation.Contain(ri.Verb) {
-				return true, nil
+			if matchResource(rule.Resource, ri) && rule.Operation.Contain(verb) {
+				return true
 			}
 		}
 	}
 
-	return false, nil
+	return false
+}
+
+// matchResource checks if a rule resource matches the request, a rule
+// like containers/exec only matches the exec subresource of containers
+func matchResource(resource string, ri *request.RequestInfo) bool {
+	if resource == model.All || resource == ri.Resource {
+		return true
+	}
+	return ri.Subresource != "" && resource == ri.Resource+"/"+ri.Subresource
 }
 
 func IsClusterAdmin(user *model.User) bool {
@@ -78,7 +153,7 @@ func IsClusterAdmin(user *model.User) bool {
 	}
 
 	for _, role := range roles {
-		if role.Name == "cluster-admin" {
+		if role.Name == model.ClusterAdminRole {
 			return true
 		}
 	}
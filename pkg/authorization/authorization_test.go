@@ -0,0 +1,72 @@
+package authorization
+
+import (
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/request"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestResolveRoles(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	repo := repository.NewRepository(db)
+	require.Nil(t, repo.Migrate())
+	require.Nil(t, repo.Init())
+	require.Nil(t, InitAuthorization(repo))
+
+	viewer, err := repo.RBAC().Create(&model.Role{Name: "viewer", Scope: model.ClusterScope, Rules: model.Rules{{Resource: model.PostResource, Operation: model.ViewOperation}}})
+	require.Nil(t, err)
+	alice, err := repo.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	group, err := repo.Group().Create(alice, &model.Group{Name: "dev", Kind: model.CustomGroup})
+	require.Nil(t, err)
+	require.Nil(t, repo.Group().AddRole(viewer, group))
+
+	getPost := &request.RequestInfo{IsResourceRequest: true, Verb: request.GetOperation, Resource: model.PostResource, Name: "1", Parts: []string{model.PostResource, "1"}}
+	getUser := &request.RequestInfo{IsResourceRequest: true, Verb: request.GetOperation, Resource: model.UserResource, Name: "1", Parts: []string{model.UserResource, "1"}}
+
+	roles, err := ResolveRoles(alice)
+	require.Nil(t, err)
+
+	// resolved roles are matched without queries
+	queries := 0
+	require.Nil(t, db.Callback().Query().Before("gorm:query").Register("test:count", func(*gorm.DB) { queries++ }))
+	for i := 0; i < 10; i++ {
+		allowed, err := roles.Authorize(getPost)
+		require.Nil(t, err)
+		assert.True(t, allowed)
+		allowed, err = roles.Authorize(getUser)
+		require.Nil(t, err)
+		assert.False(t, allowed)
+	}
+	assert.True(t, roles.AuthorizeVerb(getPost, request.ListOperation))
+	assert.False(t, roles.AuthorizeVerb(getPost, string(model.ModerateOperation)))
+	assert.Zero(t, queries)
+
+	// revoked roles take effect when the roles are resolved again
+	require.Nil(t, repo.Group().DelRole(viewer, group))
+	allowed, err := roles.Authorize(getPost)
+	require.Nil(t, err)
+	assert.True(t, allowed)
+	roles, err = ResolveRoles(alice)
+	require.Nil(t, err)
+	allowed, err = roles.Authorize(getPost)
+	require.Nil(t, err)
+	assert.False(t, allowed)
+	allowed, err = Authorize(alice, getPost)
+	require.Nil(t, err)
+	assert.False(t, allowed)
+
+	// nil roles allow nothing, like a watch without a user
+	var none *Roles
+	allowed, err = none.Authorize(getPost)
+	require.Nil(t, err)
+	assert.False(t, allowed)
+}
@@ -0,0 +1,95 @@
+package authorization
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/utils/request"
+	"github.com/eastygh/webm-nas/pkg/utils/set"
+
+	"gorm.io/gorm"
+)
+
+// OwnerResolver returns the id of the user who owns the object a request points to,
+// it returns 0 if the object does not exist.
+type OwnerResolver func(ri *request.RequestInfo) (uint, error)
+
+var (
+	// ownedVerbs are the verbs which modify an existing object
+	ownedVerbs = set.NewString(request.UpdateOperation, request.PatchOperation, request.DeleteOperation)
+
+	ownerLock      sync.RWMutex
+	ownerResolvers = map[string]OwnerResolver{}
+)
+
+// RegisterOwnerResolver makes a resource owned, resource is a resource name like posts
+// or a subresource like posts/comment.
+func RegisterOwnerResolver(resource string, resolver OwnerResolver) {
+	ownerLock.Lock()
+	defer ownerLock.Unlock()
+	ownerResolvers[resource] = resolver
+}
+
+func getOwnerResolver(ri *request.RequestInfo) OwnerResolver {
+	if !ownedVerbs.Has(ri.Verb) || ri.Name == "" {
+		return nil
+	}
+
+	resource := ri.Resource
+	if ri.Subresource != "" {
+		// only requests to a single subresource object, like posts/1/comment/2, are owned
+		if len(ri.Parts) < 4 {
+			return nil
+		}
+		resource = resource + "/" + ri.Subresource
+	} else if len(ri.Parts) > 2 {
+		return nil
+	}
+
+	ownerLock.RLock()
+	defer ownerLock.RUnlock()
+	return ownerResolvers[resource]
+}
+
+func registerDefaultOwners() {
+	RegisterOwnerResolver(model.PostResource, postOwner)
+	RegisterOwnerResolver(model.PostResource+"/comment", commentOwner)
+}
+
+func postOwner(ri *request.RequestInfo) (uint, error) {
+	pid, err := strconv.Atoi(ri.Name)
+	if err != nil {
+		return 0, nil
+	}
+
+	post, err := store.Post().GetPostByID(uint(pid))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return post.CreatorID, nil
+}
+
+func commentOwner(ri *request.RequestInfo) (uint, error) {
+	pid, err := strconv.Atoi(ri.Name)
+	if err != nil {
+		return 0, nil
+	}
+	cid, err := strconv.Atoi(ri.Parts[3])
+	if err != nil {
+		return 0, nil
+	}
+
+	comment, err := store.Post().GetComment(uint(pid), uint(cid))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return comment.UserID, nil
+}
@@ -0,0 +1,73 @@
+package authorization
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/utils/request"
+	"github.com/eastygh/webm-nas/pkg/utils/set"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOwnerResolver(t *testing.T) {
+	RegisterOwnerResolver("posts", func(*request.RequestInfo) (uint, error) { return 1, nil })
+	RegisterOwnerResolver("posts/comment", func(*request.RequestInfo) (uint, error) { return 2, nil })
+
+	resolver := request.RequestInfoFactory{APIPrefixes: set.NewString("api")}
+
+	testCases := []struct {
+		name     string
+		method   string
+		uri      string
+		expected uint
+	}{
+		{"create is not owned", "POST", "/api/v1/posts", 0},
+		{"get is not owned", "GET", "/api/v1/posts/1", 0},
+		{"update post", "PUT", "/api/v1/posts/1", 1},
+		{"delete post", "DELETE", "/api/v1/posts/1", 1},
+		{"like is not owned", "DELETE", "/api/v1/posts/1/like", 0},
+		{"add comment is not owned", "POST", "/api/v1/posts/1/comment", 0},
+		{"delete comment", "DELETE", "/api/v1/posts/1/comment/3", 2},
+		{"unknown resource", "DELETE", "/api/v1/groups/1", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ri, err := resolver.NewRequestInfo(httptest.NewRequest(tc.method, tc.uri, nil))
+			assert.NoError(t, err)
+
+			owner := getOwnerResolver(ri)
+			if tc.expected == 0 {
+				assert.Nil(t, owner)
+				return
+			}
+			assert.NotNil(t, owner)
+			id, _ := owner(ri)
+			assert.Equal(t, tc.expected, id)
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	roles := []model.Role{
+		{
+			Name:  "post-editor",
+			Scope: model.ClusterScope,
+			Rules: model.Rules{{Resource: "posts", Operation: model.EditOperation}},
+		},
+		{
+			Name:  "comment-moderator",
+			Scope: model.ClusterScope,
+			Rules: model.Rules{{Resource: "posts/comment", Operation: model.ModerateOperation}},
+		},
+	}
+
+	post := &request.RequestInfo{Namespace: request.NamespaceRoot, Resource: "posts", Name: "1"}
+	comment := &request.RequestInfo{Namespace: request.NamespaceRoot, Resource: "posts", Name: "1", Subresource: "comment"}
+
+	assert.True(t, allowed(roles, post, request.UpdateOperation))
+	assert.False(t, allowed(roles, post, string(model.ModerateOperation)))
+	assert.True(t, allowed(roles, comment, string(model.ModerateOperation)))
+	assert.True(t, allowed([]model.Role{{Rules: model.Rules{{Resource: model.All, Operation: model.AllOperation}}}}, post, string(model.ModerateOperation)))
+}
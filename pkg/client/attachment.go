@@ -0,0 +1,56 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+)
+
+func (p *PostClient) Attachments(ctx context.Context, id uint) ([]model.Attachment, error) {
+	attachments := make([]model.Attachment, 0)
+	if err := get(ctx, p.client, "/posts/"+itoa(id)+"/attachments", &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// UploadAttachment uploads the content as a file with the name to the post, the url of the attachment
+// can be used in the content of the post. The content is buffered, so the upload can be retried after logging in again.
+func (p *PostClient) UploadAttachment(ctx context.Context, id uint, name string, content io.Reader) (*model.Attachment, error) {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	file, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	attachment := &model.Attachment{}
+	req := &request{method: http.MethodPost, path: "/posts/" + itoa(id) + "/attachments", body: body.Bytes(), contentType: form.FormDataContentType()}
+	if _, err := p.client.do(ctx, req, attachment); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// DownloadAttachment returns the content of the attachment.
+func (p *PostClient) DownloadAttachment(ctx context.Context, id, aid uint) ([]byte, error) {
+	var content []byte
+	if err := get(ctx, p.client, "/posts/"+itoa(id)+"/attachments/"+itoa(aid), &content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (p *PostClient) DelAttachment(ctx context.Context, id, aid uint) error {
+	return del(ctx, p.client, "/posts/"+itoa(id)+"/attachments/"+itoa(aid), 0)
+}
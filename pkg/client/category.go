@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+)
+
+type CategoryClient struct {
+	client *Client
+}
+
+func (ca *CategoryClient) List(ctx context.Context, opts *model.ListOptions) ([]model.Category, *model.ListMeta, error) {
+	return list[model.Category](ctx, ca.client, "/categories", opts)
+}
+
+// ListAll lists all pages of categories.
+func (ca *CategoryClient) ListAll(ctx context.Context, opts *model.ListOptions) ([]model.Category, error) {
+	return listAll[model.Category](ctx, ca.client, "/categories", opts)
+}
+
+func (ca *CategoryClient) Get(ctx context.Context, id uint) (*model.Category, error) {
+	category := &model.Category{}
+	if err := get(ctx, ca.client, "/categories/"+itoa(id), category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (ca *CategoryClient) Create(ctx context.Context, category *model.Category) (*model.Category, error) {
+	created := &model.Category{}
+	if err := create(ctx, ca.client, "/categories", category, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (ca *CategoryClient) Update(ctx context.Context, id uint, category *model.Category) (*model.Category, error) {
+	updated := &model.Category{}
+	if err := update(ctx, ca.client, "/categories/"+itoa(id), 0, category, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Delete deletes the category, its children become children of its parent.
+func (ca *CategoryClient) Delete(ctx context.Context, id uint) error {
+	return del(ctx, ca.client, "/categories/"+itoa(id), 0)
+}
+
+// Posts lists the posts in the category.
+func (ca *CategoryClient) Posts(ctx context.Context, id uint, opts *model.ListOptions) ([]model.Post, *model.ListMeta, error) {
+	return list[model.Post](ctx, ca.client, "/categories/"+itoa(id)+"/posts", opts)
+}
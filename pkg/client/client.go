@@ -0,0 +1,383 @@
+// Package client is a typed Go client of the api, it logs in with a token or a user name and password,
+// unwraps the response envelope and returns failed requests as *apierrors.StatusError.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+)
+
+const (
+	// APIPrefix is the path prefix of the api version the client talks to.
+	APIPrefix = "/api/v1"
+
+	defaultTimeout = 30 * time.Second
+	mergePatchType = "application/merge-patch+json"
+)
+
+// Config of a client, the token is used if it is set, otherwise the client logs in with the user name and password.
+// With a user name and password an expired token is refreshed by logging in again.
+type Config struct {
+	// Server is the base url of the server, like http://localhost:8080
+	Server   string
+	Token    string
+	Username string
+	Password string
+	// HTTPClient defaults to a client with a 30 seconds timeout
+	HTTPClient *http.Client
+}
+
+type Client struct {
+	server   string
+	username string
+	password string
+	http     *http.Client
+
+	lock  sync.RWMutex
+	token string
+}
+
+func New(conf Config) (*Client, error) {
+	server := strings.TrimRight(conf.Server, "/")
+	u, err := url.Parse(server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server %q, expect an url like http://localhost:8080", conf.Server)
+	}
+
+	httpClient := conf.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	return &Client{
+		server:   server,
+		username: conf.Username,
+		password: conf.Password,
+		http:     httpClient,
+		token:    conf.Token,
+	}, nil
+}
+
+func (c *Client) Users() *UserClient {
+	return &UserClient{client: c}
+}
+
+func (c *Client) Groups() *GroupClient {
+	return &GroupClient{client: c}
+}
+
+func (c *Client) Roles() *RoleClient {
+	return &RoleClient{client: c}
+}
+
+func (c *Client) Posts() *PostClient {
+	return &PostClient{client: c}
+}
+
+func (c *Client) Tags() *TagClient {
+	return &TagClient{client: c}
+}
+
+func (c *Client) Categories() *CategoryClient {
+	return &CategoryClient{client: c}
+}
+
+func (c *Client) Trash() *TrashClient {
+	return &TrashClient{client: c}
+}
+
+// Token returns the current token, it is empty before logging in.
+func (c *Client) Token() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.token
+}
+
+// Login gets a token with the configured user name and password, the token is used by later requests.
+func (c *Client) Login(ctx context.Context) (*model.JWTToken, error) {
+	if c.username == "" {
+		return nil, fmt.Errorf("no user name to login")
+	}
+
+	token := &model.JWTToken{}
+	auth := &model.AuthUser{Name: c.username, Password: c.password}
+	if _, err := c.send(ctx, &request{method: http.MethodPost, path: "/auth/token", body: auth, anonymous: true}, token); err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	c.token = token.Token
+	c.lock.Unlock()
+	return token, nil
+}
+
+// Logout drops the token, the server also clears its cookies.
+func (c *Client) Logout(ctx context.Context) error {
+	if _, err := c.do(ctx, &request{method: http.MethodDelete, path: "/auth/token"}, nil); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.token = ""
+	c.lock.Unlock()
+	return nil
+}
+
+// Register creates a user without logging in.
+func (c *Client) Register(ctx context.Context, user *model.CreatedUser) (*model.User, error) {
+	created := &model.User{}
+	if _, err := c.send(ctx, &request{method: http.MethodPost, path: "/auth/user", body: user, anonymous: true}, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{}
+	// contentType of the body, defaults to json
+	contentType string
+	// version is sent as If-Match if it is not zero
+	version uint64
+	// anonymous requests are sent without a token
+	anonymous bool
+}
+
+// response is the envelope of the api, see common.Response.
+type response struct {
+	Code     int              `json:"code"`
+	Reason   apierrors.Reason `json:"reason"`
+	Msg      string           `json:"msg"`
+	Data     json.RawMessage  `json:"data"`
+	Metadata *model.ListMeta  `json:"metadata"`
+}
+
+// do sends the request with the token, logging in first if there is no token yet.
+// If the token is rejected and the client has a user name, it logs in again and retries once.
+func (c *Client) do(ctx context.Context, req *request, out interface{}) (*model.ListMeta, error) {
+	if c.Token() == "" && c.username != "" {
+		if _, err := c.Login(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	meta, err := c.send(ctx, req, out)
+	if !apierrors.IsUnauthorized(err) || c.username == "" {
+		return meta, err
+	}
+
+	if _, err := c.Login(ctx); err != nil {
+		return nil, err
+	}
+	return c.send(ctx, req, out)
+}
+
+// send sends the request once and decodes the data of the response into out.
+func (c *Client) send(ctx context.Context, req *request, out interface{}) (*model.ListMeta, error) {
+	var body io.Reader
+	contentType := req.contentType
+	switch b := req.body.(type) {
+	case nil:
+	case []byte:
+		body = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	if body != nil && contentType == "" {
+		contentType = "application/json"
+	}
+
+	u := c.server + APIPrefix + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if req.version != 0 {
+		httpReq.Header.Set("If-Match", strconv.Quote(strconv.FormatUint(req.version, 10)))
+	}
+	if token := c.Token(); token != "" && !req.anonymous {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decode(resp, data, out)
+}
+
+// decode unwraps the envelope, a failed response is returned as *apierrors.StatusError.
+// The body of a successful response is returned as it is if out is a *[]byte.
+func decode(resp *http.Response, data []byte, out interface{}) (*model.ListMeta, error) {
+	if raw, ok := out.(*[]byte); ok && resp.StatusCode < http.StatusBadRequest {
+		*raw = data
+		return nil, nil
+	}
+
+	r := &response{}
+	if err := json.Unmarshal(data, r); err != nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return nil, &apierrors.StatusError{
+				Code:    resp.StatusCode,
+				Reason:  apierrors.ReasonForStatus(resp.StatusCode),
+				Message: strings.TrimSpace(string(data)),
+			}
+		}
+		return nil, fmt.Errorf("invalid response of %s %s: %w", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		status := &apierrors.StatusError{
+			Code:    resp.StatusCode,
+			Reason:  r.Reason,
+			Message: r.Msg,
+		}
+		if status.Reason == "" {
+			status.Reason = apierrors.ReasonForStatus(resp.StatusCode)
+		}
+		if status.Message == "" {
+			status.Message = http.StatusText(resp.StatusCode)
+		}
+		if len(r.Data) > 0 && string(r.Data) != "null" {
+			var details interface{}
+			if err := json.Unmarshal(r.Data, &details); err == nil {
+				status.Details = details
+			}
+		}
+		return nil, status
+	}
+
+	if out != nil && len(r.Data) > 0 {
+		if err := json.Unmarshal(r.Data, out); err != nil {
+			return nil, fmt.Errorf("invalid data of %s %s: %w", resp.Request.Method, resp.Request.URL.Path, err)
+		}
+	}
+	return r.Metadata, nil
+}
+
+// listQuery returns the query of list options, see the lists section of the api conventions.
+func listQuery(opts *model.ListOptions) url.Values {
+	query := url.Values{}
+	if opts == nil {
+		return query
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Continue != "" {
+		query.Set("continue", opts.Continue)
+	}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.SortBy != "" {
+		sort := opts.SortBy
+		if opts.Desc {
+			sort = "-" + sort
+		}
+		query.Set("sort", sort)
+	}
+	if len(opts.Selectors) > 0 {
+		selectors := make([]string, 0, len(opts.Selectors))
+		for _, s := range opts.Selectors {
+			selectors = append(selectors, s.String())
+		}
+		query.Set("fieldSelector", strings.Join(selectors, ","))
+	}
+	return query
+}
+
+func list[T any](ctx context.Context, c *Client, path string, opts *model.ListOptions) ([]T, *model.ListMeta, error) {
+	items := make([]T, 0)
+	meta, err := c.do(ctx, &request{method: http.MethodGet, path: path, query: listQuery(opts)}, &items)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meta == nil {
+		meta = &model.ListMeta{Total: int64(len(items))}
+	}
+	return items, meta, nil
+}
+
+// listAll follows the continue tokens until the last page.
+func listAll[T any](ctx context.Context, c *Client, path string, opts *model.ListOptions) ([]T, error) {
+	o := model.ListOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Page = 0
+
+	all := make([]T, 0)
+	for {
+		items, meta, err := list[T](ctx, c, path, &o)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if meta.Continue == "" {
+			return all, nil
+		}
+		o.Continue = meta.Continue
+	}
+}
+
+func get(ctx context.Context, c *Client, path string, out interface{}) error {
+	_, err := c.do(ctx, &request{method: http.MethodGet, path: path}, out)
+	return err
+}
+
+func create(ctx context.Context, c *Client, path string, in, out interface{}) error {
+	_, err := c.do(ctx, &request{method: http.MethodPost, path: path, body: in}, out)
+	return err
+}
+
+func update(ctx context.Context, c *Client, path string, version uint64, in, out interface{}) error {
+	_, err := c.do(ctx, &request{method: http.MethodPut, path: path, body: in, version: version}, out)
+	return err
+}
+
+// patch sends a json merge patch, a version makes it fail with 409 if the object was changed since.
+func patch(ctx context.Context, c *Client, path string, version uint64, in, out interface{}) error {
+	_, err := c.do(ctx, &request{method: http.MethodPatch, path: path, body: in, contentType: mergePatchType, version: version}, out)
+	return err
+}
+
+func del(ctx context.Context, c *Client, path string, version uint64) error {
+	_, err := c.do(ctx, &request{method: http.MethodDelete, path: path, version: version}, nil)
+	return err
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeResponse(w http.ResponseWriter, code int, resp common.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func TestClient(t *testing.T) {
+	logins := 0
+	token := "expired"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		auth := &model.AuthUser{}
+		_ = json.NewDecoder(r.Body).Decode(auth)
+		if auth.Password != "123456" {
+			writeResponse(w, http.StatusUnauthorized, common.Response{Code: http.StatusUnauthorized, Reason: apierrors.ReasonUnauthorized, Msg: "wrong password"})
+			return
+		}
+		logins++
+		token = "valid"
+		writeResponse(w, http.StatusOK, common.Response{Code: http.StatusOK, Data: model.JWTToken{Token: token}})
+	})
+	mux.HandleFunc("/api/v1/posts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			writeResponse(w, http.StatusUnauthorized, common.Response{Code: http.StatusUnauthorized, Reason: apierrors.ReasonUnauthorized})
+			return
+		}
+		assert.Equal(t, "limit=2&sort=-name", r.URL.RawQuery)
+		writeResponse(w, http.StatusOK, common.Response{
+			Code:     http.StatusOK,
+			Data:     []model.Post{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}},
+			Metadata: &model.ListMeta{Total: 3, Continue: "next"},
+		})
+	})
+	mux.HandleFunc("/api/v1/posts/1", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, `"2"`, r.Header.Get("If-Match"))
+		writeResponse(w, http.StatusConflict, common.Response{
+			Code:   http.StatusConflict,
+			Reason: apierrors.ReasonConflict,
+			Msg:    apierrors.ErrModified.Error(),
+			Data:   model.Post{ID: 1, Version: 3},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Run("refresh token", func(t *testing.T) {
+		c, err := New(Config{Server: server.URL, Token: "expired", Username: "admin", Password: "123456"})
+		assert.Nil(t, err)
+
+		posts, meta, err := c.Posts().List(context.Background(), &model.ListOptions{Limit: 2, SortBy: "name", Desc: true})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b"}, []string{posts[0].Name, posts[1].Name})
+		assert.Equal(t, &model.ListMeta{Total: 3, Continue: "next"}, meta)
+		assert.Equal(t, "valid", c.Token())
+		assert.Equal(t, 1, logins)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		c, err := New(Config{Server: server.URL, Username: "admin", Password: "wrong"})
+		assert.Nil(t, err)
+
+		_, _, err = c.Posts().List(context.Background(), nil)
+		assert.True(t, apierrors.IsUnauthorized(err))
+		assert.Equal(t, "wrong password", err.Error())
+	})
+
+	t.Run("without credentials", func(t *testing.T) {
+		c, err := New(Config{Server: server.URL, Token: "expired"})
+		assert.Nil(t, err)
+
+		_, _, err = c.Posts().List(context.Background(), nil)
+		assert.True(t, apierrors.IsUnauthorized(err))
+		assert.Equal(t, http.StatusText(http.StatusUnauthorized), err.Error())
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		c, err := New(Config{Server: server.URL, Token: "valid"})
+		assert.Nil(t, err)
+
+		_, err = c.Posts().Update(context.Background(), 1, &model.Post{Name: "a", Version: 2})
+		assert.True(t, apierrors.IsConflict(err))
+		status := apierrors.FromError(err)
+		assert.Equal(t, float64(3), status.Details.(map[string]interface{})["version"])
+	})
+
+	t.Run("invalid server", func(t *testing.T) {
+		_, err := New(Config{Server: "localhost:8080"})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestClientRequests(t *testing.T) {
+	type received struct {
+		Method      string
+		Path        string
+		Query       string
+		IfMatch     string
+		ContentType string
+		Body        string
+	}
+	var last received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		last = received{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("If-Match"), r.Header.Get("Content-Type"), string(body)}
+		if strings.HasSuffix(r.URL.Path, "/attachments/2") && r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte("png"))
+			return
+		}
+		var data interface{} = map[string]interface{}{"id": 1}
+		if r.Method == http.MethodGet {
+			data = []interface{}{data}
+		}
+		writeResponse(w, http.StatusOK, common.Response{Code: http.StatusOK, Data: data})
+	}))
+	defer server.Close()
+
+	c, err := New(Config{Server: server.URL, Token: "valid"})
+	require.Nil(t, err)
+	ctx := context.Background()
+	user, err := json.Marshal(&model.User{ID: 5})
+	require.Nil(t, err)
+
+	testCases := []struct {
+		name     string
+		call     func() error
+		expected received
+	}{
+		{"delete group user", func() error { return c.Groups().DelUser(ctx, 3, 5) },
+			received{Method: "DELETE", Path: "/api/v1/groups/3/users", ContentType: "application/json", Body: string(user)}},
+		{"publish", func() error { _, err := c.Posts().Publish(ctx, 3, 2, nil); return err },
+			received{Method: "POST", Path: "/api/v1/posts/3/publish", IfMatch: `"2"`}},
+		{"archive", func() error {
+			_, err := c.Posts().Unpublish(ctx, 3, 0, &model.UnpublishOptions{Archive: true})
+			return err
+		}, received{Method: "POST", Path: "/api/v1/posts/3/unpublish", ContentType: "application/json", Body: `{"archive":true}`}},
+		{"pending comments", func() error {
+			_, _, err := c.Posts().CommentsByStatus(ctx, model.CommentPending, &model.ListOptions{Limit: 10})
+			return err
+		}, received{Method: "GET", Path: "/api/v1/posts/comments", Query: "limit=10&status=pending"}},
+		{"approve comment", func() error { _, err := c.Posts().ApproveComment(ctx, 3, 4); return err },
+			received{Method: "POST", Path: "/api/v1/posts/3/comment/4/approve"}},
+		{"reject comment", func() error { _, err := c.Posts().RejectComment(ctx, 3, 4); return err },
+			received{Method: "POST", Path: "/api/v1/posts/3/comment/4/reject"}},
+		{"merge tags", func() error { _, err := c.Tags().Merge(ctx, 3, 4); return err },
+			received{Method: "POST", Path: "/api/v1/tags/3/merge", ContentType: "application/json", Body: `{"into":4}`}},
+		{"category posts", func() error { _, _, err := c.Categories().Posts(ctx, 3, nil); return err },
+			received{Method: "GET", Path: "/api/v1/categories/3/posts"}},
+		{"restore", func() error { return c.Trash().Restore(ctx, model.PostResource, 3) },
+			received{Method: "POST", Path: "/api/v1/trash/posts/3/restore"}},
+		{"purge", func() error { return c.Trash().Purge(ctx, model.UserResource, 3) },
+			received{Method: "DELETE", Path: "/api/v1/trash/users/3"}},
+		{"delete attachment", func() error { return c.Posts().DelAttachment(ctx, 3, 2) },
+			received{Method: "DELETE", Path: "/api/v1/posts/3/attachments/2"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Nil(t, tc.call())
+			assert.Equal(t, tc.expected, last)
+		})
+	}
+
+	t.Run("attachments", func(t *testing.T) {
+		attachment, err := c.Posts().UploadAttachment(ctx, 3, "cat.png", strings.NewReader("png"))
+		require.Nil(t, err)
+		assert.Equal(t, uint(1), attachment.ID)
+		assert.Equal(t, "/api/v1/posts/3/attachments", last.Path)
+		mediaType, params, err := mime.ParseMediaType(last.ContentType)
+		require.Nil(t, err)
+		assert.Equal(t, "multipart/form-data", mediaType)
+		part, err := multipart.NewReader(strings.NewReader(last.Body), params["boundary"]).NextPart()
+		require.Nil(t, err)
+		assert.Equal(t, "file", part.FormName())
+		assert.Equal(t, "cat.png", part.FileName())
+
+		content, err := c.Posts().DownloadAttachment(ctx, 3, 2)
+		require.Nil(t, err)
+		assert.Equal(t, "png", string(content))
+	})
+}
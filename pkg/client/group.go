@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+)
+
+type GroupClient struct {
+	client *Client
+}
+
+func (g *GroupClient) List(ctx context.Context, opts *model.ListOptions) ([]model.Group, *model.ListMeta, error) {
+	return list[model.Group](ctx, g.client, "/groups", opts)
+}
+
+// ListAll lists all pages of groups.
+func (g *GroupClient) ListAll(ctx context.Context, opts *model.ListOptions) ([]model.Group, error) {
+	return listAll[model.Group](ctx, g.client, "/groups", opts)
+}
+
+func (g *GroupClient) Get(ctx context.Context, id uint) (*model.Group, error) {
+	group := &model.Group{}
+	if err := get(ctx, g.client, "/groups/"+itoa(id), group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (g *GroupClient) Create(ctx context.Context, group *model.CreatedGroup) (*model.Group, error) {
+	created := &model.Group{}
+	if err := create(ctx, g.client, "/groups", group, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Update replaces the group, it fails with a conflict if group.Version is set and the group was changed since.
+func (g *GroupClient) Update(ctx context.Context, id uint, group *model.UpdatedGroup) (*model.Group, error) {
+	updated := &model.Group{}
+	if err := update(ctx, g.client, "/groups/"+itoa(id), group.Version, group, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Patch applies a json merge patch, a zero version patches the latest version.
+func (g *GroupClient) Patch(ctx context.Context, id uint, version uint64, mergePatch interface{}) (*model.Group, error) {
+	patched := &model.Group{}
+	if err := patch(ctx, g.client, "/groups/"+itoa(id), version, mergePatch, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// Delete moves the group to the trash, a zero version deletes the latest version.
+func (g *GroupClient) Delete(ctx context.Context, id uint, version uint64) error {
+	return del(ctx, g.client, "/groups/"+itoa(id), version)
+}
+
+func (g *GroupClient) Users(ctx context.Context, id uint) ([]model.User, error) {
+	users := make([]model.User, 0)
+	if err := get(ctx, g.client, "/groups/"+itoa(id)+"/users", &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (g *GroupClient) AddUser(ctx context.Context, id, uid uint) error {
+	return create(ctx, g.client, "/groups/"+itoa(id)+"/users", &model.User{ID: uid}, nil)
+}
+
+func (g *GroupClient) DelUser(ctx context.Context, id, uid uint) error {
+	_, err := g.client.do(ctx, &request{method: http.MethodDelete, path: "/groups/" + itoa(id) + "/users", body: &model.User{ID: uid}}, nil)
+	return err
+}
+
+func (g *GroupClient) AddRole(ctx context.Context, id, rid uint) error {
+	return create(ctx, g.client, "/groups/"+itoa(id)+"/roles/"+itoa(rid), nil, nil)
+}
+
+func (g *GroupClient) DelRole(ctx context.Context, id, rid uint) error {
+	return del(ctx, g.client, "/groups/"+itoa(id)+"/roles/"+itoa(rid), 0)
+}
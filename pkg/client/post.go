@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+)
+
+type PostClient struct {
+	client *Client
+}
+
+func (p *PostClient) List(ctx context.Context, opts *model.ListOptions) ([]model.Post, *model.ListMeta, error) {
+	return list[model.Post](ctx, p.client, "/posts", opts)
+}
+
+// ListAll lists all pages of posts.
+func (p *PostClient) ListAll(ctx context.Context, opts *model.ListOptions) ([]model.Post, error) {
+	return listAll[model.Post](ctx, p.client, "/posts", opts)
+}
+
+func (p *PostClient) Get(ctx context.Context, id uint) (*model.Post, error) {
+	post := &model.Post{}
+	if err := get(ctx, p.client, "/posts/"+itoa(id), post); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (p *PostClient) Create(ctx context.Context, post *model.Post) (*model.Post, error) {
+	created := &model.Post{}
+	if err := create(ctx, p.client, "/posts", post, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Update replaces the post, it fails with a conflict if post.Version is set and the post was changed since.
+func (p *PostClient) Update(ctx context.Context, id uint, post *model.Post) (*model.Post, error) {
+	updated := &model.Post{}
+	if err := update(ctx, p.client, "/posts/"+itoa(id), post.Version, post, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Patch applies a json merge patch, a zero version patches the latest version.
+func (p *PostClient) Patch(ctx context.Context, id uint, version uint64, mergePatch interface{}) (*model.Post, error) {
+	patched := &model.Post{}
+	if err := patch(ctx, p.client, "/posts/"+itoa(id), version, mergePatch, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// Delete moves the post to the trash, a zero version deletes the latest version.
+func (p *PostClient) Delete(ctx context.Context, id uint, version uint64) error {
+	return del(ctx, p.client, "/posts/"+itoa(id), version)
+}
+
+// Publish publishes the post, or schedules it if opts has a time in the future. A zero version publishes the latest version.
+func (p *PostClient) Publish(ctx context.Context, id uint, version uint64, opts *model.PublishOptions) (*model.Post, error) {
+	if opts == nil {
+		return p.setStatus(ctx, id, "/publish", version, nil)
+	}
+	return p.setStatus(ctx, id, "/publish", version, opts)
+}
+
+// Unpublish makes the post a draft or archives it, a zero version unpublishes the latest version.
+func (p *PostClient) Unpublish(ctx context.Context, id uint, version uint64, opts *model.UnpublishOptions) (*model.Post, error) {
+	if opts == nil {
+		return p.setStatus(ctx, id, "/unpublish", version, nil)
+	}
+	return p.setStatus(ctx, id, "/unpublish", version, opts)
+}
+
+// setStatus posts the options to the action of the post, nil options are sent without a body.
+func (p *PostClient) setStatus(ctx context.Context, id uint, action string, version uint64, opts interface{}) (*model.Post, error) {
+	post := &model.Post{}
+	if _, err := p.client.do(ctx, &request{method: http.MethodPost, path: "/posts/" + itoa(id) + action, body: opts, version: version}, post); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (p *PostClient) Tags(ctx context.Context, id uint) ([]model.Tag, error) {
+	tags, _, err := list[model.Tag](ctx, p.client, "/posts/"+itoa(id)+"/tags", nil)
+	return tags, err
+}
+
+func (p *PostClient) Categories(ctx context.Context, id uint) ([]model.Category, error) {
+	categories, _, err := list[model.Category](ctx, p.client, "/posts/"+itoa(id)+"/categories", nil)
+	return categories, err
+}
+
+func (p *PostClient) Like(ctx context.Context, id uint) error {
+	return create(ctx, p.client, "/posts/"+itoa(id)+"/like", nil, nil)
+}
+
+func (p *PostClient) Unlike(ctx context.Context, id uint) error {
+	return del(ctx, p.client, "/posts/"+itoa(id)+"/like", 0)
+}
+
+func (p *PostClient) AddComment(ctx context.Context, id uint, content string) (*model.Comment, error) {
+	comment := &model.Comment{}
+	if err := create(ctx, p.client, "/posts/"+itoa(id)+"/comment", &model.Comment{Content: content}, comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// Reply adds a reply to the comment cid of the post.
+func (p *PostClient) Reply(ctx context.Context, id, cid uint, content string) (*model.Comment, error) {
+	comment := &model.Comment{}
+	if err := create(ctx, p.client, "/posts/"+itoa(id)+"/comment", &model.Comment{ParentID: &cid, Content: content}, comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// Comments lists the comments on the post with their replies as trees, opts pages the comments on the post.
+func (p *PostClient) Comments(ctx context.Context, id uint, opts *model.ListOptions) ([]model.Comment, *model.ListMeta, error) {
+	return list[model.Comment](ctx, p.client, "/posts/"+itoa(id)+"/comment", opts)
+}
+
+func (p *PostClient) UpdateComment(ctx context.Context, id, cid uint, content string) (*model.Comment, error) {
+	comment := &model.Comment{}
+	if err := update(ctx, p.client, "/posts/"+itoa(id)+"/comment/"+itoa(cid), 0, &model.CommentUpdate{Content: content}, comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (p *PostClient) DelComment(ctx context.Context, id, cid uint) error {
+	return del(ctx, p.client, "/posts/"+itoa(id)+"/comment/"+itoa(cid), 0)
+}
+
+// CommentsByStatus lists the comments on all posts with the status, like the pending comments to moderate.
+func (p *PostClient) CommentsByStatus(ctx context.Context, status model.CommentStatus, opts *model.ListOptions) ([]model.Comment, *model.ListMeta, error) {
+	query := listQuery(opts)
+	query.Set("status", string(status))
+
+	comments := make([]model.Comment, 0)
+	meta, err := p.client.do(ctx, &request{method: http.MethodGet, path: "/posts/comments", query: query}, &comments)
+	if err != nil {
+		return nil, nil, err
+	}
+	return comments, meta, nil
+}
+
+func (p *PostClient) ApproveComment(ctx context.Context, id, cid uint) (*model.Comment, error) {
+	return p.moderateComment(ctx, id, cid, "/approve")
+}
+
+func (p *PostClient) RejectComment(ctx context.Context, id, cid uint) (*model.Comment, error) {
+	return p.moderateComment(ctx, id, cid, "/reject")
+}
+
+func (p *PostClient) moderateComment(ctx context.Context, id, cid uint, action string) (*model.Comment, error) {
+	comment := &model.Comment{}
+	if err := create(ctx, p.client, "/posts/"+itoa(id)+"/comment/"+itoa(cid)+action, nil, comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// Revisions lists the revisions of the post without their content, the latest first.
+func (p *PostClient) Revisions(ctx context.Context, id uint, opts *model.ListOptions) ([]model.PostRevision, *model.ListMeta, error) {
+	return list[model.PostRevision](ctx, p.client, "/posts/"+itoa(id)+"/revisions", opts)
+}
+
+func (p *PostClient) Revision(ctx context.Context, id, revision uint) (*model.PostRevision, error) {
+	rev := &model.PostRevision{}
+	if err := get(ctx, p.client, "/posts/"+itoa(id)+"/revisions/"+itoa(revision), rev); err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// DiffRevisions diffs revision from to revision to, a zero to is the latest revision and a zero from the revision before to.
+func (p *PostClient) DiffRevisions(ctx context.Context, id, from, to uint) (*model.RevisionDiff, error) {
+	query := url.Values{}
+	if from != 0 {
+		query.Set("from", itoa(from))
+	}
+	if to != 0 {
+		query.Set("to", itoa(to))
+	}
+
+	result := &model.RevisionDiff{}
+	if _, err := p.client.do(ctx, &request{method: http.MethodGet, path: "/posts/" + itoa(id) + "/revisions/diff", query: query}, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RestoreRevision updates the post to the revision, a zero version restores it on the latest version.
+func (p *PostClient) RestoreRevision(ctx context.Context, id, revision uint, version uint64) (*model.Post, error) {
+	post := &model.Post{}
+	req := &request{method: http.MethodPost, path: "/posts/" + itoa(id) + "/revisions/" + itoa(revision) + "/restore", version: version}
+	if _, err := p.client.do(ctx, req, post); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// Search searches posts, opts only pages the hits.
+func (p *PostClient) Search(ctx context.Context, q *model.SearchQuery, opts *model.ListOptions) (*model.SearchResult, error) {
+	query := listQuery(opts)
+	query.Set("q", q.Q)
+	for key, value := range map[string]string{"tag": q.Tag, "category": q.Category, "author": q.Author} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	result := &model.SearchResult{}
+	if _, err := p.client.do(ctx, &request{method: http.MethodGet, path: "/posts/search", query: query}, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
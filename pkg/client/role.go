@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gopkg.in/yaml.v2"
+)
+
+type RoleClient struct {
+	client *Client
+}
+
+func (r *RoleClient) List(ctx context.Context, opts *model.ListOptions) ([]model.Role, *model.ListMeta, error) {
+	return list[model.Role](ctx, r.client, "/roles", opts)
+}
+
+// ListAll lists all pages of roles.
+func (r *RoleClient) ListAll(ctx context.Context, opts *model.ListOptions) ([]model.Role, error) {
+	return listAll[model.Role](ctx, r.client, "/roles", opts)
+}
+
+func (r *RoleClient) Get(ctx context.Context, id uint) (*model.Role, error) {
+	role := &model.Role{}
+	if err := get(ctx, r.client, "/roles/"+itoa(id), role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (r *RoleClient) Create(ctx context.Context, role *model.Role) (*model.Role, error) {
+	created := &model.Role{}
+	if err := create(ctx, r.client, "/roles", role, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Update replaces the role, it fails with a conflict if role.Version is set and the role was changed since.
+func (r *RoleClient) Update(ctx context.Context, id uint, role *model.Role) (*model.Role, error) {
+	updated := &model.Role{}
+	if err := update(ctx, r.client, "/roles/"+itoa(id), role.Version, role, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Patch applies a json merge patch, a zero version patches the latest version.
+func (r *RoleClient) Patch(ctx context.Context, id uint, version uint64, mergePatch interface{}) (*model.Role, error) {
+	patched := &model.Role{}
+	if err := patch(ctx, r.client, "/roles/"+itoa(id), version, mergePatch, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// Delete deletes the role, a zero version deletes the latest version.
+func (r *RoleClient) Delete(ctx context.Context, id uint, version uint64) error {
+	return del(ctx, r.client, "/roles/"+itoa(id), version)
+}
+
+func (r *RoleClient) Resources(ctx context.Context) ([]model.Resource, error) {
+	resources := make([]model.Resource, 0)
+	if err := get(ctx, r.client, "/resources", &resources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+func (r *RoleClient) Operations(ctx context.Context) ([]model.Operation, error) {
+	operations := make([]model.Operation, 0)
+	if err := get(ctx, r.client, "/operations", &operations); err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// Export returns the roles, groups, memberships and role bindings as a yaml policy.
+func (r *RoleClient) Export(ctx context.Context) (*model.RBACPolicy, error) {
+	var data []byte
+	if err := get(ctx, r.client, "/rbac/export", &data); err != nil {
+		return nil, err
+	}
+	policy := &model.RBACPolicy{}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Apply makes roles, groups and bindings match the policy and returns the plan.
+func (r *RoleClient) Apply(ctx context.Context, policy *model.RBACPolicy, opts model.ApplyOptions) (*model.RBACPlan, error) {
+	data, err := yaml.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	query.Set("dryRun", strconv.FormatBool(opts.DryRun))
+	query.Set("prune", strconv.FormatBool(opts.Prune))
+
+	plan := &model.RBACPlan{}
+	req := &request{method: http.MethodPost, path: "/rbac/apply", query: query, body: data, contentType: "application/x-yaml"}
+	if _, err := r.client.do(ctx, req, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
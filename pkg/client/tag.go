@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+)
+
+type TagClient struct {
+	client *Client
+}
+
+func (t *TagClient) List(ctx context.Context, opts *model.ListOptions) ([]model.Tag, *model.ListMeta, error) {
+	return list[model.Tag](ctx, t.client, "/tags", opts)
+}
+
+// ListAll lists all pages of tags.
+func (t *TagClient) ListAll(ctx context.Context, opts *model.ListOptions) ([]model.Tag, error) {
+	return listAll[model.Tag](ctx, t.client, "/tags", opts)
+}
+
+func (t *TagClient) Get(ctx context.Context, id uint) (*model.Tag, error) {
+	tag := &model.Tag{}
+	if err := get(ctx, t.client, "/tags/"+itoa(id), tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (t *TagClient) Create(ctx context.Context, tag *model.Tag) (*model.Tag, error) {
+	created := &model.Tag{}
+	if err := create(ctx, t.client, "/tags", tag, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Update renames the tag.
+func (t *TagClient) Update(ctx context.Context, id uint, tag *model.Tag) (*model.Tag, error) {
+	updated := &model.Tag{}
+	if err := update(ctx, t.client, "/tags/"+itoa(id), 0, tag, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (t *TagClient) Delete(ctx context.Context, id uint) error {
+	return del(ctx, t.client, "/tags/"+itoa(id), 0)
+}
+
+// Merge moves the posts of the tag to the tag into and deletes the tag.
+func (t *TagClient) Merge(ctx context.Context, id, into uint) (*model.Tag, error) {
+	merged := &model.Tag{}
+	if err := create(ctx, t.client, "/tags/"+itoa(id)+"/merge", &model.TagMerge{Into: into}, merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// Posts lists the posts with the tag.
+func (t *TagClient) Posts(ctx context.Context, id uint, opts *model.ListOptions) ([]model.Post, *model.ListMeta, error) {
+	return list[model.Post](ctx, t.client, "/tags/"+itoa(id)+"/posts", opts)
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+)
+
+// TrashClient manages deleted objects, resource is one of users, groups and posts.
+type TrashClient struct {
+	client *Client
+}
+
+func (t *TrashClient) List(ctx context.Context, resource string, opts *model.ListOptions) ([]model.TrashItem, *model.ListMeta, error) {
+	return list[model.TrashItem](ctx, t.client, "/trash/"+resource, opts)
+}
+
+// Restore undeletes the object, it fails with a conflict if its name is used by another object.
+func (t *TrashClient) Restore(ctx context.Context, resource string, id uint) error {
+	return create(ctx, t.client, "/trash/"+resource+"/"+itoa(id)+"/restore", nil, nil)
+}
+
+// Purge deletes the object permanently.
+func (t *TrashClient) Purge(ctx context.Context, resource string, id uint) error {
+	return del(ctx, t.client, "/trash/"+resource+"/"+itoa(id), 0)
+}
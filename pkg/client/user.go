@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+)
+
+type UserClient struct {
+	client *Client
+}
+
+func (u *UserClient) List(ctx context.Context, opts *model.ListOptions) ([]model.User, *model.ListMeta, error) {
+	return list[model.User](ctx, u.client, "/users", opts)
+}
+
+// ListAll lists all pages of users.
+func (u *UserClient) ListAll(ctx context.Context, opts *model.ListOptions) ([]model.User, error) {
+	return listAll[model.User](ctx, u.client, "/users", opts)
+}
+
+func (u *UserClient) Get(ctx context.Context, id uint) (*model.User, error) {
+	user := &model.User{}
+	if err := get(ctx, u.client, "/users/"+itoa(id), user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (u *UserClient) Create(ctx context.Context, user *model.CreatedUser) (*model.User, error) {
+	created := &model.User{}
+	if err := create(ctx, u.client, "/users", user, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Update replaces the user, it fails with a conflict if user.Version is set and the user was changed since.
+func (u *UserClient) Update(ctx context.Context, id uint, user *model.UpdatedUser) (*model.User, error) {
+	updated := &model.User{}
+	if err := update(ctx, u.client, "/users/"+itoa(id), user.Version, user, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Patch applies a json merge patch, a zero version patches the latest version.
+func (u *UserClient) Patch(ctx context.Context, id uint, version uint64, mergePatch interface{}) (*model.User, error) {
+	patched := &model.User{}
+	if err := patch(ctx, u.client, "/users/"+itoa(id), version, mergePatch, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// Delete moves the user to the trash, a zero version deletes the latest version.
+func (u *UserClient) Delete(ctx context.Context, id uint, version uint64) error {
+	return del(ctx, u.client, "/users/"+itoa(id), version)
+}
+
+func (u *UserClient) Groups(ctx context.Context, id uint) ([]model.Group, error) {
+	groups := make([]model.Group, 0)
+	if err := get(ctx, u.client, "/users/"+itoa(id)+"/groups", &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (u *UserClient) AddRole(ctx context.Context, id, rid uint) error {
+	return create(ctx, u.client, "/users/"+itoa(id)+"/roles/"+itoa(rid), nil, nil)
+}
+
+func (u *UserClient) DelRole(ctx context.Context, id, rid uint) error {
+	return del(ctx, u.client, "/users/"+itoa(id)+"/roles/"+itoa(rid), 0)
+}
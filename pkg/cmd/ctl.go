@@ -0,0 +1,566 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/client"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gopkg.in/yaml.v2"
+)
+
+const ctlUsage = `Usage:
+  webm-nas ctl login [flags]
+  webm-nas ctl get users|groups|roles|posts [id] [-selector s] [-sort field] [-limit n] [flags]
+  webm-nas ctl create users|groups|roles|posts -f file [flags]
+  webm-nas ctl update users|groups|roles|posts id -f file [flags]
+  webm-nas ctl delete users|groups|roles|posts id [-version n] [flags]
+
+Flags:
+  -server url         server address, default $WEBM_NAS_SERVER or http://localhost:8080
+  -token token        token, default $WEBM_NAS_TOKEN
+  -user name          user name to login, default $WEBM_NAS_USER
+  -password password  password to login, default $WEBM_NAS_PASSWORD
+  -o format           output format, table, json or yaml, default table
+
+Files are json or yaml, - reads stdin.
+`
+
+const defaultServer = "http://localhost:8080"
+
+// ctlResources are the resources of the ctl commands, the table of a resource shows its columns.
+var ctlResources = map[string]ctlResource{
+	model.UserResource: {
+		columns: []string{"ID", "NAME", "EMAIL", "VERSION", "CREATED"},
+		list: func(ctx context.Context, c *client.Client, opts *model.ListOptions) (interface{}, error) {
+			return listObjects(ctx, opts, c.Users().List, c.Users().ListAll)
+		},
+		get: func(ctx context.Context, c *client.Client, id uint) (interface{}, error) {
+			return c.Users().Get(ctx, id)
+		},
+		create: func(ctx context.Context, c *client.Client, data []byte) (interface{}, error) {
+			user := &model.CreatedUser{}
+			if err := json.Unmarshal(data, user); err != nil {
+				return nil, err
+			}
+			return c.Users().Create(ctx, user)
+		},
+		update: func(ctx context.Context, c *client.Client, id uint, data []byte) (interface{}, error) {
+			user := &model.UpdatedUser{}
+			if err := json.Unmarshal(data, user); err != nil {
+				return nil, err
+			}
+			return c.Users().Update(ctx, id, user)
+		},
+		delete: func(ctx context.Context, c *client.Client, id uint, version uint64) error {
+			return c.Users().Delete(ctx, id, version)
+		},
+		row: func(obj interface{}) []string {
+			u := obj.(model.User)
+			return []string{uitoa(u.ID), u.Name, u.Email, strconv.FormatUint(u.Version, 10), formatTime(u.CreatedAt)}
+		},
+	},
+	model.GroupResource: {
+		columns: []string{"ID", "NAME", "KIND", "DESCRIBE", "VERSION"},
+		list: func(ctx context.Context, c *client.Client, opts *model.ListOptions) (interface{}, error) {
+			return listObjects(ctx, opts, c.Groups().List, c.Groups().ListAll)
+		},
+		get: func(ctx context.Context, c *client.Client, id uint) (interface{}, error) {
+			return c.Groups().Get(ctx, id)
+		},
+		create: func(ctx context.Context, c *client.Client, data []byte) (interface{}, error) {
+			group := &model.CreatedGroup{}
+			if err := json.Unmarshal(data, group); err != nil {
+				return nil, err
+			}
+			return c.Groups().Create(ctx, group)
+		},
+		update: func(ctx context.Context, c *client.Client, id uint, data []byte) (interface{}, error) {
+			group := &model.UpdatedGroup{}
+			if err := json.Unmarshal(data, group); err != nil {
+				return nil, err
+			}
+			return c.Groups().Update(ctx, id, group)
+		},
+		delete: func(ctx context.Context, c *client.Client, id uint, version uint64) error {
+			return c.Groups().Delete(ctx, id, version)
+		},
+		row: func(obj interface{}) []string {
+			g := obj.(model.Group)
+			return []string{uitoa(g.ID), g.Name, g.Kind, g.Describe, strconv.FormatUint(g.Version, 10)}
+		},
+	},
+	model.RoleResource: {
+		columns: []string{"ID", "NAME", "SCOPE", "NAMESPACE", "RULES", "VERSION"},
+		list: func(ctx context.Context, c *client.Client, opts *model.ListOptions) (interface{}, error) {
+			return listObjects(ctx, opts, c.Roles().List, c.Roles().ListAll)
+		},
+		get: func(ctx context.Context, c *client.Client, id uint) (interface{}, error) {
+			return c.Roles().Get(ctx, id)
+		},
+		create: func(ctx context.Context, c *client.Client, data []byte) (interface{}, error) {
+			role := &model.Role{}
+			if err := json.Unmarshal(data, role); err != nil {
+				return nil, err
+			}
+			return c.Roles().Create(ctx, role)
+		},
+		update: func(ctx context.Context, c *client.Client, id uint, data []byte) (interface{}, error) {
+			role := &model.Role{}
+			if err := json.Unmarshal(data, role); err != nil {
+				return nil, err
+			}
+			return c.Roles().Update(ctx, id, role)
+		},
+		delete: func(ctx context.Context, c *client.Client, id uint, version uint64) error {
+			return c.Roles().Delete(ctx, id, version)
+		},
+		row: func(obj interface{}) []string {
+			r := obj.(model.Role)
+			rules := make([]string, 0, len(r.Rules))
+			for _, rule := range r.Rules {
+				rules = append(rules, rule.Resource+":"+string(rule.Operation))
+			}
+			return []string{uitoa(r.ID), r.Name, string(r.Scope), r.Namespace, strings.Join(rules, ","), strconv.FormatUint(r.Version, 10)}
+		},
+	},
+	model.PostResource: {
+		columns: []string{"ID", "NAME", "CREATOR", "VIEWS", "LIKES", "VERSION", "CREATED"},
+		list: func(ctx context.Context, c *client.Client, opts *model.ListOptions) (interface{}, error) {
+			return listObjects(ctx, opts, c.Posts().List, c.Posts().ListAll)
+		},
+		get: func(ctx context.Context, c *client.Client, id uint) (interface{}, error) {
+			return c.Posts().Get(ctx, id)
+		},
+		create: func(ctx context.Context, c *client.Client, data []byte) (interface{}, error) {
+			post := &model.Post{}
+			if err := json.Unmarshal(data, post); err != nil {
+				return nil, err
+			}
+			return c.Posts().Create(ctx, post)
+		},
+		update: func(ctx context.Context, c *client.Client, id uint, data []byte) (interface{}, error) {
+			post := &model.Post{}
+			if err := json.Unmarshal(data, post); err != nil {
+				return nil, err
+			}
+			return c.Posts().Update(ctx, id, post)
+		},
+		delete: func(ctx context.Context, c *client.Client, id uint, version uint64) error {
+			return c.Posts().Delete(ctx, id, version)
+		},
+		row: func(obj interface{}) []string {
+			p := obj.(model.Post)
+			return []string{uitoa(p.ID), p.Name, p.Creator.Name, strconv.FormatUint(uint64(p.Views), 10),
+				strconv.FormatUint(uint64(p.Likes), 10), strconv.FormatUint(p.Version, 10), formatTime(p.CreatedAt)}
+		},
+	},
+}
+
+type ctlResource struct {
+	columns []string
+	list    func(ctx context.Context, c *client.Client, opts *model.ListOptions) (interface{}, error)
+	get     func(ctx context.Context, c *client.Client, id uint) (interface{}, error)
+	create  func(ctx context.Context, c *client.Client, data []byte) (interface{}, error)
+	update  func(ctx context.Context, c *client.Client, id uint, data []byte) (interface{}, error)
+	delete  func(ctx context.Context, c *client.Client, id uint, version uint64) error
+	// row returns the columns of an object, obj is a value, not a pointer
+	row func(obj interface{}) []string
+}
+
+// ctlOptions are the flags of all ctl commands.
+type ctlOptions struct {
+	server   string
+	token    string
+	user     string
+	password string
+	output   string
+}
+
+func (o *ctlOptions) addFlags(fs *flag.FlagSet) {
+	server := os.Getenv("WEBM_NAS_SERVER")
+	if server == "" {
+		server = defaultServer
+	}
+	fs.StringVar(&o.server, "server", server, "server address")
+	fs.StringVar(&o.token, "token", os.Getenv("WEBM_NAS_TOKEN"), "token")
+	fs.StringVar(&o.user, "user", os.Getenv("WEBM_NAS_USER"), "user name to login")
+	fs.StringVar(&o.password, "password", os.Getenv("WEBM_NAS_PASSWORD"), "password to login")
+	fs.StringVar(&o.output, "o", "table", "output format, table, json or yaml")
+}
+
+func (o *ctlOptions) client() (*client.Client, error) {
+	switch o.output {
+	case "table", "json", "yaml":
+	default:
+		return nil, fmt.Errorf("unknown output format %q, use table, json or yaml", o.output)
+	}
+	if o.token == "" && o.user == "" {
+		return nil, fmt.Errorf("missing -token or -user to login")
+	}
+	return client.New(client.Config{
+		Server:   o.server,
+		Token:    o.token,
+		Username: o.user,
+		Password: o.password,
+	})
+}
+
+// RunCtl runs a command against the api of a running server.
+func RunCtl(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing ctl command\n%s", ctlUsage)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "login":
+		return ctlLogin(ctx, args[1:], out)
+	case "get":
+		return ctlGet(ctx, args[1:], out)
+	case "create":
+		return ctlCreate(ctx, args[1:], out)
+	case "update":
+		return ctlUpdate(ctx, args[1:], out)
+	case "delete":
+		return ctlDelete(ctx, args[1:], out)
+	default:
+		return fmt.Errorf("unknown ctl command %q\n%s", args[0], ctlUsage)
+	}
+}
+
+func ctlLogin(ctx context.Context, args []string, out io.Writer) error {
+	opts := &ctlOptions{}
+	fs := flag.NewFlagSet("ctl login", flag.ContinueOnError)
+	opts.addFlags(fs)
+	if _, err := parseInterspersed(fs, args, 0); err != nil {
+		return err
+	}
+	if opts.user == "" {
+		return fmt.Errorf("missing -user to login")
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	token, err := c.Login(ctx)
+	if err != nil {
+		return err
+	}
+	if opts.output == "table" {
+		_, err = fmt.Fprintln(out, token.Token)
+		return err
+	}
+	return printObject(out, opts.output, nil, token)
+}
+
+func ctlGet(ctx context.Context, args []string, out io.Writer) error {
+	opts := &ctlOptions{}
+	fs := flag.NewFlagSet("ctl get", flag.ContinueOnError)
+	opts.addFlags(fs)
+	selector := fs.String("selector", "", "field selectors, like name~=foo")
+	sort := fs.String("sort", "", "sort field, prefix - for descending")
+	limit := fs.Int("limit", 0, "list one page of at most limit objects, default all objects")
+	positional, err := parseInterspersed(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	resource, err := ctlResourceOf(positional)
+	if err != nil {
+		return err
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 2 {
+		id, err := parseCtlID(positional[1])
+		if err != nil {
+			return err
+		}
+		obj, err := resource.get(ctx, c, id)
+		if err != nil {
+			return err
+		}
+		return printObject(out, opts.output, &resource, obj)
+	}
+
+	listOpts := &model.ListOptions{Limit: *limit}
+	listOpts.SortBy, listOpts.Desc = model.ParseSort(*sort)
+	if listOpts.Selectors, err = model.ParseSelectors(*selector); err != nil {
+		return err
+	}
+	items, err := resource.list(ctx, c, listOpts)
+	if err != nil {
+		return err
+	}
+	return printObject(out, opts.output, &resource, items)
+}
+
+func ctlCreate(ctx context.Context, args []string, out io.Writer) error {
+	opts := &ctlOptions{}
+	fs := flag.NewFlagSet("ctl create", flag.ContinueOnError)
+	opts.addFlags(fs)
+	file := fs.String("f", "", "object file, - for stdin")
+	positional, err := parseInterspersed(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	resource, err := ctlResourceOf(positional)
+	if err != nil {
+		return err
+	}
+	data, err := readObject(*file)
+	if err != nil {
+		return err
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	obj, err := resource.create(ctx, c, data)
+	if err != nil {
+		return err
+	}
+	return printObject(out, opts.output, &resource, obj)
+}
+
+func ctlUpdate(ctx context.Context, args []string, out io.Writer) error {
+	opts := &ctlOptions{}
+	fs := flag.NewFlagSet("ctl update", flag.ContinueOnError)
+	opts.addFlags(fs)
+	file := fs.String("f", "", "object file, - for stdin, the version in it must match the current version")
+	positional, err := parseInterspersed(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	resource, err := ctlResourceOf(positional)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("missing id\n%s", ctlUsage)
+	}
+	id, err := parseCtlID(positional[1])
+	if err != nil {
+		return err
+	}
+	data, err := readObject(*file)
+	if err != nil {
+		return err
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	obj, err := resource.update(ctx, c, id, data)
+	if err != nil {
+		return err
+	}
+	return printObject(out, opts.output, &resource, obj)
+}
+
+func ctlDelete(ctx context.Context, args []string, out io.Writer) error {
+	opts := &ctlOptions{}
+	fs := flag.NewFlagSet("ctl delete", flag.ContinueOnError)
+	opts.addFlags(fs)
+	version := fs.Uint64("version", 0, "only delete this version")
+	positional, err := parseInterspersed(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	resource, err := ctlResourceOf(positional)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("missing id\n%s", ctlUsage)
+	}
+	id, err := parseCtlID(positional[1])
+	if err != nil {
+		return err
+	}
+
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	if err := resource.delete(ctx, c, id, *version); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s/%d deleted\n", positional[0], id)
+	return err
+}
+
+// parseInterspersed parses flags before and after the positional arguments, like "get users -o json".
+func parseInterspersed(fs *flag.FlagSet, args []string, maxPositional int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%v\n%s", err, ctlUsage)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) > maxPositional {
+		return nil, fmt.Errorf("unexpected arguments %v\n%s", positional[maxPositional:], ctlUsage)
+	}
+	return positional, nil
+}
+
+func ctlResourceOf(positional []string) (ctlResource, error) {
+	if len(positional) == 0 {
+		return ctlResource{}, fmt.Errorf("missing resource\n%s", ctlUsage)
+	}
+	resource, ok := ctlResources[positional[0]]
+	if !ok {
+		return ctlResource{}, fmt.Errorf("unknown resource %q, use users, groups, roles or posts", positional[0])
+	}
+	return resource, nil
+}
+
+func parseCtlID(id string) (uint, error) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid id %q", id)
+	}
+	return uint(n), nil
+}
+
+// readObject reads a json or yaml file and returns it as json.
+func readObject(file string) ([]byte, error) {
+	if file == "" {
+		return nil, fmt.Errorf("missing -f file\n%s", ctlUsage)
+	}
+
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// json is yaml too
+	var obj interface{}
+	if err := yaml.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("invalid file %s: %v", file, err)
+	}
+	return json.Marshal(jsonValue(obj))
+}
+
+// jsonValue converts the maps decoded by yaml to maps with string keys, which can be encoded to json.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// listObjects lists one page if a limit is set, otherwise all pages.
+func listObjects[T any](ctx context.Context, opts *model.ListOptions,
+	page func(context.Context, *model.ListOptions) ([]T, *model.ListMeta, error),
+	all func(context.Context, *model.ListOptions) ([]T, error)) (interface{}, error) {
+	if opts.Limit > 0 {
+		items, _, err := page(ctx, opts)
+		return items, err
+	}
+	opts.Limit = model.MaxListLimit
+	return all(ctx, opts)
+}
+
+// printObject writes the object in the format, a table has a row for each item of a list.
+func printObject(out io.Writer, format string, resource *ctlResource, obj interface{}) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(obj, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	case "yaml":
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		// keep the json names and order of the fields
+		var value yaml.MapSlice
+		var items []yaml.MapSlice
+		var v interface{} = &value
+		if strings.HasPrefix(string(data), "[") {
+			v = &items
+		}
+		if err := yaml.Unmarshal(data, v); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(v); err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, strings.Join(resource.columns, "\t"))
+	for _, row := range rows(resource, obj) {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func rows(resource *ctlResource, obj interface{}) [][]string {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Slice {
+		return [][]string{resource.row(v.Elem().Interface())}
+	}
+	rows := make([][]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		rows = append(rows, resource.row(v.Index(i).Interface()))
+	}
+	return rows
+}
+
+func uitoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(time.RFC3339)
+}
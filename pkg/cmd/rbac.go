@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/eastygh/webm-nas/pkg/config"
+	"github.com/eastygh/webm-nas/pkg/database"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/service"
+	"github.com/eastygh/webm-nas/pkg/watch"
+
+	"gopkg.in/yaml.v2"
+)
+
+const rbacUsage = `Usage:
+  webm-nas [-config path] rbac export [-o file]
+  webm-nas [-config path] rbac apply -f file [-dry-run] [-prune]
+`
+
+// RunRBAC exports or applies a rbac policy against the configured database,
+// without a running server.
+func RunRBAC(conf *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing rbac command\n%s", rbacUsage)
+	}
+
+	repo, err := openRepository(conf)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	// the server runs in another process, its watchers are not notified
+	policyService := service.NewRBACPolicyService(repo, watch.NewBroadcaster(0))
+
+	switch args[0] {
+	case "export":
+		return rbacExport(policyService, args[1:], out)
+	case "apply":
+		return rbacApply(policyService, args[1:], out)
+	default:
+		return fmt.Errorf("unknown rbac command %q\n%s", args[0], rbacUsage)
+	}
+}
+
+func rbacExport(policyService service.RBACPolicyService, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("rbac export", flag.ContinueOnError)
+	output := fs.String("o", "", "output file, default stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	policy, err := policyService.Export()
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = out.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0o644)
+}
+
+func rbacApply(policyService service.RBACPolicyService, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("rbac apply", flag.ContinueOnError)
+	file := fs.String("f", "", "policy file, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "only print the plan")
+	prune := fs.Bool("prune", false, "delete roles, groups and bindings not in the policy")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("policy file is required\n%s", rbacUsage)
+	}
+
+	var data []byte
+	var err error
+	if *file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+
+	policy := &model.RBACPolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return err
+	}
+
+	plan, err := policyService.Apply(policy, model.ApplyOptions{DryRun: *dryRun, Prune: *prune})
+	if err != nil {
+		return err
+	}
+
+	printPlan(out, plan)
+	return nil
+}
+
+func printPlan(out io.Writer, plan *model.RBACPlan) {
+	if len(plan.Items) == 0 {
+		fmt.Fprintln(out, "No changes, rbac policy is up to date.")
+		return
+	}
+
+	for _, item := range plan.Items {
+		fmt.Fprintf(out, "%s %s %s\n", item.Action, item.Kind, item.Name)
+		for _, change := range item.Changes {
+			fmt.Fprintf(out, "    %s\n", change)
+		}
+	}
+
+	if plan.Applied {
+		fmt.Fprintf(out, "Applied %d changes.\n", len(plan.Items))
+	} else {
+		fmt.Fprintf(out, "Dry run, %d changes not applied.\n", len(plan.Items))
+	}
+}
+
+func openRepository(conf *config.Config) (repository.Repository, error) {
+	db, err := database.New(&conf.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := repository.NewRepository(db)
+	if conf.DB.Migrate {
+		if err := repo.Migrate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := repo.Init(); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
@@ -5,6 +5,8 @@ const (
 	UserContextKey        = `user`
 	TraceContextKey       = `trace`
 	RequestInfoContextKey = `requestInfo`
+	APIVersionContextKey  = `apiVersion`
+	RolesContextKey       = `roles`
 
 	CookieTokenName = `token`
 	CookieLoginUser = `loginUser`
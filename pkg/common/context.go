@@ -1,6 +1,7 @@
 package common
 
 import (
+	"github.com/eastygh/webm-nas/pkg/authorization"
 	"github.com/eastygh/webm-nas/pkg/model"
 	"github.com/eastygh/webm-nas/pkg/utils/request"
 	"github.com/eastygh/webm-nas/pkg/utils/trace"
@@ -92,3 +93,49 @@ func GetRequestInfo(c *gin.Context) *request.RequestInfo {
 
 	return ri
 }
+
+// SetRoles sets the roles of the user of the request, which were resolved to authorize it.
+func SetRoles(c *gin.Context, roles *authorization.Roles) {
+	if c == nil || roles == nil {
+		return
+	}
+
+	c.Set(RolesContextKey, roles)
+}
+
+// GetRoles returns the resolved roles of the user of the request, nil if the request was not authorized by them.
+func GetRoles(c *gin.Context) *authorization.Roles {
+	if c == nil {
+		return nil
+	}
+
+	val, ok := c.Get(RolesContextKey)
+	if !ok {
+		return nil
+	}
+
+	roles, ok := val.(*authorization.Roles)
+	if !ok {
+		return nil
+	}
+
+	return roles
+}
+
+// SetAPIVersion sets the api version of the request, it selects the format of responses.
+func SetAPIVersion(c *gin.Context, version string) {
+	if c == nil || version == "" {
+		return
+	}
+
+	c.Set(APIVersionContextKey, version)
+}
+
+// GetAPIVersion returns the api version of the request, it is empty if the request is not an api request.
+func GetAPIVersion(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+
+	return c.GetString(APIVersionContextKey)
+}
@@ -5,6 +5,8 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
@@ -21,8 +23,9 @@ func TestWrapFunc(t *testing.T) {
 	}{
 		{"invalid input parameters", func(int) string { return "" }, []interface{}{1, 2}, true, 0, ""},
 		{"invalid output parameters", func() {}, nil, true, 0, ""},
-		{"error response", func() (string, error) { return "", assert.AnError }, nil, false, 500, fmt.Sprintf(`{"code":500,"msg":"%s","data":null}`, assert.AnError)},
-		{"function panic", func() (string, error) { panic("some error"); return "", assert.AnError }, nil, false, 500, fmt.Sprintf(`{"code":500,"msg":"some error","data":null}`)},
+		{"error response", func() (string, error) { return "", assert.AnError }, nil, false, 500, fmt.Sprintf(`{"code":500,"reason":"InternalError","msg":"%s","data":null}`, assert.AnError)},
+		{"not found response", func() (string, error) { return "", apierrors.NewNotFound("users", 1) }, nil, false, 404, `{"code":404,"reason":"NotFound","msg":"users \"1\" not found","data":null}`},
+		{"function panic", func() (string, error) { panic("some error"); return "", assert.AnError }, nil, false, 500, fmt.Sprintf(`{"code":500,"reason":"InternalError","msg":"some error","data":null}`)},
 		{"success response with one outputs", func() string { return "some msg" }, nil, false, 200, `"some msg"`},
 		{"success response with two outputs", func() (string, error) { return "some msg", nil }, nil, false, 200, `"some msg"`},
 	}
@@ -3,14 +3,53 @@ package common
 import (
 	"net/http"
 
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	APIVersionV1 = "v1"
+	// APIVersionV2 responds objects without the Response envelope and errors as problem details
+	APIVersionV2 = "v2"
+
+	ProblemContentType = "application/problem+json"
+)
+
+// Response is the envelope of the responses of api v1.
 type Response struct {
-	Code int         `json:"code"`
-	Msg  string      `json:"msg"`
-	Data interface{} `json:"data"`
+	Code int `json:"code"`
+	// Reason is a machine readable reason of a failed request
+	Reason apierrors.Reason `json:"reason,omitempty"`
+	Msg    string           `json:"msg"`
+	Data   interface{}      `json:"data"`
+	// Metadata is only set for lists
+	Metadata *model.ListMeta `json:"metadata,omitempty"`
+}
+
+// Problem is a failed response of api v2, see https://www.rfc-editor.org/rfc/rfc7807.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Reason is a machine readable reason of the problem
+	Reason apierrors.Reason `json:"reason"`
+	// Details are the field errors of invalid objects or the current object of conflicts
+	Details interface{} `json:"details,omitempty"`
+}
+
+// List is a page of a list of api v2.
+type List struct {
+	Items    interface{}     `json:"items"`
+	Metadata *model.ListMeta `json:"metadata,omitempty"`
+}
+
+func isV2(c *gin.Context) bool {
+	return GetAPIVersion(c) == APIVersionV2
 }
 
 func NewResponse(c *gin.Context, code int, data interface{}, msg string) {
@@ -21,23 +60,62 @@ func NewResponse(c *gin.Context, code int, data interface{}, msg string) {
 	})
 }
 
+// ResponseSuccess responds the data, api v2 responds 204 No Content without data.
 func ResponseSuccess(c *gin.Context, data interface{}) {
+	if isV2(c) {
+		if data == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		c.JSON(http.StatusOK, data)
+		return
+	}
 	NewResponse(c, http.StatusOK, data, "success")
 }
 
+// ResponseCreated responds a created object, api v2 responds 201 Created with the location of the object.
+// The location is omitted if it is empty.
+func ResponseCreated(c *gin.Context, data interface{}, location string) {
+	if !isV2(c) {
+		ResponseSuccess(c, data)
+		return
+	}
+	if location != "" {
+		c.Header("Location", location)
+	}
+	c.JSON(http.StatusCreated, data)
+}
+
+// ResponseList responds a page of a list, the total count and next page token are in metadata.
+func ResponseList(c *gin.Context, items interface{}, meta *model.ListMeta) {
+	if isV2(c) {
+		c.JSON(http.StatusOK, List{Items: items, Metadata: meta})
+		return
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:     http.StatusOK,
+		Msg:      "success",
+		Data:     items,
+		Metadata: meta,
+	})
+}
+
+// ResponseFailed responds the error, the status code and reason of an apierrors error take precedence over code.
+// Api v2 responds the error as problem details.
 func ResponseFailed(c *gin.Context, code int, err error) {
 	if code == 0 {
 		code = http.StatusInternalServerError
 	}
-	if code == http.StatusUnauthorized && c.Request != nil {
-		if val, err := c.Cookie(CookieTokenName); err == nil && val != "" {
-			c.SetCookie(CookieTokenName, "", -1, "/", "", true, true)
-			c.SetCookie(CookieLoginUser, "", -1, "/", "", true, false)
-		}
-	}
+	reason := apierrors.ReasonForStatus(code)
 
 	var msg string
+	var data interface{}
 	if err != nil {
+		// field errors of invalid objects and the current object of conflicts are returned as data
+		if status := apierrors.FromError(err); status != nil {
+			code, reason, data = status.Code, status.Reason, status.Details
+		}
+
 		msg = err.Error()
 		user := GetUser(c)
 		var name string
@@ -50,5 +128,35 @@ func ResponseFailed(c *gin.Context, code int, err error) {
 		}
 		logrus.Warnf("url: %s, user: %s, error: %v", url, name, msg)
 	}
-	NewResponse(c, code, nil, msg)
+
+	if code == http.StatusUnauthorized && c.Request != nil {
+		if val, err := c.Cookie(CookieTokenName); err == nil && val != "" {
+			c.SetCookie(CookieTokenName, "", -1, "/", "", true, true)
+			c.SetCookie(CookieLoginUser, "", -1, "/", "", true, false)
+		}
+	}
+
+	if isV2(c) {
+		problem := Problem{
+			Type:    "about:blank",
+			Title:   http.StatusText(code),
+			Status:  code,
+			Detail:  msg,
+			Reason:  reason,
+			Details: data,
+		}
+		if c.Request != nil {
+			problem.Instance = c.Request.URL.Path
+		}
+		c.Header("Content-Type", ProblemContentType)
+		c.JSON(code, problem)
+		return
+	}
+
+	c.JSON(code, Response{
+		Code:   code,
+		Reason: reason,
+		Msg:    msg,
+		Data:   data,
+	})
 }
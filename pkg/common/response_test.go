@@ -3,9 +3,13 @@ package common
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
@@ -23,8 +27,9 @@ func TestResponse(t *testing.T) {
 			code: 400,
 			err:  errors.New("some err"),
 			resp: Response{
-				Code: 400,
-				Msg:  "some err",
+				Code:   400,
+				Reason: apierrors.ReasonBadRequest,
+				Msg:    "some err",
 			},
 		},
 		{
@@ -32,8 +37,9 @@ func TestResponse(t *testing.T) {
 			code: 401,
 			err:  errors.New("some err"),
 			resp: Response{
-				Code: 401,
-				Msg:  "some err",
+				Code:   401,
+				Reason: apierrors.ReasonUnauthorized,
+				Msg:    "some err",
 			},
 		},
 		// {
@@ -47,8 +53,29 @@ func TestResponse(t *testing.T) {
 			name: "with error",
 			err:  errors.New("some err"),
 			resp: Response{
-				Code: 500,
-				Msg:  "some err",
+				Code:   500,
+				Reason: apierrors.ReasonInternalError,
+				Msg:    "some err",
+			},
+		},
+		{
+			name: "with not found error",
+			code: 500,
+			err:  apierrors.NewNotFound("posts", 3),
+			resp: Response{
+				Code:   404,
+				Reason: apierrors.ReasonNotFound,
+				Msg:    `posts "3" not found`,
+			},
+		},
+		{
+			name: "with wrapped bad request error",
+			code: 500,
+			err:  fmt.Errorf("get post: %w", apierrors.NewBadRequest(errors.New(`invalid id "a"`))),
+			resp: Response{
+				Code:   400,
+				Reason: apierrors.ReasonBadRequest,
+				Msg:    `get post: invalid id "a"`,
 			},
 		},
 		{
@@ -80,3 +107,91 @@ func TestResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestResponseFieldErrors(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "http://localhost/api/v1/roles", nil)
+
+	errs := field.ErrorList{field.Required(field.NewPath("name"), "")}
+	ResponseFailed(c, 400, errs.ToAggregate())
+
+	resp := struct {
+		Code int             `json:"code"`
+		Msg  string          `json:"msg"`
+		Data field.ErrorList `json:"data"`
+	}{}
+	err := json.NewDecoder(w.Body).Decode(&resp)
+	assert.Empty(t, err)
+	assert.Equal(t, 400, resp.Code)
+	assert.Equal(t, "name: required value", resp.Msg)
+	assert.Equal(t, errs, resp.Data)
+}
+
+func TestResponseConflict(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("PUT", "http://localhost/api/v1/posts/1", nil)
+
+	ResponseFailed(c, 500, apierrors.NewConflict(apierrors.ErrModified, map[string]interface{}{"version": 2}))
+
+	resp := Response{}
+	err := json.NewDecoder(w.Body).Decode(&resp)
+	assert.Empty(t, err)
+	assert.Equal(t, 409, w.Code)
+	assert.Equal(t, apierrors.ReasonConflict, resp.Reason)
+	assert.Equal(t, map[string]interface{}{"version": float64(2)}, resp.Data)
+}
+
+func TestResponseV2(t *testing.T) {
+	newContext := func(method, path string) (*gin.Context, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, "http://localhost"+path, nil)
+		SetAPIVersion(c, APIVersionV2)
+		return c, w
+	}
+
+	t.Run("created", func(t *testing.T) {
+		c, w := newContext("POST", "/api/v2/posts")
+		ResponseCreated(c, map[string]interface{}{"id": 3}, "/api/v2/posts/3")
+		assert.Equal(t, 201, w.Code)
+		assert.Equal(t, "/api/v2/posts/3", w.Header().Get("Location"))
+		assert.JSONEq(t, `{"id": 3}`, w.Body.String())
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		c, w := newContext("DELETE", "/api/v2/posts/3")
+		ResponseSuccess(c, nil)
+		c.Writer.WriteHeaderNow()
+		assert.Equal(t, 204, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("list", func(t *testing.T) {
+		c, w := newContext("GET", "/api/v2/posts")
+		ResponseList(c, []int{1, 2}, &model.ListMeta{Total: 2})
+		assert.Equal(t, 200, w.Code)
+		assert.JSONEq(t, `{"items": [1, 2], "metadata": {"total": 2}}`, w.Body.String())
+	})
+
+	t.Run("problem", func(t *testing.T) {
+		c, w := newContext("PUT", "/api/v2/posts/1")
+		ResponseFailed(c, 500, apierrors.NewConflict(apierrors.ErrModified, map[string]interface{}{"version": 2}))
+		assert.Equal(t, 409, w.Code)
+		assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+
+		problem := Problem{}
+		err := json.NewDecoder(w.Body).Decode(&problem)
+		assert.Empty(t, err)
+		assert.Equal(t, Problem{
+			Type:     "about:blank",
+			Title:    "Conflict",
+			Status:   409,
+			Detail:   apierrors.ErrModified.Error(),
+			Instance: "/api/v2/posts/1",
+			Reason:   apierrors.ReasonConflict,
+			Details:  map[string]interface{}{"version": float64(2)},
+		}, problem)
+	})
+}
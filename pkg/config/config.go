@@ -15,6 +15,14 @@ type Config struct {
 	OAuthConfig map[string]OAuthConfig `yaml:"oauth"`
 	Revers      ReversProxyConfig      `yaml:"revers"`
 	Static      StaticContentConfig    `yaml:"static"`
+	Audit       AuditConfig            `yaml:"audit"`
+	Trash       TrashConfig            `yaml:"trash"`
+	Idempotency IdempotencyConfig      `yaml:"idempotency"`
+	Post        PostConfig             `yaml:"post"`
+	Comment     CommentConfig          `yaml:"comment"`
+	Feed        FeedConfig             `yaml:"feed"`
+	Attachment  AttachmentConfig       `yaml:"attachment"`
+	Analytics   AnalyticsConfig        `yaml:"analytics"`
 }
 
 type ServerConfig struct {
@@ -25,6 +33,9 @@ type ServerConfig struct {
 	AllowInsecure          bool                    `yaml:"allowInsecure"`
 	LimitConfigs           []ratelimit.LimitConfig `yaml:"rateLimits"`
 	JWTSecret              string                  `yaml:"jwtSecret"`
+	// TrustedProxies are the ips and cidrs of the reverse proxies whose X-Forwarded-For is trusted,
+	// without them the client ip is the remote address of the connection
+	TrustedProxies []string `yaml:"trustedProxies"`
 }
 
 type DBConfig struct {
@@ -49,6 +60,50 @@ type StaticContentConfig struct {
 	SpaPath  string            `yaml:"spaPath"`  // which is the base uri using for spa
 }
 
+type AuditConfig struct {
+	Enable        bool   `yaml:"enable"`
+	DB            bool   `yaml:"db"`            // store events in the audit_events table
+	File          string `yaml:"file"`          // json lines file, rotated daily
+	RetentionDays int    `yaml:"retentionDays"` // 0 keeps events forever
+	MaxBodySize   int    `yaml:"maxBodySize"`   // max length of the request body summary
+}
+
+type TrashConfig struct {
+	RetentionDays int `yaml:"retentionDays"` // deleted objects are purged after the retention, 0 keeps them forever
+}
+
+type IdempotencyConfig struct {
+	TTLHours int `yaml:"ttlHours"` // responses of idempotency keys are kept for the ttl, 0 keeps them for 24 hours
+}
+
+type PostConfig struct {
+	MaxRevisions    int   `yaml:"maxRevisions"`    // revisions kept for each post, 0 keeps all revisions
+	RenderCacheSize int   `yaml:"renderCacheSize"` // posts with their rendered html kept in memory, 0 is 1024 posts
+	MaxImportSize   int64 `yaml:"maxImportSize"`   // bytes of an imported zip of posts, 0 is 100 MiB
+}
+
+type CommentConfig struct {
+	MaxDepth        int `yaml:"maxDepth"`        // replies can be nested this deep, 0 allows any depth
+	HoldNewUserDays int `yaml:"holdNewUserDays"` // comments of users registered within these days are held for moderation, 0 holds none
+}
+
+type FeedConfig struct {
+	Title   string `yaml:"title"`   // title of the feeds, default Posts
+	BaseURL string `yaml:"baseURL"` // url of the server in links of feeds, default the scheme and host of the request
+	Limit   int    `yaml:"limit"`   // latest posts in a feed, 0 is 20 posts
+}
+
+type AttachmentConfig struct {
+	Dir          string   `yaml:"dir"`          // directory of the uploaded files, default attachments
+	MaxSize      int64    `yaml:"maxSize"`      // bytes of an uploaded file, 0 is 10 MiB
+	AllowedTypes []string `yaml:"allowedTypes"` // media types sniffed from the content, default png, jpeg, gif, webp, pdf and plain text
+}
+
+type AnalyticsConfig struct {
+	ViewWindowMinutes    int `yaml:"viewWindowMinutes"`    // views of a user or ip are counted once in these minutes, 0 is 30 minutes
+	FlushIntervalSeconds int `yaml:"flushIntervalSeconds"` // counts of views and likes are written every these seconds, 0 is 10 seconds
+}
+
 type RedisConfig struct {
 	Enable   bool   `yaml:"enable"`
 	Host     string `yaml:"host"`
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ArchiveController exports posts as zips of Markdown files and imports them.
+type ArchiveController struct {
+	archiveService service.ArchiveService
+}
+
+func NewArchiveController(archiveService service.ArchiveService) Controller {
+	return &ArchiveController{
+		archiveService: archiveService,
+	}
+}
+
+// archiveUpload is the multipart form of an import.
+type archiveUpload struct {
+	File []byte `json:"file" validate:"required"`
+}
+
+// @Summary Export posts
+// @Description Export the posts as a zip, each post is a directory of an index.md with YAML front matter and its attachments.
+// @Description All posts visible to the user are exported, they can be filtered and sorted like lists
+// @Produce application/zip
+// @Tags post
+// @Security JWT
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like creatorId=3,status=draft,name~=foo"
+// @Success 200
+// @Router /api/v1/posts/export [get]
+func (a *ArchiveController) Export(c *gin.Context) {
+	opts, err := listOptions(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	posts, err := a.archiveService.Export(opts, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	filename := fmt.Sprintf("posts-%s.zip", time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Status(http.StatusOK)
+	// the response is started, errors can only abort the zip
+	if err := a.archiveService.WriteZip(c.Writer, posts); err != nil {
+		logrus.Warnf("failed to export posts: %v", err)
+		c.Abort()
+	}
+}
+
+// @Summary Import posts
+// @Description Import the Markdown files of a zip as the field file of a multipart form, like the zips of exports and Hugo sites.
+// @Description Authors are mapped to users by name, missing tags and categories are created, files whose name is used by a post are conflicts.
+// @Description Linked files of the zip are uploaded as attachments. Only editors may import posts
+// @Accept multipart/form-data
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param file formData file true "zip to import"
+// @Success 200 {object} common.Response{data=model.ImportResult}
+// @Router /api/v1/posts/import [post]
+func (a *ArchiveController) Import(c *gin.Context) {
+	user := common.GetUser(c)
+	if user == nil {
+		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("failed to get user"))
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	part, err := formFile(c, attachmentFileField)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	result, err := a.archiveService.Import(user, part, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, result)
+}
+
+func (a *ArchiveController) RegisterRoute(api *gin.RouterGroup) {
+	api.GET("/posts/export", a.Export)
+	api.POST("/posts/import", a.Import)
+}
+
+func (a *ArchiveController) RegisterRouteV2(api *Router) {
+	api.GET("/posts/export", a.Export, RouteV2{
+		Summary:             "Export posts",
+		Description:         "Export the posts as a zip, each post is a directory of an index.md with YAML front matter and its attachments",
+		Response:            "",
+		ResponseContentType: "application/zip",
+		Query:               listParameters()[3:],
+	})
+	api.POST("/posts/import", a.Import, RouteV2{
+		Summary: "Import posts",
+		Description: "Import the Markdown files of a zip with YAML front matter, authors are mapped to users by name and " +
+			"files whose name is used by a post are conflicts. Only editors may import posts",
+		Body:            archiveUpload{},
+		BodyContentType: "multipart/form-data",
+		Response:        model.ImportResult{},
+		Status:          http.StatusOK,
+	})
+}
+
+func (a *ArchiveController) Name() string {
+	return "Archive"
+}
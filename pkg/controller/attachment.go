@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// attachmentFileField is the field of the file in the multipart form of an upload.
+const attachmentFileField = "file"
+
+// AttachmentController serves the attachments of posts, like the images in their content.
+type AttachmentController struct {
+	attachmentService service.AttachmentService
+}
+
+func NewAttachmentController(attachmentService service.AttachmentService) Controller {
+	return &AttachmentController{
+		attachmentService: attachmentService,
+	}
+}
+
+// attachmentUpload is the multipart form of an upload.
+type attachmentUpload struct {
+	File []byte `json:"file" validate:"required"`
+}
+
+// @Summary List attachments
+// @Description List the attachments of the post, oldest first
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Success 200 {object} common.Response{data=[]model.Attachment}
+// @Router /api/v1/posts/{id}/attachments [get]
+func (a *AttachmentController) List(c *gin.Context) {
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	attachments, err := a.attachmentService.List(c.Param("id"), reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, attachments)
+}
+
+// @Summary Upload attachment
+// @Description Upload a file to the post as the field file of a multipart form, its url can be used in the content of the post.
+// @Description The type is sniffed from the content and must be allowed. Only the creator and editors may upload attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param file formData file true "file to upload"
+// @Success 201 {object} common.Response{data=model.Attachment}
+// @Router /api/v1/posts/{id}/attachments [post]
+func (a *AttachmentController) Create(c *gin.Context) {
+	user := common.GetUser(c)
+	if user == nil {
+		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("failed to get user"))
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the file is streamed to the service, which stops reading it at the max size
+	part, err := formFile(c, attachmentFileField)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	attachment, err := a.attachmentService.Create(user, c.Param("id"), part.FileName(), part, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	responseCreated(c, attachment, attachment.ID)
+}
+
+// formFile returns the part of the field of the multipart form of the request, the content is not buffered.
+func formFile(c *gin.Context, name string) (*multipart.Part, error) {
+	form, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("expect a multipart form: %w", err)
+	}
+	for {
+		part, err := form.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("the form has no field %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == name {
+			return part, nil
+		}
+	}
+}
+
+// @Summary Download attachment
+// @Description Download the content of the attachment, images and text are shown inline and other files are downloaded
+// @Produce octet-stream
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param attachmentId path int true "attachment id"
+// @Success 200
+// @Success 304
+// @Router /api/v1/posts/{id}/attachments/{attachmentId} [get]
+func (a *AttachmentController) Get(c *gin.Context) {
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	attachment, err := a.attachmentService.Get(c.Param("id"), c.Param("attachmentId"), reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	file, err := a.attachmentService.Open(attachment)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") || strings.HasPrefix(attachment.ContentType, "text/plain") {
+		disposition = "inline"
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", attachment.ContentType)
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}))
+	// the content is never run as a page of the server, even if a browser would sniff it as html
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	header.Set("Cache-Control", "private, no-cache")
+	header.Set("ETag", `"`+attachment.Hash+`"`)
+	http.ServeContent(c.Writer, c.Request, attachment.Name, attachment.CreatedAt, file)
+}
+
+// @Summary Delete attachment
+// @Description Delete the attachment and its content, only the creator and editors of the post may delete attachments
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param attachmentId path int true "attachment id"
+// @Success 200 {object} common.Response
+// @Router /api/v1/posts/{id}/attachments/{attachmentId} [delete]
+func (a *AttachmentController) Delete(c *gin.Context) {
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := a.attachmentService.Delete(c.Param("id"), c.Param("attachmentId"), reader); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, nil)
+}
+
+func (a *AttachmentController) RegisterRoute(api *gin.RouterGroup) {
+	api.GET("/posts/:id/attachments", a.List)
+	api.POST("/posts/:id/attachments", a.Create)
+	api.GET("/posts/:id/attachments/:attachmentId", a.Get)
+	api.DELETE("/posts/:id/attachments/:attachmentId", a.Delete)
+}
+
+func (a *AttachmentController) RegisterRouteV2(api *Router) {
+	api.GET("/posts/{id}/attachments", a.List, RouteV2{Summary: "List attachments", Response: []model.Attachment{}})
+	api.POST("/posts/{id}/attachments", a.Create, RouteV2{
+		Summary:         "Upload attachment",
+		Description:     "Upload a file to the post, its url can be used in the content. The type is sniffed from the content and must be allowed",
+		Body:            attachmentUpload{},
+		BodyContentType: "multipart/form-data",
+		Response:        model.Attachment{},
+	})
+	api.GET("/posts/{id}/attachments/{attachmentId}", a.Get, RouteV2{
+		Summary:             "Download attachment",
+		Description:         "Download the content of the attachment, images and text are shown inline",
+		Response:            "",
+		ResponseContentType: "application/octet-stream",
+	})
+	api.DELETE("/posts/{id}/attachments/{attachmentId}", a.Delete, RouteV2{
+		Summary:     "Delete attachment",
+		Description: "Delete the attachment and its content, only the creator and editors of the post may delete attachments",
+	})
+}
+
+func (a *AttachmentController) Name() string {
+	return "Attachment"
+}
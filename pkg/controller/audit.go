@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/openapi"
+	"github.com/eastygh/webm-nas/pkg/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditController struct {
+	auditService service.AuditService
+}
+
+func NewAuditController(auditService service.AuditService) Controller {
+	return &AuditController{
+		auditService: auditService,
+	}
+}
+
+// @Summary List audit events
+// @Description List audit events, newest first
+// @Produce json
+// @Tags audit
+// @Security JWT
+// @Param user query string false "user name"
+// @Param resource query string false "resource"
+// @Param since query string false "start time, RFC3339"
+// @Param until query string false "end time, RFC3339"
+// @Param limit query int false "max events, default 100"
+// @Success 200 {object} common.Response{data=[]model.AuditEvent}
+// @Router /api/v1/audit [get]
+func (a *AuditController) List(c *gin.Context) {
+	query := &model.AuditQuery{
+		User:     c.Query("user"),
+		Resource: c.Query("resource"),
+	}
+
+	var err error
+	if since := c.Query("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("invalid since: %v", err))
+			return
+		}
+	}
+	if until := c.Query("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("invalid until: %v", err))
+			return
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("invalid limit: %v", err))
+			return
+		}
+	}
+
+	events, err := a.auditService.List(query)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	common.ResponseList(c, events, nil)
+}
+
+func (a *AuditController) RegisterRoute(api *gin.RouterGroup) {
+	api.GET("/audit", a.List)
+}
+
+func (a *AuditController) RegisterRouteV2(api *Router) {
+	api.GET("/audit", a.List, RouteV2{
+		Summary:     "List audit events",
+		Description: "List audit events, newest first",
+		Response:    []model.AuditEvent{},
+		Query: []openapi.Parameter{
+			{Name: "user", In: "query", Description: "user name", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "resource", In: "query", Description: "resource", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "since", In: "query", Description: "start time, RFC3339", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "until", In: "query", Description: "end time, RFC3339", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "limit", In: "query", Description: "max events, default 100", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+		},
+	})
+}
+
+func (a *AuditController) Name() string {
+	return "Audit"
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/eastygh/webm-nas/pkg/config"
 	"net/http"
 
@@ -69,10 +70,10 @@ func (ac *AuthController) Login(c *gin.Context) {
 		c.SetCookie(common.CookieLoginUser, string(userJson), 3600*24, "/", "", secure, false)
 	}
 
-	common.ResponseSuccess(c, model.JWTToken{
+	common.ResponseCreated(c, model.JWTToken{
 		Token:    token,
 		Describe: "set token in Authorization Header, [Authorization: Bearer {token}]",
-	})
+	}, "")
 }
 
 // @Summary Logout
@@ -98,8 +99,7 @@ func (ac *AuthController) Logout(c *gin.Context) {
 // @Router /api/v1/auth/user [post]
 func (ac *AuthController) Register(c *gin.Context) {
 	createdUser := new(model.CreatedUser)
-	if err := c.BindJSON(createdUser); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if !bindJSON(c, createdUser) {
 		return
 	}
 
@@ -113,9 +113,10 @@ func (ac *AuthController) Register(c *gin.Context) {
 	user, err := ac.userService.Create(user)
 	if err != nil {
 		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
 	}
 
-	common.ResponseSuccess(c, user)
+	common.ResponseCreated(c, user, fmt.Sprintf("/api/%s/users/%d", common.GetAPIVersion(c), user.ID))
 }
 
 func (ac *AuthController) RegisterRoute(api *gin.RouterGroup) {
@@ -124,6 +125,12 @@ func (ac *AuthController) RegisterRoute(api *gin.RouterGroup) {
 	api.POST("/auth/user", ac.Register)
 }
 
+func (ac *AuthController) RegisterRouteV2(api *Router) {
+	api.POST("/auth/token", ac.Login, RouteV2{Summary: "Login", Body: model.AuthUser{}, Response: model.JWTToken{}, Public: true})
+	api.DELETE("/auth/token", ac.Logout, RouteV2{Summary: "Logout", Public: true})
+	api.POST("/auth/users", ac.Register, RouteV2{Summary: "Register user", Body: model.CreatedUser{}, Response: model.User{}, Public: true})
+}
+
 func (ac *AuthController) Name() string {
 	return "Authentication"
 }
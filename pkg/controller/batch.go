@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/audit"
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/service"
+	"github.com/eastygh/webm-nas/pkg/utils/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BatchController struct {
+	batchService service.BatchService
+	auditor      *audit.Auditor
+	resolver     request.RequestInfoResolver
+}
+
+// NewBatchController returns the controller of batches, the operations are audited like requests if auditor is not nil.
+func NewBatchController(batchService service.BatchService, auditor *audit.Auditor, resolver request.RequestInfoResolver) Controller {
+	return &BatchController{
+		batchService: batchService,
+		auditor:      auditor,
+		resolver:     resolver,
+	}
+}
+
+// @Summary Run batch
+// @Description Run a batch of create and delete operations of users, groups, posts and roles, each operation is authorized by itself.
+// @Description An atomic batch runs in a single transaction and is rolled back if an operation fails, the other operations fail with 424.
+// @Accept json
+// @Produce json
+// @Tags batch
+// @Security JWT
+// @Param batch body model.Batch true "batch"
+// @Success 200 {object} common.Response{data=model.BatchResult}
+// @Failure 400 {object} common.Response
+// @Router /api/v1/batch [post]
+func (b *BatchController) Run(c *gin.Context) {
+	batch := new(model.Batch)
+	if !bindJSON(c, batch) {
+		return
+	}
+
+	result := b.batchService.Run(common.GetUser(c), batch)
+	b.audit(c, batch, result)
+
+	common.ResponseSuccess(c, result)
+}
+
+// audit records the operations of the batch like the audit middleware records requests.
+func (b *BatchController) audit(c *gin.Context, batch *model.Batch, result *model.BatchResult) {
+	if b.auditor == nil {
+		return
+	}
+
+	user := common.GetUser(c)
+	for i, op := range batch.Operations {
+		event := model.AuditEvent{
+			Time:       time.Now(),
+			SourceIP:   c.ClientIP(),
+			Method:     op.Method,
+			Path:       op.Path,
+			StatusCode: result.Results[i].Code,
+			Outcome:    model.AuditSuccess,
+			Body:       audit.SummarizeBody(gin.MIMEJSON, op.Body, b.auditor.MaxBodySize()),
+		}
+		switch code := result.Results[i].Code; {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			event.Outcome = model.AuditDenied
+		case code >= http.StatusBadRequest:
+			event.Outcome = model.AuditFailure
+		}
+
+		if req, err := http.NewRequest(op.Method, op.Path, nil); err == nil {
+			if ri, err := b.resolver.NewRequestInfo(req); err == nil {
+				event.Verb = ri.Verb
+				event.Namespace = ri.Namespace
+				event.Resource = ri.Resource
+				event.Subresource = ri.Subresource
+				event.Name = ri.Name
+			}
+		}
+		if user != nil {
+			event.UserID = user.ID
+			event.UserName = user.Name
+		}
+
+		b.auditor.Record(event)
+	}
+}
+
+func (b *BatchController) RegisterRoute(api *gin.RouterGroup) {
+	api.POST("/"+request.BatchPath, b.Run)
+}
+
+func (b *BatchController) RegisterRouteV2(api *Router) {
+	api.POST("/"+request.BatchPath, b.Run, RouteV2{
+		Summary:     "Run batch",
+		Description: "Run operations in a single request, atomic batches roll back all operations if one fails",
+		Body:        model.Batch{},
+		Response:    model.BatchResult{},
+		Status:      http.StatusOK,
+	})
+}
+
+func (b *BatchController) Name() string {
+	return "Batch"
+}
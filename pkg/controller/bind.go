@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindJSON decodes the json body into obj and validates its struct tags,
+// it responds 400 with the field errors if the body is invalid.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return false
+	}
+	if err := validation.Struct(obj); err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/service"
+	"github.com/eastygh/webm-nas/pkg/utils/trace"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryController struct {
+	categoryService service.CategoryService
+}
+
+func NewCategoryController(categoryService service.CategoryService) Controller {
+	return &CategoryController{
+		categoryService: categoryService,
+	}
+}
+
+// @Summary List categories
+// @Description List categories with the number of their published posts
+// @Produce json
+// @Tags category
+// @Security JWT
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like parentId=3,name~=go"
+// @Success 200 {object} common.Response{data=[]model.Category,metadata=model.ListMeta}
+// @Router /api/v1/categories [get]
+func (ca *CategoryController) List(c *gin.Context) {
+	opts, err := listOptions(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	categories, meta, err := ca.categoryService.List(opts)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseList(c, categories, meta)
+}
+
+// @Summary Get category
+// @Description Get category with its children and the number of its published posts
+// @Produce json
+// @Tags category
+// @Security JWT
+// @Param id path int true "category id"
+// @Success 200 {object} common.Response{data=model.Category}
+// @Router /api/v1/categories/{id} [get]
+func (ca *CategoryController) Get(c *gin.Context) {
+	category, err := ca.categoryService.Get(c.Param("id"))
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, category)
+}
+
+// @Summary Create category
+// @Description Create category, a subcategory if parentId is set
+// @Accept json
+// @Produce json
+// @Tags category
+// @Security JWT
+// @Param category body model.Category true "category info"
+// @Success 201 {object} common.Response{data=model.Category}
+// @Router /api/v1/categories [post]
+func (ca *CategoryController) Create(c *gin.Context) {
+	category := new(model.Category)
+	if !bindJSON(c, category) {
+		return
+	}
+
+	common.TraceStep(c, "start create category", trace.Field{Key: "category", Value: category.Name})
+	defer common.TraceStep(c, "create category done", trace.Field{Key: "category", Value: category.Name})
+
+	category, err := ca.categoryService.Create(category)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	responseCreated(c, category, category.ID)
+}
+
+// @Summary Update category
+// @Description Rename category or move it to another parent
+// @Accept json
+// @Produce json
+// @Tags category
+// @Security JWT
+// @Param id path int true "category id"
+// @Param category body model.Category true "category info"
+// @Success 200 {object} common.Response{data=model.Category}
+// @Router /api/v1/categories/{id} [put]
+func (ca *CategoryController) Update(c *gin.Context) {
+	category := new(model.Category)
+	if !bindJSON(c, category) {
+		return
+	}
+
+	common.TraceStep(c, "start update category", trace.Field{Key: "category", Value: category.Name})
+	defer common.TraceStep(c, "update category done", trace.Field{Key: "category", Value: category.Name})
+
+	category, err := ca.categoryService.Update(c.Param("id"), category)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, category)
+}
+
+// @Summary Delete category
+// @Description Delete category and remove it from its posts, its children are moved to its parent
+// @Produce json
+// @Tags category
+// @Security JWT
+// @Param id path int true "category id"
+// @Success 200 {object} common.Response
+// @Router /api/v1/categories/{id} [delete]
+func (ca *CategoryController) Delete(c *gin.Context) {
+	if err := ca.categoryService.Delete(c.Param("id")); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, nil)
+}
+
+// @Summary List category posts
+// @Description List the posts in the category or its subcategories
+// @Produce json
+// @Tags category
+// @Security JWT
+// @Param id path int true "category id"
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like creatorId=3,name~=foo"
+// @Success 200 {object} common.Response{data=[]model.Post,metadata=model.ListMeta}
+// @Router /api/v1/categories/{id}/posts [get]
+func (ca *CategoryController) ListPosts(c *gin.Context) {
+	opts, err := listOptions(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	posts, meta, err := ca.categoryService.ListPosts(c.Param("id"), opts, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseList(c, posts, meta)
+}
+
+func (ca *CategoryController) RegisterRoute(api *gin.RouterGroup) {
+	api.GET("/categories", ca.List)
+	api.POST("/categories", ca.Create)
+	api.GET("/categories/:id", ca.Get)
+	api.PUT("/categories/:id", ca.Update)
+	api.DELETE("/categories/:id", ca.Delete)
+	api.GET("/categories/:id/posts", ca.ListPosts)
+}
+
+func (ca *CategoryController) RegisterRouteV2(api *Router) {
+	api.GET("/categories", ca.List, RouteV2{
+		Summary:     "List categories",
+		Description: "List categories with the number of their published posts",
+		Response:    []model.Category{},
+		List:        true,
+	})
+	api.POST("/categories", ca.Create, RouteV2{Summary: "Create category", Body: model.Category{}, Response: model.Category{}})
+	api.GET("/categories/{id}", ca.Get, RouteV2{Summary: "Get category", Description: "Get the category with its children", Response: model.Category{}})
+	api.PUT("/categories/{id}", ca.Update, RouteV2{
+		Summary:     "Update category",
+		Description: "Rename the category or move it to another parent, a category can not be moved into its subcategories",
+		Body:        model.Category{},
+		Response:    model.Category{},
+	})
+	api.DELETE("/categories/{id}", ca.Delete, RouteV2{
+		Summary:     "Delete category",
+		Description: "Delete the category and remove it from its posts, its children are moved to its parent",
+	})
+	api.GET("/categories/{id}/posts", ca.ListPosts, RouteV2{
+		Summary:     "List category posts",
+		Description: "List the posts in the category or its subcategories",
+		Response:    []model.Post{},
+		List:        true,
+	})
+}
+
+func (ca *CategoryController) Name() string {
+	return "Category"
+}
@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/authorization"
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/service"
+	"github.com/eastygh/webm-nas/pkg/utils/feed"
+	"github.com/eastygh/webm-nas/pkg/utils/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+// feedFormats are the formats of feeds, the extensions of feed paths.
+var feedFormats = []feed.Format{feed.Atom, feed.RSS, feed.JSON}
+
+// FeedController serves the feeds of posts outside of the api, so feed readers can read them without a session.
+// Users who may not list posts read the feeds with their feed token in the token query.
+type FeedController struct {
+	feedService service.FeedService
+	baseURL     string
+}
+
+// NewFeedController returns the controller of feeds, links in the feeds start with baseURL
+// or the scheme and host of the request if it is empty.
+func NewFeedController(feedService service.FeedService, baseURL string) Controller {
+	return &FeedController{
+		feedService: feedService,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// @Summary Posts feed
+// @Description Feed of the latest published posts, feed is posts.atom, posts.rss or posts.json
+// @Produce application/atom+xml,application/rss+xml,application/feed+json
+// @Tags feed
+// @Param feed path string true "posts.atom, posts.rss or posts.json"
+// @Param token query string false "feed token of the user"
+// @Success 200
+// @Success 304
+// @Router /feeds/{feed} [get]
+func (f *FeedController) Posts(c *gin.Context) {
+	name, format, ok := parseFeed(c.Param("feed"))
+	if !ok || name != "posts" {
+		common.ResponseFailed(c, http.StatusNotFound, fmt.Errorf("feed %s not found", c.Param("feed")))
+		return
+	}
+	f.serve(c, "", "", format)
+}
+
+// @Summary Tag feed
+// @Description Feed of the latest published posts with the tag, feed is the tag id with the extension of the format, like 3.atom
+// @Produce application/atom+xml,application/rss+xml,application/feed+json
+// @Tags feed
+// @Param feed path string true "tag id and format, like 3.atom, 3.rss or 3.json"
+// @Param token query string false "feed token of the user"
+// @Success 200
+// @Success 304
+// @Router /feeds/tags/{feed} [get]
+func (f *FeedController) TagPosts(c *gin.Context) {
+	f.serveResource(c, model.TagResource)
+}
+
+// @Summary Category feed
+// @Description Feed of the latest published posts in the category or its subcategories, feed is the category id with the extension of the format
+// @Produce application/atom+xml,application/rss+xml,application/feed+json
+// @Tags feed
+// @Param feed path string true "category id and format, like 3.atom, 3.rss or 3.json"
+// @Param token query string false "feed token of the user"
+// @Success 200
+// @Success 304
+// @Router /feeds/categories/{feed} [get]
+func (f *FeedController) CategoryPosts(c *gin.Context) {
+	f.serveResource(c, model.CategoryResource)
+}
+
+func (f *FeedController) serveResource(c *gin.Context, resource string) {
+	id, format, ok := parseFeed(c.Param("feed"))
+	if !ok {
+		common.ResponseFailed(c, http.StatusNotFound, fmt.Errorf("feed %s not found", c.Param("feed")))
+		return
+	}
+	f.serve(c, resource, id, format)
+}
+
+// serve writes the feed if the reader may list posts, or 304 if the feed did not change since the conditional headers.
+func (f *FeedController) serve(c *gin.Context, resource, id string, format feed.Format) {
+	user, err := f.reader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	ri := &request.RequestInfo{IsResourceRequest: true, Namespace: request.NamespaceRoot, Resource: model.PostResource, Verb: request.ListOperation}
+	allowed, err := authorization.AuthorizeVerb(user, ri, request.ListOperation)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !allowed {
+		if user.ID == 0 {
+			common.ResponseFailed(c, http.StatusUnauthorized, errors.New("a feed token is required"))
+		} else {
+			common.ResponseFailed(c, http.StatusForbidden, fmt.Errorf("user [%s] is forbidden to list posts", user.Name))
+		}
+		return
+	}
+
+	base := f.base(c)
+	result, err := f.feedService.Posts(resource, id, base, base+c.Request.URL.RequestURI())
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	data, err := feed.Write(result, format)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := fmt.Sprintf(`"%x"`, sum[:16])
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", result.Updated.UTC().Format(http.TimeFormat))
+	if user.ID != 0 {
+		c.Header("Cache-Control", "private, no-cache")
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+	if notModified(c, etag, result.Updated) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, feed.ContentTypes[format], data)
+}
+
+// reader returns the user of the feed token, or the user of the session if there is no token.
+func (f *FeedController) reader(c *gin.Context) (*model.User, error) {
+	if token := c.Query("token"); token != "" {
+		return f.feedService.GetTokenUser(token)
+	}
+	if user := common.GetUser(c); user != nil {
+		return user, nil
+	}
+	return &model.User{}, nil
+}
+
+// base returns the configured base url, or the scheme and host of the request.
+func (f *FeedController) base(c *gin.Context) string {
+	if f.baseURL != "" {
+		return f.baseURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// notModified checks the conditional headers of the request, If-None-Match is checked before If-Modified-Since.
+func notModified(c *gin.Context, etag string, updated time.Time) bool {
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	return err == nil && !updated.Truncate(time.Second).After(since)
+}
+
+// parseFeed splits a feed path like posts.atom into its name and format.
+func parseFeed(path string) (string, feed.Format, bool) {
+	i := strings.LastIndex(path, ".")
+	if i <= 0 {
+		return "", "", false
+	}
+	name, format := path[:i], feed.Format(path[i+1:])
+	for _, f := range feedFormats {
+		if f == format {
+			return name, format, true
+		}
+	}
+	return "", "", false
+}
+
+// @Summary Create feed token
+// @Description Create a feed token of the user, the previous feed token of the user stops working
+// @Produce json
+// @Tags feed
+// @Security JWT
+// @Success 200 {object} common.Response{data=model.CreatedFeedToken}
+// @Router /feeds/token [post]
+func (f *FeedController) CreateToken(c *gin.Context) {
+	user := common.GetUser(c)
+	if user == nil {
+		common.ResponseFailed(c, http.StatusUnauthorized, nil)
+		return
+	}
+
+	token, err := f.feedService.CreateToken(user)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	created := &model.CreatedFeedToken{Token: token}
+	for _, format := range feedFormats {
+		created.Feeds = append(created.Feeds, fmt.Sprintf("%s/feeds/posts.%s?token=%s", f.base(c), format, token))
+	}
+	common.ResponseSuccess(c, created)
+}
+
+// @Summary Delete feed token
+// @Description Delete the feed token of the user
+// @Produce json
+// @Tags feed
+// @Security JWT
+// @Success 200 {object} common.Response
+// @Router /feeds/token [delete]
+func (f *FeedController) DeleteToken(c *gin.Context) {
+	user := common.GetUser(c)
+	if user == nil {
+		common.ResponseFailed(c, http.StatusUnauthorized, nil)
+		return
+	}
+
+	if err := f.feedService.DeleteToken(user); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, nil)
+}
+
+// RegisterRoute registers the routes of feeds, feeds is the group of /feeds.
+func (f *FeedController) RegisterRoute(feeds *gin.RouterGroup) {
+	feeds.GET("/:feed", f.Posts)
+	feeds.GET("/tags/:feed", f.TagPosts)
+	feeds.GET("/categories/:feed", f.CategoryPosts)
+	feeds.POST("/token", f.CreateToken)
+	feeds.DELETE("/token", f.DeleteToken)
+}
+
+func (f *FeedController) Name() string {
+	return "Feed"
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/eastygh/webm-nas/pkg/common"
 	"github.com/eastygh/webm-nas/pkg/model"
@@ -27,17 +28,27 @@ func NewGroupController(groupService service.GroupService) Controller {
 // @Produce json
 // @Tags group
 // @Security JWT
-// @Success 200 {object} common.Response{data=[]model.Group}
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like creatorId=3,name~=foo"
+// @Success 200 {object} common.Response{data=[]model.Group,metadata=model.ListMeta}
 // @Router /api/v1/groups [get]
 func (g *GroupController) List(c *gin.Context) {
 	common.TraceStep(c, "start list group")
-	groups, err := g.groupService.List()
+	opts, err := listOptions(c)
 	if err != nil {
 		common.ResponseFailed(c, http.StatusBadRequest, err)
 		return
 	}
+	groups, meta, err := g.groupService.List(opts)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
 	common.TraceStep(c, "list group done")
-	common.ResponseSuccess(c, groups)
+	common.ResponseList(c, groups, meta)
 }
 
 // @Summary Get group
@@ -51,9 +62,10 @@ func (g *GroupController) List(c *gin.Context) {
 func (g *GroupController) Get(c *gin.Context) {
 	group, err := g.groupService.Get(c.Param("id"))
 	if err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
+	setETag(c, group.Version)
 	common.ResponseSuccess(c, group)
 }
 
@@ -74,8 +86,7 @@ func (g *GroupController) Create(c *gin.Context) {
 	}
 
 	createdGroup := new(model.CreatedGroup)
-	if err := c.BindJSON(createdGroup); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if !bindJSON(c, createdGroup) {
 		return
 	}
 
@@ -89,7 +100,8 @@ func (g *GroupController) Create(c *gin.Context) {
 		return
 	}
 
-	common.ResponseSuccess(c, group)
+	setETag(c, group.Version)
+	responseCreated(c, group, group.ID)
 }
 
 // @Summary Update group
@@ -98,8 +110,9 @@ func (g *GroupController) Create(c *gin.Context) {
 // @Produce json
 // @Tags group
 // @Security JWT
-// @Param group body model.UpdatedUser true "group info"
+// @Param group body model.UpdatedGroup true "group info"
 // @Param id   path      int  true  "group id"
+// @Param If-Match header string false "ETag of the version to change"
 // @Success 200 {object} common.Response{data=model.Group}
 // @Router /api/v1/groups/{id} [put]
 func (g *GroupController) Update(c *gin.Context) {
@@ -112,10 +125,16 @@ func (g *GroupController) Update(c *gin.Context) {
 	id := c.Param("id")
 
 	new := new(model.UpdatedGroup)
-	if err := c.BindJSON(new); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if !bindJSON(c, new) {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
+	if version != 0 {
+		new.Version = version
+	}
 
 	common.TraceStep(c, "start update group", trace.Field{"group", new.Name})
 	defer common.TraceStep(c, "update group done", trace.Field{"group", new.Name})
@@ -126,6 +145,47 @@ func (g *GroupController) Update(c *gin.Context) {
 		return
 	}
 
+	setETag(c, group.Version)
+	common.ResponseSuccess(c, group)
+}
+
+// @Summary Patch group
+// @Description Patch group with a json merge patch or json patch
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Tags group
+// @Security JWT
+// @Param patch body object true "merge patch or json patch"
+// @Param id   path      int  true  "group id"
+// @Param If-Match header string false "ETag of the version to change"
+// @Success 200 {object} common.Response{data=model.Group}
+// @Router /api/v1/groups/{id} [patch]
+func (g *GroupController) Patch(c *gin.Context) {
+	user := common.GetUser(c)
+	if user == nil {
+		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("failed to get user"))
+		return
+	}
+
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	patchType, patch, ok := readPatch(c)
+	if !ok {
+		return
+	}
+
+	common.TraceStep(c, "start patch group", trace.Field{Key: "id", Value: c.Param("id")})
+	defer common.TraceStep(c, "patch group done", trace.Field{Key: "id", Value: c.Param("id")})
+
+	group, err := g.groupService.Patch(user, c.Param("id"), version, patchType, patch)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(c, group.Version)
 	common.ResponseSuccess(c, group)
 }
 
@@ -135,6 +195,7 @@ func (g *GroupController) Update(c *gin.Context) {
 // @Tags group
 // @Security JWT
 // @Param id path int true "group id"
+// @Param If-Match header string false "ETag of the version to change"
 // @Success 200 {object} common.Response
 // @Router /api/v1/groups/{id} [delete]
 func (g *GroupController) Delete(c *gin.Context) {
@@ -144,8 +205,12 @@ func (g *GroupController) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := g.groupService.Delete(c.Param("id")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := g.groupService.Delete(c.Param("id"), version); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -163,11 +228,11 @@ func (g *GroupController) Delete(c *gin.Context) {
 func (g *GroupController) GetUsers(c *gin.Context) {
 	users, err := g.groupService.GetUsers(c.Param("id"))
 	if err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	common.ResponseSuccess(c, users)
+	common.ResponseList(c, users, nil)
 }
 
 // @Summary Add user
@@ -187,7 +252,7 @@ func (g *GroupController) AddUser(c *gin.Context) {
 	}
 
 	if err := g.groupService.AddUser(user, c.Param("id")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -201,16 +266,49 @@ func (g *GroupController) AddUser(c *gin.Context) {
 // @Security JWT
 // @Param id path int true "group id"
 // @Param user body model.User true "user info"
-// @Param name    query     string  true  "user name"
-// @Param name    query     string  true  "user role"
 // @Success 200 {object} common.Response
 // @Router /api/v1/groups/{id}/users [delete]
 func (g *GroupController) DelUser(c *gin.Context) {
 	user := new(model.User)
-	user.Name = c.Query("name")
+	if err := c.BindJSON(user); err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
 
 	if err := g.groupService.DelUser(user, c.Param("id")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	common.ResponseSuccess(c, nil)
+}
+
+// AddMember adds the user of the path to the group, the user of AddUser is in the body.
+func (g *GroupController) AddMember(c *gin.Context) {
+	uid, err := strconv.ParseUint(c.Param("userId"), 10, 0)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("invalid user id %q", c.Param("userId")))
+		return
+	}
+
+	if err := g.groupService.AddUser(&model.User{ID: uint(uid)}, c.Param("id")); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	common.ResponseSuccess(c, nil)
+}
+
+// DelMember deletes the user of the path from the group.
+func (g *GroupController) DelMember(c *gin.Context) {
+	uid, err := strconv.ParseUint(c.Param("userId"), 10, 0)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("invalid user id %q", c.Param("userId")))
+		return
+	}
+
+	if err := g.groupService.DelUser(&model.User{ID: uint(uid)}, c.Param("id")); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -223,12 +321,12 @@ func (g *GroupController) DelUser(c *gin.Context) {
 // @Tags group
 // @Security JWT
 // @Param id path int true "group id"
-// @Param rid path int true "role id"
+// @Param roleId path int true "role id"
 // @Success 200 {object} common.Response
-// @Router /api/v1/groups/{id}/roles/{rid} [post]
+// @Router /api/v1/groups/{id}/roles/{roleId} [post]
 func (g *GroupController) AddRole(c *gin.Context) {
-	if err := g.groupService.AddRole(c.Param("id"), c.Param("rid")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if err := g.groupService.AddRole(c.Param("id"), c.Param("roleId")); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -241,12 +339,12 @@ func (g *GroupController) AddRole(c *gin.Context) {
 // @Tags group
 // @Security JWT
 // @Param id path int true "group id"
-// @Param rid path int true "role id"
+// @Param roleId path int true "role id"
 // @Success 200 {object} common.Response
-// @Router /api/v1/groups/{id}/roles/{rid} [delete]
+// @Router /api/v1/groups/{id}/roles/{roleId} [delete]
 func (g *GroupController) DelRole(c *gin.Context) {
-	if err := g.groupService.DelRole(c.Param("id"), c.Param("rid")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if err := g.groupService.DelRole(c.Param("id"), c.Param("roleId")); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -258,12 +356,27 @@ func (g *GroupController) RegisterRoute(api *gin.RouterGroup) {
 	api.POST("/groups", g.Create)
 	api.GET("/groups/:id", g.Get)
 	api.PUT("/groups/:id", g.Update)
+	api.PATCH("/groups/:id", g.Patch)
 	api.DELETE("/groups/:id", g.Delete)
 	api.GET("/groups/:id/users", g.GetUsers)
 	api.POST("/groups/:id/users", g.AddUser)
 	api.DELETE("/groups/:id/users", g.DelUser)
-	api.POST("/groups/:id/roles/:rid", g.AddRole)
-	api.DELETE("/groups/:id/roles/:rid", g.DelRole)
+	api.POST("/groups/:id/roles/:roleId", g.AddRole)
+	api.DELETE("/groups/:id/roles/:roleId", g.DelRole)
+}
+
+func (g *GroupController) RegisterRouteV2(api *Router) {
+	api.GET("/groups", g.List, RouteV2{Summary: "List groups", Response: []model.Group{}, List: true})
+	api.POST("/groups", g.Create, RouteV2{Summary: "Create group", Body: model.CreatedGroup{}, Response: model.Group{}, Versioned: true})
+	api.GET("/groups/{id}", g.Get, RouteV2{Summary: "Get group", Response: model.Group{}, Versioned: true})
+	api.PUT("/groups/{id}", g.Update, RouteV2{Summary: "Update group", Body: model.UpdatedGroup{}, Response: model.Group{}, Versioned: true})
+	api.PATCH("/groups/{id}", g.Patch, RouteV2{Summary: "Patch group", Response: model.Group{}, Versioned: true})
+	api.DELETE("/groups/{id}", g.Delete, RouteV2{Summary: "Delete group", Versioned: true})
+	api.GET("/groups/{id}/users", g.GetUsers, RouteV2{Summary: "List group users", Response: []model.User{}})
+	api.PUT("/groups/{id}/users/{userId}", g.AddMember, RouteV2{Summary: "Add group user"})
+	api.DELETE("/groups/{id}/users/{userId}", g.DelMember, RouteV2{Summary: "Delete group user"})
+	api.PUT("/groups/{id}/roles/{roleId}", g.AddRole, RouteV2{Summary: "Add group role"})
+	api.DELETE("/groups/{id}/roles/{roleId}", g.DelRole, RouteV2{Summary: "Delete group role"})
 }
 
 func (g *GroupController) Name() string {
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listOptions parses the list query: limit, continue, page, sort and fieldSelector.
+// The limit is at most model.MaxListLimit and defaults to model.DefaultListLimit, except for v1 lists
+// without limit, continue and page which return all objects like before lists were paged.
+func listOptions(c *gin.Context) (*model.ListOptions, error) {
+	opts := &model.ListOptions{
+		Continue: c.Query("continue"),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return nil, apierrors.NewBadRequest(fmt.Errorf("%w: invalid limit %s", model.ErrInvalidListOptions, limit))
+		}
+		if n > model.MaxListLimit {
+			n = model.MaxListLimit
+		}
+		opts.Limit = n
+	}
+	if page := c.Query("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n <= 0 {
+			return nil, apierrors.NewBadRequest(fmt.Errorf("%w: invalid page %s", model.ErrInvalidListOptions, page))
+		}
+		opts.Page = n
+	}
+	if opts.Limit == 0 && (common.GetAPIVersion(c) != common.APIVersionV1 || opts.Continue != "" || opts.Page > 0) {
+		opts.Limit = model.DefaultListLimit
+	}
+	opts.SortBy, opts.Desc = model.ParseSort(c.Query("sort"))
+
+	var err error
+	if opts.Selectors, err = model.ParseSelectors(c.Query("fieldSelector")); err != nil {
+		return nil, apierrors.NewBadRequest(err)
+	}
+	return opts, nil
+}
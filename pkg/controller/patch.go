@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/utils/jsonpatch"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readPatch returns the patch type by the content type of the request and the patch.
+func readPatch(c *gin.Context) (jsonpatch.PatchType, []byte, bool) {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	patchType := jsonpatch.PatchType(mediaType)
+	if patchType != jsonpatch.MergePatchType && patchType != jsonpatch.JSONPatchType {
+		common.ResponseFailed(c, http.StatusUnsupportedMediaType,
+			fmt.Errorf("unsupported content type %q, use %s or %s", mediaType, jsonpatch.MergePatchType, jsonpatch.JSONPatchType))
+		return "", nil, false
+	}
+
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return "", nil, false
+	}
+	return patchType, patch, true
+}
@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/eastygh/webm-nas/pkg/analytics"
+	"github.com/eastygh/webm-nas/pkg/authorization"
 	"github.com/eastygh/webm-nas/pkg/common"
 	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/openapi"
 	"github.com/eastygh/webm-nas/pkg/service"
+	"github.com/eastygh/webm-nas/pkg/utils/request"
 	"github.com/eastygh/webm-nas/pkg/utils/trace"
 
 	"github.com/gin-gonic/gin"
@@ -27,17 +31,76 @@ func NewPostController(postService service.PostService) Controller {
 // @Produce json
 // @Tags post
 // @Security JWT
-// @Success 200 {object} common.Response{data=[]model.Post}
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like creatorId=3,status=draft,name~=foo"
+// @Success 200 {object} common.Response{data=[]model.Post,metadata=model.ListMeta}
 // @Router /api/v1/posts [get]
 func (p *PostController) List(c *gin.Context) {
 	common.TraceStep(c, "start list post")
-	posts, err := p.postService.List()
+	opts, err := listOptions(c)
 	if err != nil {
 		common.ResponseFailed(c, http.StatusBadRequest, err)
 		return
 	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	posts, meta, err := p.postService.List(opts, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
 	common.TraceStep(c, "list post done")
-	common.ResponseSuccess(c, posts)
+	common.ResponseList(c, posts, meta)
+}
+
+// @Summary Search post
+// @Description Search posts by name, content, summary, tags, categories and comments, best first
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param q query string true "words to search, the last word also matches as a prefix"
+// @Param tag query string false "only posts with the tag"
+// @Param category query string false "only posts in the category"
+// @Param author query string false "only posts of the user"
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Success 200 {object} common.Response{data=model.SearchResult}
+// @Router /api/v1/posts/search [get]
+func (p *PostController) Search(c *gin.Context) {
+	opts, err := listOptions(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	// search results are always paged
+	if opts.Limit == 0 {
+		opts.Limit = model.DefaultListLimit
+	}
+	query := &model.SearchQuery{
+		Q:        c.Query("q"),
+		Tag:      c.Query("tag"),
+		Category: c.Query("category"),
+		Author:   c.Query("author"),
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	result, err := p.postService.Search(query, opts, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, result)
 }
 
 // @Summary Get post
@@ -55,11 +118,18 @@ func (p *PostController) Get(c *gin.Context) {
 		return
 	}
 
-	post, err := p.postService.Get(user, c.Param("id"))
+	reader, err := postReader(c)
 	if err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
+
+	post, err := p.postService.Get(user, c.Param("id"), reader, analytics.Viewer(user, c.ClientIP()))
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	setETag(c, post.Version)
 	common.ResponseSuccess(c, post)
 }
 
@@ -94,7 +164,8 @@ func (p *PostController) Create(c *gin.Context) {
 		return
 	}
 
-	common.ResponseSuccess(c, post)
+	setETag(c, post.Version)
+	responseCreated(c, post, post.ID)
 }
 
 // @Summary Update post
@@ -103,8 +174,9 @@ func (p *PostController) Create(c *gin.Context) {
 // @Produce json
 // @Tags post
 // @Security JWT
-// @Param post body model.UpdatedUser true "post info"
+// @Param post body model.Post true "post info"
 // @Param id   path      int  true  "post id"
+// @Param If-Match header string false "ETag of the version to change"
 // @Success 200 {object} common.Response{data=model.Post}
 // @Router /api/v1/posts/{id} [put]
 func (p *PostController) Update(c *gin.Context) {
@@ -121,16 +193,64 @@ func (p *PostController) Update(c *gin.Context) {
 		common.ResponseFailed(c, http.StatusBadRequest, err)
 		return
 	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if version != 0 {
+		new.Version = version
+	}
 
 	common.TraceStep(c, "start update post", trace.Field{"post", new.Name})
 	defer common.TraceStep(c, "update post done", trace.Field{"post", new.Name})
 
-	post, err := p.postService.Update(id, new)
+	post, err := p.postService.Update(user, id, new)
 	if err != nil {
 		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(c, post.Version)
+	common.ResponseSuccess(c, post)
+}
+
+// @Summary Patch post
+// @Description Patch post with a json merge patch or json patch
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param patch body object true "merge patch or json patch"
+// @Param id   path      int  true  "post id"
+// @Param If-Match header string false "ETag of the version to change"
+// @Success 200 {object} common.Response{data=model.Post}
+// @Router /api/v1/posts/{id} [patch]
+func (p *PostController) Patch(c *gin.Context) {
+	user := common.GetUser(c)
+	if user == nil {
+		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("failed to get user"))
+		return
+	}
+
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	patchType, patch, ok := readPatch(c)
+	if !ok {
+		return
+	}
+
+	common.TraceStep(c, "start patch post", trace.Field{Key: "id", Value: c.Param("id")})
+	defer common.TraceStep(c, "patch post done", trace.Field{Key: "id", Value: c.Param("id")})
+
+	post, err := p.postService.Patch(user, c.Param("id"), version, patchType, patch)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(c, post.Version)
 	common.ResponseSuccess(c, post)
 }
 
@@ -140,6 +260,7 @@ func (p *PostController) Update(c *gin.Context) {
 // @Tags post
 // @Security JWT
 // @Param id path int true "post id"
+// @Param If-Match header string false "ETag of the version to change"
 // @Success 200 {object} common.Response
 // @Router /api/v1/posts/{id} [delete]
 func (p *PostController) Delete(c *gin.Context) {
@@ -149,14 +270,205 @@ func (p *PostController) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := p.postService.Delete(c.Param("id")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := p.postService.Delete(c.Param("id"), version); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	common.ResponseSuccess(c, nil)
 }
 
+// @Summary Publish post
+// @Description Publish the post now, or schedule it if publishAt is in the future. Only the creator and editors may publish a post
+// @Accept json
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param If-Match header string false "ETag of the version to change"
+// @Param options body model.PublishOptions false "publish options"
+// @Success 200 {object} common.Response{data=model.Post}
+// @Router /api/v1/posts/{id}/publish [post]
+func (p *PostController) Publish(c *gin.Context) {
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	opts := &model.PublishOptions{}
+	if c.Request.ContentLength != 0 && !bindJSON(c, opts) {
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	post, err := p.postService.Publish(c.Param("id"), version, reader, opts)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	setETag(c, post.Version)
+	common.ResponseSuccess(c, post)
+}
+
+// @Summary Unpublish post
+// @Description Make the post a draft, or archive it. Only the creator and editors may unpublish a post
+// @Accept json
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param If-Match header string false "ETag of the version to change"
+// @Param options body model.UnpublishOptions false "unpublish options"
+// @Success 200 {object} common.Response{data=model.Post}
+// @Router /api/v1/posts/{id}/unpublish [post]
+func (p *PostController) Unpublish(c *gin.Context) {
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	opts := &model.UnpublishOptions{}
+	if c.Request.ContentLength != 0 && !bindJSON(c, opts) {
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	post, err := p.postService.Unpublish(c.Param("id"), version, reader, opts)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	setETag(c, post.Version)
+	common.ResponseSuccess(c, post)
+}
+
+// @Summary List post revisions
+// @Description List the revisions of the post without their content, the latest first
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like authorId=3"
+// @Success 200 {object} common.Response{data=[]model.PostRevision,metadata=model.ListMeta}
+// @Router /api/v1/posts/{id}/revisions [get]
+func (p *PostController) ListRevisions(c *gin.Context) {
+	opts, err := listOptions(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	revisions, meta, err := p.postService.ListRevisions(c.Param("id"), opts, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseList(c, revisions, meta)
+}
+
+// @Summary Get post revision
+// @Description Get a revision of the post
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param revision path int true "revision number"
+// @Success 200 {object} common.Response{data=model.PostRevision}
+// @Router /api/v1/posts/{id}/revisions/{revision} [get]
+func (p *PostController) GetRevision(c *gin.Context) {
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	revision, err := p.postService.GetRevision(c.Param("id"), c.Param("revision"), reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, revision)
+}
+
+// @Summary Diff post revisions
+// @Description Line diff of the fields changed from a revision to another one, revision 0 is an empty post
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param from query int false "old revision, default the revision before to"
+// @Param to query int false "new revision, default the latest revision"
+// @Success 200 {object} common.Response{data=model.RevisionDiff}
+// @Router /api/v1/posts/{id}/revisions/diff [get]
+func (p *PostController) DiffRevisions(c *gin.Context) {
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	result, err := p.postService.DiffRevisions(c.Param("id"), c.Query("from"), c.Query("to"), reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, result)
+}
+
+// @Summary Restore post revision
+// @Description Update the post to the revision, which is saved as a new revision. Only the creator and editors may restore a revision
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param revision path int true "revision number"
+// @Param If-Match header string false "ETag of the version to change"
+// @Success 200 {object} common.Response{data=model.Post}
+// @Router /api/v1/posts/{id}/revisions/{revision}/restore [post]
+func (p *PostController) RestoreRevision(c *gin.Context) {
+	user := common.GetUser(c)
+	if user == nil {
+		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("failed to get user"))
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	post, err := p.postService.RestoreRevision(user, c.Param("id"), c.Param("revision"), version, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	setETag(c, post.Version)
+	common.ResponseSuccess(c, post)
+}
+
 // @Summary Add Like
 // @Description Add Like
 // @Produce json
@@ -171,9 +483,14 @@ func (p *PostController) AddLike(c *gin.Context) {
 		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("failed to get user"))
 		return
 	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
 
-	if err := p.postService.AddLike(user, c.Param("id")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if err := p.postService.AddLike(user, c.Param("id"), reader); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -194,9 +511,14 @@ func (p *PostController) DelLike(c *gin.Context) {
 		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("failed to get user"))
 		return
 	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
 
-	if err := p.postService.DelLike(user, c.Param("id")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if err := p.postService.DelLike(user, c.Param("id"), reader); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -204,12 +526,14 @@ func (p *PostController) DelLike(c *gin.Context) {
 }
 
 // @Summary Add Comment
-// @Description Add Comment
+// @Description Add a comment, or a reply to the comment parentId. Comments of new users are pending until they are approved
+// @Accept json
 // @Produce json
 // @Tags post
 // @Security JWT
 // @Param id path int true "post id"
-// @Success 200 {object} common.Response
+// @Param comment body model.Comment true "comment content and parentId"
+// @Success 200 {object} common.Response{data=model.Comment}
 // @Router /api/v1/posts/{id}/comment [post]
 func (p *PostController) AddComment(c *gin.Context) {
 	user := common.GetUser(c)
@@ -219,50 +543,386 @@ func (p *PostController) AddComment(c *gin.Context) {
 	}
 
 	comment := &model.Comment{}
-	if err := c.Bind(comment); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("failed to get comment: %v", err))
+	if !bindJSON(c, comment) {
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	comment, err := p.postService.AddComment(user, c.Param("id"), comment)
+	comment, err = p.postService.AddComment(user, c.Param("id"), comment, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	responseCreated(c, comment, comment.ID)
+}
+
+// @Summary List Comments
+// @Description List the comments on the post with their replies as trees, pages only count the comments on the post
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like userId=3"
+// @Success 200 {object} common.Response{data=[]model.Comment,metadata=model.ListMeta}
+// @Router /api/v1/posts/{id}/comment [get]
+func (p *PostController) ListComments(c *gin.Context) {
+	opts, err := listOptions(c)
 	if err != nil {
 		common.ResponseFailed(c, http.StatusBadRequest, err)
 		return
 	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
 
+	comments, meta, err := p.postService.ListComments(c.Param("id"), opts, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseList(c, comments, meta)
+}
+
+// @Summary Update Comment
+// @Description Edit the content of the comment, the comment has the time of the last edit
+// @Accept json
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param commentId path int true "comment id"
+// @Param comment body model.CommentUpdate true "comment content"
+// @Success 200 {object} common.Response{data=model.Comment}
+// @Router /api/v1/posts/{id}/comment/{commentId} [put]
+func (p *PostController) UpdateComment(c *gin.Context) {
+	user := common.GetUser(c)
+	if user == nil {
+		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("failed to get user"))
+		return
+	}
+
+	update := &model.CommentUpdate{}
+	if !bindJSON(c, update) {
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	comment, err := p.postService.UpdateComment(user, c.Param("id"), c.Param("commentId"), update, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
 	common.ResponseSuccess(c, comment)
 }
 
+// @Summary Approve Comment
+// @Description Approve a pending or rejected comment, only editors may moderate comments
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param commentId path int true "comment id"
+// @Success 200 {object} common.Response{data=model.Comment}
+// @Router /api/v1/posts/{id}/comment/{commentId}/approve [post]
+func (p *PostController) ApproveComment(c *gin.Context) {
+	p.moderateComment(c, p.postService.ApproveComment)
+}
+
+// @Summary Reject Comment
+// @Description Reject a comment, it is only visible to its author and editors. Only editors may moderate comments
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param commentId path int true "comment id"
+// @Success 200 {object} common.Response{data=model.Comment}
+// @Router /api/v1/posts/{id}/comment/{commentId}/reject [post]
+func (p *PostController) RejectComment(c *gin.Context) {
+	p.moderateComment(c, p.postService.RejectComment)
+}
+
+func (p *PostController) moderateComment(c *gin.Context, moderate func(pid, cid string, reader model.PostReader) (*model.Comment, error)) {
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	comment, err := moderate(c.Param("id"), c.Param("commentId"), reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, comment)
+}
+
+// @Summary List Comments to moderate
+// @Description List the comments of all posts by moderation status, oldest first. Only editors may moderate comments
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param status query string false "pending, approved or rejected, default pending"
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like postId=3"
+// @Success 200 {object} common.Response{data=[]model.Comment,metadata=model.ListMeta}
+// @Router /api/v1/posts/comments [get]
+func (p *PostController) ListCommentsByStatus(c *gin.Context) {
+	opts, err := listOptions(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	comments, meta, err := p.postService.ListCommentsByStatus(c.Query("status"), opts, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseList(c, comments, meta)
+}
+
 // @Summary Delete Comment
-// @Description Delete Comment
+// @Description Delete Comment, a comment with replies is kept without its content
 // @Produce json
 // @Tags post
 // @Security JWT
 // @Param id path int true "post id"
-// @Param cid path int true "comment id"
+// @Param commentId path int true "comment id"
 // @Success 200 {object} common.Response
-// @Router /api/v1/posts/{id}/comment/${cid} [delete]
+// @Router /api/v1/posts/{id}/comment/{commentId} [delete]
 func (p *PostController) DelComment(c *gin.Context) {
-	if err := p.postService.DelComment(c.Param("cid")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if err := p.postService.DelComment(c.Param("id"), c.Param("commentId")); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	common.ResponseSuccess(c, nil)
 }
 
+// @Summary Get post tags
+// @Description Get the tags of the post
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Success 200 {object} common.Response{data=[]model.Tag}
+// @Router /api/v1/posts/{id}/tags [get]
+func (p *PostController) GetTags(c *gin.Context) {
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	tags, err := p.postService.GetTags(c.Param("id"), reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseList(c, tags, nil)
+}
+
+// @Summary Get post categories
+// @Description Get the categories of the post
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Success 200 {object} common.Response{data=[]model.Category}
+// @Router /api/v1/posts/{id}/categories [get]
+func (p *PostController) GetCategories(c *gin.Context) {
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	categories, err := p.postService.GetCategories(c.Param("id"), reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseList(c, categories, nil)
+}
+
 func (p *PostController) RegisterRoute(api *gin.RouterGroup) {
 	api.GET("/posts", p.List)
 	api.POST("/posts", p.Create)
+	api.GET("/posts/search", p.Search)
+	api.GET("/posts/comments", p.ListCommentsByStatus)
 	api.GET("/posts/:id", p.Get)
 	api.PUT("/posts/:id", p.Update)
+	api.PATCH("/posts/:id", p.Patch)
 	api.DELETE("/posts/:id", p.Delete)
+	api.POST("/posts/:id/publish", p.Publish)
+	api.POST("/posts/:id/unpublish", p.Unpublish)
+	api.GET("/posts/:id/revisions", p.ListRevisions)
+	api.GET("/posts/:id/revisions/diff", p.DiffRevisions)
+	api.GET("/posts/:id/revisions/:revision", p.GetRevision)
+	api.POST("/posts/:id/revisions/:revision/restore", p.RestoreRevision)
+	api.GET("/posts/:id/tags", p.GetTags)
+	api.GET("/posts/:id/categories", p.GetCategories)
 	api.POST("/posts/:id/like", p.AddLike)
 	api.DELETE("/posts/:id/like", p.DelLike)
+	api.GET("/posts/:id/comment", p.ListComments)
 	api.POST("/posts/:id/comment", p.AddComment)
-	api.DELETE("/posts/:id/comment/:cid", p.DelLike)
+	api.PUT("/posts/:id/comment/:commentId", p.UpdateComment)
+	api.DELETE("/posts/:id/comment/:commentId", p.DelComment)
+	api.POST("/posts/:id/comment/:commentId/approve", p.ApproveComment)
+	api.POST("/posts/:id/comment/:commentId/reject", p.RejectComment)
+}
+
+func (p *PostController) RegisterRouteV2(api *Router) {
+	api.GET("/posts", p.List, RouteV2{Summary: "List posts", Response: []model.Post{}, List: true})
+	api.POST("/posts", p.Create, RouteV2{Summary: "Create post", Body: model.Post{}, Response: model.Post{}, Versioned: true})
+	api.GET("/posts/search", p.Search, RouteV2{
+		Summary:     "Search posts",
+		Description: "Search posts by name, content, summary, tags, categories and comments, best first",
+		Response:    model.SearchResult{},
+		Query: append([]openapi.Parameter{
+			{Name: "q", In: "query", Required: true, Description: "words to search, the last word also matches as a prefix", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "tag", In: "query", Description: "only posts with the tag", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "category", In: "query", Description: "only posts in the category", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "author", In: "query", Description: "only posts of the user", Schema: &openapi.Schema{Type: "string"}},
+		}, listParameters()[:3]...),
+	})
+	api.GET("/posts/comments", p.ListCommentsByStatus, RouteV2{
+		Summary:     "List comments to moderate",
+		Description: "List the comments of all posts by moderation status, oldest first. Only editors may moderate comments",
+		Response:    []model.Comment{},
+		List:        true,
+		Query: []openapi.Parameter{
+			{Name: "status", In: "query", Description: "pending, approved or rejected, default pending",
+				Schema: &openapi.Schema{Type: "string", Enum: []string{"pending", "approved", "rejected"}}},
+		},
+	})
+	api.GET("/posts/{id}", p.Get, RouteV2{Summary: "Get post", Response: model.Post{}, Versioned: true})
+	api.PUT("/posts/{id}", p.Update, RouteV2{Summary: "Update post", Body: model.Post{}, Response: model.Post{}, Versioned: true})
+	api.PATCH("/posts/{id}", p.Patch, RouteV2{Summary: "Patch post", Response: model.Post{}, Versioned: true})
+	api.DELETE("/posts/{id}", p.Delete, RouteV2{Summary: "Delete post", Versioned: true})
+	api.POST("/posts/{id}/publish", p.Publish, RouteV2{
+		Summary:     "Publish post",
+		Description: "Publish the post now, or schedule it if publishAt is in the future. Only the creator and editors may publish a post",
+		Body:        model.PublishOptions{},
+		Response:    model.Post{},
+		Status:      http.StatusOK,
+		Versioned:   true,
+	})
+	api.POST("/posts/{id}/unpublish", p.Unpublish, RouteV2{
+		Summary:     "Unpublish post",
+		Description: "Make the post a draft, or archive it. Only the creator and editors may unpublish a post",
+		Body:        model.UnpublishOptions{},
+		Response:    model.Post{},
+		Status:      http.StatusOK,
+		Versioned:   true,
+	})
+	api.GET("/posts/{id}/revisions", p.ListRevisions, RouteV2{
+		Summary:     "List post revisions",
+		Description: "List the revisions of the post without their content, the latest first",
+		Response:    []model.PostRevision{},
+		List:        true,
+	})
+	api.GET("/posts/{id}/revisions/diff", p.DiffRevisions, RouteV2{
+		Summary:     "Diff post revisions",
+		Description: "Line diff of the fields changed from a revision to another one, revision 0 is an empty post",
+		Response:    model.RevisionDiff{},
+		Query: []openapi.Parameter{
+			{Name: "from", In: "query", Description: "old revision, default the revision before to", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "to", In: "query", Description: "new revision, default the latest revision", Schema: &openapi.Schema{Type: "integer"}},
+		},
+	})
+	api.GET("/posts/{id}/revisions/{revision}", p.GetRevision, RouteV2{Summary: "Get post revision", Response: model.PostRevision{}})
+	api.POST("/posts/{id}/revisions/{revision}/restore", p.RestoreRevision, RouteV2{
+		Summary:     "Restore post revision",
+		Description: "Update the post to the revision, which is saved as a new revision. Only the creator and editors may restore a revision",
+		Response:    model.Post{},
+		Status:      http.StatusOK,
+		Versioned:   true,
+	})
+	api.GET("/posts/{id}/tags", p.GetTags, RouteV2{Summary: "List post tags", Response: []model.Tag{}})
+	api.GET("/posts/{id}/categories", p.GetCategories, RouteV2{Summary: "List post categories", Response: []model.Category{}})
+	api.PUT("/posts/{id}/like", p.AddLike, RouteV2{Summary: "Like post"})
+	api.DELETE("/posts/{id}/like", p.DelLike, RouteV2{Summary: "Unlike post"})
+	api.GET("/posts/{id}/comments", p.ListComments, RouteV2{
+		Summary:     "List comments",
+		Description: "List the comments on the post with their replies as trees, pages only count the comments on the post",
+		Response:    []model.Comment{},
+		List:        true,
+	})
+	api.POST("/posts/{id}/comments", p.AddComment, RouteV2{
+		Summary:     "Add comment",
+		Description: "Add a comment, or a reply to the comment parentId. Comments of new users are pending until they are approved",
+		Body:        model.Comment{},
+		Response:    model.Comment{},
+	})
+	api.PUT("/posts/{id}/comments/{commentId}", p.UpdateComment, RouteV2{
+		Summary:  "Update comment",
+		Body:     model.CommentUpdate{},
+		Response: model.Comment{},
+	})
+	api.DELETE("/posts/{id}/comments/{commentId}", p.DelComment, RouteV2{
+		Summary:     "Delete comment",
+		Description: "Delete the comment, a comment with replies is kept without its content",
+	})
+	api.POST("/posts/{id}/comments/{commentId}/approve", p.ApproveComment, RouteV2{
+		Summary:     "Approve comment",
+		Description: "Approve a pending or rejected comment, only editors may moderate comments",
+		Response:    model.Comment{},
+		Status:      http.StatusOK,
+	})
+	api.POST("/posts/{id}/comments/{commentId}/reject", p.RejectComment, RouteV2{
+		Summary:     "Reject comment",
+		Description: "Reject a comment, it is only visible to its author and editors. Only editors may moderate comments",
+		Response:    model.Comment{},
+		Status:      http.StatusOK,
+	})
 }
 
 func (p *PostController) Name() string {
 	return "Post"
 }
+
+// postReader returns the reader of posts of the request, users allowed to moderate posts are editors.
+// The roles resolved by the authorization of the request are used if there are any.
+func postReader(c *gin.Context) (model.PostReader, error) {
+	user := common.GetUser(c)
+	if user == nil {
+		user = &model.User{}
+	}
+	ri := &request.RequestInfo{IsResourceRequest: true, Resource: model.PostResource}
+	if current := common.GetRequestInfo(c); current != nil {
+		ri.Namespace = current.Namespace
+	}
+
+	roles := common.GetRoles(c)
+	if roles == nil {
+		var err error
+		if roles, err = authorization.ResolveRoles(user); err != nil {
+			return model.PostReader{UserID: user.ID}, err
+		}
+	}
+	return model.PostReader{UserID: user.ID, Editor: roles.AuthorizeVerb(ri, string(model.ModerateOperation))}, nil
+}
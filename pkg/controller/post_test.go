@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/authorization"
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPostReaderRoles(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	repo := repository.NewRepository(db)
+	require.Nil(t, repo.Migrate())
+	require.Nil(t, repo.Init())
+	require.Nil(t, authorization.InitAuthorization(repo))
+
+	moderator, err := repo.RBAC().Create(&model.Role{Name: "moderator", Scope: model.ClusterScope, Rules: model.Rules{{Resource: model.PostResource, Operation: model.ModerateOperation}}})
+	require.Nil(t, err)
+	alice, err := repo.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	require.Nil(t, repo.User().AddRole(moderator, alice))
+
+	queries := 0
+	require.Nil(t, db.Callback().Query().Before("gorm:query").Register("test:count", func(*gorm.DB) { queries++ }))
+	newContext := func() *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		common.SetUser(c, alice)
+		return c
+	}
+
+	// the roles of the user are resolved without roles of the request
+	reader, err := postReader(newContext())
+	require.Nil(t, err)
+	assert.Equal(t, model.PostReader{UserID: alice.ID, Editor: true}, reader)
+	assert.NotZero(t, queries)
+
+	// the roles resolved by the authorization of the request are used without queries
+	roles, err := authorization.ResolveRoles(alice)
+	require.Nil(t, err)
+	c := newContext()
+	common.SetRoles(c, roles)
+	queries = 0
+	reader, err = postReader(c)
+	require.Nil(t, err)
+	assert.Equal(t, model.PostReader{UserID: alice.ID, Editor: true}, reader)
+	assert.Zero(t, queries)
+}
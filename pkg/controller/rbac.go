@@ -1,21 +1,32 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 
+	"github.com/eastygh/webm-nas/pkg/apierrors"
 	"github.com/eastygh/webm-nas/pkg/common"
 	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/openapi"
 	"github.com/eastygh/webm-nas/pkg/service"
 
 	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v2"
 )
 
+// maxPolicySize is the largest policy which can be applied, exports of large servers are far smaller.
+const maxPolicySize = 4 << 20
+
 type RBACController struct {
-	rbacService service.RBACService
+	rbacService   service.RBACService
+	policyService service.RBACPolicyService
 }
 
-func NewRbacController(rbacService service.RBACService) Controller {
-	return &RBACController{rbacService: rbacService}
+func NewRbacController(rbacService service.RBACService, policyService service.RBACPolicyService) Controller {
+	return &RBACController{rbacService: rbacService, policyService: policyService}
 }
 
 // @Summary List rbac role
@@ -23,16 +34,26 @@ func NewRbacController(rbacService service.RBACService) Controller {
 // @Produce json
 // @Tags rbac
 // @Security JWT
-// @Success 200 {object} common.Response{data=[]model.Role}
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like creatorId=3,name~=foo"
+// @Success 200 {object} common.Response{data=[]model.Role,metadata=model.ListMeta}
 // @Router /api/v1/roles [get]
 func (rbac *RBACController) List(c *gin.Context) {
-	roles, err := rbac.rbacService.List()
+	opts, err := listOptions(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	roles, meta, err := rbac.rbacService.List(opts)
 	if err != nil {
 		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	common.ResponseSuccess(c, roles)
+	common.ResponseList(c, roles, meta)
 }
 
 // @Summary Create rbac role
@@ -46,7 +67,11 @@ func (rbac *RBACController) List(c *gin.Context) {
 // @Router /api/v1/roles [post]
 func (rbac *RBACController) Create(c *gin.Context) {
 	role := &model.Role{}
-	if err := c.BindJSON(role); err != nil {
+	if !bindJSON(c, role) {
+		return
+	}
+
+	if err := rbac.rbacService.Validate(role); err != nil {
 		common.ResponseFailed(c, http.StatusBadRequest, err)
 		return
 	}
@@ -57,7 +82,8 @@ func (rbac *RBACController) Create(c *gin.Context) {
 		return
 	}
 
-	common.ResponseSuccess(c, role)
+	setETag(c, role.Version)
+	responseCreated(c, role, role.ID)
 }
 
 // @Summary Get role
@@ -71,9 +97,10 @@ func (rbac *RBACController) Create(c *gin.Context) {
 func (rbac *RBACController) Get(c *gin.Context) {
 	role, err := rbac.rbacService.Get(c.Param("id"))
 	if err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
+	setETag(c, role.Version)
 	common.ResponseSuccess(c, role)
 }
 
@@ -86,13 +113,25 @@ func (rbac *RBACController) Get(c *gin.Context) {
 // @Param role body model.Role true "rbac role info"
 // @Success 200 {object} common.Response
 // @Param id path int true "role id"
-// @Router /api/v1/roles/:id [put]
+// @Param If-Match header string false "ETag of the version to change"
+// @Router /api/v1/roles/{id} [put]
 func (rbac *RBACController) Update(c *gin.Context) {
 	role := &model.Role{}
-	if err := c.BindJSON(role); err != nil {
+	if !bindJSON(c, role) {
+		return
+	}
+
+	if err := rbac.rbacService.Validate(role); err != nil {
 		common.ResponseFailed(c, http.StatusBadRequest, err)
 		return
 	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if version != 0 {
+		role.Version = version
+	}
 
 	id := c.Param("id")
 	role, err := rbac.rbacService.Update(id, role)
@@ -101,6 +140,38 @@ func (rbac *RBACController) Update(c *gin.Context) {
 		return
 	}
 
+	setETag(c, role.Version)
+	common.ResponseSuccess(c, role)
+}
+
+// @Summary Patch rbac role
+// @Description Patch rbac role with a json merge patch or json patch
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Tags rbac
+// @Security JWT
+// @Param patch body object true "merge patch or json patch"
+// @Param id path int true "role id"
+// @Param If-Match header string false "ETag of the version to change"
+// @Success 200 {object} common.Response{data=model.Role}
+// @Router /api/v1/roles/{id} [patch]
+func (rbac *RBACController) Patch(c *gin.Context) {
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	patchType, patch, ok := readPatch(c)
+	if !ok {
+		return
+	}
+
+	role, err := rbac.rbacService.Patch(c.Param("id"), version, patchType, patch)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(c, role.Version)
 	common.ResponseSuccess(c, role)
 }
 
@@ -110,11 +181,16 @@ func (rbac *RBACController) Update(c *gin.Context) {
 // @Tags rbac
 // @Security JWT
 // @Param id path int true "role id"
+// @Param If-Match header string false "ETag of the version to change"
 // @Success 200 {object} common.Response
 // @Router /api/v1/roles/{id} [delete]
 func (rbac *RBACController) Delete(c *gin.Context) {
-	if err := rbac.rbacService.Delete(c.Param("id")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := rbac.rbacService.Delete(c.Param("id"), version); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -131,11 +207,11 @@ func (rbac *RBACController) Delete(c *gin.Context) {
 func (rbac *RBACController) ListResources(c *gin.Context) {
 	data, err := rbac.rbacService.ListResources()
 	if err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	common.ResponseSuccess(c, data)
+	common.ResponseList(c, data, nil)
 }
 
 // @Summary List operations
@@ -148,11 +224,75 @@ func (rbac *RBACController) ListResources(c *gin.Context) {
 func (rbac *RBACController) ListOperations(c *gin.Context) {
 	data, err := rbac.rbacService.ListOperations()
 	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	common.ResponseList(c, data, nil)
+}
+
+// @Summary Export rbac policy
+// @Description Export roles, groups, memberships and role bindings as yaml
+// @Produce application/x-yaml
+// @Tags rbac
+// @Security JWT
+// @Success 200 {object} model.RBACPolicy
+// @Router /api/v1/rbac/export [get]
+func (rbac *RBACController) Export(c *gin.Context) {
+	policy, err := rbac.policyService.Export()
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	data, err := yaml.Marshal(policy)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/x-yaml; charset=utf-8", data)
+}
+
+// @Summary Apply rbac policy
+// @Description Make roles, groups and bindings match a yaml (or json) policy, return the plan
+// @Accept application/x-yaml
+// @Produce json
+// @Tags rbac
+// @Security JWT
+// @Param policy body model.RBACPolicy true "rbac policy"
+// @Param dryRun query bool false "only show the plan"
+// @Param prune query bool false "delete objects not in the policy"
+// @Success 200 {object} common.Response{data=model.RBACPlan}
+// @Router /api/v1/rbac/apply [post]
+func (rbac *RBACController) Apply(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPolicySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = apierrors.NewRequestEntityTooLarge(fmt.Errorf("policies can be at most %d bytes", maxPolicySize))
+		}
 		common.ResponseFailed(c, http.StatusBadRequest, err)
 		return
 	}
 
-	common.ResponseSuccess(c, data)
+	policy := &model.RBACPolicy{}
+	if err := yaml.UnmarshalStrict(body, policy); err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+
+	opts := model.ApplyOptions{}
+	opts.DryRun, _ = strconv.ParseBool(c.Query("dryRun"))
+	opts.Prune, _ = strconv.ParseBool(c.Query("prune"))
+
+	plan, err := rbac.policyService.Apply(policy, opts)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	common.ResponseSuccess(c, plan)
 }
 
 func (rbac *RBACController) RegisterRoute(api *gin.RouterGroup) {
@@ -160,9 +300,41 @@ func (rbac *RBACController) RegisterRoute(api *gin.RouterGroup) {
 	api.POST("/roles", rbac.Create)
 	api.GET("/roles/:id", rbac.Get)
 	api.PUT("/roles/:id", rbac.Update)
+	api.PATCH("/roles/:id", rbac.Patch)
 	api.DELETE("/roles/:id", rbac.Delete)
 	api.GET("/resources", rbac.ListResources)
 	api.GET("/operations", rbac.ListOperations)
+	api.GET("/rbac/export", rbac.Export)
+	api.POST("/rbac/apply", rbac.Apply)
+}
+
+func (rbac *RBACController) RegisterRouteV2(api *Router) {
+	api.GET("/roles", rbac.List, RouteV2{Summary: "List roles", Response: []model.Role{}, List: true})
+	api.POST("/roles", rbac.Create, RouteV2{Summary: "Create role", Body: model.Role{}, Response: model.Role{}, Versioned: true})
+	api.GET("/roles/{id}", rbac.Get, RouteV2{Summary: "Get role", Response: model.Role{}, Versioned: true})
+	api.PUT("/roles/{id}", rbac.Update, RouteV2{Summary: "Update role", Body: model.Role{}, Response: model.Role{}, Versioned: true})
+	api.PATCH("/roles/{id}", rbac.Patch, RouteV2{Summary: "Patch role", Response: model.Role{}, Versioned: true})
+	api.DELETE("/roles/{id}", rbac.Delete, RouteV2{Summary: "Delete role", Versioned: true})
+	api.GET("/resources", rbac.ListResources, RouteV2{Summary: "List resources", Response: []model.Resource{}})
+	api.GET("/operations", rbac.ListOperations, RouteV2{Summary: "List operations", Response: []model.Operation{}})
+	api.GET("/rbac/export", rbac.Export, RouteV2{
+		Summary:             "Export rbac policy",
+		Description:         "Export roles, groups, memberships and role bindings as yaml",
+		Response:            model.RBACPolicy{},
+		ResponseContentType: "application/x-yaml",
+	})
+	api.POST("/rbac/apply", rbac.Apply, RouteV2{
+		Summary:         "Apply rbac policy",
+		Description:     "Make roles, groups and bindings match a yaml (or json) policy, return the plan",
+		Body:            model.RBACPolicy{},
+		BodyContentType: "application/x-yaml",
+		Response:        model.RBACPlan{},
+		Status:          http.StatusOK,
+		Query: []openapi.Parameter{
+			{Name: "dryRun", In: "query", Description: "only show the plan", Schema: &openapi.Schema{Type: "boolean"}},
+			{Name: "prune", In: "query", Description: "delete objects not in the policy", Schema: &openapi.Schema{Type: "boolean"}},
+		},
+	})
 }
 
 func (rbac *RBACController) Name() string {
@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteV2 describes a route of api v2 in the openapi document.
+type RouteV2 struct {
+	Summary     string
+	Description string
+	// Body is a value of the type of the request body, like model.CreatedUser{}
+	Body interface{}
+	// BodyContentType defaults to application/json, the body of PATCH is a merge patch or json patch
+	BodyContentType string
+	// Response is a value of the type of the response body, a route without response responds 204 No Content.
+	// Slices are responded as lists, like {"items": [...], "metadata": {...}}
+	Response interface{}
+	// ResponseContentType defaults to application/json
+	ResponseContentType string
+	// Status of a successful response with a body, defaults to 201 for POST and 200 for others
+	Status int
+	// List adds the paging, sorting and filtering query of lists
+	List bool
+	// Versioned adds the If-Match header to the request and the ETag header to the response
+	Versioned bool
+	// Public routes do not need a token
+	Public bool
+	// Query are the query parameters besides those of lists
+	Query []openapi.Parameter
+}
+
+// ControllerV2 is a controller which also serves api v2.
+type ControllerV2 interface {
+	Controller
+	RegisterRouteV2(*Router)
+}
+
+// Router registers the routes of api v2 and describes them in the openapi document,
+// paths use braces for parameters like /posts/{id}.
+type Router struct {
+	group  *gin.RouterGroup
+	prefix string
+	doc    *openapi.Document
+}
+
+func NewRouter(group *gin.RouterGroup, doc *openapi.Document) *Router {
+	return &Router{group: group, prefix: group.BasePath(), doc: doc}
+}
+
+func (r *Router) GET(path string, handler gin.HandlerFunc, route RouteV2) {
+	r.Handle(http.MethodGet, path, handler, route)
+}
+
+func (r *Router) POST(path string, handler gin.HandlerFunc, route RouteV2) {
+	r.Handle(http.MethodPost, path, handler, route)
+}
+
+func (r *Router) PUT(path string, handler gin.HandlerFunc, route RouteV2) {
+	r.Handle(http.MethodPut, path, handler, route)
+}
+
+func (r *Router) PATCH(path string, handler gin.HandlerFunc, route RouteV2) {
+	r.Handle(http.MethodPatch, path, handler, route)
+}
+
+func (r *Router) DELETE(path string, handler gin.HandlerFunc, route RouteV2) {
+	r.Handle(http.MethodDelete, path, handler, route)
+}
+
+var pathParam = regexp.MustCompile(`{(\w+)}`)
+
+// Handle registers the handler and adds the operation to the document.
+// The operation id is the camel case summary, it panics if summaries are not unique.
+func (r *Router) Handle(method, routePath string, handler gin.HandlerFunc, route RouteV2) {
+	op := &openapi.Operation{
+		Tags:        []string{strings.SplitN(strings.TrimPrefix(routePath, "/"), "/", 2)[0]},
+		Summary:     route.Summary,
+		Description: route.Description,
+		OperationID: operationID(route.Summary),
+		Responses:   map[string]*openapi.Response{},
+		Security:    []openapi.SecurityRequirement{},
+	}
+	if r.hasOperation(op.OperationID) {
+		panic(fmt.Sprintf("duplicated operation %q of %s %s", op.OperationID, method, routePath))
+	}
+	if !route.Public {
+		op.Security = append(op.Security, openapi.SecurityRequirement{openapi.JWTSecurity: {}})
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(routePath, -1) {
+		// ids like id and roleId are numbers, others like resource are strings
+		var schema *openapi.Schema
+		if name := match[1]; name == "id" || strings.HasSuffix(name, "Id") {
+			schema = r.doc.Schema(uint(0))
+		} else {
+			schema = r.doc.Schema("")
+		}
+		op.Parameters = append(op.Parameters, openapi.Parameter{Name: match[1], In: "path", Required: true, Schema: schema})
+	}
+	if route.List {
+		op.Parameters = append(op.Parameters, listParameters()...)
+	}
+	op.Parameters = append(op.Parameters, route.Query...)
+	if route.Versioned && method != http.MethodGet {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name:        "If-Match",
+			In:          "header",
+			Description: `ETag of the version to change, like "3"`,
+			Schema:      r.doc.Schema(""),
+		})
+	}
+
+	if method == http.MethodPatch {
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				"application/merge-patch+json": {Schema: &openapi.Schema{Type: "object"}},
+				"application/json-patch+json":  {Schema: r.doc.Schema([]jsonPatchOperation{})},
+			},
+		}
+	} else if route.Body != nil {
+		contentType := route.BodyContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]openapi.MediaType{contentType: {Schema: r.doc.Schema(route.Body)}},
+		}
+	}
+
+	op.Responses[successStatus(method, route)] = r.response(route)
+	op.Responses["default"] = &openapi.Response{
+		Description: "problem details of the error",
+		Content:     map[string]openapi.MediaType{common.ProblemContentType: {Schema: r.doc.Schema(common.Problem{})}},
+	}
+	r.doc.AddOperation(strings.ToLower(method), r.prefix+routePath, op)
+
+	r.group.Handle(method, pathParam.ReplaceAllString(routePath, ":$1"), handler)
+}
+
+func (r *Router) response(route RouteV2) *openapi.Response {
+	if route.Response == nil {
+		return &openapi.Response{Description: "no content"}
+	}
+
+	resp := &openapi.Response{Description: "success"}
+	schema := r.doc.Schema(route.Response)
+	if reflect.TypeOf(route.Response).Kind() == reflect.Slice {
+		schema = &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"items":    schema,
+				"metadata": r.doc.Schema(model.ListMeta{}),
+			},
+			Required: []string{"items"},
+		}
+	}
+	contentType := route.ResponseContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	resp.Content = map[string]openapi.MediaType{contentType: {Schema: schema}}
+	if route.Versioned {
+		resp.Headers = map[string]openapi.Header{
+			"ETag": {Description: "version of the object", Schema: r.doc.Schema("")},
+		}
+	}
+	return resp
+}
+
+func (r *Router) hasOperation(id string) bool {
+	for _, item := range r.doc.Paths {
+		for _, op := range *item {
+			if op.OperationID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func successStatus(method string, route RouteV2) string {
+	switch {
+	case route.Response == nil:
+		return "204"
+	case route.Status != 0:
+		return fmt.Sprint(route.Status)
+	case method == http.MethodPost:
+		return "201"
+	default:
+		return "200"
+	}
+}
+
+// operationID returns the camel case of a summary, like listUsers of "List users".
+func operationID(summary string) string {
+	words := strings.Fields(summary)
+	for i, word := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(word)
+		} else {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, "")
+}
+
+func listParameters() []openapi.Parameter {
+	return []openapi.Parameter{
+		{Name: "limit", In: "query", Description: "page size, default 100, at most 1000", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+		{Name: "continue", In: "query", Description: "token of the next page, returned in metadata", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "page", In: "query", Description: "page number, starts from 1", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+		{Name: "sort", In: "query", Description: "sort field, prefix - for descending", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "fieldSelector", In: "query", Description: "selectors like name~=foo", Schema: &openapi.Schema{Type: "string"}},
+	}
+}
+
+// jsonPatchOperation is an operation of a json patch, see https://www.rfc-editor.org/rfc/rfc6902.
+type jsonPatchOperation struct {
+	Op    string      `json:"op" validate:"required,oneof=add remove replace move copy test"`
+	Path  string      `json:"path" validate:"required"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// responseCreated responds the created object, its location is the path of the request with the id of the object.
+func responseCreated(c *gin.Context, obj interface{}, id uint) {
+	common.ResponseCreated(c, obj, path.Join(c.Request.URL.Path, strconv.FormatUint(uint64(id), 10)))
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/openapi"
+	"github.com/eastygh/webm-nas/pkg/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatsController serves the daily views and likes of posts and the top posts.
+type StatsController struct {
+	statsService service.StatsService
+}
+
+func NewStatsController(statsService service.StatsService) Controller {
+	return &StatsController{
+		statsService: statsService,
+	}
+}
+
+// @Summary Get post stats
+// @Description Get the daily views and likes of the post, every day from from to to has a stat.
+// @Description Likes of a day are the likes minus the unlikes of the day
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param id path int true "post id"
+// @Param from query string false "first day like 2026-01-02, default 29 days before to"
+// @Param to query string false "last day like 2026-01-31, default today in UTC"
+// @Success 200 {object} common.Response{data=model.PostStats}
+// @Router /api/v1/posts/{id}/stats [get]
+func (s *StatsController) Get(c *gin.Context) {
+	query, err := statsQuery(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	stats, err := s.statsService.Get(c.Param("id"), query, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, stats)
+}
+
+// @Summary Top viewed posts
+// @Description List the posts with the most views of the days, most viewed first
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param from query string false "first day like 2026-01-02, default 29 days before to"
+// @Param to query string false "last day like 2026-01-31, default today in UTC"
+// @Param limit query int false "number of posts, default 10, at most 100"
+// @Success 200 {object} common.Response{data=[]model.TopPost}
+// @Router /api/v1/posts/top/views [get]
+func (s *StatsController) TopViews(c *gin.Context) {
+	s.top(c, "views")
+}
+
+// @Summary Top liked posts
+// @Description List the posts with the most likes of the days, most liked first
+// @Produce json
+// @Tags post
+// @Security JWT
+// @Param from query string false "first day like 2026-01-02, default 29 days before to"
+// @Param to query string false "last day like 2026-01-31, default today in UTC"
+// @Param limit query int false "number of posts, default 10, at most 100"
+// @Success 200 {object} common.Response{data=[]model.TopPost}
+// @Router /api/v1/posts/top/likes [get]
+func (s *StatsController) TopLikes(c *gin.Context) {
+	s.top(c, "likes")
+}
+
+func (s *StatsController) top(c *gin.Context, by string) {
+	query, err := statsQuery(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	posts, err := s.statsService.Top(by, query, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, posts)
+}
+
+func statsQuery(c *gin.Context) (*model.StatsQuery, error) {
+	query := &model.StatsQuery{
+		From: c.Query("from"),
+		To:   c.Query("to"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, fmt.Errorf("invalid limit: %v", err)
+		}
+	}
+	return query, nil
+}
+
+func (s *StatsController) RegisterRoute(api *gin.RouterGroup) {
+	api.GET("/posts/:id/stats", s.Get)
+	api.GET("/posts/top/views", s.TopViews)
+	api.GET("/posts/top/likes", s.TopLikes)
+}
+
+func (s *StatsController) RegisterRouteV2(api *Router) {
+	days := []openapi.Parameter{
+		{Name: "from", In: "query", Description: "first day like 2026-01-02, default 29 days before to", Schema: &openapi.Schema{Type: "string", Format: "date"}},
+		{Name: "to", In: "query", Description: "last day like 2026-01-31, default today in UTC", Schema: &openapi.Schema{Type: "string", Format: "date"}},
+	}
+	top := append(days[:2:2],
+		openapi.Parameter{Name: "limit", In: "query", Description: "number of posts, default 10, at most 100", Schema: &openapi.Schema{Type: "integer", Format: "int64"}})
+
+	api.GET("/posts/{id}/stats", s.Get, RouteV2{
+		Summary:     "Get post stats",
+		Description: "Get the daily views and likes of the post, likes of a day are the likes minus the unlikes of the day",
+		Response:    model.PostStats{},
+		Query:       days,
+	})
+	api.GET("/posts/top/views", s.TopViews, RouteV2{
+		Summary:     "Top viewed posts",
+		Description: "List the posts with the most views of the days, most viewed first",
+		Response:    []model.TopPost{},
+		Query:       top,
+	})
+	api.GET("/posts/top/likes", s.TopLikes, RouteV2{
+		Summary:     "Top liked posts",
+		Description: "List the posts with the most likes of the days, most liked first",
+		Response:    []model.TopPost{},
+		Query:       top,
+	})
+}
+
+func (s *StatsController) Name() string {
+	return "Stats"
+}
@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/service"
+	"github.com/eastygh/webm-nas/pkg/utils/trace"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TagController struct {
+	tagService service.TagService
+}
+
+func NewTagController(tagService service.TagService) Controller {
+	return &TagController{
+		tagService: tagService,
+	}
+}
+
+// @Summary List tags
+// @Description List tags with the number of their published posts
+// @Produce json
+// @Tags tag
+// @Security JWT
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like name~=go"
+// @Success 200 {object} common.Response{data=[]model.Tag,metadata=model.ListMeta}
+// @Router /api/v1/tags [get]
+func (t *TagController) List(c *gin.Context) {
+	opts, err := listOptions(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	tags, meta, err := t.tagService.List(opts)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseList(c, tags, meta)
+}
+
+// @Summary Get tag
+// @Description Get tag with the number of its published posts
+// @Produce json
+// @Tags tag
+// @Security JWT
+// @Param id path int true "tag id"
+// @Success 200 {object} common.Response{data=model.Tag}
+// @Router /api/v1/tags/{id} [get]
+func (t *TagController) Get(c *gin.Context) {
+	tag, err := t.tagService.Get(c.Param("id"))
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, tag)
+}
+
+// @Summary Create tag
+// @Description Create tag
+// @Accept json
+// @Produce json
+// @Tags tag
+// @Security JWT
+// @Param tag body model.Tag true "tag info"
+// @Success 201 {object} common.Response{data=model.Tag}
+// @Router /api/v1/tags [post]
+func (t *TagController) Create(c *gin.Context) {
+	tag := new(model.Tag)
+	if !bindJSON(c, tag) {
+		return
+	}
+
+	common.TraceStep(c, "start create tag", trace.Field{Key: "tag", Value: tag.Name})
+	defer common.TraceStep(c, "create tag done", trace.Field{Key: "tag", Value: tag.Name})
+
+	tag, err := t.tagService.Create(tag)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	responseCreated(c, tag, tag.ID)
+}
+
+// @Summary Update tag
+// @Description Rename tag
+// @Accept json
+// @Produce json
+// @Tags tag
+// @Security JWT
+// @Param id path int true "tag id"
+// @Param tag body model.Tag true "tag info"
+// @Success 200 {object} common.Response{data=model.Tag}
+// @Router /api/v1/tags/{id} [put]
+func (t *TagController) Update(c *gin.Context) {
+	tag := new(model.Tag)
+	if !bindJSON(c, tag) {
+		return
+	}
+
+	common.TraceStep(c, "start update tag", trace.Field{Key: "tag", Value: tag.Name})
+	defer common.TraceStep(c, "update tag done", trace.Field{Key: "tag", Value: tag.Name})
+
+	tag, err := t.tagService.Update(c.Param("id"), tag)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, tag)
+}
+
+// @Summary Delete tag
+// @Description Delete tag and remove it from its posts
+// @Produce json
+// @Tags tag
+// @Security JWT
+// @Param id path int true "tag id"
+// @Success 200 {object} common.Response
+// @Router /api/v1/tags/{id} [delete]
+func (t *TagController) Delete(c *gin.Context) {
+	if err := t.tagService.Delete(c.Param("id")); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, nil)
+}
+
+// @Summary Merge tags
+// @Description Move the posts of the tag to the tag into and delete the tag
+// @Accept json
+// @Produce json
+// @Tags tag
+// @Security JWT
+// @Param id path int true "tag id"
+// @Param merge body model.TagMerge true "tag to merge into"
+// @Success 200 {object} common.Response{data=model.Tag}
+// @Router /api/v1/tags/{id}/merge [post]
+func (t *TagController) Merge(c *gin.Context) {
+	merge := new(model.TagMerge)
+	if !bindJSON(c, merge) {
+		return
+	}
+
+	common.TraceStep(c, "start merge tag", trace.Field{Key: "id", Value: c.Param("id")})
+	defer common.TraceStep(c, "merge tag done", trace.Field{Key: "id", Value: c.Param("id")})
+
+	tag, err := t.tagService.Merge(c.Param("id"), merge)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, tag)
+}
+
+// @Summary List tag posts
+// @Description List the posts with the tag
+// @Produce json
+// @Tags tag
+// @Security JWT
+// @Param id path int true "tag id"
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like creatorId=3,name~=foo"
+// @Success 200 {object} common.Response{data=[]model.Post,metadata=model.ListMeta}
+// @Router /api/v1/tags/{id}/posts [get]
+func (t *TagController) ListPosts(c *gin.Context) {
+	opts, err := listOptions(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	reader, err := postReader(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	posts, meta, err := t.tagService.ListPosts(c.Param("id"), opts, reader)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseList(c, posts, meta)
+}
+
+func (t *TagController) RegisterRoute(api *gin.RouterGroup) {
+	api.GET("/tags", t.List)
+	api.POST("/tags", t.Create)
+	api.GET("/tags/:id", t.Get)
+	api.PUT("/tags/:id", t.Update)
+	api.DELETE("/tags/:id", t.Delete)
+	api.POST("/tags/:id/merge", t.Merge)
+	api.GET("/tags/:id/posts", t.ListPosts)
+}
+
+func (t *TagController) RegisterRouteV2(api *Router) {
+	api.GET("/tags", t.List, RouteV2{
+		Summary:     "List tags",
+		Description: "List tags with the number of their published posts",
+		Response:    []model.Tag{},
+		List:        true,
+	})
+	api.POST("/tags", t.Create, RouteV2{Summary: "Create tag", Body: model.Tag{}, Response: model.Tag{}})
+	api.GET("/tags/{id}", t.Get, RouteV2{Summary: "Get tag", Response: model.Tag{}})
+	api.PUT("/tags/{id}", t.Update, RouteV2{Summary: "Rename tag", Body: model.Tag{}, Response: model.Tag{}})
+	api.DELETE("/tags/{id}", t.Delete, RouteV2{Summary: "Delete tag", Description: "Delete the tag and remove it from its posts"})
+	api.POST("/tags/{id}/merge", t.Merge, RouteV2{
+		Summary:     "Merge tags",
+		Description: "Move the posts of the tag to the tag into and delete the tag",
+		Body:        model.TagMerge{},
+		Response:    model.Tag{},
+		Status:      http.StatusOK,
+	})
+	api.GET("/tags/{id}/posts", t.ListPosts, RouteV2{Summary: "List tag posts", Response: []model.Post{}, List: true})
+}
+
+func (t *TagController) Name() string {
+	return "Tag"
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashController struct {
+	trashService service.TrashService
+}
+
+func NewTrashController(trashService service.TrashService) Controller {
+	return &TrashController{
+		trashService: trashService,
+	}
+}
+
+// @Summary List trash
+// @Description List the deleted objects of a resource, newest first
+// @Produce json
+// @Tags trash
+// @Security JWT
+// @Param resource path string true "resource, one of users, groups, posts"
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like name~=foo"
+// @Success 200 {object} common.Response{data=[]model.TrashItem,metadata=model.ListMeta}
+// @Router /api/v1/trash/{resource} [get]
+func (t *TrashController) List(c *gin.Context) {
+	opts, err := listOptions(c)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusBadRequest, err)
+		return
+	}
+	items, meta, err := t.trashService.List(c.Param("resource"), opts)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseList(c, items, meta)
+}
+
+// @Summary Restore object
+// @Description Restore a deleted object, it fails with 409 if its name is used by another object
+// @Produce json
+// @Tags trash
+// @Security JWT
+// @Param resource path string true "resource, one of users, groups, posts"
+// @Param id path string true "object id"
+// @Success 200 {object} common.Response
+// @Failure 409 {object} common.Response
+// @Router /api/v1/trash/{resource}/{id}/restore [post]
+func (t *TrashController) Restore(c *gin.Context) {
+	obj, err := t.trashService.Restore(c.Param("resource"), c.Param("id"))
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, obj)
+}
+
+// @Summary Purge object
+// @Description Delete a deleted object permanently
+// @Produce json
+// @Tags trash
+// @Security JWT
+// @Param resource path string true "resource, one of users, groups, posts"
+// @Param id path string true "object id"
+// @Success 200 {object} common.Response
+// @Router /api/v1/trash/{resource}/{id} [delete]
+func (t *TrashController) Purge(c *gin.Context) {
+	if err := t.trashService.Purge(c.Param("resource"), c.Param("id")); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+	common.ResponseSuccess(c, nil)
+}
+
+func (t *TrashController) RegisterRoute(api *gin.RouterGroup) {
+	api.GET("/trash/:resource", t.List)
+	api.POST("/trash/:resource/:id/restore", t.Restore)
+	api.DELETE("/trash/:resource/:id", t.Purge)
+}
+
+func (t *TrashController) RegisterRouteV2(api *Router) {
+	api.GET("/trash/{resource}", t.List, RouteV2{Summary: "List trash", Description: "List soft-deleted objects of users, groups or posts", Response: []model.TrashItem{}, List: true})
+	api.POST("/trash/{resource}/{id}/restore", t.Restore, RouteV2{
+		Summary:     "Restore object",
+		Description: "Restore a soft-deleted object, respond the restored object",
+		Response:    map[string]interface{}{},
+		Status:      http.StatusOK,
+	})
+	api.DELETE("/trash/{resource}/{id}", t.Purge, RouteV2{Summary: "Purge object"})
+}
+
+func (t *TrashController) Name() string {
+	return "Trash"
+}
@@ -29,17 +29,27 @@ func NewUserController(userService service.UserService) Controller {
 // @Produce json
 // @Tags user
 // @Security JWT
-// @Success 200 {object} common.Response{data=model.Users}
+// @Param limit query int false "page size, default 100, at most 1000"
+// @Param continue query string false "token of the next page, returned in metadata"
+// @Param page query int false "page number, starts from 1"
+// @Param sort query string false "sort field, prefix - for descending"
+// @Param fieldSelector query string false "selectors like creatorId=3,name~=foo"
+// @Success 200 {object} common.Response{data=model.Users,metadata=model.ListMeta}
 // @Router /api/v1/users [get]
 func (u *UserController) List(c *gin.Context) {
 	common.TraceStep(c, "start list user")
-	users, err := u.userService.List()
+	opts, err := listOptions(c)
 	if err != nil {
 		common.ResponseFailed(c, http.StatusBadRequest, err)
 		return
 	}
+	users, meta, err := u.userService.List(opts)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
 	common.TraceStep(c, "list user done")
-	common.ResponseSuccess(c, users)
+	common.ResponseList(c, users, meta)
 }
 
 // @Summary Get user
@@ -53,9 +63,10 @@ func (u *UserController) List(c *gin.Context) {
 func (u *UserController) Get(c *gin.Context) {
 	user, err := u.userService.Get(c.Param("id"))
 	if err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
+	setETag(c, user.Version)
 	common.ResponseSuccess(c, user)
 }
 
@@ -70,8 +81,7 @@ func (u *UserController) Get(c *gin.Context) {
 // @Router /api/v1/users [post]
 func (u *UserController) Create(c *gin.Context) {
 	createdUser := new(model.CreatedUser)
-	if err := c.BindJSON(createdUser); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if !bindJSON(c, createdUser) {
 		return
 	}
 
@@ -87,9 +97,11 @@ func (u *UserController) Create(c *gin.Context) {
 	user, err := u.userService.Create(user)
 	if err != nil {
 		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
 	}
 
-	common.ResponseSuccess(c, user)
+	setETag(c, user.Version)
+	responseCreated(c, user, user.ID)
 }
 
 // @Summary Update user
@@ -100,6 +112,7 @@ func (u *UserController) Create(c *gin.Context) {
 // @Security JWT
 // @Param user body model.UpdatedUser true "user info"
 // @Param id   path      int  true  "user id"
+// @Param If-Match header string false "ETag of the version to change"
 // @Success 200 {object} common.Response{data=model.User}
 // @Router /api/v1/users/{id} [put]
 func (u *UserController) Update(c *gin.Context) {
@@ -110,10 +123,16 @@ func (u *UserController) Update(c *gin.Context) {
 	}
 
 	new := new(model.UpdatedUser)
-	if err := c.BindJSON(new); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if !bindJSON(c, new) {
 		return
 	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if version != 0 {
+		new.Version = version
+	}
 	logrus.Infof("get update user: %#v", new.Name)
 
 	common.TraceStep(c, "start update user", trace.Field{"user", new.Name})
@@ -125,6 +144,47 @@ func (u *UserController) Update(c *gin.Context) {
 		return
 	}
 
+	setETag(c, user.Version)
+	common.ResponseSuccess(c, user)
+}
+
+// @Summary Patch user
+// @Description Patch user with a json merge patch or json patch, the password can be set by the patch
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Tags user
+// @Security JWT
+// @Param patch body object true "merge patch or json patch"
+// @Param id   path      int  true  "user id"
+// @Param If-Match header string false "ETag of the version to change"
+// @Success 200 {object} common.Response{data=model.User}
+// @Router /api/v1/users/{id} [patch]
+func (u *UserController) Patch(c *gin.Context) {
+	user := common.GetUser(c)
+	if user == nil || (strconv.Itoa(int(user.ID)) != c.Param("id") && !authorization.IsClusterAdmin(user)) {
+		common.ResponseFailed(c, http.StatusForbidden, nil)
+		return
+	}
+
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	patchType, patch, ok := readPatch(c)
+	if !ok {
+		return
+	}
+
+	common.TraceStep(c, "start patch user", trace.Field{Key: "id", Value: c.Param("id")})
+	defer common.TraceStep(c, "patch user done", trace.Field{Key: "id", Value: c.Param("id")})
+
+	user, err := u.userService.Patch(c.Param("id"), version, patchType, patch)
+	if err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(c, user.Version)
 	common.ResponseSuccess(c, user)
 }
 
@@ -134,6 +194,7 @@ func (u *UserController) Update(c *gin.Context) {
 // @Tags user
 // @Security JWT
 // @Param id path int true "user id"
+// @Param If-Match header string false "ETag of the version to change"
 // @Success 200 {object} common.Response
 // @Router /api/v1/users/{id} [delete]
 func (u *UserController) Delete(c *gin.Context) {
@@ -143,8 +204,12 @@ func (u *UserController) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := u.userService.Delete(c.Param("id")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := u.userService.Delete(c.Param("id"), version); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -162,11 +227,11 @@ func (u *UserController) Delete(c *gin.Context) {
 func (u *UserController) GetGroups(c *gin.Context) {
 	groups, err := u.userService.GetGroups(c.Param("id"))
 	if err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	common.ResponseSuccess(c, groups)
+	common.ResponseList(c, groups, nil)
 }
 
 // @Summary Add role
@@ -175,12 +240,12 @@ func (u *UserController) GetGroups(c *gin.Context) {
 // @Tags user
 // @Security JWT
 // @Param id path int true "user id"
-// @Param rid path int true "role id"
+// @Param roleId path int true "role id"
 // @Success 200 {object} common.Response
-// @Router /api/v1/users/{id}/roles/{rid} [post]
+// @Router /api/v1/users/{id}/roles/{roleId} [post]
 func (u *UserController) AddRole(c *gin.Context) {
-	if err := u.userService.AddRole(c.Param("id"), c.Param("rid")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if err := u.userService.AddRole(c.Param("id"), c.Param("roleId")); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -193,12 +258,12 @@ func (u *UserController) AddRole(c *gin.Context) {
 // @Tags user
 // @Security JWT
 // @Param id path int true "user id"
-// @Param rid path int true "role id"
+// @Param roleId path int true "role id"
 // @Success 200 {object} common.Response
-// @Router /api/v1/users/{id}/roles/{rid} [delete]
+// @Router /api/v1/users/{id}/roles/{roleId} [delete]
 func (u *UserController) DelRole(c *gin.Context) {
-	if err := u.userService.DelRole(c.Param("id"), c.Param("rid")); err != nil {
-		common.ResponseFailed(c, http.StatusBadRequest, err)
+	if err := u.userService.DelRole(c.Param("id"), c.Param("roleId")); err != nil {
+		common.ResponseFailed(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -210,10 +275,23 @@ func (u *UserController) RegisterRoute(api *gin.RouterGroup) {
 	api.POST("/users", u.Create)
 	api.GET("/users/:id", u.Get)
 	api.PUT("/users/:id", u.Update)
+	api.PATCH("/users/:id", u.Patch)
 	api.DELETE("/users/:id", u.Delete)
 	api.GET("/users/:id/groups", u.GetGroups)
-	api.POST("/users/:id/roles/:rid", u.AddRole)
-	api.DELETE("/users/:id/roles/:rid", u.DelRole)
+	api.POST("/users/:id/roles/:roleId", u.AddRole)
+	api.DELETE("/users/:id/roles/:roleId", u.DelRole)
+}
+
+func (u *UserController) RegisterRouteV2(api *Router) {
+	api.GET("/users", u.List, RouteV2{Summary: "List users", Response: []model.User{}, List: true})
+	api.POST("/users", u.Create, RouteV2{Summary: "Create user", Body: model.CreatedUser{}, Response: model.User{}, Versioned: true})
+	api.GET("/users/{id}", u.Get, RouteV2{Summary: "Get user", Response: model.User{}, Versioned: true})
+	api.PUT("/users/{id}", u.Update, RouteV2{Summary: "Update user", Body: model.UpdatedUser{}, Response: model.User{}, Versioned: true})
+	api.PATCH("/users/{id}", u.Patch, RouteV2{Summary: "Patch user", Response: model.User{}, Versioned: true})
+	api.DELETE("/users/{id}", u.Delete, RouteV2{Summary: "Delete user", Versioned: true})
+	api.GET("/users/{id}/groups", u.GetGroups, RouteV2{Summary: "List user groups", Response: []model.Group{}})
+	api.PUT("/users/{id}/roles/{roleId}", u.AddRole, RouteV2{Summary: "Add user role"})
+	api.DELETE("/users/{id}/roles/{roleId}", u.DelRole, RouteV2{Summary: "Delete user role"})
 }
 
 func (u *UserController) Name() string {
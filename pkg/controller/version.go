@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ifMatch returns the version of the If-Match header, zero if there is no header or it is "*".
+func ifMatch(c *gin.Context) (uint64, bool) {
+	etag := strings.TrimSpace(c.GetHeader("If-Match"))
+	if etag == "" || etag == "*" {
+		return 0, true
+	}
+
+	version, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(etag, "W/"), `"`), 10, 64)
+	if err != nil || version == 0 {
+		common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("invalid If-Match %s, expect an ETag like \"1\"", etag))
+		return 0, false
+	}
+	return version, true
+}
+
+// setETag returns the version of the object as ETag.
+func setETag(c *gin.Context, version uint64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatUint(version, 10)))
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/service"
+	"github.com/eastygh/webm-nas/pkg/watch"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newVersionTest(t *testing.T) (*gin.Engine, *model.Group) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	repo := repository.NewRepository(db)
+	require.Nil(t, repo.Migrate())
+	alice, err := repo.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	group, err := repo.Group().Create(alice, &model.Group{Name: "dev", Kind: model.CustomGroup})
+	require.Nil(t, err)
+
+	groups := NewGroupController(service.NewGroupService(repo.Group(), repo.User(), repo.RBAC(), watch.NewBroadcaster(0))).(*GroupController)
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(func(c *gin.Context) { common.SetUser(c, alice) })
+	e.GET("/api/v1/groups/:id", groups.Get)
+	e.PUT("/api/v1/groups/:id", groups.Update)
+	e.PATCH("/api/v1/groups/:id", groups.Patch)
+	e.DELETE("/api/v1/groups/:id", groups.Delete)
+	return e, group
+}
+
+type versionResponse struct {
+	Code   int              `json:"code"`
+	Reason apierrors.Reason `json:"reason"`
+	Data   model.Group      `json:"data"`
+}
+
+func serveVersion(t *testing.T, e *gin.Engine, method, ifMatch, contentType, body string) (*httptest.ResponseRecorder, versionResponse) {
+	req := httptest.NewRequest(method, "/api/v1/groups/1", strings.NewReader(body))
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	var resp versionResponse
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return w, resp
+}
+
+func TestVersionHeaders(t *testing.T) {
+	e, group := newVersionTest(t)
+	require.Equal(t, uint(1), group.ID)
+
+	w, resp := serveVersion(t, e, http.MethodGet, "", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	assert.Equal(t, uint64(1), resp.Data.Version)
+
+	// a missing If-Match and * update the current version
+	w, resp = serveVersion(t, e, http.MethodPut, "", "application/json", `{"describe":"one"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	assert.Equal(t, uint64(2), resp.Data.Version)
+	w, _ = serveVersion(t, e, http.MethodPut, "*", "application/json", `{"describe":"two"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+	// a stale If-Match is a conflict with the current object
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		contentType := "application/json"
+		if method == http.MethodPatch {
+			contentType = "application/merge-patch+json"
+		}
+		w, resp = serveVersion(t, e, method, `"2"`, contentType, `{"describe":"stale"}`)
+		assert.Equal(t, http.StatusConflict, w.Code, method)
+		assert.Equal(t, apierrors.ReasonConflict, resp.Reason, method)
+		assert.Equal(t, uint64(3), resp.Data.Version, method)
+		assert.Equal(t, "two", resp.Data.Describe, method)
+	}
+
+	// weak etags are accepted, a patch increases the version
+	w, resp = serveVersion(t, e, http.MethodPatch, `W/"3"`, "application/merge-patch+json", `{"describe":"patched"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	assert.Equal(t, "patched", resp.Data.Describe)
+	w, _ = serveVersion(t, e, http.MethodPatch, "", "application/merge-patch+json", `{"describe":"again"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+
+	// invalid versions are rejected
+	for _, ifMatch := range []string{`"0"`, `"abc"`, `"-1"`} {
+		w, _ = serveVersion(t, e, http.MethodPut, ifMatch, "application/json", `{"describe":"invalid"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, ifMatch)
+	}
+
+	w, _ = serveVersion(t, e, http.MethodDelete, `"5"`, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = serveVersion(t, e, http.MethodGet, "", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
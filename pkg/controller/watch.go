@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/authorization"
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/openapi"
+	"github.com/eastygh/webm-nas/pkg/utils/request"
+	"github.com/eastygh/webm-nas/pkg/utils/set"
+	"github.com/eastygh/webm-nas/pkg/watch"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+var watchableResources = set.NewString(model.PostResource, model.UserResource, model.GroupResource, model.RoleResource)
+
+type WatchController struct {
+	broadcaster *watch.Broadcaster
+}
+
+func NewWatchController(broadcaster *watch.Broadcaster) Controller {
+	return &WatchController{
+		broadcaster: broadcaster,
+	}
+}
+
+// @Summary Watch resource
+// @Description Stream changes of a resource as server-sent events, the event id is the resource version
+// @Produce text/event-stream
+// @Tags watch
+// @Security JWT
+// @Param resource path string true "resource, one of posts, users, groups, roles"
+// @Param name path string false "only watch the object with the name"
+// @Param resourceVersion query string false "resume after the resource version, Last-Event-ID header is used if empty"
+// @Success 200 {object} watch.Event
+// @Failure 410 {object} common.Response
+// @Router /api/v1/watch/{resource}/{name} [get]
+func (w *WatchController) Watch(c *gin.Context) {
+	resource := c.Param("resource")
+	if !watchableResources.Has(resource) {
+		common.ResponseFailed(c, http.StatusNotFound, fmt.Errorf("resource %s can not be watched", resource))
+		return
+	}
+	name := c.Param("name")
+
+	rv := c.Query("resourceVersion")
+	if rv == "" {
+		rv = c.GetHeader("Last-Event-ID")
+	}
+	var resourceVersion uint64
+	if rv != "" {
+		var err error
+		if resourceVersion, err = strconv.ParseUint(rv, 10, 64); err != nil {
+			common.ResponseFailed(c, http.StatusBadRequest, fmt.Errorf("invalid resource version: %v", err))
+			return
+		}
+	}
+
+	watcher, err := w.broadcaster.Watch(resource, resourceVersion)
+	if err != nil {
+		if errors.Is(err, watch.ErrResourceVersionTooOld) {
+			common.ResponseFailed(c, http.StatusGone, err)
+			return
+		}
+		common.ResponseFailed(c, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer watcher.Stop()
+
+	user := common.GetUser(c)
+	namespace := request.NamespaceRoot
+	if ri := common.GetRequestInfo(c); ri != nil && ri.Namespace != "" {
+		namespace = ri.Namespace
+	}
+	// roles are resolved once per stream and again with every heartbeat,
+	// so revoked roles take effect on open streams without loading them for every event
+	var roles *authorization.Roles
+	if user != nil {
+		if roles, err = resolveRoles(user); err != nil {
+			common.ResponseFailed(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// a bookmark tells the client the resource version to resume from
+	// even if nothing changes before it reconnects
+	c.Render(-1, sseEvent(watch.Event{
+		Type:            watch.Bookmark,
+		Resource:        resource,
+		ResourceVersion: watcher.ResourceVersion(),
+	}))
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(out io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			if user != nil {
+				if roles, err = resolveRoles(user); err != nil {
+					return false
+				}
+			}
+			_, err := io.WriteString(out, ": heartbeat\n\n")
+			return err == nil
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false
+			}
+			if name != "" && event.Name != name {
+				return true
+			}
+			if !canGet(roles, namespace, event) || !visible(user, roles, namespace, event) {
+				return true
+			}
+			c.Render(-1, sseEvent(event))
+			return true
+		}
+	})
+}
+
+// resolveRoles resolves the roles of the user of a watch.
+func resolveRoles(user *model.User) (*authorization.Roles, error) {
+	// resolving may append system groups to an anonymous user, use a copy
+	return authorization.ResolveRoles(&model.User{ID: user.ID, Name: user.Name})
+}
+
+// canGet checks if the roles of the watch allow to get the object of the event, roles are nil without a user.
+func canGet(roles *authorization.Roles, namespace string, event watch.Event) bool {
+	allowed, err := roles.Authorize(&request.RequestInfo{
+		IsResourceRequest: true,
+		Verb:              request.GetOperation,
+		Namespace:         namespace,
+		Resource:          event.Resource,
+		Name:              event.Name,
+		Parts:             []string{event.Resource, event.Name},
+	})
+	return err == nil && allowed
+}
+
+// visible hides the changes of posts which are not visible to the user, like the drafts of others.
+func visible(user *model.User, roles *authorization.Roles, namespace string, event watch.Event) bool {
+	post, ok := event.Object.(*model.Post)
+	// deleted events only have the id of the post
+	if !ok || event.Type == watch.Deleted {
+		return true
+	}
+	reader := model.PostReader{UserID: user.ID}
+	if post.VisibleTo(reader) {
+		return true
+	}
+	return roles.AuthorizeVerb(&request.RequestInfo{IsResourceRequest: true, Namespace: namespace, Resource: model.PostResource}, string(model.ModerateOperation))
+}
+
+func sseEvent(event watch.Event) sse.Event {
+	return sse.Event{
+		Id:    strconv.FormatUint(event.ResourceVersion, 10),
+		Event: string(event.Type),
+		Data:  event,
+	}
+}
+
+func (w *WatchController) RegisterRoute(api *gin.RouterGroup) {
+	api.GET("/watch/:resource", w.Watch)
+	api.GET("/watch/:resource/:name", w.Watch)
+}
+
+func (w *WatchController) RegisterRouteV2(api *Router) {
+	route := RouteV2{
+		Summary:             "Watch resource",
+		Description:         "Stream changes of a resource as server-sent events",
+		Response:            watch.Event{},
+		ResponseContentType: "text/event-stream",
+		Query: []openapi.Parameter{
+			{Name: "resourceVersion", In: "query", Description: "resume after the resource version, Last-Event-ID header is used if empty", Schema: &openapi.Schema{Type: "string"}},
+		},
+	}
+	api.GET("/watch/{resource}", w.Watch, route)
+
+	route.Summary = "Watch object"
+	route.Description = "Stream changes of an object as server-sent events"
+	api.GET("/watch/{resource}/{name}", w.Watch, route)
+}
+
+func (w *WatchController) Name() string {
+	return "Watch"
+}
@@ -0,0 +1,18 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/eastygh/webm-nas/pkg/config"
+	"gorm.io/gorm"
+)
+
+func New(conf *config.DBConfig) (*gorm.DB, error) {
+	switch conf.Type {
+	case "sqlite":
+		return NewSqlite(conf)
+	default:
+		//db, err = database.NewPostgres(&conf.DB) or some other db
+		return nil, fmt.Errorf("unsupported db type: %s", conf.Type)
+	}
+}
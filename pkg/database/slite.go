@@ -7,5 +7,6 @@ import (
 )
 
 func NewSqlite(conf *config.DBConfig) (*gorm.DB, error) {
-	return gorm.Open(sqlite.Open(conf.Filename), &gorm.Config{})
+	// translate unique constraint violations to gorm.ErrDuplicatedKey
+	return gorm.Open(sqlite.Open(conf.Filename), &gorm.Config{TranslateError: true})
 }
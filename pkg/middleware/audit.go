@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/audit"
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/utils/request"
+	"github.com/eastygh/webm-nas/pkg/utils/set"
+
+	"github.com/gin-gonic/gin"
+)
+
+var readOnlyVerbs = set.NewString(request.GetOperation, request.ListOperation, request.WatchOperation)
+
+// AuditMiddleware records mutations and denied requests of resources,
+// it should run after authentication and before authorization.
+func AuditMiddleware(auditor *audit.Auditor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ri := common.GetRequestInfo(c)
+		if auditor == nil || ri == nil || !ri.IsResourceRequest {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if !readOnlyVerbs.Has(ri.Verb) && c.Request.Body != nil {
+			// keep a prefix of the body and hand the whole body to the handlers
+			body, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(auditor.MaxBodySize())*4))
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		outcome := model.AuditSuccess
+		switch {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			outcome = model.AuditDenied
+		case status >= http.StatusBadRequest:
+			outcome = model.AuditFailure
+		}
+
+		if readOnlyVerbs.Has(ri.Verb) && outcome != model.AuditDenied {
+			return
+		}
+
+		event := model.AuditEvent{
+			Time:        time.Now(),
+			SourceIP:    c.ClientIP(),
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			Verb:        ri.Verb,
+			Namespace:   ri.Namespace,
+			Resource:    ri.Resource,
+			Subresource: ri.Subresource,
+			Name:        ri.Name,
+			Outcome:     outcome,
+			StatusCode:  status,
+			Body:        audit.SummarizeBody(c.ContentType(), body, auditor.MaxBodySize()),
+		}
+		if user := common.GetUser(c); user != nil {
+			event.UserID = user.ID
+			event.UserName = user.Name
+		}
+
+		auditor.Record(event)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -28,12 +28,20 @@ func AuthorizationMiddleware() gin.HandlerFunc {
 
 		if ri.IsResourceRequest {
 			resource := ri.Resource
-			ok, err := authorization.Authorize(user, ri)
+			roles, err := authorization.ResolveRoles(user)
 			if err != nil {
 				common.ResponseFailed(c, http.StatusInternalServerError, err)
 				c.Abort()
 				return
 			}
+			ok, err := roles.Authorize(ri)
+			if err != nil {
+				common.ResponseFailed(c, http.StatusInternalServerError, err)
+				c.Abort()
+				return
+			}
+			// the roles are reused by handlers, like the check if the user may moderate posts
+			common.SetRoles(c, roles)
 
 			logrus.Infof("authorize user [%s(%d)], namespace [%s] resource [%s(%s)] verb [%s], result: %t",
 				user.Name, user.ID, ri.Namespace, ri.Resource, ri.Name, ri.Verb, ok)
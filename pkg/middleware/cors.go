@@ -13,8 +13,8 @@ func CORSMiddleware() gin.HandlerFunc {
 			return true
 		},
 		AllowMethods:     []string{"PUT", "PATCH", "GET", "DELETE", "POST", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Length", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Length", "Content-Type", "If-Match", IdempotencyKeyHeader},
+		ExposeHeaders:    []string{"Content-Length", "ETag", IdempotentReplayedHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 		AllowWebSockets:  true,
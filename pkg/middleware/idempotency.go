@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/common"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotencyReserves    = 3
+	defaultIdempotencyKeysTTL = 24 * time.Hour
+	// defaultMaxIdempotentRequestSize is the default size of the largest request body with a key, which is buffered
+	defaultMaxIdempotentRequestSize = 32 << 20
+	// idempotencyLease is how long a request in progress keeps its key, a retry after it runs the request again
+	idempotencyLease = 5 * time.Minute
+)
+
+// IdempotencyMiddleware makes POST and PATCH requests with an Idempotency-Key header safe to retry.
+// The first request with a key is run and its response is stored for ttl, later requests with the key
+// and the same method, path and body get the stored response without running again.
+// A key is rejected with 422 if it is reused for another request, and with 409 while its first request is in progress
+// for at most the lease, so the key of a request which never finished can be used again.
+// Keys are scoped by the user, it should run after authentication and authorization.
+// The bodies of requests with a key are buffered, larger bodies than maxRequestSize are rejected with 413,
+// so it should be at least the size of the largest upload.
+func IdempotencyMiddleware(repo repository.IdempotencyRepository, ttl time.Duration, maxRequestSize int64) gin.HandlerFunc {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyKeysTTL
+	}
+	if maxRequestSize <= 0 {
+		maxRequestSize = defaultMaxIdempotentRequestSize
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || (c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPatch) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abort(c, http.StatusBadRequest, fmt.Errorf("%s is longer than %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(io.LimitReader(c.Request.Body, maxRequestSize+1)); err != nil {
+				abort(c, http.StatusBadRequest, err)
+				return
+			}
+			if int64(len(body)) > maxRequestSize {
+				abort(c, http.StatusRequestEntityTooLarge, fmt.Errorf("requests with %s are limited to %d bytes", IdempotencyKeyHeader, maxRequestSize))
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		var uid uint
+		if user := common.GetUser(c); user != nil {
+			uid = user.ID
+		}
+		token, err := leaseToken()
+		if err != nil {
+			abort(c, http.StatusInternalServerError, err)
+			return
+		}
+		now := time.Now()
+		record := &model.IdempotencyRecord{
+			Key:         fmt.Sprintf("%d:%s", uid, key),
+			Fingerprint: fingerprint(c.Request, body),
+			ExpiresAt:   now.Add(ttl),
+			LockedUntil: now.Add(idempotencyLease),
+			Token:       token,
+		}
+
+		stored, err := reserve(repo, record)
+		if err != nil {
+			abort(c, http.StatusInternalServerError, err)
+			return
+		}
+		if stored != nil {
+			replay(c, stored, record.Fingerprint)
+			return
+		}
+
+		defer func() {
+			// a panic is not a response, the request can be retried with the same key
+			if r := recover(); r != nil {
+				_ = repo.Delete(record.Key, record.Token)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if status := recorder.Status(); status >= http.StatusInternalServerError {
+			// failed requests can be retried with the same key
+			err = repo.Delete(record.Key, record.Token)
+		} else {
+			record.StatusCode = status
+			record.ContentType = recorder.Header().Get("Content-Type")
+			record.Body = recorder.body.Bytes()
+			err = repo.Update(record)
+		}
+		if err != nil {
+			logrus.Warnf("failed to store response of %s %s: %v", IdempotencyKeyHeader, key, err)
+		}
+	}
+}
+
+// reserve stores the record of a new request, it returns the stored record if the key is already used.
+// An expired record and a record in progress whose lease ended are taken over by the new request,
+// a record of another request in progress is returned, so replay rejects it.
+func reserve(repo repository.IdempotencyRepository, record *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	for i := 0; ; i++ {
+		err := repo.Create(record)
+		if err == nil {
+			return nil, nil
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+
+		stored, err := repo.Get(record.Key)
+		if apierrors.IsNotFound(err) && i < maxIdempotencyReserves {
+			// deleted since, try again
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		abandoned := stored.StatusCode == 0 && stored.Fingerprint == record.Fingerprint && stored.LockedUntil.Before(now)
+		if stored.ExpiresAt.After(now) && !abandoned {
+			return stored, nil
+		}
+		if i >= maxIdempotencyReserves {
+			return nil, fmt.Errorf("failed to reserve %s %s", IdempotencyKeyHeader, record.Key)
+		}
+		record.ID = stored.ID
+		leased, err := repo.Lease(record, now)
+		if err != nil || leased {
+			return nil, err
+		}
+		// taken over by another request, try again
+		record.ID = 0
+	}
+}
+
+// replay responds the stored response of the key, if it was stored for the same request.
+func replay(c *gin.Context, stored *model.IdempotencyRecord, fingerprint string) {
+	switch {
+	case stored.Fingerprint != fingerprint:
+		abort(c, http.StatusUnprocessableEntity, fmt.Errorf("%s is already used for another request", IdempotencyKeyHeader))
+	case stored.StatusCode == 0:
+		abort(c, http.StatusConflict, fmt.Errorf("a request with the %s is in progress, retry later", IdempotencyKeyHeader))
+	default:
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Data(stored.StatusCode, stored.ContentType, stored.Body)
+		c.Abort()
+	}
+}
+
+func abort(c *gin.Context, code int, err error) {
+	common.ResponseFailed(c, code, err)
+	c.Abort()
+}
+
+// leaseToken returns a random token of a request, which identifies the request holding the lease of a key.
+func leaseToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// fingerprint returns the hash of the method, path and body of the request.
+func fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
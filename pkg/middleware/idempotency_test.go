@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeIdempotencyRepository struct {
+	records map[string]model.IdempotencyRecord
+}
+
+func (f *fakeIdempotencyRepository) Create(record *model.IdempotencyRecord) error {
+	if _, ok := f.records[record.Key]; ok {
+		return apierrors.NewAlreadyExists("idempotency keys", record.Key)
+	}
+	f.records[record.Key] = *record
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) Get(key string) (*model.IdempotencyRecord, error) {
+	record, ok := f.records[key]
+	if !ok {
+		return nil, apierrors.NewNotFound("idempotency keys", key)
+	}
+	return &record, nil
+}
+
+func (f *fakeIdempotencyRepository) Lease(record *model.IdempotencyRecord, now time.Time) (bool, error) {
+	stored, ok := f.records[record.Key]
+	if !ok || !(stored.ExpiresAt.Before(now) || (stored.StatusCode == 0 && stored.LockedUntil.Before(now))) {
+		return false, nil
+	}
+	f.records[record.Key] = *record
+	return true, nil
+}
+
+func (f *fakeIdempotencyRepository) Update(record *model.IdempotencyRecord) error {
+	if f.records[record.Key].Token != record.Token {
+		return apierrors.NewConflict(fmt.Errorf("%s was taken over", record.Key), nil)
+	}
+	f.records[record.Key] = *record
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) Delete(key, token string) error {
+	if f.records[key].Token == token {
+		delete(f.records, key)
+	}
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) DeleteExpired(now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeIdempotencyRepository) Migrate() error {
+	return nil
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &fakeIdempotencyRepository{records: map[string]model.IdempotencyRecord{}}
+	repo.records["0:expired"] = model.IdempotencyRecord{Key: "0:expired", StatusCode: http.StatusOK, ExpiresAt: time.Now().Add(-time.Minute)}
+
+	calls := 0
+	e := gin.New()
+	e.Use(IdempotencyMiddleware(repo, time.Hour, 8))
+	e.POST("/posts", func(c *gin.Context) {
+		calls++
+		c.String(http.StatusCreated, "created %d", calls)
+	})
+	e.POST("/slow", func(c *gin.Context) {
+		calls++
+		// the lease ended during the request and a retry took the key over
+		record := repo.records["0:k6"]
+		record.Token = "retry"
+		repo.records["0:k6"] = record
+		c.String(http.StatusCreated, "created %d", calls)
+	})
+	e.POST("/fail", func(c *gin.Context) {
+		calls++
+		c.String(http.StatusInternalServerError, "failed")
+	})
+
+	testCases := []struct {
+		Name string
+		Path string
+		Key  string
+		Body string
+
+		ExpectedCode     int
+		ExpectedBody     string
+		ExpectedReplayed bool
+		ExpectedCalls    int
+	}{
+		{"without key", "/posts", "", "a", http.StatusCreated, "created 1", false, 1},
+		{"first request", "/posts", "k1", "a", http.StatusCreated, "created 2", false, 2},
+		{"retry", "/posts", "k1", "a", http.StatusCreated, "created 2", true, 2},
+		{"reused key", "/posts", "k1", "b", http.StatusUnprocessableEntity, "", false, 2},
+		{"expired key", "/posts", "expired", "a", http.StatusCreated, "created 3", false, 3},
+		{"failed request", "/fail", "k2", "a", http.StatusInternalServerError, "failed", false, 4},
+		{"retry of failed request", "/fail", "k2", "a", http.StatusInternalServerError, "failed", false, 5},
+		{"too long key", "/posts", strings.Repeat("k", maxIdempotencyKeyLength+1), "a", http.StatusBadRequest, "", false, 5},
+		{"too large body", "/posts", "k5", "123456789", http.StatusRequestEntityTooLarge, "", false, 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.Path, strings.NewReader(tc.Body))
+			if tc.Key != "" {
+				req.Header.Set(IdempotencyKeyHeader, tc.Key)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.ExpectedCode, w.Code)
+			if tc.ExpectedBody != "" {
+				assert.Equal(t, tc.ExpectedBody, w.Body.String())
+			}
+			assert.Equal(t, tc.ExpectedReplayed, w.Header().Get(IdempotentReplayedHeader) == "true")
+			assert.Equal(t, tc.ExpectedCalls, calls)
+		})
+	}
+
+	t.Run("in progress", func(t *testing.T) {
+		repo.records["0:k3"] = model.IdempotencyRecord{Key: "0:k3", Fingerprint: fingerprint(httptest.NewRequest(http.MethodPost, "/posts", nil), []byte("a")), ExpiresAt: time.Now().Add(time.Hour), LockedUntil: time.Now().Add(time.Minute)}
+		req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader("a"))
+		req.Header.Set(IdempotencyKeyHeader, "k3")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("taken over", func(t *testing.T) {
+		// only the request holding the lease completes the record
+		req := httptest.NewRequest(http.MethodPost, "/slow", strings.NewReader("a"))
+		req.Header.Set(IdempotencyKeyHeader, "k6")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Zero(t, repo.records["0:k6"].StatusCode)
+		assert.Equal(t, "retry", repo.records["0:k6"].Token)
+	})
+
+	t.Run("lease ended", func(t *testing.T) {
+		// the server stopped during the first request, a retry after the lease runs it again
+		a := fingerprint(httptest.NewRequest(http.MethodPost, "/posts", nil), []byte("a"))
+		repo.records["0:k4"] = model.IdempotencyRecord{Key: "0:k4", Fingerprint: a, ExpiresAt: time.Now().Add(time.Hour), LockedUntil: time.Now().Add(-time.Second)}
+		serve := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(body))
+			req.Header.Set(IdempotencyKeyHeader, "k4")
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			return w
+		}
+
+		// the key is still not used for another request
+		w := serve("b")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, 6, calls)
+
+		w = serve("a")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "created 7", w.Body.String())
+		assert.Equal(t, http.StatusCreated, repo.records["0:k4"].StatusCode)
+
+		w = serve("a")
+		assert.Equal(t, "created 7", w.Body.String())
+		assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, 7, calls)
+	})
+}
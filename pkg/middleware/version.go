@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/eastygh/webm-nas/pkg/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersionMiddleware sets the api version of requests like /api/{version}/..., the version selects the format of responses.
+// It should run before other middlewares which may respond errors.
+func APIVersionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(strings.TrimPrefix(c.Request.URL.Path, "/"), "/", 3)
+		if len(parts) >= 2 && parts[0] == "api" {
+			common.SetAPIVersion(c, parts[1])
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// PostFrontMatter is the YAML front matter of a post in an export, the Markdown file has the content after it.
+// Imports also read the title, draft and date of the front matter of Hugo posts.
+type PostFrontMatter struct {
+	Name        string     `yaml:"name,omitempty"`
+	Title       string     `yaml:"title,omitempty"`
+	Summary     string     `yaml:"summary,omitempty"`
+	Author      string     `yaml:"author,omitempty"`
+	Status      PostStatus `yaml:"status,omitempty"`
+	Draft       bool       `yaml:"draft,omitempty"`
+	Tags        []string   `yaml:"tags,omitempty"`
+	Categories  []string   `yaml:"categories,omitempty"`
+	Date        *time.Time `yaml:"date,omitempty"`
+	PublishedAt *time.Time `yaml:"publishedAt,omitempty"`
+	CreatedAt   *time.Time `yaml:"createdAt,omitempty"`
+	UpdatedAt   *time.Time `yaml:"updatedAt,omitempty"`
+}
+
+// ImportStatus is the result of importing a Markdown file.
+type ImportStatus string
+
+const (
+	ImportCreated ImportStatus = "created"
+	// ImportConflict files are not imported because a post has their name
+	ImportConflict ImportStatus = "conflict"
+	ImportFailed   ImportStatus = "failed"
+)
+
+// ImportResult is the result of an import, the results of the files are in the order of their paths.
+type ImportResult struct {
+	Summary ImportSummary      `json:"summary"`
+	Results []ImportItemResult `json:"results"`
+}
+
+type ImportSummary struct {
+	Total     int `json:"total"`
+	Created   int `json:"created"`
+	Conflicts int `json:"conflicts"`
+	Failed    int `json:"failed"`
+}
+
+// ImportItemResult is the result of a Markdown file, Warnings are the problems of created posts,
+// like unknown authors and attachments which could not be uploaded.
+type ImportItemResult struct {
+	File     string       `json:"file"`
+	Name     string       `json:"name"`
+	Status   ImportStatus `json:"status"`
+	ID       uint         `json:"id,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	Warnings []string     `json:"warnings,omitempty"`
+}
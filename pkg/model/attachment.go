@@ -0,0 +1,34 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// AttachmentSubresource is the subresource of posts for their attachments.
+const AttachmentSubresource = "attachments"
+
+// Attachment is a file uploaded to a post, like an image embedded in its content.
+// The content is stored in the attachment directory, it is removed when the post is purged from the trash.
+type Attachment struct {
+	ID     uint `json:"id" gorm:"autoIncrement;primaryKey"`
+	PostID uint `json:"postId" gorm:"not null;index"`
+	UserID uint `json:"userId"`
+	// Name is the file name of the upload, ContentType is sniffed from the content
+	Name        string `json:"name" gorm:"size:256;not null"`
+	ContentType string `json:"contentType" gorm:"size:128;not null"`
+	Size        int64  `json:"size"`
+	// Hash is the hex sha256 of the content
+	Hash string `json:"hash" gorm:"size:64;not null"`
+	// Key is the name of the file of the content in the attachment directory
+	Key string `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	// URL is the download url, which can be used in the content of the post
+	URL string `json:"url" gorm:"-"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AttachmentURL returns the download url of the attachment of the post.
+func AttachmentURL(postID, id uint) string {
+	return fmt.Sprintf("/api/v1/%s/%d/%s/%d", PostResource, postID, AttachmentSubresource, id)
+}
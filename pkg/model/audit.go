@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+const (
+	AuditSuccess = "success"
+	AuditDenied  = "denied"
+	AuditFailure = "failure"
+)
+
+// AuditEvent records who did what, from where and with which result.
+type AuditEvent struct {
+	ID          uint      `json:"id" gorm:"autoIncrement;primaryKey"`
+	Time        time.Time `json:"time" gorm:"index"`
+	UserID      uint      `json:"userId"`
+	UserName    string    `json:"userName" gorm:"size:100;index"`
+	SourceIP    string    `json:"sourceIP" gorm:"size:64"`
+	Method      string    `json:"method" gorm:"size:16"`
+	Path        string    `json:"path" gorm:"size:1024"`
+	Verb        string    `json:"verb" gorm:"size:32"`
+	Namespace   string    `json:"namespace" gorm:"size:100"`
+	Resource    string    `json:"resource" gorm:"size:100;index"`
+	Subresource string    `json:"subresource" gorm:"size:100"`
+	Name        string    `json:"name" gorm:"size:256"`
+	Outcome     string    `json:"outcome" gorm:"size:32"`
+	StatusCode  int       `json:"statusCode"`
+	Body        string    `json:"body" gorm:"type:text"`
+}
+
+type AuditQuery struct {
+	User     string
+	Resource string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
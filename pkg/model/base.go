@@ -6,6 +6,8 @@ import (
 	"gorm.io/gorm"
 )
 
+// BaseModel has the timestamps of soft deleted models, deleted objects are moved to the trash.
+// Names of such models are unique among the objects which are not deleted, see the partial unique indexes.
 type BaseModel struct {
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
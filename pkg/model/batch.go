@@ -0,0 +1,53 @@
+package model
+
+import (
+	"encoding/json"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+)
+
+const MaxBatchOperations = 100
+
+// Batch is a list of operations run in a single request.
+// An atomic batch runs all operations in a single transaction, nothing is changed if one of them fails,
+// otherwise each operation is run by itself and the others are not affected by its failure.
+type Batch struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations" validate:"required,min=1,max=100,dive"`
+}
+
+// BatchOperation is an operation of a batch, like a request of its own.
+// Path is the path of the request, like /api/v1/posts or /api/v1/posts/1,
+// a non zero version must be the current version of the deleted object like If-Match.
+type BatchOperation struct {
+	Method  string          `json:"method" validate:"required,oneof=POST PUT DELETE"`
+	Path    string          `json:"path" validate:"required"`
+	Version uint64          `json:"version,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty" swaggertype:"object"`
+}
+
+type BatchResult struct {
+	Summary BatchSummary      `json:"summary"`
+	Results []BatchItemResult `json:"results"`
+}
+
+// BatchSummary counts the results of a batch, RolledBack is true if an atomic batch was rolled back.
+type BatchSummary struct {
+	Total      int  `json:"total"`
+	Succeeded  int  `json:"succeeded"`
+	Failed     int  `json:"failed"`
+	RolledBack bool `json:"rolledBack"`
+}
+
+// BatchItemResult is the result of an operation, in the same form as the response of the request.
+type BatchItemResult struct {
+	Code   int              `json:"code"`
+	Reason apierrors.Reason `json:"reason,omitempty"`
+	Msg    string           `json:"msg"`
+	Data   interface{}      `json:"data,omitempty"`
+}
+
+// Succeeded returns true if the operation succeeded.
+func (r *BatchItemResult) Succeeded() bool {
+	return r.Code < 400
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// FeedToken lets a user read feeds in feed readers, which can not log in, the token is sent in the url of the feeds.
+// A user has at most one feed token.
+type FeedToken struct {
+	ID     uint `gorm:"autoIncrement;primaryKey"`
+	UserID uint `gorm:"not null;uniqueIndex"`
+	// Hash is the sha256 hash of the token, the token itself is only returned when it is created
+	Hash      string `gorm:"size:64;not null;uniqueIndex"`
+	CreatedAt time.Time
+}
+
+// CreatedFeedToken is a new feed token with the urls of the feeds of all posts.
+type CreatedFeedToken struct {
+	Token string   `json:"token"`
+	Feeds []string `json:"feeds"`
+}
@@ -10,20 +10,21 @@ const (
 
 type Group struct {
 	ID        uint   `json:"id" gorm:"autoIncrement;primaryKey"`
-	Name      string `json:"name" gorm:"size:100;not null;unique"`
+	Name      string `json:"name" gorm:"size:100;not null;uniqueIndex:idx_groups_name,where:deleted_at IS NULL"`
 	Kind      string `json:"kind" gorm:"size:100"`
 	Describe  string `json:"describe" gorm:"size:1024;"`
 	CreatorId uint   `json:"creatorId"`
 	UpdaterId uint   `json:"updaterId"`
 	Users     []User `json:"users" gorm:"many2many:user_groups;"`
 	Roles     []Role `json:"roles" gorm:"many2many:group_roles;"`
+	Version   uint64 `json:"version" gorm:"not null;default:1"`
 
 	BaseModel
 }
 
 type CreatedGroup struct {
-	Name      string `json:"name"`
-	Describe  string `json:"describe"`
+	Name      string `json:"name" validate:"required,max=100,name"`
+	Describe  string `json:"describe" validate:"max=1024"`
 	CreatorId uint   `json:"creatorId"`
 }
 
@@ -35,16 +36,19 @@ func (g *CreatedGroup) GetGroup(uid uint) *Group {
 	}
 }
 
+// UpdatedGroup replaces the describe of a group, the name can not be changed.
+// A non zero version must be the current version of the group.
 type UpdatedGroup struct {
-	Name      string `json:"name"`
-	Describe  string `json:"describe"`
-	UpdaterId uint   `json:"updaterId"`
+	Name     string `json:"name" validate:"omitempty,max=100,name"`
+	Describe string `json:"describe" validate:"max=1024"`
+	Version  uint64 `json:"version"`
 }
 
 func (g *UpdatedGroup) GetGroup(uid uint) *Group {
 	return &Group{
 		Name:      g.Name,
 		Describe:  g.Describe,
-		UpdaterId: g.UpdaterId,
+		UpdaterId: uid,
+		Version:   g.Version,
 	}
 }
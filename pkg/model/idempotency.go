@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// IdempotencyRecord keeps the response of a request with an Idempotency-Key header,
+// so that retries of the request get the same response instead of running it again.
+type IdempotencyRecord struct {
+	ID uint `gorm:"autoIncrement;primaryKey"`
+	// Key is the key of the header scoped by the user who sent it
+	Key string `gorm:"size:320;not null;uniqueIndex"`
+	// Fingerprint is the hash of the method, path and body of the request
+	Fingerprint string `gorm:"size:64;not null"`
+	// StatusCode is 0 while the request is in progress
+	StatusCode  int
+	ContentType string `gorm:"size:128"`
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"index"`
+	// LockedUntil is the end of the lease of a request in progress,
+	// a retry takes the key over after it, like when the server stopped during the request
+	LockedUntil time.Time
+	// Token is a random token of the request which holds the lease,
+	// the record is only completed or released by that request
+	Token string `gorm:"size:32"`
+}
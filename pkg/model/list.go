@@ -0,0 +1,95 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// ErrInvalidListOptions is wrapped by the errors of unknown fields, operators or continue tokens.
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+type SelectorOperator string
+
+const (
+	SelectorEquals    SelectorOperator = "="
+	SelectorNotEquals SelectorOperator = "!="
+	SelectorContains  SelectorOperator = "~="
+)
+
+// Selector filters a list by a field, like creatorId=3 or name~=foo.
+type Selector struct {
+	Field    string
+	Operator SelectorOperator
+	Value    string
+}
+
+func (s Selector) String() string {
+	return s.Field + string(s.Operator) + s.Value
+}
+
+// ListOptions selects, sorts and pages a list, fields are the json names of the object.
+// A zero Limit lists all objects.
+type ListOptions struct {
+	Limit int
+	// Continue is the token returned by the previous page, it can not be used with Page
+	Continue string
+	// Page starts from 1, the page size is Limit
+	Page      int
+	SortBy    string
+	Desc      bool
+	Selectors []Selector
+}
+
+// ListMeta is returned with a page of a list.
+type ListMeta struct {
+	Total    int64  `json:"total"`
+	Continue string `json:"continue,omitempty"`
+}
+
+// ParseSelectors parses comma separated selectors, like "creatorId=3,name~=foo".
+func ParseSelectors(selector string) ([]Selector, error) {
+	selectors := make([]Selector, 0)
+	for _, s := range strings.Split(selector, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		// the first "=" ends the operator, the value may contain "=" too
+		idx := strings.Index(s, "=")
+		op := SelectorEquals
+		if idx > 0 {
+			switch s[idx-1] {
+			case '!':
+				op, idx = SelectorNotEquals, idx-1
+			case '~':
+				op, idx = SelectorContains, idx-1
+			}
+		}
+		if idx <= 0 || strings.TrimSpace(s[:idx]) == "" {
+			return nil, fmt.Errorf("%w: invalid selector %q, expect field=value, field!=value or field~=value", ErrInvalidListOptions, s)
+		}
+
+		selectors = append(selectors, Selector{
+			Field:    strings.TrimSpace(s[:idx]),
+			Operator: op,
+			Value:    strings.TrimSpace(s[idx+len(op):]),
+		})
+	}
+	return selectors, nil
+}
+
+// ParseSort parses a sort field, a leading "-" sorts descending.
+func ParseSort(sort string) (field string, desc bool) {
+	sort = strings.TrimSpace(sort)
+	if strings.HasPrefix(sort, "-") {
+		return sort[1:], true
+	}
+	return strings.TrimPrefix(sort, "+"), false
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSelectors(t *testing.T) {
+	tests := []struct {
+		selector string
+		expected []Selector
+		invalid  bool
+	}{
+		{
+			selector: "",
+			expected: []Selector{},
+		},
+		{
+			selector: "creatorId=3",
+			expected: []Selector{{Field: "creatorId", Operator: SelectorEquals, Value: "3"}},
+		},
+		{
+			selector: "name~=foo, kind!=system",
+			expected: []Selector{
+				{Field: "name", Operator: SelectorContains, Value: "foo"},
+				{Field: "kind", Operator: SelectorNotEquals, Value: "system"},
+			},
+		},
+		{
+			selector: "name=a!=b",
+			expected: []Selector{{Field: "name", Operator: SelectorEquals, Value: "a!=b"}},
+		},
+		{
+			selector: "name",
+			invalid:  true,
+		},
+		{
+			selector: "!=foo",
+			invalid:  true,
+		},
+	}
+
+	for _, test := range tests {
+		selectors, err := ParseSelectors(test.selector)
+		if test.invalid {
+			assert.ErrorIs(t, err, ErrInvalidListOptions, test.selector)
+			continue
+		}
+		assert.NoError(t, err, test.selector)
+		assert.Equal(t, test.expected, selectors, test.selector)
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		sort  string
+		field string
+		desc  bool
+	}{
+		{sort: "", field: ""},
+		{sort: "name", field: "name"},
+		{sort: "+name", field: "name"},
+		{sort: "-createdAt", field: "createdAt", desc: true},
+	}
+
+	for _, test := range tests {
+		field, desc := ParseSort(test.sort)
+		assert.Equal(t, test.field, field, test.sort)
+		assert.Equal(t, test.desc, desc, test.sort)
+	}
+}
@@ -0,0 +1,59 @@
+package model
+
+// RBACPolicy is the declarative form of roles, groups and their bindings,
+// used to export the permissions of a server and apply them to another one.
+type RBACPolicy struct {
+	Roles  []PolicyRole  `json:"roles" yaml:"roles"`
+	Groups []PolicyGroup `json:"groups" yaml:"groups"`
+	Users  []PolicyUser  `json:"users" yaml:"users"`
+}
+
+type PolicyRole struct {
+	Name      string `json:"name" yaml:"name"`
+	Scope     Scope  `json:"scope" yaml:"scope"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Rules     Rules  `json:"rules" yaml:"rules"`
+}
+
+type PolicyGroup struct {
+	Name     string   `json:"name" yaml:"name"`
+	Kind     string   `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Describe string   `json:"describe,omitempty" yaml:"describe,omitempty"`
+	Members  []string `json:"members,omitempty" yaml:"members,omitempty"`
+	Roles    []string `json:"roles,omitempty" yaml:"roles,omitempty"`
+}
+
+// PolicyUser holds the roles bound directly to an existing user,
+// users themselves are never created or deleted by a policy.
+type PolicyUser struct {
+	Name  string   `json:"name" yaml:"name"`
+	Roles []string `json:"roles" yaml:"roles"`
+}
+
+type ApplyOptions struct {
+	DryRun bool `json:"dryRun"`
+	Prune  bool `json:"prune"`
+}
+
+type PlanAction string
+
+const (
+	PlanCreate PlanAction = "create"
+	PlanUpdate PlanAction = "update"
+	PlanDelete PlanAction = "delete"
+)
+
+type PlanItem struct {
+	Kind    string     `json:"kind"`
+	Name    string     `json:"name"`
+	Action  PlanAction `json:"action"`
+	Changes []string   `json:"changes,omitempty"`
+}
+
+// RBACPlan describes the changes needed to make the server match a policy.
+type RBACPlan struct {
+	DryRun  bool       `json:"dryRun"`
+	Prune   bool       `json:"prune"`
+	Applied bool       `json:"applied"`
+	Items   []PlanItem `json:"items"`
+}
@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/utils/markdown"
+)
 
 const (
 	PostAssociation       = "Posts"
@@ -8,32 +12,88 @@ const (
 	CategoriesAssociation = "Categories"
 )
 
+// PostStatus is the publishing state of a post, only published posts are visible to everyone.
+type PostStatus string
+
+const (
+	PostDraft PostStatus = "draft"
+	// PostScheduled posts are published by the scheduler at their publishedAt time
+	PostScheduled PostStatus = "scheduled"
+	PostPublished PostStatus = "published"
+	PostArchived  PostStatus = "archived"
+)
+
+// Post is a post of a user, its tags and categories are linked by their names and nil tags or categories are kept on updates.
 type Post struct {
 	ID         uint       `json:"id" gorm:"autoIncrement;primaryKey"`
-	Name       string     `json:"name" gorm:"size:256;not null;unique"`
-	Content    string     `json:"content" gorm:"type:text;not null"`
-	Summary    string     `json:"summary" gorm:"size:512"`
+	Name       string     `json:"name" gorm:"size:256;not null;uniqueIndex:idx_posts_name,where:deleted_at IS NULL" validate:"required,max=256"`
+	Content    string     `json:"content" gorm:"type:text;not null" validate:"required"`
+	Summary    string     `json:"summary" gorm:"size:512" validate:"max=512"`
 	CreatorID  uint       `json:"creatorId"`
 	Creator    User       `json:"creator" gorm:"foreignKey:CreatorID"`
-	Tags       []Tag      `json:"tags"  gorm:"many2many:tag_posts"`
-	Categories []Category `json:"categories" gorm:"many2many:category_posts"`
+	Tags       []Tag      `json:"tags"  gorm:"many2many:tag_posts" validate:"dive"`
+	Categories []Category `json:"categories" gorm:"many2many:category_posts" validate:"dive"`
 	Comments   []Comment  `json:"comments"`
 
-	Views     uint `json:"views" gorm:"type:uint"`
-	Likes     uint `json:"likes" gorm:"-"`
-	UserLiked bool `json:"userLiked" gorm:"-"`
+	// HTML is the content rendered from Markdown and TOC its headings, they are only set when a single post is got
+	HTML string             `json:"html,omitempty" gorm:"-"`
+	TOC  []markdown.Heading `json:"toc,omitempty" gorm:"-"`
+
+	// Status defaults to published, drafts, scheduled and archived posts are only visible to their creator and editors
+	Status      PostStatus `json:"status" gorm:"size:16;not null;default:published;index" validate:"omitempty,oneof=draft scheduled published archived"`
+	PublishedAt *time.Time `json:"publishedAt"`
+
+	Views     uint   `json:"views" gorm:"type:uint"`
+	Likes     uint   `json:"likes" gorm:"-"`
+	UserLiked bool   `json:"userLiked" gorm:"-"`
+	Version   uint64 `json:"version" gorm:"not null;default:1"`
 
 	BaseModel
 }
 
+// VisibleTo reports whether the post is visible to the reader.
+func (p *Post) VisibleTo(reader PostReader) bool {
+	return reader.Editor || p.Status == PostPublished || (reader.UserID != 0 && p.CreatorID == reader.UserID)
+}
+
+// PostReader is the user who reads posts, editors may moderate posts and see the posts of all users.
+type PostReader struct {
+	UserID uint
+	Editor bool
+}
+
+// PublishOptions publishes a post now or schedules it.
+type PublishOptions struct {
+	// PublishAt schedules the post if it is in the future
+	PublishAt *time.Time `json:"publishAt"`
+}
+
+// UnpublishOptions makes a post a draft or archives it.
+type UnpublishOptions struct {
+	Archive bool `json:"archive"`
+}
+
 type Tag struct {
 	ID   uint   `json:"id" gorm:"autoIncrement;primaryKey"`
-	Name string `json:"name" gorm:"size:256;not null;unique"`
+	Name string `json:"name" gorm:"size:256;not null;unique" validate:"required,max=256"`
+	// PostCount is the number of published posts with the tag, it is not set in posts
+	PostCount int64 `json:"postCount,omitempty" gorm:"-"`
+}
+
+// TagMerge merges a tag into the tag Into, the posts of the tag get the tag Into.
+type TagMerge struct {
+	Into uint `json:"into" validate:"required"`
 }
 
+// Category is a category of posts, categories are nested by their parent.
 type Category struct {
-	ID   uint   `json:"id" gorm:"autoIncrement;primaryKey"`
-	Name string `json:"name" gorm:"size:256;not null;unique"`
+	ID       uint   `json:"id" gorm:"autoIncrement;primaryKey"`
+	Name     string `json:"name" gorm:"size:256;not null;unique" validate:"required,max=256"`
+	ParentID *uint  `json:"parentId" gorm:"index"`
+	// PostCount is the number of published posts in the category, without its subcategories. It is not set in posts
+	PostCount int64 `json:"postCount,omitempty" gorm:"-"`
+	// Children are the direct subcategories, they are only set when a single category is got
+	Children []Category `json:"children,omitempty" gorm:"-"`
 }
 
 type Like struct {
@@ -44,15 +104,48 @@ type Like struct {
 	Post   Post `json:"-" gorm:"foreignKey:PostID"`
 }
 
+// CommentStatus is the moderation state of a comment, only approved comments are visible to everyone.
+type CommentStatus string
+
+const (
+	CommentApproved CommentStatus = "approved"
+	// CommentPending comments are held for moderation, like the comments of new users
+	CommentPending  CommentStatus = "pending"
+	CommentRejected CommentStatus = "rejected"
+)
+
 type Comment struct {
-	ID        uint      `json:"id" gorm:"autoIncrement;primaryKey"`
-	ParentID  *uint     `json:"parentId"`
-	Parent    *Comment  `json:"parent" gorm:"foreignKey:ParentID"`
-	UserID    uint      `json:"userId"`
-	User      User      `json:"user" gorm:"foreignKey:UserID"`
-	PostID    uint      `json:"postId"`
-	Post      Post      `json:"-" gorm:"foreignKey:PostID"`
-	Content   string    `json:"content" gorm:"size:1024"`
+	ID       uint     `json:"id" gorm:"autoIncrement;primaryKey"`
+	ParentID *uint    `json:"parentId" gorm:"index"`
+	Parent   *Comment `json:"parent,omitempty" gorm:"foreignKey:ParentID" validate:"-"`
+	UserID   uint     `json:"userId"`
+	User     User     `json:"user" gorm:"foreignKey:UserID" validate:"-"`
+	PostID   uint     `json:"postId" gorm:"index"`
+	Post     Post     `json:"-" gorm:"foreignKey:PostID" validate:"-"`
+	Content  string   `json:"content" gorm:"size:1024" validate:"required,max=1024"`
+	// HTML is the content rendered from Markdown
+	HTML string `json:"html,omitempty" gorm:"-"`
+
+	// Depth is 0 for comments on the post, replies are one deeper than their parent
+	Depth  uint          `json:"depth"`
+	Status CommentStatus `json:"status" gorm:"size:16;not null;default:approved;index"`
+	// EditedAt is when the content was edited last, it is empty if the comment was never edited
+	EditedAt *time.Time `json:"editedAt"`
+	// DeletedAt is set on deleted comments which are kept for their replies, their content is removed
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Replies are the visible replies, they are only set in comment trees
+	Replies []Comment `json:"replies,omitempty" gorm:"-"`
+
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+// VisibleTo reports whether the comment is visible to the reader, pending and rejected comments are visible to their author and editors.
+func (c *Comment) VisibleTo(reader PostReader) bool {
+	return reader.Editor || c.Status == CommentApproved || (reader.UserID != 0 && c.UserID == reader.UserID)
+}
+
+// CommentUpdate is the edited content of a comment.
+type CommentUpdate struct {
+	Content string `json:"content" validate:"required,max=1024"`
+}
@@ -11,6 +11,8 @@ import (
 
 const (
 	All = "*"
+	// ClusterAdminRole is the role of the administrators, it allows every operation
+	ClusterAdminRole = "cluster-admin"
 )
 
 type Scope string
@@ -22,23 +24,26 @@ const (
 
 type Role struct {
 	ID        uint   `json:"id" gorm:"autoIncrement;primaryKey"`
-	Name      string `json:"name" gorm:"size:100;not null;unique"`
-	Scope     Scope  `json:"scope" gorm:"size:100"`
-	Namespace string `json:"namespace"  gorm:"size:100"`
+	Name      string `json:"name" gorm:"size:100;not null;unique" validate:"required,max=100,name"`
+	Scope     Scope  `json:"scope" gorm:"size:100" validate:"oneof=cluster namespace"`
+	Namespace string `json:"namespace"  gorm:"size:100" validate:"required_if=Scope namespace,max=100"`
 	Rules     Rules  `json:"rules" gorm:"type:json"`
+	Version   uint64 `json:"version" gorm:"not null;default:1"`
 }
 
 const (
 	AllOperation  Operation = "*"
 	EditOperation Operation = "edit"
 	ViewOperation Operation = "view"
+	// ModerateOperation allows to update and delete owned objects, like posts and comments, of other users
+	ModerateOperation Operation = "moderate"
 )
 
 type Operation string
 
 var (
-	EditOperationSet = set.NewString(request.CreateOperation, request.DeleteOperation, request.UpdateOperation, request.PatchOperation, request.GetOperation, request.ListOperation)
-	ViewOperationSet = set.NewString(request.GetOperation, request.ListOperation)
+	EditOperationSet = set.NewString(request.CreateOperation, request.DeleteOperation, request.UpdateOperation, request.PatchOperation, request.GetOperation, request.ListOperation, request.WatchOperation)
+	ViewOperationSet = set.NewString(request.GetOperation, request.ListOperation, request.WatchOperation)
 )
 
 func (op Operation) Contain(verb string) bool {
@@ -55,8 +60,8 @@ func (op Operation) Contain(verb string) bool {
 }
 
 type Rule struct {
-	Resource  string    `json:"resource"`
-	Operation Operation `json:"operation"`
+	Resource  string    `json:"resource" yaml:"resource"`
+	Operation Operation `json:"operation" yaml:"operation"`
 }
 
 type Rules []Rule
@@ -85,7 +90,7 @@ func (r *Rules) Scan(value interface{}) error {
 	return nil
 }
 
-func (r *Rules) Value() (driver.Value, error) {
+func (r Rules) Value() (driver.Value, error) {
 	b, err := json.Marshal(r)
 	return string(b), err
 }
@@ -103,6 +108,11 @@ const (
 	RoleResource      = "roles"
 	AuthResource      = "auth"
 	NamespaceResource = "namespaces"
+	RBACResource      = "rbac"
+	AuditResource     = "audit"
+	TrashResource     = "trash"
+	TagResource       = "tags"
+	CategoryResource  = "categories"
 )
 
 type Resource struct {
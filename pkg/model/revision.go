@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/utils/diff"
+)
+
+// PostRevision is a saved version of the name, summary and content of a post,
+// the revisions of a post are numbered from 1 and the latest revision is the current post.
+type PostRevision struct {
+	ID       uint   `json:"id" gorm:"autoIncrement;primaryKey"`
+	PostID   uint   `json:"postId" gorm:"not null;uniqueIndex:idx_post_revisions_revision"`
+	Revision uint   `json:"revision" gorm:"not null;uniqueIndex:idx_post_revisions_revision"`
+	AuthorID uint   `json:"authorId"`
+	Author   User   `json:"author" gorm:"foreignKey:AuthorID"`
+	Name     string `json:"name" gorm:"size:256"`
+	Summary  string `json:"summary" gorm:"size:512"`
+	Content  string `json:"content" gorm:"type:text"`
+	// ChangedFields are the fields changed since the previous revision, like name and content
+	ChangedFields []string `json:"changedFields" gorm:"serializer:json"`
+	// RestoredFrom is the revision which was restored by this revision
+	RestoredFrom *uint     `json:"restoredFrom,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// RevisionDiff is the line diff of the fields changed from a revision of a post to another one.
+type RevisionDiff struct {
+	From   uint        `json:"from"`
+	To     uint        `json:"to"`
+	Fields []FieldDiff `json:"fields"`
+}
+
+// FieldDiff is the line diff of a field, Unified is the same diff formatted like diff -u.
+type FieldDiff struct {
+	Field   string      `json:"field"`
+	Lines   []diff.Line `json:"lines"`
+	Unified string      `json:"unified"`
+}
@@ -0,0 +1,38 @@
+package model
+
+// SearchQuery searches posts by the words of Q, matches can be filtered by the names of a tag, category and author.
+type SearchQuery struct {
+	Q        string
+	Tag      string
+	Category string
+	Author   string
+}
+
+// SearchHit is a matched post without its content.
+type SearchHit struct {
+	Post Post `json:"post"`
+	// Score ranks the hits, higher is better
+	Score float64 `json:"score"`
+	// Snippet is the html escaped text around the matches, matched words are marked by <mark></mark>
+	Snippet string `json:"snippet"`
+}
+
+// Facet is the number of matched posts with a tag, category or author.
+type Facet struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// SearchFacets count all matched posts, not only the posts of the page.
+type SearchFacets struct {
+	Tags       []Facet `json:"tags"`
+	Categories []Facet `json:"categories"`
+	Authors    []Facet `json:"authors"`
+}
+
+// SearchResult is a page of hits, best first.
+type SearchResult struct {
+	Items    []SearchHit  `json:"items"`
+	Metadata *ListMeta    `json:"metadata"`
+	Facets   SearchFacets `json:"facets"`
+}
@@ -0,0 +1,39 @@
+package model
+
+// StatsDayLayout is the layout of the days of stats, days are in UTC.
+const StatsDayLayout = "2006-01-02"
+
+// PostStat is the number of views and likes of a post on a day, Likes are the likes minus the unlikes of the day.
+type PostStat struct {
+	PostID uint   `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	Day    string `json:"day" gorm:"primaryKey;size:10;index"`
+	Views  int64  `json:"views" gorm:"not null;default:0"`
+	Likes  int64  `json:"likes" gorm:"not null;default:0"`
+}
+
+// PostStats are the daily views and likes of a post from the day From to the day To, Series has every day of the range.
+// Views and Likes are the totals of the post, views which are not written yet are not counted.
+type PostStats struct {
+	PostID uint       `json:"postId"`
+	Views  uint       `json:"views"`
+	Likes  uint       `json:"likes"`
+	From   string     `json:"from"`
+	To     string     `json:"to"`
+	Series []PostStat `json:"series"`
+}
+
+// TopPost is a post with its views and likes of the days of a top list.
+type TopPost struct {
+	Post  Post  `json:"post"`
+	Views int64 `json:"views"`
+	Likes int64 `json:"likes"`
+}
+
+// StatsQuery selects the days of stats and the length of top lists, zero values are the defaults.
+type StatsQuery struct {
+	// From and To are days like 2026-01-02, To defaults to today and From to 29 days before To
+	From string
+	To   string
+	// Limit is the number of top posts, default 10
+	Limit int
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// TrashItem is a soft deleted object, it can be restored until it is purged.
+type TrashItem struct {
+	Resource  string    `json:"resource"`
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deletedAt"`
+	// PurgeAt is empty if the trash is kept forever
+	PurgeAt *time.Time  `json:"purgeAt,omitempty"`
+	Object  interface{} `json:"object"`
+}
@@ -13,13 +13,14 @@ const (
 
 type User struct {
 	ID        uint       `json:"id" gorm:"autoIncrement;primaryKey"`
-	Name      string     `json:"name" gorm:"size:100;not null;unique"`
+	Name      string     `json:"name" gorm:"size:100;not null;uniqueIndex:idx_users_name,where:deleted_at IS NULL"`
 	Password  string     `json:"-" gorm:"size:256;"`
 	Email     string     `json:"email" gorm:"size:256;"`
 	Avatar    string     `json:"avatar" gorm:"size:256;"`
 	AuthInfos []AuthInfo `json:"authInfos" gorm:"foreignKey:UserId;references:ID"`
 	Groups    []Group    `json:"groups" gorm:"many2many:user_groups;"`
 	Roles     []Role     `json:"roles" gorm:"many2many:user_roles;"`
+	Version   uint64     `json:"version" gorm:"not null;default:1"`
 
 	BaseModel
 }
@@ -58,10 +59,10 @@ func (*AuthInfo) TableName() string {
 }
 
 type CreatedUser struct {
-	Name      string     `json:"name"`
-	Password  string     `json:"password"`
-	Email     string     `json:"email"`
-	Avatar    string     `json:"avatar"`
+	Name      string     `json:"name" validate:"required,max=100,username"`
+	Password  string     `json:"password" validate:"required,min=6,max=72"`
+	Email     string     `json:"email" validate:"omitempty,max=256,email"`
+	Avatar    string     `json:"avatar" validate:"max=256"`
 	AuthInfos []AuthInfo `json:"authInfos"`
 }
 
@@ -75,11 +76,15 @@ func (u *CreatedUser) GetUser() *User {
 	}
 }
 
+// UpdatedUser replaces name, email and avatar of a user, the password is only changed if it is not empty.
+// A non zero version must be the current version of the user.
 type UpdatedUser struct {
-	Name      string     `json:"name"`
-	Password  string     `json:"password"`
-	Email     string     `json:"email"`
+	Name      string     `json:"name" validate:"required,max=100,username"`
+	Password  string     `json:"password" validate:"omitempty,min=6,max=72"`
+	Email     string     `json:"email" validate:"omitempty,max=256,email"`
+	Avatar    string     `json:"avatar" validate:"max=256"`
 	AuthInfos []AuthInfo `json:"authInfos"`
+	Version   uint64     `json:"version"`
 }
 
 func (u *UpdatedUser) GetUser() *User {
@@ -87,7 +92,9 @@ func (u *UpdatedUser) GetUser() *User {
 		Name:      u.Name,
 		Password:  u.Password,
 		Email:     u.Email,
+		Avatar:    u.Avatar,
 		AuthInfos: u.AuthInfos,
+		Version:   u.Version,
 	}
 }
 
@@ -0,0 +1,70 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type node struct {
+	ID       uint            `json:"id"`
+	Name     string          `json:"name" validate:"required,min=2,max=10"`
+	Kind     string          `json:"kind,omitempty" validate:"required,oneof=file dir"`
+	Size     int64           `json:"size" validate:"min=0"`
+	Tags     []string        `json:"tags" validate:"max=3,dive,min=1"`
+	Parent   *node           `json:"parent,omitempty"`
+	Children []node          `json:"children"`
+	Meta     meta            `json:"meta" validate:"required"`
+	Extra    json.RawMessage `json:"extra"`
+	Created  time.Time       `json:"createdAt"`
+	Ignored  string          `json:"-"`
+	private  string
+	base
+}
+
+type meta struct {
+	Labels map[string]string `json:"labels"`
+}
+
+type base struct {
+	Version int `json:"version"`
+}
+
+func TestSchema(t *testing.T) {
+	d := NewDocument(Info{Title: "test", Version: "1.0"})
+
+	assert.Equal(t, Ref("node"), d.Schema(node{}))
+	assert.Equal(t, &Schema{Type: "array", Items: Ref("node")}, d.Schema([]*node{}))
+	assert.Equal(t, &Schema{}, d.Schema(nil))
+
+	schema := d.Components.Schemas["node"]
+	assert.Equal(t, []string{"name", "meta"}, schema.Required)
+	assert.ElementsMatch(t, []string{"id", "name", "kind", "size", "tags", "parent", "children", "meta", "extra", "createdAt", "version"},
+		keys(schema.Properties))
+
+	two, ten, three, zero := 2, 10, 3, float64(0)
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64", Minimum: &zero}, schema.Properties["id"])
+	assert.Equal(t, &Schema{Type: "string", MinLength: &two, MaxLength: &ten}, schema.Properties["name"])
+	assert.Equal(t, &Schema{Type: "string", Enum: []string{"file", "dir"}}, schema.Properties["kind"])
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64", Minimum: &zero}, schema.Properties["size"])
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Type: "string"}, MaxItems: &three}, schema.Properties["tags"])
+	assert.Equal(t, Ref("node"), schema.Properties["parent"])
+	assert.Equal(t, Ref("meta"), schema.Properties["meta"])
+	assert.Equal(t, &Schema{}, schema.Properties["extra"])
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, schema.Properties["createdAt"])
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64"}, schema.Properties["version"])
+
+	assert.Equal(t, &Schema{Type: "object", Properties: map[string]*Schema{
+		"labels": {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+	}}, d.Components.Schemas["meta"])
+}
+
+func keys(m map[string]*Schema) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+)
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+func newAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{
+		db: db,
+	}
+}
+
+func (a *attachmentRepository) Create(attachment *model.Attachment) error {
+	return a.db.Create(attachment).Error
+}
+
+// Get returns the attachment of the post.
+func (a *attachmentRepository) Get(pid, id uint) (*model.Attachment, error) {
+	attachment := new(model.Attachment)
+	if err := a.db.Where("post_id = ?", pid).First(attachment, id).Error; err != nil {
+		return nil, notFound(err, model.AttachmentSubresource, id)
+	}
+	return attachment, nil
+}
+
+// List lists the attachments of the post, oldest first.
+func (a *attachmentRepository) List(pid uint) ([]model.Attachment, error) {
+	attachments := make([]model.Attachment, 0)
+	if err := a.db.Where("post_id = ?", pid).Order("id").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (a *attachmentRepository) Delete(pid, id uint) error {
+	result := a.db.Where("post_id = ?", pid).Delete(&model.Attachment{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return notFound(gorm.ErrRecordNotFound, model.AttachmentSubresource, id)
+	}
+	return nil
+}
+
+// Keys returns the keys of all attachments, the files without an attachment are orphans.
+func (a *attachmentRepository) Keys() ([]string, error) {
+	keys := make([]string, 0)
+	if err := a.db.Model(&model.Attachment{}).Pluck("key", &keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (a *attachmentRepository) Migrate() error {
+	return a.db.AutoMigrate(&model.Attachment{})
+}
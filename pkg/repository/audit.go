@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func newAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+func (a *auditRepository) Create(events []model.AuditEvent) error {
+	return a.db.Create(events).Error
+}
+
+func (a *auditRepository) List(query *model.AuditQuery) ([]model.AuditEvent, error) {
+	db := a.db.Model(&model.AuditEvent{})
+	if query.User != "" {
+		db = db.Where("user_name = ?", query.User)
+	}
+	if query.Resource != "" {
+		db = db.Where("resource = ?", query.Resource)
+	}
+	if !query.Since.IsZero() {
+		db = db.Where("time >= ?", query.Since)
+	}
+	if !query.Until.IsZero() {
+		db = db.Where("time < ?", query.Until)
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	events := make([]model.AuditEvent, 0)
+	err := db.Order(clause.OrderByColumn{Column: clause.Column{Name: "time"}, Desc: true}).Find(&events).Error
+	return events, err
+}
+
+func (a *auditRepository) DeleteBefore(t time.Time) (int64, error) {
+	result := a.db.Where("time < ?", t).Delete(&model.AuditEvent{})
+	return result.RowsAffected, result.Error
+}
+
+func (a *auditRepository) Migrate() error {
+	return a.db.AutoMigrate(&model.AuditEvent{})
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type categoryRepository struct {
+	db     *gorm.DB
+	search *searchIndex
+}
+
+func newCategoryRepository(db *gorm.DB, search *searchIndex) CategoryRepository {
+	return &categoryRepository{
+		db:     db,
+		search: search,
+	}
+}
+
+func (c *categoryRepository) List(opts *model.ListOptions) ([]model.Category, *model.ListMeta, error) {
+	query, meta, err := paginate(c.db.Model(&model.Category{}), opts, categoryListFields, clause.OrderByColumn{Column: clause.Column{Name: "name"}})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	categories := make([]model.Category, 0)
+	if err := query.Find(&categories).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := c.countPosts(categories); err != nil {
+		return nil, nil, err
+	}
+	return categories, meta, nil
+}
+
+// GetCategoryByID returns the category with its children.
+func (c *categoryRepository) GetCategoryByID(id uint) (*model.Category, error) {
+	category := new(model.Category)
+	if err := c.db.First(category, id).Error; err != nil {
+		return nil, notFound(err, model.CategoryResource, id)
+	}
+	children := make([]model.Category, 0)
+	if err := c.db.Where("parent_id = ?", id).Order("name").Find(&children).Error; err != nil {
+		return nil, err
+	}
+	if err := c.countPosts(children); err != nil {
+		return nil, err
+	}
+	category.Children = children
+
+	counts, err := postCounts(c.db, "category_posts", "category_id", []uint{id})
+	if err != nil {
+		return nil, err
+	}
+	category.PostCount = counts[id]
+	return category, nil
+}
+
+func (c *categoryRepository) countPosts(categories []model.Category) error {
+	ids := make([]uint, len(categories))
+	for i := range categories {
+		ids[i] = categories[i].ID
+	}
+	counts, err := postCounts(c.db, "category_posts", "category_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range categories {
+		categories[i].PostCount = counts[categories[i].ID]
+	}
+	return nil
+}
+
+func (c *categoryRepository) Create(category *model.Category) (*model.Category, error) {
+	err := c.db.Create(category).Error
+	return category, alreadyExists(err, model.CategoryResource, category.Name)
+}
+
+// Update renames or moves the category and reindexes its posts.
+func (c *categoryRepository) Update(category *model.Category) (*model.Category, error) {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(category).Select("Name", "ParentID").Updates(category)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apierrors.NewNotFound(model.CategoryResource, category.ID)
+		}
+		return reindexPosts(c.search.withDB(tx), "category_posts", "category_id", category.ID)
+	})
+	return category, alreadyExists(err, model.CategoryResource, category.Name)
+}
+
+// Delete deletes the category and removes it from its posts, its children become children of its parent.
+func (c *categoryRepository) Delete(id uint) error {
+	return c.db.Transaction(func(tx *gorm.DB) error {
+		category := new(model.Category)
+		if err := tx.First(category, id).Error; err != nil {
+			return notFound(err, model.CategoryResource, id)
+		}
+		if err := tx.Model(&model.Category{}).Where("parent_id = ?", id).UpdateColumn("parent_id", category.ParentID).Error; err != nil {
+			return err
+		}
+
+		posts, err := associatedPosts(tx, "category_posts", "category_id", id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM category_posts WHERE category_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(category).Error; err != nil {
+			return err
+		}
+		return c.search.withDB(tx).indexPosts(posts)
+	})
+}
+
+// Descendants returns the id of the category and the ids of all its subcategories.
+func (c *categoryRepository) Descendants(id uint) ([]uint, error) {
+	categories := make([]model.Category, 0)
+	if err := c.db.Select("id", "parent_id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	children := make(map[uint][]uint, len(categories))
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	ids := []uint{id}
+	seen := map[uint]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const commentResource = "comments"
+
+var (
+	commentUpdateFields = []string{"Content", "EditedAt", "UpdatedAt"}
+	commentStatusFields = []string{"Status", "UpdatedAt"}
+)
+
+func (p *postRepository) AddComment(comment *model.Comment) (*model.Comment, error) {
+	err := p.indexed(func(tx *gorm.DB) error {
+		return tx.Create(comment).Error
+	}, func() uint { return comment.PostID })
+	return comment, err
+}
+
+func (p *postRepository) GetComment(pid, cid uint) (*model.Comment, error) {
+	comment := new(model.Comment)
+	if err := p.db.Preload("User").Where("post_id = ?", pid).First(comment, cid).Error; err != nil {
+		return nil, notFound(err, commentResource, cid)
+	}
+	return comment, nil
+}
+
+// ListComments lists a page of the comments on the post, which are not replies, with their replies as trees.
+// Only the comments visible to the reader are listed, replies are sorted oldest first.
+func (p *postRepository) ListComments(pid uint, opts *model.ListOptions, reader model.PostReader) ([]model.Comment, *model.ListMeta, error) {
+	roots := visibleComments(p.db.Model(&model.Comment{}).Where("post_id = ? AND parent_id IS NULL", pid), reader)
+	query, meta, err := paginate(roots, opts, commentListFields, clause.OrderByColumn{Column: clause.Column{Name: "created_at"}})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	comments := make([]model.Comment, 0)
+	if err := query.Preload("User").Find(&comments).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := p.loadReplies(comments, reader); err != nil {
+		return nil, nil, err
+	}
+	return comments, meta, nil
+}
+
+// loadReplies sets the replies of the comments visible to the reader, one level of the trees at a time.
+func (p *postRepository) loadReplies(comments []model.Comment, reader model.PostReader) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+
+	replies := make([]model.Comment, 0)
+	if err := visibleComments(p.db.Model(&model.Comment{}).Where("parent_id IN ?", ids), reader).
+		Preload("User").Order("created_at").Order("id").Find(&replies).Error; err != nil {
+		return err
+	}
+	// the replies are complete trees before they are copied to their parents
+	if err := p.loadReplies(replies, reader); err != nil {
+		return err
+	}
+
+	byParent := make(map[uint][]model.Comment, len(comments))
+	for _, reply := range replies {
+		byParent[*reply.ParentID] = append(byParent[*reply.ParentID], reply)
+	}
+	for i := range comments {
+		comments[i].Replies = byParent[comments[i].ID]
+	}
+	return nil
+}
+
+// visibleComments filters the comments visible to the reader, see model.Comment.VisibleTo.
+func visibleComments(query *gorm.DB, reader model.PostReader) *gorm.DB {
+	if reader.Editor {
+		return query
+	}
+	if reader.UserID == 0 {
+		return query.Where("comments.status = ?", model.CommentApproved)
+	}
+	return query.Where("(comments.status = ? OR comments.user_id = ?)", model.CommentApproved, reader.UserID)
+}
+
+// ListCommentsByStatus lists the comments of all posts with the status, like the pending comments to moderate, oldest first.
+func (p *postRepository) ListCommentsByStatus(status model.CommentStatus, opts *model.ListOptions) ([]model.Comment, *model.ListMeta, error) {
+	query, meta, err := paginate(p.db.Model(&model.Comment{}).Where("status = ? AND deleted_at IS NULL", status), opts, commentListFields,
+		clause.OrderByColumn{Column: clause.Column{Name: "created_at"}})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	comments := make([]model.Comment, 0)
+	if err := query.Preload("User").Find(&comments).Error; err != nil {
+		return nil, nil, err
+	}
+	return comments, meta, nil
+}
+
+// UpdateComment updates the content and edit time of the comment.
+func (p *postRepository) UpdateComment(comment *model.Comment) (*model.Comment, error) {
+	err := p.indexed(func(tx *gorm.DB) error {
+		return tx.Model(comment).Select(commentUpdateFields).Updates(comment).Error
+	}, func() uint { return comment.PostID })
+	return comment, err
+}
+
+// UpdateCommentStatus updates the moderation status of the comment.
+func (p *postRepository) UpdateCommentStatus(comment *model.Comment) (*model.Comment, error) {
+	err := p.indexed(func(tx *gorm.DB) error {
+		return tx.Model(comment).Select(commentStatusFields).Updates(comment).Error
+	}, func() uint { return comment.PostID })
+	return comment, err
+}
+
+// DelComment deletes the comment. A comment with replies is kept without its content, so the thread keeps its structure,
+// deleted comments are removed with their last reply.
+func (p *postRepository) DelComment(pid, cid uint) error {
+	return p.indexed(func(tx *gorm.DB) error {
+		comment := new(model.Comment)
+		if err := tx.Where("post_id = ? AND deleted_at IS NULL", pid).First(comment, cid).Error; err != nil {
+			return notFound(err, commentResource, cid)
+		}
+
+		for {
+			var replies int64
+			if err := tx.Model(&model.Comment{}).Where("parent_id = ?", comment.ID).Count(&replies).Error; err != nil {
+				return err
+			}
+			if replies > 0 {
+				if comment.DeletedAt != nil {
+					return nil
+				}
+				now := time.Now()
+				comment.Content, comment.DeletedAt, comment.UpdatedAt = "", &now, now
+				return tx.Model(comment).Select("Content", "DeletedAt", "UpdatedAt").Updates(comment).Error
+			}
+
+			if err := tx.Delete(comment).Error; err != nil {
+				return err
+			}
+			if comment.ParentID == nil {
+				return nil
+			}
+			parent := new(model.Comment)
+			if err := tx.First(parent, *comment.ParentID).Error; err != nil {
+				return notFound(err, commentResource, *comment.ParentID)
+			}
+			if parent.DeletedAt == nil {
+				return nil
+			}
+			comment = parent
+		}
+	}, func() uint { return pid })
+}
+
+// migrateCommentDepth sets the depth of replies created before comments had depths.
+func migrateCommentDepth(db *gorm.DB) error {
+	comments := make([]model.Comment, 0)
+	if err := db.Select("id", "parent_id", "depth").Find(&comments).Error; err != nil {
+		return err
+	}
+	parents := make(map[uint]uint, len(comments))
+	for _, c := range comments {
+		if c.ParentID != nil {
+			parents[c.ID] = *c.ParentID
+		}
+	}
+
+	for _, c := range comments {
+		if c.ParentID == nil || c.Depth != 0 {
+			continue
+		}
+		depth := uint(0)
+		// the number of comments bounds the depth, even if the parents had a cycle
+		for id := c.ID; depth < uint(len(comments)); depth++ {
+			parent, ok := parents[id]
+			if !ok {
+				break
+			}
+			id = parent
+		}
+		if err := db.Model(&model.Comment{}).Where("id = ?", c.ID).UpdateColumn("depth", depth).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
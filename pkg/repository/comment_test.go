@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestComments(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+
+	alice, err := r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	bob, err := r.User().Create(&model.User{Name: "bob", Password: "123456"})
+	require.Nil(t, err)
+	post, err := r.Post().Create(alice, &model.Post{Name: "post", Content: "content"})
+	require.Nil(t, err)
+
+	add := func(user *model.User, parent *model.Comment, content string, status model.CommentStatus) *model.Comment {
+		comment := &model.Comment{PostID: post.ID, UserID: user.ID, Content: content, Status: status}
+		if parent != nil {
+			comment.ParentID, comment.Depth = &parent.ID, parent.Depth+1
+		}
+		_, err := r.Post().AddComment(comment)
+		require.Nil(t, err)
+		return comment
+	}
+	first := add(alice, nil, "first", model.CommentApproved)
+	reply := add(bob, first, "reply", model.CommentApproved)
+	add(alice, reply, "nested", model.CommentApproved)
+	add(bob, first, "held", model.CommentPending)
+	second := add(bob, nil, "second", model.CommentApproved)
+
+	// tree renders the contents of the comments and their replies
+	var tree func(comments []model.Comment) []interface{}
+	tree = func(comments []model.Comment) []interface{} {
+		result := make([]interface{}, 0)
+		for _, c := range comments {
+			result = append(result, c.Content)
+			if len(c.Replies) > 0 {
+				result = append(result, tree(c.Replies))
+			}
+		}
+		return result
+	}
+	list := func(reader model.PostReader, opts *model.ListOptions) []interface{} {
+		comments, _, err := r.Post().ListComments(post.ID, opts, reader)
+		require.Nil(t, err)
+		return tree(comments)
+	}
+
+	assert.Equal(t, []interface{}{"first", []interface{}{"reply", []interface{}{"nested"}}, "second"}, list(model.PostReader{UserID: alice.ID}, nil))
+	assert.Equal(t, []interface{}{"first", []interface{}{"reply", []interface{}{"nested"}, "held"}, "second"}, list(model.PostReader{UserID: bob.ID}, nil))
+	assert.Equal(t, []interface{}{"second"}, list(model.PostReader{Editor: true}, &model.ListOptions{Limit: 1, Page: 2}))
+
+	pending, meta, err := r.Post().ListCommentsByStatus(model.CommentPending, nil)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), meta.Total)
+	assert.Equal(t, "held", pending[0].Content)
+	assert.Equal(t, "bob", pending[0].User.Name)
+
+	// comments with replies are kept without content, deleted comments are removed with their last reply
+	require.Nil(t, r.Post().DelComment(post.ID, reply.ID))
+	assert.Equal(t, []interface{}{"first", []interface{}{"", []interface{}{"nested"}}, "second"}, list(model.PostReader{}, nil))
+	assert.NotNil(t, r.Post().DelComment(post.ID, reply.ID))
+
+	comments, _, err := r.Post().ListComments(post.ID, nil, model.PostReader{})
+	require.Nil(t, err)
+	require.Nil(t, r.Post().DelComment(post.ID, comments[0].Replies[0].Replies[0].ID))
+	assert.Equal(t, []interface{}{"first", "second"}, list(model.PostReader{}, nil))
+	require.Nil(t, r.Post().DelComment(post.ID, second.ID))
+	assert.Equal(t, []interface{}{"first"}, list(model.PostReader{}, nil))
+
+	// replies created before depths get their depth by the migration
+	require.Nil(t, db.Model(&model.Comment{}).Where("parent_id IS NOT NULL").UpdateColumn("depth", 0).Error)
+	deep := add(bob, add(alice, first, "one", model.CommentApproved), "two", model.CommentApproved)
+	require.Nil(t, db.Model(deep).UpdateColumn("depth", 0).Error)
+	require.Nil(t, r.Migrate())
+	migrated, err := r.Post().GetComment(post.ID, deep.ID)
+	require.Nil(t, err)
+	assert.Equal(t, uint(2), migrated.Depth)
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+
+	"gorm.io/gorm"
+)
+
+// notFound converts gorm.ErrRecordNotFound to a NotFound error of the resource, other errors are returned as is.
+func notFound(err error, resource string, name interface{}) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apierrors.NewNotFound(resource, name)
+	}
+	return err
+}
+
+// alreadyExists converts gorm.ErrDuplicatedKey to an AlreadyExists error of the resource, other errors are returned as is.
+func alreadyExists(err error, resource string, name interface{}) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return apierrors.NewAlreadyExists(resource, name)
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const feedTokenResource = "feed tokens"
+
+type feedTokenRepository struct {
+	db *gorm.DB
+}
+
+func newFeedTokenRepository(db *gorm.DB) FeedTokenRepository {
+	return &feedTokenRepository{
+		db: db,
+	}
+}
+
+// Create stores the token, it replaces the token of the user.
+func (f *feedTokenRepository) Create(token *model.FeedToken) error {
+	return f.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"hash", "created_at"}),
+	}).Create(token).Error
+}
+
+func (f *feedTokenRepository) GetByHash(hash string) (*model.FeedToken, error) {
+	token := new(model.FeedToken)
+	if err := f.db.Where(&model.FeedToken{Hash: hash}).First(token).Error; err != nil {
+		return nil, notFound(err, feedTokenResource, hash)
+	}
+	return token, nil
+}
+
+func (f *feedTokenRepository) Delete(uid uint) error {
+	return f.db.Where(&model.FeedToken{UserID: uid}).Delete(&model.FeedToken{}).Error
+}
+
+func (f *feedTokenRepository) Migrate() error {
+	return f.db.AutoMigrate(&model.FeedToken{})
+}
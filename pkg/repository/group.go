@@ -8,7 +8,7 @@ import (
 )
 
 var (
-	groupUpdateFields = []string{"Describe", "Roles", "UpdaterId"}
+	groupUpdateFields = []string{"Describe", "Roles", "UpdaterId", "UpdatedAt"}
 )
 
 type groupRepository struct {
@@ -21,19 +21,24 @@ func newGroupRepository(db *gorm.DB) GroupRepository {
 	}
 }
 
-func (g *groupRepository) List() ([]model.Group, error) {
+func (g *groupRepository) List(opts *model.ListOptions) ([]model.Group, *model.ListMeta, error) {
+	query, meta, err := paginate(g.db.Model(&model.Group{}), opts, groupListFields, clause.OrderByColumn{Column: clause.Column{Name: "name"}})
+	if err != nil {
+		return nil, nil, err
+	}
+
 	groups := make([]model.Group, 0)
-	if err := g.db.Order("name").Preload("Roles").Find(&groups).Error; err != nil {
-		return nil, err
+	if err := query.Preload("Roles").Find(&groups).Error; err != nil {
+		return nil, nil, err
 	}
-	return groups, nil
+	return groups, meta, nil
 }
 
 func (g *groupRepository) Create(user *model.User, group *model.Group) (*model.Group, error) {
 	group.CreatorId = user.ID
 	group.Users = []model.User{*user}
 	err := g.db.Create(group).Error
-	return group, err
+	return group, alreadyExists(err, model.GroupResource, group.Name)
 }
 
 func (g *groupRepository) CreateGroups(groups []model.Group, conds ...clause.Expression) error {
@@ -79,7 +84,7 @@ func (g *groupRepository) DelRole(role *model.Role, group *model.Group) error {
 func (g *groupRepository) GetGroupByID(id uint) (*model.Group, error) {
 	group := new(model.Group)
 	if err := g.db.Preload("Users").Preload("Roles").First(group, id).Error; err != nil {
-		return nil, err
+		return nil, notFound(err, model.GroupResource, id)
 	}
 
 	return group, nil
@@ -88,19 +93,19 @@ func (g *groupRepository) GetGroupByID(id uint) (*model.Group, error) {
 func (g *groupRepository) GetGroupByName(name string) (*model.Group, error) {
 	group := new(model.Group)
 	if err := g.db.Preload("Users").Preload("Roles").Where("name = ?", name).First(group).Error; err != nil {
-		return nil, err
+		return nil, notFound(err, model.GroupResource, name)
 	}
 
 	return group, nil
 }
 
 func (g *groupRepository) Update(group *model.Group) (*model.Group, error) {
-	err := g.db.Model(group).Select(groupUpdateFields).Updates(group).Error
+	err := updateVersion(g.db, group, &group.Version, groupUpdateFields)
 	return group, err
 }
 
-func (g *groupRepository) Delete(id uint) error {
-	return g.db.Delete(&model.Group{}, id).Error
+func (g *groupRepository) Delete(id uint, version uint64) error {
+	return deleteVersion(g.db, &model.Group{ID: id}, version)
 }
 
 func (g *groupRepository) RoleBinding(role *model.Role, group *model.Group) error {
@@ -108,5 +113,8 @@ func (g *groupRepository) RoleBinding(role *model.Role, group *model.Group) erro
 }
 
 func (g *groupRepository) Migrate() error {
+	if err := dropUniqueName(g.db, &model.Group{}); err != nil {
+		return err
+	}
 	return g.db.AutoMigrate(&model.Group{})
 }
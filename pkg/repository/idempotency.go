@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+)
+
+const idempotencyResource = "idempotency keys"
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func newIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{
+		db: db,
+	}
+}
+
+// Create stores a record of a request in progress, an AlreadyExists error is returned if the key is used.
+func (i *idempotencyRepository) Create(record *model.IdempotencyRecord) error {
+	return alreadyExists(i.db.Create(record).Error, idempotencyResource, record.Key)
+}
+
+func (i *idempotencyRepository) Get(key string) (*model.IdempotencyRecord, error) {
+	record := new(model.IdempotencyRecord)
+	if err := i.db.Where(&model.IdempotencyRecord{Key: key}).First(record).Error; err != nil {
+		return nil, notFound(err, idempotencyResource, key)
+	}
+	return record, nil
+}
+
+// Lease updates the stored record only if it expired or its lease ended, so only one retry takes a key over.
+func (i *idempotencyRepository) Lease(record *model.IdempotencyRecord, now time.Time) (bool, error) {
+	result := i.db.Model(record).
+		Where("expires_at < ? OR (status_code = 0 AND (locked_until IS NULL OR locked_until < ?))", now, now).
+		Select("Fingerprint", "StatusCode", "ContentType", "Body", "ExpiresAt", "LockedUntil", "Token").
+		Updates(record)
+	return result.RowsAffected == 1, result.Error
+}
+
+// Update stores the response of the record if the request still holds its lease,
+// a Conflict error is returned if the record was taken over by another request.
+func (i *idempotencyRepository) Update(record *model.IdempotencyRecord) error {
+	result := i.db.Model(record).Where("token = ?", record.Token).Select("StatusCode", "ContentType", "Body").Updates(record)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apierrors.NewConflict(fmt.Errorf("%s %s was taken over by another request", idempotencyResource, record.Key), nil)
+	}
+	return nil
+}
+
+// Delete deletes the record of the key if the request of the token still holds its lease.
+func (i *idempotencyRepository) Delete(key, token string) error {
+	return i.db.Where(map[string]interface{}{"key": key, "token": token}).Delete(&model.IdempotencyRecord{}).Error
+}
+
+func (i *idempotencyRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := i.db.Where("expires_at < ?", now).Delete(&model.IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
+
+func (i *idempotencyRepository) Migrate() error {
+	return i.db.AutoMigrate(&model.IdempotencyRecord{})
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestIdempotencyLease(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+
+	now := time.Now()
+	first := &model.IdempotencyRecord{Key: "1:k", Fingerprint: "a", ExpiresAt: now.Add(time.Hour), LockedUntil: now.Add(time.Minute), Token: "first"}
+	require.Nil(t, r.Idempotency().Create(first))
+
+	retry := func() *model.IdempotencyRecord {
+		return &model.IdempotencyRecord{ID: first.ID, Key: "1:k", Fingerprint: "a", ExpiresAt: now.Add(2 * time.Hour), LockedUntil: now.Add(2 * time.Minute), Token: "retry"}
+	}
+
+	// the lease of the first request is not over
+	leased, err := r.Idempotency().Lease(retry(), now)
+	require.Nil(t, err)
+	assert.False(t, leased)
+
+	leased, err = r.Idempotency().Lease(retry(), now.Add(time.Minute+time.Second))
+	require.Nil(t, err)
+	assert.True(t, leased)
+	stored, err := r.Idempotency().Get("1:k")
+	require.Nil(t, err)
+	assert.True(t, stored.LockedUntil.Equal(now.Add(2*time.Minute)), stored.LockedUntil)
+
+	// the first request lost its lease, it can not complete or release the record
+	first.StatusCode = 500
+	assert.True(t, apierrors.IsConflict(r.Idempotency().Update(first)))
+	require.Nil(t, r.Idempotency().Delete(first.Key, first.Token))
+	stored, err = r.Idempotency().Get("1:k")
+	require.Nil(t, err)
+	assert.Zero(t, stored.StatusCode)
+	assert.Equal(t, "retry", stored.Token)
+
+	// a stored response is kept until it expires
+	stored.StatusCode = 201
+	require.Nil(t, r.Idempotency().Update(stored))
+	leased, err = r.Idempotency().Lease(retry(), now.Add(time.Hour))
+	require.Nil(t, err)
+	assert.False(t, leased)
+	leased, err = r.Idempotency().Lease(retry(), now.Add(2*time.Hour+time.Second))
+	require.Nil(t, err)
+	assert.True(t, leased)
+
+	// records of requests in progress stored before leases can be taken over
+	require.Nil(t, db.Model(&model.IdempotencyRecord{}).Where("id = ?", first.ID).Update("locked_until", nil).Error)
+	leased, err = r.Idempotency().Lease(retry(), now)
+	require.Nil(t, err)
+	assert.True(t, leased)
+
+	// the request holding the lease releases the record
+	require.Nil(t, r.Idempotency().Delete("1:k", "retry"))
+	_, err = r.Idempotency().Get("1:k")
+	assert.True(t, apierrors.IsNotFound(err))
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/eastygh/webm-nas/pkg/model"
 	"gorm.io/gorm/clause"
@@ -11,7 +12,16 @@ type Repository interface {
 	User() UserRepository
 	Group() GroupRepository
 	Post() PostRepository
+	Tag() TagRepository
+	Category() CategoryRepository
 	RBAC() RBACRepository
+	Audit() AuditRepository
+	Trash() TrashRepository
+	Idempotency() IdempotencyRepository
+	FeedToken() FeedTokenRepository
+	Attachment() AttachmentRepository
+	Stats() StatsRepository
+	Transaction(fn func(Repository) error) error
 	Close() error
 	Ping(ctx context.Context) error
 	Init() error
@@ -26,7 +36,7 @@ type UserRepository interface {
 	GetUserByID(uint) (*model.User, error)
 	GetUserByAuthID(authType, authID string) (*model.User, error)
 	GetUserByName(string) (*model.User, error)
-	List() (model.Users, error)
+	List(opts *model.ListOptions) (model.Users, *model.ListMeta, error)
 	Create(*model.User) (*model.User, error)
 	Update(*model.User) (*model.User, error)
 	Delete(*model.User) error
@@ -41,11 +51,12 @@ type UserRepository interface {
 type GroupRepository interface {
 	GetGroupByID(uint) (*model.Group, error)
 	GetGroupByName(string) (*model.Group, error)
-	List() ([]model.Group, error)
+	List(opts *model.ListOptions) ([]model.Group, *model.ListMeta, error)
 	Create(*model.User, *model.Group) (*model.Group, error)
 	CreateGroups(groups []model.Group, conds ...clause.Expression) error
 	Update(*model.Group) (*model.Group, error)
-	Delete(uint) error
+	// Delete deletes the group, a non zero version must be the current version
+	Delete(id uint, version uint64) error
 	GetUsers(*model.Group) (model.Users, error)
 	AddUser(user *model.User, group *model.Group) error
 	DelUser(user *model.User, group *model.Group) error
@@ -58,34 +69,137 @@ type GroupRepository interface {
 type PostRepository interface {
 	GetPostByID(uint) (*model.Post, error)
 	GetPostByName(string) (*model.Post, error)
-	List() ([]model.Post, error)
+	// List lists the posts visible to the reader
+	List(opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error)
+	// ListByTag lists the posts with the tag visible to the reader
+	ListByTag(tid uint, opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error)
+	// ListByCategories lists the posts in any of the categories visible to the reader
+	ListByCategories(cids []uint, opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error)
 	Create(*model.User, *model.Post) (*model.Post, error)
-	Update(*model.Post) (*model.Post, error)
-	Delete(uint) error
+	// Update updates the post and saves it as a revision, only the latest maxRevisions are kept if it is positive
+	Update(post *model.Post, revision *model.PostRevision, maxRevisions int) (*model.Post, error)
+	UpdateStatus(*model.Post) (*model.Post, error)
+	// SetContent replaces the content of the post and its latest revision without saving a revision
+	SetContent(id uint, content string) error
+	PublishDue(now time.Time) ([]model.Post, error)
+	// Delete deletes the post, a non zero version must be the current version
+	Delete(id uint, version uint64) error
 	GetTags(*model.Post) ([]model.Tag, error)
 	GetCategories(*model.Post) ([]model.Category, error)
-	IncView(id uint) error
 	AddLike(pid, uid uint) error
 	DelLike(pid, uid uint) error
 	GetLike(pid, uid uint) (bool, error)
 	GetLikeByUser(uid uint) ([]model.Like, error)
 	AddComment(comment *model.Comment) (*model.Comment, error)
-	DelComment(id string) error
-	ListComment(pid string) ([]model.Comment, error)
+	GetComment(pid, cid uint) (*model.Comment, error)
+	// DelComment deletes the comment, a comment with replies is kept without its content
+	DelComment(pid, cid uint) error
+	// ListComments lists the comments on the post visible to the reader as trees of replies
+	ListComments(pid uint, opts *model.ListOptions, reader model.PostReader) ([]model.Comment, *model.ListMeta, error)
+	ListCommentsByStatus(status model.CommentStatus, opts *model.ListOptions) ([]model.Comment, *model.ListMeta, error)
+	UpdateComment(comment *model.Comment) (*model.Comment, error)
+	UpdateCommentStatus(comment *model.Comment) (*model.Comment, error)
+	ListRevisions(pid uint, opts *model.ListOptions) ([]model.PostRevision, *model.ListMeta, error)
+	GetRevision(pid, revision uint) (*model.PostRevision, error)
+	// Search searches posts by FTS5 on SQLite built with it, by LIKE otherwise
+	Search(q *model.SearchQuery, opts *model.ListOptions, reader model.PostReader) (*model.SearchResult, error)
 	Migrate() error
 }
 
+// TagRepository manages tags, tags of posts are created with the posts if they do not exist.
+type TagRepository interface {
+	List(opts *model.ListOptions) ([]model.Tag, *model.ListMeta, error)
+	GetTagByID(id uint) (*model.Tag, error)
+	Create(tag *model.Tag) (*model.Tag, error)
+	Update(tag *model.Tag) (*model.Tag, error)
+	Delete(id uint) error
+	// Merge moves the posts of the tag from to the tag into and deletes the tag from
+	Merge(from, into uint) (*model.Tag, error)
+}
+
+// CategoryRepository manages categories, categories of posts are created with the posts if they do not exist.
+type CategoryRepository interface {
+	List(opts *model.ListOptions) ([]model.Category, *model.ListMeta, error)
+	GetCategoryByID(id uint) (*model.Category, error)
+	Create(category *model.Category) (*model.Category, error)
+	Update(category *model.Category) (*model.Category, error)
+	// Delete deletes the category, its children become children of its parent
+	Delete(id uint) error
+	// Descendants returns the id of the category and the ids of all its subcategories
+	Descendants(id uint) ([]uint, error)
+}
+
 type RBACRepository interface {
-	List() ([]model.Role, error)
+	List(opts *model.ListOptions) ([]model.Role, *model.ListMeta, error)
 	ListResources() ([]model.Resource, error)
 	Create(role *model.Role) (*model.Role, error)
 	CreateResource(resource *model.Resource) (*model.Resource, error)
 	CreateResources(resources []model.Resource, conds ...clause.Expression) error
-	GetRoleByID(id int) (*model.Role, error)
+	GetRoleByID(id uint) (*model.Role, error)
 	GetResource(id int) (*model.Resource, error)
 	GetRoleByName(name string) (*model.Role, error)
 	Update(role *model.Role) (*model.Role, error)
-	Delete(id uint) error
+	// Delete deletes the role, a non zero version must be the current version
+	Delete(id uint, version uint64) error
 	DeleteResource(id uint) error
 	Migrate() error
 }
+
+// TrashRepository lists, restores and purges soft deleted objects of users, groups and posts.
+type TrashRepository interface {
+	List(resource string, opts *model.ListOptions) ([]model.TrashItem, *model.ListMeta, error)
+	Restore(resource string, id uint) error
+	Purge(resource string, id uint) error
+	PurgeBefore(t time.Time) (int64, error)
+}
+
+// IdempotencyRepository stores the responses of requests with idempotency keys.
+type IdempotencyRepository interface {
+	Create(record *model.IdempotencyRecord) error
+	Get(key string) (*model.IdempotencyRecord, error)
+	// Lease replaces the stored record with the ID of record if it expired or its lease ended before now,
+	// it returns false if the record is used by another request
+	Lease(record *model.IdempotencyRecord, now time.Time) (bool, error)
+	// Update and Delete only change the record if its token is still the token of the request holding the lease
+	Update(record *model.IdempotencyRecord) error
+	Delete(key, token string) error
+	// DeleteExpired deletes the records which expired before now
+	DeleteExpired(now time.Time) (int64, error)
+	Migrate() error
+}
+
+// FeedTokenRepository stores the feed tokens of users by their hashes.
+type FeedTokenRepository interface {
+	// Create stores the token, it replaces the token of the user
+	Create(token *model.FeedToken) error
+	GetByHash(hash string) (*model.FeedToken, error)
+	Delete(uid uint) error
+	Migrate() error
+}
+
+// AttachmentRepository stores the attachments of posts, their content is stored by the attachment service.
+type AttachmentRepository interface {
+	Create(attachment *model.Attachment) error
+	Get(pid, id uint) (*model.Attachment, error)
+	List(pid uint) ([]model.Attachment, error)
+	Delete(pid, id uint) error
+	// Keys returns the keys of the content of all attachments
+	Keys() ([]string, error)
+	Migrate() error
+}
+
+// StatsRepository stores the daily views and likes of posts.
+type StatsRepository interface {
+	Add(stats []model.PostStat) error
+	List(pid uint, from, to string) ([]model.PostStat, error)
+	// Top returns the posts with the most views or likes, by is views or likes
+	Top(by string, from, to string, limit int, reader model.PostReader) ([]model.TopPost, error)
+	Migrate() error
+}
+
+type AuditRepository interface {
+	Create(events []model.AuditEvent) error
+	List(query *model.AuditQuery) ([]model.AuditEvent, error)
+	DeleteBefore(t time.Time) (int64, error)
+	Migrate() error
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// listFields maps the json fields which can be selected and sorted to the columns.
+type listFields map[string]string
+
+var (
+	userListFields = listFields{
+		"id":        "id",
+		"name":      "name",
+		"email":     "email",
+		"createdAt": "created_at",
+		"updatedAt": "updated_at",
+	}
+	groupListFields = listFields{
+		"id":        "id",
+		"name":      "name",
+		"kind":      "kind",
+		"creatorId": "creator_id",
+		"createdAt": "created_at",
+		"updatedAt": "updated_at",
+	}
+	postListFields = listFields{
+		"id":          "id",
+		"name":        "name",
+		"creatorId":   "creator_id",
+		"status":      "status",
+		"views":       "views",
+		"publishedAt": "published_at",
+		"createdAt":   "created_at",
+		"updatedAt":   "updated_at",
+	}
+	commentListFields = listFields{
+		"id":        "id",
+		"postId":    "post_id",
+		"userId":    "user_id",
+		"status":    "status",
+		"createdAt": "created_at",
+		"updatedAt": "updated_at",
+	}
+	tagListFields = listFields{
+		"id":   "id",
+		"name": "name",
+	}
+	categoryListFields = listFields{
+		"id":       "id",
+		"name":     "name",
+		"parentId": "parent_id",
+	}
+	revisionListFields = listFields{
+		"revision":  "revision",
+		"authorId":  "author_id",
+		"createdAt": "created_at",
+	}
+	roleListFields = listFields{
+		"id":        "id",
+		"name":      "name",
+		"scope":     "scope",
+		"namespace": "namespace",
+	}
+)
+
+type continueToken struct {
+	Offset int `json:"offset"`
+}
+
+func encodeContinue(offset int) string {
+	data, _ := json.Marshal(continueToken{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeContinue(token string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, apierrors.NewBadRequest(fmt.Errorf("%w: invalid continue token", model.ErrInvalidListOptions))
+	}
+	ct := continueToken{}
+	if err := json.Unmarshal(data, &ct); err != nil || ct.Offset < 0 {
+		return 0, apierrors.NewBadRequest(fmt.Errorf("%w: invalid continue token", model.ErrInvalidListOptions))
+	}
+	return ct.Offset, nil
+}
+
+// paginate filters the query by the selectors, counts the matched rows and returns the query of the page.
+// The query must have a model, defaultSort is used if opts has no sort field.
+// Rows are always ordered by id at last, so pages are stable.
+func paginate(query *gorm.DB, opts *model.ListOptions, fields listFields, defaultSort clause.OrderByColumn) (*gorm.DB, *model.ListMeta, error) {
+	if opts == nil {
+		opts = &model.ListOptions{}
+	}
+
+	for _, s := range opts.Selectors {
+		column, ok := fields[s.Field]
+		if !ok {
+			return nil, nil, apierrors.NewBadRequest(fmt.Errorf("%w: field %s can not be selected", model.ErrInvalidListOptions, s.Field))
+		}
+		switch s.Operator {
+		case model.SelectorEquals:
+			query = query.Where(clause.Eq{Column: clause.Column{Name: column}, Value: s.Value})
+		case model.SelectorNotEquals:
+			query = query.Where(clause.Neq{Column: clause.Column{Name: column}, Value: s.Value})
+		case model.SelectorContains:
+			// % and _ of the value match themselves
+			query = query.Where(clause.Expr{SQL: `? LIKE ? ESCAPE '\'`, Vars: []interface{}{clause.Column{Name: column}, "%" + escapeLike(s.Value) + "%"}})
+		default:
+			return nil, nil, apierrors.NewBadRequest(fmt.Errorf("%w: unsupported selector operator %s", model.ErrInvalidListOptions, s.Operator))
+		}
+	}
+
+	sort := defaultSort
+	if opts.SortBy != "" {
+		column, ok := fields[opts.SortBy]
+		if !ok {
+			return nil, nil, apierrors.NewBadRequest(fmt.Errorf("%w: field %s can not be sorted", model.ErrInvalidListOptions, opts.SortBy))
+		}
+		sort = clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: opts.Desc}
+	}
+
+	offset, err := listOffset(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// a new session, so the query can be used for counting and finding
+	query = query.Session(&gorm.Session{})
+
+	meta := &model.ListMeta{}
+	if err := query.Count(&meta.Total).Error; err != nil {
+		return nil, nil, err
+	}
+
+	query = query.Order(sort)
+	if sort.Column.Name != "id" {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: sort.Desc})
+	}
+	if opts.Limit > 0 {
+		query = query.Offset(offset).Limit(opts.Limit)
+		meta.Continue = nextContinue(opts, offset, meta.Total)
+	} else if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	return query, meta, nil
+}
+
+// listOffset returns the offset of the page selected by the continue token or page number.
+func listOffset(opts *model.ListOptions) (int, error) {
+	switch {
+	case opts.Continue != "" && opts.Page > 0:
+		return 0, apierrors.NewBadRequest(fmt.Errorf("%w: continue and page can not be used together", model.ErrInvalidListOptions))
+	case opts.Continue != "":
+		return decodeContinue(opts.Continue)
+	case opts.Page > 1:
+		return (opts.Page - 1) * opts.Limit, nil
+	}
+	return 0, nil
+}
+
+// nextContinue returns the token of the page after the page at offset, it is empty on the last page.
+func nextContinue(opts *model.ListOptions, offset int, total int64) string {
+	if next := offset + opts.Limit; opts.Limit > 0 && int64(next) < total {
+		return encodeContinue(next)
+	}
+	return ""
+}
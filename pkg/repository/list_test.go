@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestListSelectors(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+	for _, name := range []string{"a_b", "axb", "100%", "1000", `a\b`, "admin"} {
+		_, err := r.User().Create(&model.User{Name: name, Password: "123456"})
+		require.Nil(t, err)
+	}
+
+	testCases := []struct {
+		selector string
+		expected []string
+	}{
+		{selector: "name~=b", expected: []string{`a\b`, "a_b", "axb"}},
+		{selector: "name~=_", expected: []string{"a_b"}},
+		{selector: "name~=%", expected: []string{"100%"}},
+		{selector: "name~=0%", expected: []string{"100%"}},
+		{selector: `name~=\`, expected: []string{`a\b`}},
+		{selector: "name~=a_", expected: []string{"a_b"}},
+		{selector: "name=axb", expected: []string{"axb"}},
+		{selector: "name~=a,name!=admin", expected: []string{`a\b`, "a_b", "axb"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.selector, func(t *testing.T) {
+			selectors, err := model.ParseSelectors(tc.selector)
+			require.Nil(t, err)
+			users, _, err := r.User().List(&model.ListOptions{Selectors: selectors})
+			require.Nil(t, err)
+			names := make([]string, 0, len(users))
+			for _, user := range users {
+				names = append(names, user.Name)
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+}
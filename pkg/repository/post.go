@@ -1,26 +1,117 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
 	"github.com/eastygh/webm-nas/pkg/model"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+var (
+	postUpdateFields = []string{"Name", "Content", "Summary", "UpdatedAt"}
+	postStatusFields = []string{"Status", "PublishedAt", "UpdatedAt"}
+)
+
 type postRepository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	search *searchIndex
 }
 
-func newPostRepository(db *gorm.DB) PostRepository {
+func newPostRepository(db *gorm.DB, search *searchIndex) PostRepository {
 	return &postRepository{
-		db: db,
+		db:     db,
+		search: search,
 	}
 }
 
-func (p *postRepository) List() ([]model.Post, error) {
+// indexed runs fn in a transaction and updates the search index of the post, id is called after fn.
+func (p *postRepository) indexed(fn func(tx *gorm.DB) error, id func() uint) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return p.search.withDB(tx).Index(id())
+	})
+}
+
+func (p *postRepository) List(opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error) {
+	return p.list(p.db.Model(&model.Post{}), opts, reader)
+}
+
+func (p *postRepository) ListByTag(tid uint, opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error) {
+	return p.list(p.db.Model(&model.Post{}).Where("posts.id IN (SELECT post_id FROM tag_posts WHERE tag_id = ?)", tid), opts, reader)
+}
+
+func (p *postRepository) ListByCategories(cids []uint, opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error) {
+	return p.list(p.db.Model(&model.Post{}).Where("posts.id IN (SELECT post_id FROM category_posts WHERE category_id IN ?)", cids), opts, reader)
+}
+
+// list lists the posts of the query visible to the reader without their content.
+func (p *postRepository) list(query *gorm.DB, opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error) {
+	query, meta, err := paginate(visiblePosts(query, reader), opts, postListFields, clause.OrderByColumn{Column: clause.Column{Name: "created_at"}, Desc: true})
+	if err != nil {
+		return nil, nil, err
+	}
+
 	posts := make([]model.Post, 0)
-	if err := p.db.Omit("content").Preload("Creator").Preload("Tags").Preload("Categories").Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}, Desc: true}).Find(&posts).Error; err != nil {
-		return nil, err
+	if err := query.Omit("content").Preload("Creator").Preload("Tags").Preload("Categories").Find(&posts).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := p.countLikes(posts); err != nil {
+		return nil, nil, err
+	}
+	return posts, meta, nil
+}
+
+// linkTaxonomies sets the tags and categories of the post by their names, tags and categories which do not exist are created.
+func linkTaxonomies(tx *gorm.DB, post *model.Post) error {
+	for i := range post.Tags {
+		tag := model.Tag{Name: post.Tags[i].Name}
+		if err := tx.Where(tag).FirstOrCreate(&tag).Error; err != nil {
+			return err
+		}
+		post.Tags[i] = tag
+	}
+	for i := range post.Categories {
+		category := model.Category{Name: post.Categories[i].Name}
+		if err := tx.Where(category).FirstOrCreate(&category).Error; err != nil {
+			return err
+		}
+		post.Categories[i] = category
+	}
+	return nil
+}
+
+// replaceTaxonomies replaces the tags and categories of the post, nil tags or categories are cleared.
+func replaceTaxonomies(tx *gorm.DB, post *model.Post) error {
+	if err := linkTaxonomies(tx, post); err != nil {
+		return err
+	}
+	if err := tx.Model(post).Association(model.TagAssociation).Replace(post.Tags); err != nil {
+		return err
+	}
+	return tx.Model(post).Association(model.CategoriesAssociation).Replace(post.Categories)
+}
+
+// visiblePosts filters the posts visible to the reader, see model.Post.VisibleTo.
+func visiblePosts(query *gorm.DB, reader model.PostReader) *gorm.DB {
+	if reader.Editor {
+		return query
+	}
+	if reader.UserID == 0 {
+		return query.Where("posts.status = ?", model.PostPublished)
+	}
+	return query.Where("(posts.status = ? OR posts.creator_id = ?)", model.PostPublished, reader.UserID)
+}
+
+// countLikes sets the likes of the posts.
+func (p *postRepository) countLikes(posts []model.Post) error {
+	if len(posts) == 0 {
+		return nil
 	}
 
 	ids := make([]uint, len(posts))
@@ -35,7 +126,7 @@ func (p *postRepository) List() ([]model.Post, error) {
 
 	results := []result{}
 	if err := p.db.Model(&model.Like{}).Select("post_id as id, count(likes.post_id) as likes").Where("post_id in ?", ids).Group("post_id").Scan(&results).Error; err != nil {
-		return nil, err
+		return err
 	}
 
 	resMap := make(map[uint]uint, len(results))
@@ -46,15 +137,25 @@ func (p *postRepository) List() ([]model.Post, error) {
 	for i := range posts {
 		posts[i].Likes = resMap[posts[i].ID]
 	}
-
-	return posts, nil
+	return nil
 }
 
+// Create creates the post of the user, the fields owned by the server like the id, views and version are ignored.
 func (p *postRepository) Create(user *model.User, post *model.Post) (*model.Post, error) {
+	post.ID, post.Views, post.Likes, post.Version, post.Comments = 0, 0, 0, 0, nil
+	post.BaseModel = model.BaseModel{}
 	post.CreatorID = user.ID
 	post.Creator = *user
-	err := p.db.Create(post).Error
-	return post, err
+	err := p.indexed(func(tx *gorm.DB) error {
+		if err := linkTaxonomies(tx, post); err != nil {
+			return err
+		}
+		if err := tx.Create(post).Error; err != nil {
+			return err
+		}
+		return addRevision(tx, post, &model.PostRevision{AuthorID: user.ID}, 0)
+	}, func() uint { return post.ID })
+	return post, alreadyExists(err, model.PostResource, post.Name)
 }
 
 func (p *postRepository) GetTags(post *model.Post) ([]model.Tag, error) {
@@ -71,8 +172,8 @@ func (p *postRepository) GetCategories(post *model.Post) ([]model.Category, erro
 
 func (p *postRepository) GetPostByID(id uint) (*model.Post, error) {
 	post := new(model.Post)
-	if err := p.db.Preload("Creator").Preload("Tags").Preload("Categories").Preload("Comments.User").Preload("Comments").First(post, id).Error; err != nil {
-		return nil, err
+	if err := p.db.Preload("Creator").Preload("Tags").Preload("Categories").Preload("Comments", "status = ? AND deleted_at IS NULL", model.CommentApproved).Preload("Comments.User").First(post, id).Error; err != nil {
+		return nil, notFound(err, model.PostResource, id)
 	}
 
 	likes, err := p.CountLike(id)
@@ -88,29 +189,83 @@ func (p *postRepository) GetPostByID(id uint) (*model.Post, error) {
 func (p *postRepository) GetPostByName(name string) (*model.Post, error) {
 	post := new(model.Post)
 	if err := p.db.Where("name = ?", name).First(post).Error; err != nil {
-		return nil, err
+		return nil, notFound(err, model.PostResource, name)
 	}
 
 	return post, nil
 }
 
-func (p *postRepository) Update(post *model.Post) (*model.Post, error) {
-	err := p.db.Model(post).Omit("views", "creator_id").Updates(post).Error
+// Update updates the post if its version is still post.Version and saves it as the next revision,
+// the fields of revision like the author are kept. Only the latest maxRevisions are kept if it is positive.
+func (p *postRepository) Update(post *model.Post, revision *model.PostRevision, maxRevisions int) (*model.Post, error) {
+	err := p.indexed(func(tx *gorm.DB) error {
+		if err := updateVersion(tx, post, &post.Version, postUpdateFields); err != nil {
+			return err
+		}
+		if err := replaceTaxonomies(tx, post); err != nil {
+			return err
+		}
+		return addRevision(tx, post, revision, maxRevisions)
+	}, func() uint { return post.ID })
+	return post, alreadyExists(err, model.PostResource, post.Name)
+}
+
+// UpdateStatus updates the status and publish time of the post if its version is still post.Version.
+func (p *postRepository) UpdateStatus(post *model.Post) (*model.Post, error) {
+	err := updateVersion(p.db, post, &post.Version, postStatusFields)
 	return post, err
 }
 
-func (p *postRepository) Delete(id uint) error {
-	return p.db.Delete(&model.Post{}, id).Error
+// SetContent replaces the content of the post and its latest revision, the version and update time are kept.
+// It completes a new post, like an imported post whose links are replaced by the urls of its attachments.
+func (p *postRepository) SetContent(id uint, content string) error {
+	return p.indexed(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Post{ID: id}).UpdateColumn("content", content).Error; err != nil {
+			return err
+		}
+		latest := tx.Model(&model.PostRevision{}).Select("MAX(revision)").Where("post_id = ?", id)
+		return tx.Model(&model.PostRevision{}).Where("post_id = ? AND revision = (?)", id, latest).UpdateColumn("content", content).Error
+	}, func() uint { return id })
 }
 
-func (p *postRepository) IncView(id uint) error {
-	post := &model.Post{ID: id}
-	return p.db.Model(post).UpdateColumn("views", gorm.Expr("views + 1")).Error
+// PublishDue publishes the scheduled posts whose publish time is not after now and returns them.
+// Each post is published by an update of its own, a post changed meanwhile, like a post edited by its author
+// at the same time, is skipped and published by the next call if it is still due. Posts after an error are still
+// published and the first error is returned.
+func (p *postRepository) PublishDue(now time.Time) ([]model.Post, error) {
+	due := make([]model.Post, 0)
+	if err := p.db.Where("status = ? AND published_at <= ?", model.PostScheduled, now).Find(&due).Error; err != nil {
+		return nil, err
+	}
+
+	var firstErr error
+	posts := make([]model.Post, 0, len(due))
+	for i := range due {
+		post := &due[i]
+		post.Status = model.PostPublished
+		post.UpdatedAt = now
+		err := updateVersion(p.db, post, &post.Version, postStatusFields)
+		switch {
+		case err == nil:
+			posts = append(posts, *post)
+		case errors.Is(err, apierrors.ErrModified):
+			// changed since it was found, the next call publishes it if it is still due
+		case firstErr == nil:
+			firstErr = err
+		}
+	}
+	return posts, firstErr
+}
+
+func (p *postRepository) Delete(id uint, version uint64) error {
+	return p.indexed(func(tx *gorm.DB) error {
+		return deleteVersion(tx, &model.Post{ID: id}, version)
+	}, func() uint { return id })
 }
 
 func (p *postRepository) AddLike(pid, uid uint) error {
 	like := &model.Like{PostID: pid, UserID: uid}
-	return p.db.Create(like).Error
+	return alreadyExists(p.db.Create(like).Error, "likes", pid)
 }
 
 func (p *postRepository) DelLike(pid, uid uint) error {
@@ -135,21 +290,23 @@ func (p *postRepository) GetLikeByUser(uid uint) ([]model.Like, error) {
 	return likes, err
 }
 
-func (p *postRepository) AddComment(comment *model.Comment) (*model.Comment, error) {
-	err := p.db.Create(comment).Error
-	return comment, err
-}
-
-func (p *postRepository) DelComment(id string) error {
-	return p.db.Delete(&model.Comment{}, id).Error
-}
-
-func (p *postRepository) ListComment(pid string) ([]model.Comment, error) {
-	comments := make([]model.Comment, 0)
-	err := p.db.Where("post_id = ?", pid).Find(comments).Error
-	return comments, err
-}
-
 func (p *postRepository) Migrate() error {
-	return p.db.AutoMigrate(&model.Post{}, &model.Like{}, &model.Tag{}, &model.Category{}, &model.Comment{})
+	if err := dropUniqueName(p.db, &model.Post{}); err != nil {
+		return err
+	}
+	if err := p.db.AutoMigrate(&model.Post{}, &model.Like{}, &model.Tag{}, &model.Category{}, &model.Comment{}, &model.PostRevision{}); err != nil {
+		return err
+	}
+	if err := migrateRevisions(p.db); err != nil {
+		return err
+	}
+	if err := migrateCommentDepth(p.db); err != nil {
+		return err
+	}
+	// posts created before the status were published when they were created
+	if err := p.db.Model(&model.Post{}).Where("status = ? AND published_at IS NULL", model.PostPublished).
+		UpdateColumn("published_at", gorm.Expr("created_at")).Error; err != nil {
+		return err
+	}
+	return p.search.Migrate()
 }
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPublishDue(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+	alice, err := r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+
+	now := time.Now()
+	due, later := now.Add(-time.Minute), now.Add(time.Hour)
+	for _, post := range []*model.Post{
+		{Name: "edited", Content: "one", Status: model.PostScheduled, PublishedAt: &due},
+		{Name: "due", Content: "two", Status: model.PostScheduled, PublishedAt: &due},
+		{Name: "later", Content: "three", Status: model.PostScheduled, PublishedAt: &later},
+	} {
+		_, err := r.Post().Create(alice, post)
+		require.Nil(t, err)
+	}
+
+	// the author edits the first post while it is published
+	edited := false
+	require.Nil(t, db.Callback().Update().Before("gorm:update").Register("test:edit", func(tx *gorm.DB) {
+		if post, ok := tx.Statement.Dest.(*model.Post); ok && post.ID == 1 && !edited {
+			edited = true
+			require.Nil(t, tx.Session(&gorm.Session{NewDB: true}).Exec("UPDATE posts SET version = version + 1 WHERE id = 1").Error)
+		}
+	}))
+
+	posts, err := r.Post().PublishDue(now)
+	require.Nil(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "due", posts[0].Name)
+	assert.True(t, edited)
+
+	status := func(id uint) model.PostStatus {
+		post, err := r.Post().GetPostByID(id)
+		require.Nil(t, err)
+		return post.Status
+	}
+	assert.Equal(t, model.PostScheduled, status(1))
+	assert.Equal(t, model.PostPublished, status(2))
+	assert.Equal(t, model.PostScheduled, status(3))
+
+	// the edited post is published by the next call
+	posts, err = r.Post().PublishDue(now)
+	require.Nil(t, err)
+	require.Len(t, posts, 1)
+	assert.Equal(t, "edited", posts[0].Name)
+	assert.Equal(t, model.PostPublished, status(1))
+}
+
+func TestCreatePost(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+	alice, err := r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+
+	// the fields owned by the server are ignored
+	created := time.Now().Add(-time.Hour)
+	post, err := r.Post().Create(alice, &model.Post{ID: 7, Name: "post", Content: "one", CreatorID: 9, Views: 100, Likes: 5, Version: 42,
+		Comments: []model.Comment{{UserID: alice.ID, Content: "hi"}}, BaseModel: model.BaseModel{CreatedAt: created}})
+	require.Nil(t, err)
+	assert.Equal(t, uint(1), post.ID)
+
+	got, err := r.Post().GetPostByID(post.ID)
+	require.Nil(t, err)
+	assert.Equal(t, alice.ID, got.CreatorID)
+	assert.Zero(t, got.Views)
+	assert.Zero(t, got.Likes)
+	assert.Equal(t, uint64(1), got.Version)
+	assert.True(t, got.CreatedAt.After(created))
+	assert.Zero(t, count(t, db, "comments", "post_id = ?", post.ID))
+}
@@ -7,6 +7,10 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+var (
+	roleUpdateFields = []string{"Name", "Scope", "Namespace", "Rules"}
+)
+
 type rbacRepository struct {
 	db *gorm.DB
 }
@@ -17,12 +21,17 @@ func newRBACRepository(db *gorm.DB) RBACRepository {
 	}
 }
 
-func (rbac *rbacRepository) List() ([]model.Role, error) {
+func (rbac *rbacRepository) List(opts *model.ListOptions) ([]model.Role, *model.ListMeta, error) {
+	query, meta, err := paginate(rbac.db.Model(&model.Role{}), opts, roleListFields, clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	if err != nil {
+		return nil, nil, err
+	}
+
 	roles := make([]model.Role, 0)
-	if err := rbac.db.Find(&roles).Error; err != nil {
-		return nil, err
+	if err := query.Find(&roles).Error; err != nil {
+		return nil, nil, err
 	}
-	return roles, nil
+	return roles, meta, nil
 }
 
 func (rbac *rbacRepository) ListResources() ([]model.Resource, error) {
@@ -35,7 +44,7 @@ func (rbac *rbacRepository) ListResources() ([]model.Resource, error) {
 
 func (rbac *rbacRepository) Create(role *model.Role) (*model.Role, error) {
 	err := rbac.db.Create(role).Error
-	return role, err
+	return role, alreadyExists(err, model.RoleResource, role.Name)
 }
 
 func (rbac *rbacRepository) CreateResource(resource *model.Resource) (*model.Resource, error) {
@@ -48,34 +57,34 @@ func (rbac *rbacRepository) CreateResources(resources []model.Resource, conds ..
 	return err
 }
 
-func (rbac *rbacRepository) GetRoleByID(id int) (*model.Role, error) {
+func (rbac *rbacRepository) GetRoleByID(id uint) (*model.Role, error) {
 	role := &model.Role{}
 	err := rbac.db.First(role, id).Error
-	return role, err
+	return role, notFound(err, model.RoleResource, id)
 }
 
 func (rbac *rbacRepository) GetResource(id int) (*model.Resource, error) {
 	res := &model.Resource{}
 	err := rbac.db.First(res, id).Error
-	return res, err
+	return res, notFound(err, "resources", id)
 }
 
 func (rbac *rbacRepository) GetRoleByName(name string) (*model.Role, error) {
 	role := new(model.Role)
-	if err := rbac.db.Preload(model.UserAssociation).Where("name = ?", name).First(role).Error; err != nil {
-		return nil, err
+	if err := rbac.db.Where("name = ?", name).First(role).Error; err != nil {
+		return nil, notFound(err, model.RoleResource, name)
 	}
 
 	return role, nil
 }
 
 func (rbac *rbacRepository) Update(role *model.Role) (*model.Role, error) {
-	err := rbac.db.Updates(role).Error
+	err := updateVersion(rbac.db, role, &role.Version, roleUpdateFields)
 	return role, err
 }
 
-func (rbac *rbacRepository) Delete(id uint) error {
-	return rbac.db.Delete(&model.Role{}, id).Error
+func (rbac *rbacRepository) Delete(id uint, version uint64) error {
+	return deleteVersion(rbac.db, &model.Role{ID: id}, version)
 }
 
 func (rbac *rbacRepository) DeleteResource(id uint) error {
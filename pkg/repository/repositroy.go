@@ -10,12 +10,26 @@ import (
 )
 
 func NewRepository(db *gorm.DB) Repository {
+	return newRepository(db, newSearchIndex(db))
+}
+
+// newRepository returns the repository of db, search is the search index of db.
+func newRepository(db *gorm.DB, search *searchIndex) *repository {
 	r := &repository{
-		db:    db,
-		user:  newUserRepository(db),
-		group: newGroupRepository(db),
-		post:  newPostRepository(db),
-		rbac:  newRBACRepository(db),
+		db:          db,
+		search:      search,
+		user:        newUserRepository(db),
+		group:       newGroupRepository(db),
+		post:        newPostRepository(db, search),
+		tag:         newTagRepository(db, search),
+		category:    newCategoryRepository(db, search),
+		rbac:        newRBACRepository(db),
+		audit:       newAuditRepository(db),
+		trash:       newTrashRepository(db, search),
+		idempotency: newIdempotencyRepository(db),
+		feedToken:   newFeedTokenRepository(db),
+		attachment:  newAttachmentRepository(db),
+		stats:       newStatsRepository(db),
 	}
 
 	r.migrants = getMigrants(
@@ -23,6 +37,11 @@ func NewRepository(db *gorm.DB) Repository {
 		r.group,
 		r.post,
 		r.rbac,
+		r.audit,
+		r.idempotency,
+		r.feedToken,
+		r.attachment,
+		r.stats,
 	)
 
 	return r
@@ -39,12 +58,21 @@ func getMigrants(objs ...interface{}) []Migrant {
 }
 
 type repository struct {
-	user     UserRepository
-	group    GroupRepository
-	post     PostRepository
-	rbac     RBACRepository
-	db       *gorm.DB
-	migrants []Migrant
+	user        UserRepository
+	group       GroupRepository
+	post        PostRepository
+	tag         TagRepository
+	category    CategoryRepository
+	rbac        RBACRepository
+	audit       AuditRepository
+	trash       TrashRepository
+	idempotency IdempotencyRepository
+	feedToken   FeedTokenRepository
+	attachment  AttachmentRepository
+	stats       StatsRepository
+	db          *gorm.DB
+	search      *searchIndex
+	migrants    []Migrant
 }
 
 func (r *repository) User() UserRepository {
@@ -59,10 +87,50 @@ func (r *repository) Post() PostRepository {
 	return r.post
 }
 
+func (r *repository) Tag() TagRepository {
+	return r.tag
+}
+
+func (r *repository) Category() CategoryRepository {
+	return r.category
+}
+
 func (r *repository) RBAC() RBACRepository {
 	return r.rbac
 }
 
+func (r *repository) Audit() AuditRepository {
+	return r.audit
+}
+
+func (r *repository) Trash() TrashRepository {
+	return r.trash
+}
+
+func (r *repository) Idempotency() IdempotencyRepository {
+	return r.idempotency
+}
+
+func (r *repository) FeedToken() FeedTokenRepository {
+	return r.feedToken
+}
+
+func (r *repository) Attachment() AttachmentRepository {
+	return r.attachment
+}
+
+func (r *repository) Stats() StatsRepository {
+	return r.stats
+}
+
+// Transaction runs fn with a repository bound to a single db transaction,
+// the transaction is committed if fn returns nil and rolled back otherwise.
+func (r *repository) Transaction(fn func(Repository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(newRepository(tx, r.search.withDB(tx)))
+	})
+}
+
 func (r *repository) Close() error {
 	db, _ := r.db.DB()
 	if db != nil {
@@ -117,6 +185,26 @@ func (r *repository) Init() error {
 			Name:  model.PostResource,
 			Scope: model.ClusterScope,
 		},
+		{
+			Name:  model.PostResource + "/comment",
+			Scope: model.ClusterScope,
+		},
+		{
+			Name:  model.PostResource + "/revisions",
+			Scope: model.ClusterScope,
+		},
+		{
+			Name:  model.PostResource + "/like",
+			Scope: model.ClusterScope,
+		},
+		{
+			Name:  model.TagResource,
+			Scope: model.ClusterScope,
+		},
+		{
+			Name:  model.CategoryResource,
+			Scope: model.ClusterScope,
+		},
 		{
 			Name:  model.GroupResource,
 			Scope: model.ClusterScope,
@@ -137,6 +225,18 @@ func (r *repository) Init() error {
 			Name:  model.NamespaceResource,
 			Scope: model.ClusterScope,
 		},
+		{
+			Name:  model.RBACResource,
+			Scope: model.ClusterScope,
+		},
+		{
+			Name:  model.AuditResource,
+			Scope: model.ClusterScope,
+		},
+		{
+			Name:  model.TrashResource,
+			Scope: model.ClusterScope,
+		},
 	}
 
 	if err := r.RBAC().CreateResources(resources, clause.OnConflict{DoNothing: true}); err != nil {
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const revisionResource = "revisions"
+
+// revisionFields are the fields of posts saved by revisions.
+var revisionFields = []string{"name", "summary", "content"}
+
+func (p *postRepository) ListRevisions(pid uint, opts *model.ListOptions) ([]model.PostRevision, *model.ListMeta, error) {
+	query, meta, err := paginate(p.db.Model(&model.PostRevision{}).Where("post_id = ?", pid), opts, revisionListFields,
+		clause.OrderByColumn{Column: clause.Column{Name: "revision"}, Desc: true})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revisions := make([]model.PostRevision, 0)
+	if err := query.Omit("content").Preload("Author").Find(&revisions).Error; err != nil {
+		return nil, nil, err
+	}
+	return revisions, meta, nil
+}
+
+func (p *postRepository) GetRevision(pid, revision uint) (*model.PostRevision, error) {
+	rev := new(model.PostRevision)
+	if err := p.db.Preload("Author").Where("post_id = ? AND revision = ?", pid, revision).First(rev).Error; err != nil {
+		return nil, notFound(err, revisionResource, revision)
+	}
+	return rev, nil
+}
+
+// addRevision saves the post as its next revision if the post changed since the latest revision,
+// the revisions before the latest max revisions are deleted if max is positive.
+func addRevision(tx *gorm.DB, post *model.Post, revision *model.PostRevision, max int) error {
+	latest := new(model.PostRevision)
+	if err := tx.Where("post_id = ?", post.ID).Order("revision desc").Limit(1).Find(latest).Error; err != nil {
+		return err
+	}
+
+	changed := changedFields(latest, post)
+	if len(changed) == 0 {
+		return nil
+	}
+	revision.PostID = post.ID
+	revision.Revision = latest.Revision + 1
+	revision.Name, revision.Summary, revision.Content = post.Name, post.Summary, post.Content
+	revision.ChangedFields = changed
+	if err := tx.Create(revision).Error; err != nil {
+		return err
+	}
+
+	if max > 0 && revision.Revision > uint(max) {
+		return tx.Where("post_id = ? AND revision <= ?", post.ID, revision.Revision-uint(max)).Delete(&model.PostRevision{}).Error
+	}
+	return nil
+}
+
+// changedFields returns the fields of the post which differ from the revision, all fields if there is no revision.
+func changedFields(revision *model.PostRevision, post *model.Post) []string {
+	if revision.ID == 0 {
+		return append([]string(nil), revisionFields...)
+	}
+
+	changed := make([]string, 0, len(revisionFields))
+	for i, same := range []bool{revision.Name == post.Name, revision.Summary == post.Summary, revision.Content == post.Content} {
+		if !same {
+			changed = append(changed, revisionFields[i])
+		}
+	}
+	return changed
+}
+
+// migrateRevisions saves posts without revisions, like posts created before revisions, as their first revision.
+func migrateRevisions(db *gorm.DB) error {
+	return db.Exec(`INSERT INTO post_revisions (post_id, revision, author_id, name, summary, content, changed_fields, created_at)
+SELECT id, 1, creator_id, name, summary, content, ?, updated_at FROM posts
+WHERE NOT EXISTS (SELECT 1 FROM post_revisions WHERE post_revisions.post_id = posts.id)`, `["name","summary","content"]`).Error
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRevisions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+
+	alice, err := r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	bob, err := r.User().Create(&model.User{Name: "bob", Password: "123456"})
+	require.Nil(t, err)
+
+	post, err := r.Post().Create(alice, &model.Post{Name: "post", Content: "one", Summary: "one"})
+	require.Nil(t, err)
+
+	revisions := func() []model.PostRevision {
+		revisions, meta, err := r.Post().ListRevisions(post.ID, nil)
+		require.Nil(t, err)
+		assert.Equal(t, int64(len(revisions)), meta.Total)
+		return revisions
+	}
+	update := func(content string, max int) {
+		post.Content = content
+		_, err := r.Post().Update(post, &model.PostRevision{AuthorID: bob.ID}, max)
+		require.Nil(t, err)
+	}
+
+	first := revisions()
+	require.Len(t, first, 1)
+	assert.Equal(t, uint(1), first[0].Revision)
+	assert.Equal(t, alice.ID, first[0].Author.ID)
+	assert.Equal(t, []string{"name", "summary", "content"}, first[0].ChangedFields)
+	assert.Empty(t, first[0].Content)
+
+	update("two", 0)
+	// an update without changes is no revision
+	update("two", 0)
+	second, err := r.Post().GetRevision(post.ID, 2)
+	require.Nil(t, err)
+	assert.Equal(t, "two", second.Content)
+	assert.Equal(t, []string{"content"}, second.ChangedFields)
+	assert.Equal(t, bob.ID, second.AuthorID)
+	assert.Len(t, revisions(), 2)
+
+	// only the latest revisions are kept
+	update("three", 2)
+	update("four", 2)
+	kept := revisions()
+	require.Len(t, kept, 2)
+	assert.Equal(t, []uint{4, 3}, []uint{kept[0].Revision, kept[1].Revision})
+	_, err = r.Post().GetRevision(post.ID, 1)
+	assert.NotNil(t, err)
+
+	// posts without revisions get their first revision by the migration
+	require.Nil(t, db.Where("post_id = ?", post.ID).Delete(&model.PostRevision{}).Error)
+	require.Nil(t, r.Migrate())
+	migrated := revisions()
+	require.Len(t, migrated, 1)
+	assert.Equal(t, alice.ID, migrated[0].AuthorID)
+	latest, err := r.Post().GetRevision(post.ID, 1)
+	require.Nil(t, err)
+	assert.Equal(t, "four", latest.Content)
+}
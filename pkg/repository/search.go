@@ -0,0 +1,491 @@
+package repository
+
+import (
+	"errors"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+)
+
+// postSearchTable is the SQLite FTS5 table of posts, its rowid is the id of the post.
+// Without the table, like on other dbs or SQLite built without FTS5, posts are searched by LIKE.
+// The SQLite driver only has FTS5 if it is built with the sqlite_fts5 tag, like by make build,
+// builds without the tag always search by LIKE.
+const postSearchTable = "post_search"
+
+// the weights of the columns of post_search, also used to score LIKE matches
+var searchWeights = []struct {
+	column string
+	weight float64
+}{
+	{"name", 10},
+	{"content", 1},
+	{"summary", 4},
+	{"tags", 6},
+	{"categories", 6},
+	{"comments", 2},
+}
+
+const (
+	// matches are marked by control characters in snippets, so the snippets can be html escaped before marking them
+	markStart = "\x02"
+	markEnd   = "\x03"
+	ellipsis  = "…"
+
+	// snippetTokens is the number of words of FTS5 snippets, LIKE snippets have about the same length
+	snippetTokens = 24
+	snippetRunes  = 160
+
+	maxFacets = 20
+)
+
+// searchIndex keeps the FTS5 table in sync with posts, it does nothing if the table does not exist.
+type searchIndex struct {
+	db    *gorm.DB
+	state *searchState
+}
+
+// searchState caches whether the FTS5 table exists, it is shared by the indexes of a db and its transactions.
+type searchState struct {
+	lock     sync.Mutex
+	detected bool
+	fts      bool
+}
+
+func newSearchIndex(db *gorm.DB) *searchIndex {
+	return &searchIndex{db: db, state: &searchState{}}
+}
+
+// withDB returns the index using db, like a transaction, which shares the cached state of s.
+func (s *searchIndex) withDB(db *gorm.DB) *searchIndex {
+	return &searchIndex{db: db, state: s.state}
+}
+
+// fts reports whether posts are searched by the FTS5 table, it is only detected once, or again after a migration.
+func (s *searchIndex) fts() (bool, error) {
+	s.state.lock.Lock()
+	defer s.state.lock.Unlock()
+	if s.state.detected {
+		return s.state.fts, nil
+	}
+
+	if s.db.Dialector.Name() == "sqlite" {
+		err := s.db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5') AND EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)", postSearchTable).
+			Scan(&s.state.fts).Error
+		if err != nil {
+			return false, err
+		}
+	}
+	s.state.detected = true
+	return s.state.fts, nil
+}
+
+// indexPosts updates the posts in the index.
+func (s *searchIndex) indexPosts(posts []uint) error {
+	for _, id := range posts {
+		if err := s.Index(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Index adds the post to the index or replaces it, a deleted post is removed.
+func (s *searchIndex) Index(id uint) error {
+	ok, err := s.fts()
+	if err != nil || !ok {
+		return err
+	}
+
+	post := new(model.Post)
+	if err := s.db.Preload("Tags").Preload("Categories").Preload("Comments", "status = ?", model.CommentApproved).First(post, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return s.remove(id)
+		}
+		return err
+	}
+	if err := s.remove(id); err != nil {
+		return err
+	}
+
+	tags := make([]string, len(post.Tags))
+	for i, tag := range post.Tags {
+		tags[i] = tag.Name
+	}
+	categories := make([]string, len(post.Categories))
+	for i, category := range post.Categories {
+		categories[i] = category.Name
+	}
+	comments := make([]string, len(post.Comments))
+	for i, comment := range post.Comments {
+		comments[i] = comment.Content
+	}
+	return s.db.Exec("INSERT INTO "+postSearchTable+" (rowid, name, content, summary, tags, categories, comments) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		post.ID, post.Name, post.Content, post.Summary, strings.Join(tags, " "), strings.Join(categories, " "), strings.Join(comments, "\n")).Error
+}
+
+// Remove removes the post from the index.
+func (s *searchIndex) Remove(id uint) error {
+	ok, err := s.fts()
+	if err != nil || !ok {
+		return err
+	}
+	return s.remove(id)
+}
+
+func (s *searchIndex) remove(id uint) error {
+	return s.db.Exec("DELETE FROM "+postSearchTable+" WHERE rowid = ?", id).Error
+}
+
+// Migrate creates the FTS5 table on SQLite built with FTS5 and indexes all posts if the table is new.
+func (s *searchIndex) Migrate() error {
+	// the table is detected again after it is created
+	defer func() {
+		s.state.lock.Lock()
+		s.state.detected = false
+		s.state.lock.Unlock()
+	}()
+
+	if s.db.Dialector.Name() != "sqlite" {
+		return nil
+	}
+	var available, exists bool
+	if err := s.db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&available).Error; err != nil || !available {
+		return err
+	}
+	if err := s.db.Raw("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)", postSearchTable).Scan(&exists).Error; err != nil || exists {
+		return err
+	}
+
+	columns := make([]string, len(searchWeights))
+	for i, w := range searchWeights {
+		columns[i] = w.column
+	}
+	create := "CREATE VIRTUAL TABLE " + postSearchTable + " USING fts5(" + strings.Join(columns, ", ") + ", tokenize = 'unicode61 remove_diacritics 2')"
+	if err := s.db.Exec(create).Error; err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0)
+	if err := s.db.Model(&model.Post{}).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.Index(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchTerms splits the query into words, punctuation separates words like the unicode61 tokenizer.
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// ftsMatch returns the FTS5 query matching all terms, the last term also matches as a prefix.
+func ftsMatch(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"`
+	}
+	quoted[len(quoted)-1] += "*"
+	return strings.Join(quoted, " ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// likeColumns are the expressions of the columns of post_search, searched by LIKE ? ESCAPE '\'.
+var likeColumns = map[string]string{
+	"name":       "posts.name LIKE ? ESCAPE '\\'",
+	"content":    "posts.content LIKE ? ESCAPE '\\'",
+	"summary":    "posts.summary LIKE ? ESCAPE '\\'",
+	"tags":       "EXISTS (SELECT 1 FROM tag_posts JOIN tags ON tags.id = tag_posts.tag_id WHERE tag_posts.post_id = posts.id AND tags.name LIKE ? ESCAPE '\\')",
+	"categories": "EXISTS (SELECT 1 FROM category_posts JOIN categories ON categories.id = category_posts.category_id WHERE category_posts.post_id = posts.id AND categories.name LIKE ? ESCAPE '\\')",
+	"comments":   "EXISTS (SELECT 1 FROM comments WHERE comments.post_id = posts.id AND comments.status = 'approved' AND comments.content LIKE ? ESCAPE '\\')",
+}
+
+// likeMatch returns the condition matching all terms in any column and the expression of the score,
+// the score is the sum of the weights of the columns matching a term.
+func likeMatch(terms []string) (string, []interface{}, string, []interface{}) {
+	conds, scores := make([]string, len(terms)), make([]string, 0, len(terms)*len(searchWeights))
+	var condArgs, scoreArgs []interface{}
+	for i, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		ors := make([]string, len(searchWeights))
+		for j, w := range searchWeights {
+			ors[j] = likeColumns[w.column]
+			condArgs = append(condArgs, pattern)
+			scores = append(scores, "CASE WHEN "+likeColumns[w.column]+" THEN "+strconv.FormatFloat(w.weight, 'f', -1, 64)+" ELSE 0 END")
+			scoreArgs = append(scoreArgs, pattern)
+		}
+		conds[i] = "(" + strings.Join(ors, " OR ") + ")"
+	}
+	return strings.Join(conds, " AND "), condArgs, strings.Join(scores, " + "), scoreArgs
+}
+
+// likeSnippet returns the text around the first match in the first text which has a match, matches are marked.
+func likeSnippet(terms []string, texts ...string) string {
+	for _, text := range texts {
+		runes := []rune(text)
+		lower := lowerRunes(text)
+
+		first := -1
+		for _, term := range terms {
+			if i := runeIndex(lower, lowerRunes(term), 0); i >= 0 && (first < 0 || i < first) {
+				first = i
+			}
+		}
+		if first < 0 {
+			continue
+		}
+
+		start := first - snippetRunes/4
+		if start < 0 {
+			start = 0
+		}
+		end := start + snippetRunes
+		if end > len(runes) {
+			end = len(runes)
+		}
+		return markTerms(runes[start:end], lower[start:end], terms, start > 0, end < len(runes))
+	}
+	return ""
+}
+
+func markTerms(runes, lower []rune, terms []string, head, tail bool) string {
+	marked := make([]bool, len(runes))
+	for _, term := range terms {
+		t := lowerRunes(term)
+		for i := runeIndex(lower, t, 0); i >= 0; i = runeIndex(lower, t, i+len(t)) {
+			for j := i; j < i+len(t); j++ {
+				marked[j] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	if head {
+		b.WriteString(ellipsis)
+	}
+	for i, r := range runes {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(markStart)
+		}
+		b.WriteRune(r)
+		if marked[i] && (i == len(runes)-1 || !marked[i+1]) {
+			b.WriteString(markEnd)
+		}
+	}
+	if tail {
+		b.WriteString(ellipsis)
+	}
+	return b.String()
+}
+
+// lowerRunes lowers each rune, unlike strings.ToLower the number of runes never changes.
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func runeIndex(s, sub []rune, from int) int {
+	if len(sub) == 0 {
+		return -1
+	}
+	for i := from; i+len(sub) <= len(s); i++ {
+		match := true
+		for j, r := range sub {
+			if s[i+j] != r {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// highlight escapes the snippet and replaces the markers of matches by <mark></mark>.
+func highlight(snippet string) string {
+	return strings.NewReplacer(markStart, "<mark>", markEnd, "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// Search returns a page of the posts matching the query, best first, with the facets of all matched posts.
+func (p *postRepository) Search(q *model.SearchQuery, opts *model.ListOptions, reader model.PostReader) (*model.SearchResult, error) {
+	if opts == nil {
+		opts = &model.ListOptions{}
+	}
+	offset, err := listOffset(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.SearchResult{
+		Items:    make([]model.SearchHit, 0),
+		Metadata: &model.ListMeta{},
+		Facets:   model.SearchFacets{Tags: []model.Facet{}, Categories: []model.Facet{}, Authors: []model.Facet{}},
+	}
+	terms := searchTerms(q.Q)
+	if len(terms) == 0 {
+		return result, nil
+	}
+
+	fts, err := p.search.fts()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched *gorm.DB
+	var score string
+	var scoreArgs []interface{}
+	if fts {
+		weights := make([]string, len(searchWeights))
+		for i, w := range searchWeights {
+			weights[i] = strconv.FormatFloat(w.weight, 'f', -1, 64)
+		}
+		// bm25 is negative, lower is better
+		score = "-bm25(" + postSearchTable + ", " + strings.Join(weights, ", ") + ")"
+		matched = p.db.Table(postSearchTable).
+			Joins("JOIN posts ON posts.id = "+postSearchTable+".rowid AND posts.deleted_at IS NULL").
+			Where(postSearchTable+" MATCH ?", ftsMatch(terms))
+	} else {
+		var cond string
+		var condArgs []interface{}
+		cond, condArgs, score, scoreArgs = likeMatch(terms)
+		matched = p.db.Model(&model.Post{}).Where(cond, condArgs...)
+	}
+	matched = visiblePosts(filterSearch(matched, q), reader).Session(&gorm.Session{})
+
+	if err := matched.Count(&result.Metadata.Total).Error; err != nil {
+		return nil, err
+	}
+	if result.Metadata.Total == 0 {
+		return result, nil
+	}
+
+	type hit struct {
+		ID      uint
+		Score   float64
+		Snippet string
+	}
+	columns := "posts.id AS id, " + score + " AS score"
+	if fts {
+		columns += ", snippet(" + postSearchTable + ", -1, ?, ?, ?, ?) AS snippet"
+		scoreArgs = append(scoreArgs, markStart, markEnd, ellipsis, snippetTokens)
+	}
+	page := matched.Select(columns, scoreArgs...).Order("score DESC, posts.id DESC")
+	if opts.Limit > 0 {
+		page = page.Limit(opts.Limit)
+		result.Metadata.Continue = nextContinue(opts, offset, result.Metadata.Total)
+	}
+	if offset > 0 {
+		page = page.Offset(offset)
+	}
+	hits := make([]hit, 0)
+	if err := page.Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	posts := make([]model.Post, 0, len(ids))
+	if err := p.db.Omit("content").Preload("Creator").Preload("Tags").Preload("Categories").Where("id IN ?", ids).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	if err := p.countLikes(posts); err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]model.Post, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+
+	var contents map[uint]string
+	if !fts {
+		// LIKE has no snippets, they are cut from the content
+		if contents, err = p.contents(ids); err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range hits {
+		post := byID[h.ID]
+		snippet := h.Snippet
+		if !fts {
+			snippet = likeSnippet(terms, contents[h.ID], post.Summary, post.Name)
+		}
+		if snippet == "" {
+			snippet = post.Summary
+		}
+		result.Items = append(result.Items, model.SearchHit{Post: post, Score: h.Score, Snippet: highlight(snippet)})
+	}
+
+	result.Facets, err = p.searchFacets(matched.Select("posts.id"))
+	return result, err
+}
+
+// filterSearch filters the matched posts by the names of a tag, category and author.
+func filterSearch(query *gorm.DB, q *model.SearchQuery) *gorm.DB {
+	if q.Tag != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM tag_posts JOIN tags ON tags.id = tag_posts.tag_id WHERE tag_posts.post_id = posts.id AND tags.name = ?)", q.Tag)
+	}
+	if q.Category != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM category_posts JOIN categories ON categories.id = category_posts.category_id WHERE category_posts.post_id = posts.id AND categories.name = ?)", q.Category)
+	}
+	if q.Author != "" {
+		query = query.Where("posts.creator_id IN (SELECT id FROM users WHERE name = ?)", q.Author)
+	}
+	return query
+}
+
+// searchFacets counts the matched posts by tag, category and author, the most used first.
+func (p *postRepository) searchFacets(ids *gorm.DB) (model.SearchFacets, error) {
+	facets := model.SearchFacets{}
+	queries := []struct {
+		facets *[]model.Facet
+		query  *gorm.DB
+	}{
+		{&facets.Tags, p.db.Table("tags").Joins("JOIN tag_posts ON tag_posts.tag_id = tags.id").
+			Where("tag_posts.post_id IN (?)", ids).Select("tags.name AS name, count(*) AS count").Group("tags.name")},
+		{&facets.Categories, p.db.Table("categories").Joins("JOIN category_posts ON category_posts.category_id = categories.id").
+			Where("category_posts.post_id IN (?)", ids).Select("categories.name AS name, count(*) AS count").Group("categories.name")},
+		{&facets.Authors, p.db.Table("users").Joins("JOIN posts ON posts.creator_id = users.id").
+			Where("posts.id IN (?)", ids).Select("users.name AS name, count(*) AS count").Group("users.name")},
+	}
+	for _, q := range queries {
+		*q.facets = make([]model.Facet, 0)
+		if err := q.query.Order("count DESC, name").Limit(maxFacets).Scan(q.facets).Error; err != nil {
+			return facets, err
+		}
+	}
+	return facets, nil
+}
+
+func (p *postRepository) contents(ids []uint) (map[uint]string, error) {
+	rows := make([]model.Post, 0, len(ids))
+	if err := p.db.Select("id", "content").Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	contents := make(map[uint]string, len(rows))
+	for _, row := range rows {
+		contents[row.ID] = row.Content
+	}
+	return contents, nil
+}
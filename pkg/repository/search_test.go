@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestSearch searches by LIKE, or by FTS5 if the test is built with the sqlite_fts5 tag.
+func TestSearch(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+
+	alice, err := r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	bob, err := r.User().Create(&model.User{Name: "bob", Password: "123456"})
+	require.Nil(t, err)
+
+	programming := []model.Category{{Name: "programming"}}
+	golang := &model.Post{Name: "Learning Go", Content: "Go channels and <b>goroutines</b> make concurrency easy.", Summary: "go",
+		Tags: []model.Tag{{Name: "golang"}}}
+	rust := &model.Post{Name: "Rust ownership", Content: "Ownership without a garbage collector, unlike Go.", Summary: "rust",
+		Tags: []model.Tag{{Name: "rust"}}}
+	cooking := &model.Post{Name: "Pasta", Content: "Boil water, add salt.", Summary: "pasta"}
+	for _, post := range []*model.Post{golang, rust} {
+		post.Categories = programming
+		_, err := r.Post().Create(alice, post)
+		require.Nil(t, err)
+	}
+	_, err = r.Post().Create(bob, cooking)
+	require.Nil(t, err)
+
+	search := func(q *model.SearchQuery) *model.SearchResult {
+		result, err := r.Post().Search(q, &model.ListOptions{Limit: 10}, model.PostReader{UserID: alice.ID})
+		require.Nil(t, err)
+		return result
+	}
+	names := func(result *model.SearchResult) []string {
+		names := make([]string, len(result.Items))
+		for i, hit := range result.Items {
+			names[i] = hit.Post.Name
+		}
+		return names
+	}
+
+	result := search(&model.SearchQuery{Q: "go"})
+	assert.Equal(t, []string{"Learning Go", "Rust ownership"}, names(result))
+	assert.Equal(t, int64(2), result.Metadata.Total)
+	assert.Equal(t, []model.Facet{{Name: "golang", Count: 1}, {Name: "rust", Count: 1}}, result.Facets.Tags)
+	assert.Equal(t, []model.Facet{{Name: "programming", Count: 2}}, result.Facets.Categories)
+	assert.Equal(t, []model.Facet{{Name: "alice", Count: 2}}, result.Facets.Authors)
+	assert.Contains(t, result.Items[1].Snippet, "<mark>Go</mark>")
+	assert.Greater(t, result.Items[0].Score, result.Items[1].Score)
+
+	// content is html escaped, the prefix of the last word matches too
+	result = search(&model.SearchQuery{Q: "gorout"})
+	assert.Equal(t, []string{"Learning Go"}, names(result))
+	assert.Contains(t, result.Items[0].Snippet, "&lt;b&gt;<mark>gorout")
+
+	assert.Equal(t, []string{"Rust ownership"}, names(search(&model.SearchQuery{Q: "go", Tag: "rust"})))
+	assert.Empty(t, names(search(&model.SearchQuery{Q: "go", Author: "bob"})))
+	assert.Empty(t, names(search(&model.SearchQuery{Q: "%"})))
+
+	// the index follows comments, updates and deletes
+	_, err = r.Post().AddComment(&model.Comment{PostID: cooking.ID, UserID: alice.ID, Content: "needs more garlic"})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Pasta"}, names(search(&model.SearchQuery{Q: "garlic"})))
+
+	cooking.Content = "Boil water, add olive oil."
+	_, err = r.Post().Update(cooking, &model.PostRevision{AuthorID: bob.ID}, 0)
+	require.Nil(t, err)
+	assert.Empty(t, names(search(&model.SearchQuery{Q: "salt"})))
+	assert.Equal(t, []string{"Pasta"}, names(search(&model.SearchQuery{Q: "olive"})))
+
+	require.Nil(t, r.Post().Delete(cooking.ID, 0))
+	assert.Empty(t, names(search(&model.SearchQuery{Q: "olive"})))
+	require.Nil(t, r.Trash().Restore(model.PostResource, cooking.ID))
+	assert.Equal(t, []string{"Pasta"}, names(search(&model.SearchQuery{Q: "olive"})))
+
+	// drafts are only found by their creator and editors
+	_, err = r.Post().Create(bob, &model.Post{Name: "Draft", Content: "olive bread", Status: model.PostDraft})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Pasta"}, names(search(&model.SearchQuery{Q: "olive"})))
+	for _, reader := range []model.PostReader{{UserID: bob.ID}, {Editor: true}} {
+		result, err := r.Post().Search(&model.SearchQuery{Q: "olive"}, nil, reader)
+		require.Nil(t, err)
+		assert.ElementsMatch(t, []string{"Pasta", "Draft"}, names(result))
+	}
+}
+
+func TestLikeSnippet(t *testing.T) {
+	terms := searchTerms("Über, GO!")
+	assert.Equal(t, []string{"Über", "GO"}, terms)
+	assert.Equal(t, "\x02über\x03 \x02go\x03 <b>", likeSnippet(terms, "", "über go <b>"))
+	assert.Equal(t, "", likeSnippet(terms, "nothing"))
+	assert.Equal(t, "über go &lt;b&gt;", highlight("über go <b>"))
+	assert.Equal(t, `"a" "b"*`, ftsMatch([]string{"a", "b"}))
+}
+
+func TestSearchDetectedOnce(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+	alice, err := r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+
+	detections := 0
+	require.Nil(t, db.Callback().Row().Before("gorm:row").Register("test:count", func(tx *gorm.DB) {
+		if strings.Contains(tx.Statement.SQL.String(), "sqlite_compileoption_used") {
+			detections++
+		}
+	}))
+
+	// posts are indexed in transactions, which share the detection of the repository
+	for _, name := range []string{"one", "two"} {
+		_, err = r.Post().Create(alice, &model.Post{Name: name, Content: "go", Tags: []model.Tag{{Name: "go"}}})
+		require.Nil(t, err)
+		_, err = r.Post().Search(&model.SearchQuery{Q: "go"}, nil, model.PostReader{UserID: alice.ID})
+		require.Nil(t, err)
+	}
+	assert.Equal(t, 1, detections)
+
+	// the table is detected again after a migration
+	require.Nil(t, r.Migrate())
+	detections = 0
+	_, err = r.Post().Search(&model.SearchQuery{Q: "go"}, nil, model.PostReader{UserID: alice.ID})
+	require.Nil(t, err)
+	assert.Equal(t, 1, detections)
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type statsRepository struct {
+	db *gorm.DB
+}
+
+func newStatsRepository(db *gorm.DB) StatsRepository {
+	return &statsRepository{
+		db: db,
+	}
+}
+
+// Add adds the views and likes to the daily stats and the views to the counters of the posts.
+// The counters are updated without the update time and version of the posts.
+func (s *statsRepository) Add(stats []model.PostStat) error {
+	if len(stats) == 0 {
+		return nil
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "post_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"views": gorm.Expr("post_stats.views + excluded.views"),
+				"likes": gorm.Expr("post_stats.likes + excluded.likes"),
+			}),
+		}).Create(&stats).Error
+		if err != nil {
+			return err
+		}
+
+		views := make(map[uint]int64)
+		for _, stat := range stats {
+			views[stat.PostID] += stat.Views
+		}
+		for pid, n := range views {
+			if n == 0 {
+				continue
+			}
+			if err := tx.Model(&model.Post{ID: pid}).UpdateColumn("views", gorm.Expr("views + ?", n)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// List returns the stats of the post from the day from to the day to, days without views and likes have no stats.
+func (s *statsRepository) List(pid uint, from, to string) ([]model.PostStat, error) {
+	stats := make([]model.PostStat, 0)
+	err := s.db.Where("post_id = ? AND day BETWEEN ? AND ?", pid, from, to).Order("day").Find(&stats).Error
+	return stats, err
+}
+
+// Top returns the posts visible to the reader with the most views or likes from the day from to the day to,
+// posts without views or likes of the days are not listed.
+func (s *statsRepository) Top(by string, from, to string, limit int, reader model.PostReader) ([]model.TopPost, error) {
+	if by != "views" && by != "likes" {
+		return nil, fmt.Errorf("unknown stat %s", by)
+	}
+
+	type result struct {
+		PostID uint
+		Views  int64
+		Likes  int64
+	}
+	results := make([]result, 0)
+	query := s.db.Model(&model.PostStat{}).
+		Select("post_stats.post_id, SUM(post_stats.views) AS views, SUM(post_stats.likes) AS likes").
+		Joins("JOIN posts ON posts.id = post_stats.post_id AND posts.deleted_at IS NULL").
+		Where("post_stats.day BETWEEN ? AND ?", from, to)
+	err := visiblePosts(query, reader).
+		Group("post_stats.post_id").
+		Having(fmt.Sprintf("SUM(post_stats.%s) > 0", by)).
+		Order(fmt.Sprintf("%s DESC, post_stats.post_id", by)).
+		Limit(limit).
+		Scan(&results).Error
+	if err != nil || len(results) == 0 {
+		return []model.TopPost{}, err
+	}
+
+	ids := make([]uint, len(results))
+	for i, r := range results {
+		ids[i] = r.PostID
+	}
+	posts := make([]model.Post, 0, len(ids))
+	if err := s.db.Omit("content").Preload("Creator").Preload("Tags").Preload("Categories").Find(&posts, ids).Error; err != nil {
+		return nil, err
+	}
+	if err := (&postRepository{db: s.db}).countLikes(posts); err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]model.Post, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+
+	top := make([]model.TopPost, 0, len(results))
+	for _, r := range results {
+		if post, ok := byID[r.PostID]; ok {
+			top = append(top, model.TopPost{Post: post, Views: r.Views, Likes: r.Likes})
+		}
+	}
+	return top, nil
+}
+
+func (s *statsRepository) Migrate() error {
+	return s.db.AutoMigrate(&model.PostStat{})
+}
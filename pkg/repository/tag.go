@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type tagRepository struct {
+	db     *gorm.DB
+	search *searchIndex
+}
+
+func newTagRepository(db *gorm.DB, search *searchIndex) TagRepository {
+	return &tagRepository{
+		db:     db,
+		search: search,
+	}
+}
+
+func (t *tagRepository) List(opts *model.ListOptions) ([]model.Tag, *model.ListMeta, error) {
+	query, meta, err := paginate(t.db.Model(&model.Tag{}), opts, tagListFields, clause.OrderByColumn{Column: clause.Column{Name: "name"}})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags := make([]model.Tag, 0)
+	if err := query.Find(&tags).Error; err != nil {
+		return nil, nil, err
+	}
+	counts, err := postCounts(t.db, "tag_posts", "tag_id", tagIDs(tags))
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range tags {
+		tags[i].PostCount = counts[tags[i].ID]
+	}
+	return tags, meta, nil
+}
+
+func (t *tagRepository) GetTagByID(id uint) (*model.Tag, error) {
+	tag := new(model.Tag)
+	if err := t.db.First(tag, id).Error; err != nil {
+		return nil, notFound(err, model.TagResource, id)
+	}
+	counts, err := postCounts(t.db, "tag_posts", "tag_id", []uint{id})
+	if err != nil {
+		return nil, err
+	}
+	tag.PostCount = counts[id]
+	return tag, nil
+}
+
+func (t *tagRepository) Create(tag *model.Tag) (*model.Tag, error) {
+	err := t.db.Create(tag).Error
+	return tag, alreadyExists(err, model.TagResource, tag.Name)
+}
+
+// Update renames the tag and reindexes its posts.
+func (t *tagRepository) Update(tag *model.Tag) (*model.Tag, error) {
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(tag).Select("Name").Updates(tag)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apierrors.NewNotFound(model.TagResource, tag.ID)
+		}
+		return reindexPosts(t.search.withDB(tx), "tag_posts", "tag_id", tag.ID)
+	})
+	return tag, alreadyExists(err, model.TagResource, tag.Name)
+}
+
+// Delete deletes the tag and removes it from its posts.
+func (t *tagRepository) Delete(id uint) error {
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		posts, err := associatedPosts(tx, "tag_posts", "tag_id", id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM tag_posts WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&model.Tag{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apierrors.NewNotFound(model.TagResource, id)
+		}
+		return t.search.withDB(tx).indexPosts(posts)
+	})
+}
+
+// Merge moves the posts of the tag from to the tag into and deletes the tag from.
+func (t *tagRepository) Merge(from, into uint) (*model.Tag, error) {
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		posts, err := associatedPosts(tx, "tag_posts", "tag_id", from)
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT INTO tag_posts (tag_id, post_id) SELECT ?, post_id FROM tag_posts
+WHERE tag_id = ? AND post_id NOT IN (SELECT post_id FROM tag_posts WHERE tag_id = ?)`, into, from, into).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM tag_posts WHERE tag_id = ?", from).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.Tag{}, from).Error; err != nil {
+			return err
+		}
+		return t.search.withDB(tx).indexPosts(posts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t.GetTagByID(into)
+}
+
+func tagIDs(tags []model.Tag) []uint {
+	ids := make([]uint, len(tags))
+	for i := range tags {
+		ids[i] = tags[i].ID
+	}
+	return ids
+}
+
+// postCounts counts the published posts of tags or categories by the join table of posts, keyed by the ids.
+func postCounts(db *gorm.DB, table, column string, ids []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(ids))
+	if len(ids) == 0 {
+		return counts, nil
+	}
+
+	type result struct {
+		ID    uint
+		Count int64
+	}
+	results := make([]result, 0)
+	if err := db.Table(table).Select(table+"."+column+" AS id, COUNT(*) AS count").
+		Joins("JOIN posts ON posts.id = "+table+".post_id AND posts.deleted_at IS NULL AND posts.status = ?", model.PostPublished).
+		Where(table+"."+column+" IN ?", ids).Group(table + "." + column).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		counts[r.ID] = r.Count
+	}
+	return counts, nil
+}
+
+// associatedPosts returns the ids of the posts of a tag or category by the join table of posts.
+func associatedPosts(db *gorm.DB, table, column string, id uint) ([]uint, error) {
+	posts := make([]uint, 0)
+	err := db.Table(table).Where(column+" = ?", id).Pluck("post_id", &posts).Error
+	return posts, err
+}
+
+// reindexPosts updates the search index of the posts of a tag or category.
+func reindexPosts(index *searchIndex, table, column string, id uint) error {
+	posts, err := associatedPosts(index.db, table, column, id)
+	if err != nil {
+		return err
+	}
+	return index.indexPosts(posts)
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTags(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+
+	alice, err := r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+
+	golang, err := r.Tag().Create(&model.Tag{Name: "go"})
+	require.Nil(t, err)
+	// existing tags are linked by their names
+	first, err := r.Post().Create(alice, &model.Post{Name: "first", Content: "one", Tags: []model.Tag{{Name: "go"}, {Name: "golang"}}})
+	require.Nil(t, err)
+	assert.Equal(t, golang.ID, first.Tags[0].ID)
+	second, err := r.Post().Create(alice, &model.Post{Name: "second", Content: "two", Tags: []model.Tag{{Name: "golang"}}})
+	require.Nil(t, err)
+	_, err = r.Post().Create(alice, &model.Post{Name: "draft", Content: "three", Status: model.PostDraft, Tags: []model.Tag{{Name: "go"}}})
+	require.Nil(t, err)
+
+	tags, meta, err := r.Tag().List(nil)
+	require.Nil(t, err)
+	require.Len(t, tags, 2)
+	assert.Equal(t, int64(2), meta.Total)
+	// drafts are not counted
+	assert.Equal(t, "go", tags[0].Name)
+	assert.Equal(t, int64(1), tags[0].PostCount)
+	assert.Equal(t, "golang", tags[1].Name)
+	assert.Equal(t, int64(2), tags[1].PostCount)
+
+	merged, err := r.Tag().Merge(tags[1].ID, golang.ID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), merged.PostCount)
+	_, err = r.Tag().GetTagByID(tags[1].ID)
+	assert.True(t, apierrors.IsNotFound(err))
+	posts, _, err := r.Post().ListByTag(golang.ID, nil, model.PostReader{})
+	require.Nil(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, second.ID, posts[0].ID)
+
+	// an update replaces the tags, empty and nil tags are cleared
+	second.Tags = []model.Tag{}
+	_, err = r.Post().Update(second, &model.PostRevision{AuthorID: alice.ID}, 0)
+	require.Nil(t, err)
+	cleared, err := r.Post().GetTags(second)
+	require.Nil(t, err)
+	assert.Empty(t, cleared)
+	first.Tags = nil
+	_, err = r.Post().Update(first, &model.PostRevision{AuthorID: alice.ID}, 0)
+	require.Nil(t, err)
+	cleared, err = r.Post().GetTags(first)
+	require.Nil(t, err)
+	assert.Empty(t, cleared)
+
+	require.Nil(t, r.Tag().Delete(golang.ID))
+	assert.True(t, apierrors.IsNotFound(r.Tag().Delete(golang.ID)))
+}
+
+func TestCategories(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+
+	alice, err := r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+
+	root, err := r.Category().Create(&model.Category{Name: "root"})
+	require.Nil(t, err)
+	child, err := r.Category().Create(&model.Category{Name: "child", ParentID: &root.ID})
+	require.Nil(t, err)
+	leaf, err := r.Category().Create(&model.Category{Name: "leaf", ParentID: &child.ID})
+	require.Nil(t, err)
+	_, err = r.Post().Create(alice, &model.Post{Name: "post", Content: "one", Categories: []model.Category{{Name: "leaf"}}})
+	require.Nil(t, err)
+
+	descendants, err := r.Category().Descendants(root.ID)
+	require.Nil(t, err)
+	assert.Equal(t, []uint{root.ID, child.ID, leaf.ID}, descendants)
+	posts, _, err := r.Post().ListByCategories(descendants, nil, model.PostReader{})
+	require.Nil(t, err)
+	assert.Len(t, posts, 1)
+
+	got, err := r.Category().GetCategoryByID(child.ID)
+	require.Nil(t, err)
+	require.Len(t, got.Children, 1)
+	assert.Equal(t, int64(1), got.Children[0].PostCount)
+
+	// the children of a deleted category are moved to its parent
+	require.Nil(t, r.Category().Delete(child.ID))
+	moved, err := r.Category().GetCategoryByID(leaf.ID)
+	require.Nil(t, err)
+	assert.Equal(t, root.ID, *moved.ParentID)
+	assert.Equal(t, int64(1), moved.PostCount)
+
+	// an update without categories clears them
+	post := &posts[0]
+	post.Content = "changed"
+	post.Categories = nil
+	_, err = r.Post().Update(post, &model.PostRevision{AuthorID: alice.ID}, 0)
+	require.Nil(t, err)
+	categories, err := r.Post().GetCategories(post)
+	require.Nil(t, err)
+	assert.Empty(t, categories)
+}
@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var trashListFields = listFields{
+	"id":        "id",
+	"name":      "name",
+	"deletedAt": "deleted_at",
+}
+
+// trashable is a soft deleted model whose objects can be listed, restored and purged.
+type trashable struct {
+	model func() interface{}
+	find  func(query *gorm.DB) ([]model.TrashItem, error)
+	// restore restores the dependents which are soft deleted together with the object
+	restore func(tx *gorm.DB, id uint) error
+	// indexed objects are added to the search index again when they are restored
+	indexed bool
+	// purge removes the dependents and associations of the object before it is deleted permanently
+	purge func(tx *gorm.DB, id uint) error
+}
+
+// trashables are purged in this order, so posts are purged before their creators
+var trashResources = []string{model.PostResource, model.GroupResource, model.UserResource}
+
+// userReferences are the objects which reference users and are kept when a user is purged,
+// a user can not be purged while they exist, also if they are in the trash.
+var userReferences = []struct {
+	resource string
+	model    interface{}
+	column   string
+}{
+	{model.PostResource, &model.Post{}, "creator_id"},
+	{"comments", &model.Comment{}, "user_id"},
+	{"revisions", &model.PostRevision{}, "author_id"},
+	{model.GroupResource, &model.Group{}, "creator_id"},
+	{"attachments", &model.Attachment{}, "user_id"},
+}
+
+// checkUserReferences returns a Conflict error if objects still reference the user.
+func checkUserReferences(tx *gorm.DB, id uint) error {
+	for _, ref := range userReferences {
+		var count int64
+		if err := tx.Unscoped().Model(ref.model).Where(ref.column+" = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return apierrors.NewConflict(fmt.Errorf("user %d still owns %d %s, they have to be purged first", id, count, ref.resource), nil)
+		}
+	}
+	return nil
+}
+
+var trashables = map[string]trashable{
+	model.UserResource: {
+		model: func() interface{} { return &model.User{} },
+		find:  findTrashedUsers,
+		restore: func(tx *gorm.DB, id uint) error {
+			return tx.Unscoped().Model(&model.AuthInfo{}).Where("user_id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil).Error
+		},
+		purge: func(tx *gorm.DB, id uint) error {
+			user := &model.User{ID: id}
+			if err := checkUserReferences(tx, id); err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", id).Delete(&model.FeedToken{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("user_id = ?", id).Delete(&model.AuthInfo{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", id).Delete(&model.Like{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(user).Association(model.GroupAssociation).Clear(); err != nil {
+				return err
+			}
+			return tx.Model(user).Association("Roles").Clear()
+		},
+	},
+	model.GroupResource: {
+		model: func() interface{} { return &model.Group{} },
+		find:  findTrashedGroups,
+		purge: func(tx *gorm.DB, id uint) error {
+			group := &model.Group{ID: id}
+			if err := tx.Model(group).Association(model.UserAssociation).Clear(); err != nil {
+				return err
+			}
+			return tx.Model(group).Association("Roles").Clear()
+		},
+	},
+	model.PostResource: {
+		model:   func() interface{} { return &model.Post{} },
+		find:    findTrashedPosts,
+		indexed: true,
+		purge: func(tx *gorm.DB, id uint) error {
+			post := &model.Post{ID: id}
+			if err := tx.Where("post_id = ?", id).Delete(&model.Like{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id = ?", id).Delete(&model.Comment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id = ?", id).Delete(&model.PostRevision{}).Error; err != nil {
+				return err
+			}
+			// the files of the attachments are removed by the cleanup of orphans
+			if err := tx.Where("post_id = ?", id).Delete(&model.Attachment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id = ?", id).Delete(&model.PostStat{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(post).Association(model.TagAssociation).Clear(); err != nil {
+				return err
+			}
+			return tx.Model(post).Association(model.CategoriesAssociation).Clear()
+		},
+	},
+}
+
+type trashRepository struct {
+	db     *gorm.DB
+	search *searchIndex
+}
+
+func newTrashRepository(db *gorm.DB, search *searchIndex) TrashRepository {
+	return &trashRepository{
+		db:     db,
+		search: search,
+	}
+}
+
+func getTrashable(resource string) (trashable, error) {
+	kind, ok := trashables[resource]
+	if !ok {
+		return kind, apierrors.NewNotFound(model.TrashResource, resource)
+	}
+	return kind, nil
+}
+
+// trashed returns the query of the soft deleted objects of the resource.
+func trashed(db *gorm.DB, kind trashable) *gorm.DB {
+	return db.Unscoped().Model(kind.model()).Where("deleted_at IS NOT NULL")
+}
+
+// trashedName returns the name of a soft deleted object, a NotFound error if it is not in the trash.
+func trashedName(db *gorm.DB, resource string, kind trashable, id uint) (string, error) {
+	names := make([]string, 0, 1)
+	if err := trashed(db, kind).Where("id = ?", id).Pluck("name", &names).Error; err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", apierrors.NewNotFound(model.TrashResource+"/"+resource, id)
+	}
+	return names[0], nil
+}
+
+func (t *trashRepository) List(resource string, opts *model.ListOptions) ([]model.TrashItem, *model.ListMeta, error) {
+	kind, err := getTrashable(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query, meta, err := paginate(trashed(t.db, kind), opts, trashListFields, clause.OrderByColumn{Column: clause.Column{Name: "deleted_at"}, Desc: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := kind.find(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, meta, nil
+}
+
+// Restore undeletes the object, an AlreadyExists error is returned if its name is used by another object.
+func (t *trashRepository) Restore(resource string, id uint) error {
+	kind, err := getTrashable(resource)
+	if err != nil {
+		return err
+	}
+
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		name, err := trashedName(tx, resource, kind, id)
+		if err != nil {
+			return err
+		}
+		// the version is increased, so updates based on the deleted object fail
+		updates := map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")}
+		if err := tx.Unscoped().Model(kind.model()).Where("id = ?", id).UpdateColumns(updates).Error; err != nil {
+			return alreadyExists(err, resource, name)
+		}
+		if kind.restore != nil {
+			if err := kind.restore(tx, id); err != nil {
+				return err
+			}
+		}
+		if kind.indexed {
+			return t.search.withDB(tx).Index(id)
+		}
+		return nil
+	})
+}
+
+// Purge deletes a soft deleted object permanently, a Conflict error is returned if other objects still reference it,
+// like the posts of a user.
+func (t *trashRepository) Purge(resource string, id uint) error {
+	kind, err := getTrashable(resource)
+	if err != nil {
+		return err
+	}
+
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := trashedName(tx, resource, kind, id); err != nil {
+			return err
+		}
+		return purge(tx, kind, id)
+	})
+}
+
+// PurgeBefore permanently deletes the objects of all resources which were deleted before t, it returns the number of purged objects.
+// Objects which are still referenced by other objects are skipped.
+func (t *trashRepository) PurgeBefore(before time.Time) (int64, error) {
+	var count int64
+	for _, resource := range trashResources {
+		kind := trashables[resource]
+
+		ids := make([]uint, 0)
+		if err := trashed(t.db, kind).Where("deleted_at < ?", before).Pluck("id", &ids).Error; err != nil {
+			return count, err
+		}
+		for _, id := range ids {
+			if err := t.db.Transaction(func(tx *gorm.DB) error { return purge(tx, kind, id) }); err != nil {
+				if apierrors.IsConflict(err) {
+					continue
+				}
+				return count, fmt.Errorf("failed to purge %s %d: %w", resource, id, err)
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+func purge(tx *gorm.DB, kind trashable, id uint) error {
+	if err := kind.purge(tx, id); err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(kind.model(), id).Error
+}
+
+func findTrashedUsers(query *gorm.DB) ([]model.TrashItem, error) {
+	users := make([]model.User, 0)
+	if err := query.Omit("password").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	items := make([]model.TrashItem, len(users))
+	for i := range users {
+		items[i] = trashItem(model.UserResource, users[i].ID, users[i].Name, users[i].DeletedAt, &users[i])
+	}
+	return items, nil
+}
+
+func findTrashedGroups(query *gorm.DB) ([]model.TrashItem, error) {
+	groups := make([]model.Group, 0)
+	if err := query.Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	items := make([]model.TrashItem, len(groups))
+	for i := range groups {
+		items[i] = trashItem(model.GroupResource, groups[i].ID, groups[i].Name, groups[i].DeletedAt, &groups[i])
+	}
+	return items, nil
+}
+
+func findTrashedPosts(query *gorm.DB) ([]model.TrashItem, error) {
+	posts := make([]model.Post, 0)
+	if err := query.Omit("content").Preload("Creator").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	items := make([]model.TrashItem, len(posts))
+	for i := range posts {
+		items[i] = trashItem(model.PostResource, posts[i].ID, posts[i].Name, posts[i].DeletedAt, &posts[i])
+	}
+	return items, nil
+}
+
+func trashItem(resource string, id uint, name string, deletedAt gorm.DeletedAt, obj interface{}) model.TrashItem {
+	return model.TrashItem{
+		Resource:  resource,
+		ID:        id,
+		Name:      name,
+		DeletedAt: deletedAt.Time,
+		Object:    obj,
+	}
+}
+
+// dropUniqueName drops the unique constraint of the name column created by older versions,
+// names are unique among the objects which are not deleted by a partial unique index instead,
+// so the name of an object in the trash can be used again.
+func dropUniqueName(db *gorm.DB, value interface{}) error {
+	m := db.Migrator()
+	if !m.HasTable(value) {
+		return nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(value); err != nil {
+		return err
+	}
+
+	columnTypes, err := m.ColumnTypes(value)
+	if err != nil {
+		return err
+	}
+	for _, ct := range columnTypes {
+		if unique, ok := ct.Unique(); ct.Name() == "name" && ok && unique {
+			if err := m.AlterColumn(value, "Name"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if constraint := "uni_" + stmt.Table + "_name"; m.HasConstraint(value, constraint) {
+		return m.DropConstraint(value, constraint)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTrashTest(t *testing.T) (Repository, *gorm.DB, *model.User) {
+	// foreign keys are enforced, so purging users before their posts fails
+	db, err := gorm.Open(sqlite.Open("file::memory:?_foreign_keys=on"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+	alice, err := r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	return r, db, alice
+}
+
+// count counts the rows of the table including soft deleted rows.
+func count(t *testing.T, db *gorm.DB, table string, query string, args ...interface{}) int64 {
+	var n int64
+	require.Nil(t, db.Table(table).Where(query, args...).Count(&n).Error)
+	return n
+}
+
+func TestTrashRestore(t *testing.T) {
+	r, _, alice := newTrashTest(t)
+
+	first, err := r.Post().Create(alice, &model.Post{Name: "first", Content: "one"})
+	require.Nil(t, err)
+	require.Nil(t, r.Post().Delete(first.ID, 0))
+
+	items, meta, err := r.Trash().List(model.PostResource, nil)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), meta.Total)
+	require.Len(t, items, 1)
+	assert.Equal(t, "first", items[0].Name)
+
+	// the name of a deleted post can be reused, the deleted post can not be restored then
+	reused, err := r.Post().Create(alice, &model.Post{Name: "first", Content: "again"})
+	require.Nil(t, err)
+	err = r.Trash().Restore(model.PostResource, first.ID)
+	assert.True(t, apierrors.IsAlreadyExists(err), err)
+	assert.Equal(t, http.StatusConflict, apierrors.FromError(err).Code)
+	_, err = r.Post().GetPostByID(first.ID)
+	assert.True(t, apierrors.IsNotFound(err))
+
+	require.Nil(t, r.Post().Delete(reused.ID, 0))
+	require.Nil(t, r.Trash().Restore(model.PostResource, first.ID))
+	restored, err := r.Post().GetPostByID(first.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "one", restored.Content)
+	// updates based on the deleted post fail
+	assert.Equal(t, first.Version+1, restored.Version)
+
+	// objects which are not in the trash are not found
+	assert.True(t, apierrors.IsNotFound(r.Trash().Restore(model.PostResource, first.ID)))
+	assert.True(t, apierrors.IsNotFound(r.Trash().Purge(model.PostResource, first.ID)))
+	assert.True(t, apierrors.IsNotFound(r.Trash().Restore("unknown", first.ID)))
+}
+
+func TestTrashPurge(t *testing.T) {
+	r, db, alice := newTrashTest(t)
+	bob, err := r.User().Create(&model.User{Name: "bob", Password: "123456"})
+	require.Nil(t, err)
+
+	post, err := r.Post().Create(alice, &model.Post{Name: "post", Content: "one", Tags: []model.Tag{{Name: "go"}}})
+	require.Nil(t, err)
+	other, err := r.Post().Create(alice, &model.Post{Name: "other", Content: "two"})
+	require.Nil(t, err)
+	for _, pid := range []uint{post.ID, other.ID} {
+		require.Nil(t, r.Post().AddLike(pid, bob.ID))
+		_, err = r.Post().AddComment(&model.Comment{PostID: pid, UserID: bob.ID, Content: "hi"})
+		require.Nil(t, err)
+		require.Nil(t, r.Attachment().Create(&model.Attachment{PostID: pid, UserID: alice.ID, Name: "a.txt", ContentType: "text/plain", Hash: "h", Key: fmt.Sprintf("key%d", pid)}))
+	}
+	post.Content = "changed"
+	_, err = r.Post().Update(post, &model.PostRevision{AuthorID: alice.ID}, 0)
+	require.Nil(t, err)
+
+	require.Nil(t, r.Post().Delete(post.ID, 0))
+	require.Nil(t, r.Trash().Purge(model.PostResource, post.ID))
+
+	assert.Zero(t, count(t, db, "posts", "id = ?", post.ID))
+	dependents := []string{"likes", "comments", "post_revisions", "attachments", "tag_posts"}
+	for _, table := range dependents {
+		assert.Zero(t, count(t, db, table, "post_id = ?", post.ID), table)
+	}
+	// the dependents of other posts are kept
+	for _, table := range dependents[:4] {
+		assert.Equal(t, int64(1), count(t, db, table, "post_id = ?", other.ID), table)
+	}
+}
+
+func TestTrashPurgeBefore(t *testing.T) {
+	r, db, alice := newTrashTest(t)
+	group, err := r.Group().Create(alice, &model.Group{Name: "dev", Kind: model.CustomGroup})
+	require.Nil(t, err)
+	post, err := r.Post().Create(alice, &model.Post{Name: "post", Content: "one"})
+	require.Nil(t, err)
+
+	// the creator is deleted before the post, the post has to be purged first anyway
+	require.Nil(t, r.User().Delete(alice))
+	require.Nil(t, r.Group().Delete(group.ID, 0))
+	require.Nil(t, r.Post().Delete(post.ID, 0))
+
+	n, err := r.Trash().PurgeBefore(time.Now().Add(-time.Hour))
+	require.Nil(t, err)
+	assert.Zero(t, n)
+
+	n, err = r.Trash().PurgeBefore(time.Now().Add(time.Second))
+	require.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Zero(t, count(t, db, "users", "id = ?", alice.ID))
+	assert.Zero(t, count(t, db, "groups", "id = ?", group.ID))
+	assert.Zero(t, count(t, db, "posts", "id = ?", post.ID))
+	assert.Zero(t, count(t, db, "user_groups", "user_id = ?", alice.ID))
+}
+
+func TestTrashPurgeUser(t *testing.T) {
+	r, db, alice := newTrashTest(t)
+	bob, err := r.User().Create(&model.User{Name: "bob", Password: "123456"})
+	require.Nil(t, err)
+	post, err := r.Post().Create(alice, &model.Post{Name: "post", Content: "one"})
+	require.Nil(t, err)
+	_, err = r.Post().AddComment(&model.Comment{PostID: post.ID, UserID: bob.ID, Content: "hi"})
+	require.Nil(t, err)
+	require.Nil(t, r.User().Delete(alice))
+	require.Nil(t, r.User().Delete(bob))
+
+	// users who still own objects are not purged
+	for _, user := range []*model.User{alice, bob} {
+		err = r.Trash().Purge(model.UserResource, user.ID)
+		assert.True(t, apierrors.IsConflict(err), err)
+		assert.Equal(t, http.StatusConflict, apierrors.FromError(err).Code)
+	}
+	n, err := r.Trash().PurgeBefore(time.Now().Add(time.Second))
+	require.Nil(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, int64(2), count(t, db, "users", "id IN ?", []uint{alice.ID, bob.ID}))
+
+	// the post is still in the trash
+	require.Nil(t, r.Post().Delete(post.ID, 0))
+	assert.True(t, apierrors.IsConflict(r.Trash().Purge(model.UserResource, alice.ID)))
+
+	// purging the post purges the comment, nothing references the users then
+	n, err = r.Trash().PurgeBefore(time.Now().Add(time.Second))
+	require.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Zero(t, count(t, db, "users", "id IN ?", []uint{alice.ID, bob.ID}))
+}
+
+// oldUser is the user table of versions whose user names were unique among deleted users too.
+type oldUser struct {
+	ID        uint   `gorm:"autoIncrement;primaryKey"`
+	Name      string `gorm:"size:100;not null;unique"`
+	Password  string `gorm:"type:varchar(256);"`
+	DeletedAt gorm.DeletedAt
+}
+
+func (oldUser) TableName() string {
+	return "users"
+}
+
+func TestDropUniqueName(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	require.Nil(t, db.AutoMigrate(&oldUser{}))
+	require.Nil(t, db.Create(&oldUser{Name: "alice", Password: "123456"}).Error)
+
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+	// migrating twice changes nothing
+	require.Nil(t, r.Migrate())
+
+	alice, err := r.User().GetUserByName("alice")
+	require.Nil(t, err)
+	_, err = r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	assert.True(t, apierrors.IsAlreadyExists(err), err)
+
+	require.Nil(t, r.User().Delete(alice))
+	_, err = r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+}
@@ -6,10 +6,12 @@ import (
 	"github.com/eastygh/webm-nas/pkg/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	userCreateField = []string{"name", "email", "password", "avatar", model.UserAuthInfoAssociation}
+	userUpdateField = []string{"name", "email", "avatar", "updated_at"}
 )
 
 type userRepository struct {
@@ -22,34 +24,42 @@ func newUserRepository(db *gorm.DB) UserRepository {
 	}
 }
 
-func (u *userRepository) List() (model.Users, error) {
+func (u *userRepository) List(opts *model.ListOptions) (model.Users, *model.ListMeta, error) {
+	query, meta, err := paginate(u.db.Model(&model.User{}), opts, userListFields, clause.OrderByColumn{Column: clause.Column{Name: "name"}})
+	if err != nil {
+		return nil, nil, err
+	}
+
 	users := make(model.Users, 0)
-	if err := u.db.Preload(model.UserAuthInfoAssociation).Preload(model.GroupAssociation).Preload("Roles").Order("name").Find(&users).Error; err != nil {
-		return nil, err
+	if err := query.Preload(model.UserAuthInfoAssociation).Preload(model.GroupAssociation).Preload("Roles").Find(&users).Error; err != nil {
+		return nil, nil, err
 	}
-	return users, nil
+	return users, meta, nil
 }
 
 func (u *userRepository) Create(user *model.User) (*model.User, error) {
 	if err := u.db.Select(userCreateField).Create(user).Error; err != nil {
-		return nil, err
+		return nil, alreadyExists(err, model.UserResource, user.Name)
 	}
 	return user, nil
 }
 
+// Update replaces the fields of the user, the password is only updated if it is not empty.
+// The version of the user must be the current version, it is increased by the update.
 func (u *userRepository) Update(user *model.User) (*model.User, error) {
-	if err := u.db.Model(&model.User{}).Where("id = ?", user.ID).Updates(user).Error; err != nil {
+	fields := userUpdateField
+	if user.Password != "" {
+		fields = append(fields[:len(fields):len(fields)], "password")
+	}
+	if err := updateVersion(u.db, user, &user.Version, fields); err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
+// Delete deletes the user and its auth infos, the version of the user is checked if it is not zero.
 func (u *userRepository) Delete(user *model.User) error {
-	err := u.db.Select(model.UserAuthInfoAssociation).Delete(user).Error
-	if err != nil {
-		return err
-	}
-	return nil
+	return deleteVersion(u.db.Select(model.UserAuthInfoAssociation), user, user.Version)
 }
 
 func (u *userRepository) GetUserByID(id uint) (*model.User, error) {
@@ -60,7 +70,7 @@ func (u *userRepository) GetUserByID(id uint) (*model.User, error) {
 
 	user := new(model.User)
 	if err := u.db.Omit("Password").Preload(model.UserAuthInfoAssociation).Preload("Groups").Preload("Groups.Roles").Preload("Roles").First(user, id).Error; err != nil {
-		return nil, err
+		return nil, notFound(err, model.UserResource, id)
 	}
 	return user, nil
 }
@@ -68,7 +78,7 @@ func (u *userRepository) GetUserByID(id uint) (*model.User, error) {
 func (u *userRepository) GetUserByAuthID(authType, authID string) (*model.User, error) {
 	authInfo := new(model.AuthInfo)
 	if err := u.db.Where("auth_type = ? and auth_id = ?", authType, authID).First(authInfo).Error; err != nil {
-		return nil, err
+		return nil, notFound(err, "authinfos", authID)
 	}
 
 	return u.GetUserByID(authInfo.UserId)
@@ -77,7 +87,7 @@ func (u *userRepository) GetUserByAuthID(authType, authID string) (*model.User,
 func (u *userRepository) GetUserByName(name string) (*model.User, error) {
 	user := new(model.User)
 	if err := u.db.Preload(model.UserAuthInfoAssociation).Preload("Groups").Preload("Groups.Roles").Preload("Roles").Where("name = ?", name).First(user).Error; err != nil {
-		return nil, err
+		return nil, notFound(err, model.UserResource, name)
 	}
 	return user, nil
 }
@@ -114,5 +124,8 @@ func (u *userRepository) GetGroups(user *model.User) ([]model.Group, error) {
 }
 
 func (u *userRepository) Migrate() error {
+	if err := dropUniqueName(u.db, &model.User{}); err != nil {
+		return err
+	}
 	return u.db.AutoMigrate(&model.User{}, &model.AuthInfo{})
 }
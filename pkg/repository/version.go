@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+
+	"gorm.io/gorm"
+)
+
+// updateVersion updates the fields of value only if its version in the db is still *version,
+// *version is increased on success and a Conflict is returned if the row was changed by others.
+// value must be a pointer to a model with the primary key set.
+func updateVersion(db *gorm.DB, value interface{}, version *uint64, fields []string) error {
+	current := *version
+	*version = current + 1
+
+	result := db.Model(value).Where("version = ?", current).Select(append(fields[:len(fields):len(fields)], "version")).Updates(value)
+	if result.Error != nil {
+		*version = current
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		*version = current
+		return apierrors.NewConflict(apierrors.ErrModified, nil)
+	}
+	return nil
+}
+
+// deleteVersion deletes value by its primary key, a non zero version must be the version in the db.
+func deleteVersion(db *gorm.DB, value interface{}, version uint64) error {
+	if version != 0 {
+		db = db.Where("version = ?", version)
+	}
+	result := db.Delete(value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if version != 0 && result.RowsAffected == 0 {
+		return apierrors.NewConflict(apierrors.ErrModified, nil)
+	}
+	return nil
+}
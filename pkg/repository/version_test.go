@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestVersion(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	r := NewRepository(db)
+	require.Nil(t, r.Migrate())
+
+	alice, err := r.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	group, err := r.Group().Create(alice, &model.Group{Name: "dev", Kind: model.CustomGroup})
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), group.Version)
+
+	// an update increases the version
+	updated, err := r.Group().Update(&model.Group{ID: group.ID, Describe: "one", Version: 1})
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2), updated.Version)
+
+	// an update based on an old version changes nothing and keeps the version of the object
+	stale := &model.Group{ID: group.ID, Describe: "stale", Version: 1}
+	_, err = r.Group().Update(stale)
+	assert.True(t, errors.Is(err, apierrors.ErrModified), err)
+	assert.Equal(t, uint64(1), stale.Version)
+	current, err := r.Group().GetGroupByID(group.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "one", current.Describe)
+	assert.Equal(t, uint64(2), current.Version)
+
+	// a delete with an old version is a conflict, a zero version deletes unconditionally
+	err = r.Group().Delete(group.ID, 1)
+	assert.True(t, errors.Is(err, apierrors.ErrModified), err)
+	_, err = r.Group().GetGroupByID(group.ID)
+	require.Nil(t, err)
+	require.Nil(t, r.Group().Delete(group.ID, 2))
+	_, err = r.Group().GetGroupByID(group.ID)
+	assert.True(t, apierrors.IsNotFound(err))
+
+	other, err := r.Group().Create(alice, &model.Group{Name: "ops", Kind: model.CustomGroup})
+	require.Nil(t, err)
+	require.Nil(t, r.Group().Delete(other.ID, 0))
+	// deleting a deleted object with a version is a conflict too, it is not there in that version
+	err = r.Group().Delete(other.ID, 1)
+	assert.True(t, errors.Is(err, apierrors.ErrModified), err)
+}
@@ -3,7 +3,6 @@ package server
 import (
 	"context"
 	"fmt"
-	"gorm.io/gorm"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -12,6 +11,8 @@ import (
 	"time"
 
 	_ "github.com/eastygh/webm-nas/docs"
+	"github.com/eastygh/webm-nas/pkg/analytics"
+	"github.com/eastygh/webm-nas/pkg/audit"
 	"github.com/eastygh/webm-nas/pkg/authentication"
 	"github.com/eastygh/webm-nas/pkg/authorization"
 	"github.com/eastygh/webm-nas/pkg/common"
@@ -19,11 +20,13 @@ import (
 	"github.com/eastygh/webm-nas/pkg/controller"
 	"github.com/eastygh/webm-nas/pkg/database"
 	"github.com/eastygh/webm-nas/pkg/middleware"
+	"github.com/eastygh/webm-nas/pkg/openapi"
 	"github.com/eastygh/webm-nas/pkg/repository"
 	"github.com/eastygh/webm-nas/pkg/service"
 	"github.com/eastygh/webm-nas/pkg/utils/request"
 	"github.com/eastygh/webm-nas/pkg/utils/set"
 	"github.com/eastygh/webm-nas/pkg/version"
+	"github.com/eastygh/webm-nas/pkg/watch"
 	"github.com/pkg/errors"
 
 	"github.com/gin-gonic/gin"
@@ -33,18 +36,19 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+const (
+	cleanupInterval = time.Hour
+	// scheduleInterval is how often scheduled posts are published
+	scheduleInterval = time.Minute
+)
+
 func New(conf *config.Config, logger *logrus.Logger) (*Server, error) {
 	rateLimitMiddleware, err := middleware.RateLimitMiddleware(conf.Server.LimitConfigs)
 	if err != nil {
 		return nil, err
 	}
 
-	var db *gorm.DB
-	if conf.DB.Type == "sqlite" {
-		db, err = database.NewSqlite(&conf.DB)
-	} else {
-		//db, err = database.NewPostgres(&conf.DB) or some other db
-	}
+	db, err := database.New(&conf.DB)
 	if err != nil {
 		return nil, errors.Wrap(err, "db init failed")
 	}
@@ -60,46 +64,105 @@ func New(conf *config.Config, logger *logrus.Logger) (*Server, error) {
 		return nil, err
 	}
 
-	userService := service.NewUserService(modelRepository.User())
-	groupService := service.NewGroupService(modelRepository.Group(), modelRepository.User())
+	broadcaster := watch.NewBroadcaster(0)
+
+	userService := service.NewUserService(modelRepository.User(), broadcaster)
+	groupService := service.NewGroupService(modelRepository.Group(), modelRepository.User(), modelRepository.RBAC(), broadcaster)
 	jwtService := authentication.NewJWTService(conf.Server.JWTSecret)
-	rbacService := service.NewRBACService(modelRepository.RBAC())
+	rbacService := service.NewRBACService(modelRepository.RBAC(), broadcaster)
+	reportInvalidRoles(rbacService, logger)
 
 	userController := controller.NewUserController(userService)
 	groupController := controller.NewGroupController(groupService)
 	authController := controller.NewAuthController(userService, jwtService, conf)
-	rbacController := controller.NewRbacController(rbacService)
-	postController := controller.NewPostController(service.NewPostService(modelRepository.Post()))
+	rbacController := controller.NewRbacController(rbacService, service.NewRBACPolicyService(modelRepository, broadcaster))
+	recorder := analytics.New(modelRepository.Stats(), analytics.Options{
+		Window:        time.Duration(conf.Analytics.ViewWindowMinutes) * time.Minute,
+		FlushInterval: time.Duration(conf.Analytics.FlushIntervalSeconds) * time.Second,
+	})
+	postService := service.NewPostService(modelRepository.Post(), recorder, broadcaster, service.PostOptions{
+		MaxRevisions:    conf.Post.MaxRevisions,
+		MaxCommentDepth: conf.Comment.MaxDepth,
+		HoldNewUsers:    time.Duration(conf.Comment.HoldNewUserDays) * 24 * time.Hour,
+		RenderCacheSize: conf.Post.RenderCacheSize,
+	})
+	postController := controller.NewPostController(postService)
+	statsController := controller.NewStatsController(service.NewStatsService(modelRepository))
+	tagController := controller.NewTagController(service.NewTagService(modelRepository.Tag(), modelRepository.Post()))
+	categoryController := controller.NewCategoryController(service.NewCategoryService(modelRepository.Category(), modelRepository.Post()))
+	feedController := controller.NewFeedController(service.NewFeedService(modelRepository, service.FeedOptions{
+		Title: conf.Feed.Title,
+		Limit: conf.Feed.Limit,
+	}), conf.Feed.BaseURL)
+	attachmentService := service.NewAttachmentService(modelRepository, service.AttachmentOptions{
+		Dir:          conf.Attachment.Dir,
+		MaxSize:      conf.Attachment.MaxSize,
+		AllowedTypes: conf.Attachment.AllowedTypes,
+	})
+	attachmentController := controller.NewAttachmentController(attachmentService)
+	archiveController := controller.NewArchiveController(service.NewArchiveService(modelRepository, attachmentService, broadcaster, service.ArchiveOptions{
+		MaxImportSize: conf.Post.MaxImportSize,
+	}))
+	auditController := controller.NewAuditController(service.NewAuditService(modelRepository.Audit()))
+	watchController := controller.NewWatchController(broadcaster)
+	trashService := service.NewTrashService(modelRepository, time.Duration(conf.Trash.RetentionDays)*24*time.Hour, broadcaster)
+	trashController := controller.NewTrashController(trashService)
 
 	if err := authorization.InitAuthorization(modelRepository); err != nil {
 		return nil, err
 	}
 
-	controllers := []controller.Controller{userController, groupController, authController, rbacController, postController}
+	auditor, err := audit.New(&conf.Audit, modelRepository.Audit())
+	if err != nil {
+		return nil, errors.Wrap(err, "audit init failed")
+	}
+
+	requestInfoResolver := &request.RequestInfoFactory{
+		APIPrefixes: set.NewString("api"),
+		// api v2 uses plural nouns for subresources
+		SubresourceAliases: map[string]string{"posts/comments": "comment"},
+	}
+	batchController := controller.NewBatchController(service.NewBatchService(modelRepository, broadcaster, requestInfoResolver), auditor, requestInfoResolver)
+
+	controllers := []controller.Controller{userController, groupController, authController, rbacController, postController, statsController, attachmentController, archiveController, tagController, categoryController, auditController, watchController, batchController, trashController}
 
 	gin.SetMode(conf.Server.ENV)
 
 	e := gin.New()
+	// client ips are used to rate limit, audit and count views, only proxies of the config may set them
+	if err := e.SetTrustedProxies(conf.Server.TrustedProxies); err != nil {
+		return nil, errors.Wrap(err, "invalid trusted proxies")
+	}
 	e.Use(
 		gin.Recovery(),
+		middleware.APIVersionMiddleware(),
 		rateLimitMiddleware,
 		middleware.MonitorMiddleware(),
 		middleware.CORSMiddleware(),
-		middleware.RequestInfoMiddleware(&request.RequestInfoFactory{APIPrefixes: set.NewString("api")}),
+		middleware.RequestInfoMiddleware(requestInfoResolver),
 		middleware.LogMiddleware(logger, "/"),
 		middleware.AuthenticationMiddleware(jwtService, modelRepository.User()),
+		middleware.AuditMiddleware(auditor),
 		middleware.AuthorizationMiddleware(),
+		middleware.IdempotencyMiddleware(modelRepository.Idempotency(), time.Duration(conf.Idempotency.TTLHours)*time.Hour, maxUploadSize(conf)),
 		middleware.TraceMiddleware(),
 	)
 
 	e.LoadHTMLFiles("static/terminal.html")
 
 	return &Server{
-		engine:      e,
-		config:      conf,
-		logger:      logger,
-		repository:  modelRepository,
-		controllers: controllers,
+		engine:       e,
+		config:       conf,
+		logger:       logger,
+		repository:   modelRepository,
+		auditor:      auditor,
+		analytics:    recorder,
+		broadcaster:  broadcaster,
+		trashService: trashService,
+		postService:  postService,
+		attachments:  attachmentService,
+		controllers:  controllers,
+		feeds:        feedController,
 	}, nil
 }
 
@@ -108,9 +171,17 @@ type Server struct {
 	config *config.Config
 	logger *logrus.Logger
 
-	repository repository.Repository
+	repository   repository.Repository
+	auditor      *audit.Auditor
+	analytics    *analytics.Recorder
+	broadcaster  *watch.Broadcaster
+	trashService service.TrashService
+	postService  service.PostService
+	attachments  service.AttachmentService
 
 	controllers []controller.Controller
+	// feeds are served on /feeds, outside of the api
+	feeds controller.Controller
 }
 
 // graceful shutdown
@@ -126,6 +197,13 @@ func (s *Server) Run() error {
 		Addr:    addr,
 		Handler: s.engine,
 	}
+	// watch streams never end by themselves, close them before waiting for active connections
+	server.RegisterOnShutdown(s.broadcaster.Close)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.cleanup(stop)
+	go s.publishScheduled(stop)
 
 	go func() {
 		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
@@ -146,12 +224,69 @@ func (s *Server) Run() error {
 }
 
 func (s *Server) Close() {
+	if s.analytics != nil {
+		if err := s.analytics.Close(); err != nil {
+			s.logger.Warnf("failed to write post stats, %v", err)
+		}
+	}
+
+	if s.auditor != nil {
+		if err := s.auditor.Close(); err != nil {
+			s.logger.Warnf("failed to close auditor, %v", err)
+		}
+	}
+
 	if err := s.repository.Close(); err != nil {
 		s.logger.Warnf("failed to close repository, %v", err)
 	}
 
 }
 
+// cleanup purges the expired objects of the trash, expired idempotency keys and orphaned attachment files
+// periodically until stop is closed.
+func (s *Server) cleanup(stop <-chan struct{}) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.trashService.PurgeExpired(); err != nil {
+			s.logger.Warnf("Failed to purge trash: %v", err)
+		}
+		if _, err := s.repository.Idempotency().DeleteExpired(time.Now()); err != nil {
+			s.logger.Warnf("Failed to delete expired idempotency keys: %v", err)
+		}
+		if _, err := s.attachments.CleanupOrphans(); err != nil {
+			s.logger.Warnf("Failed to clean up attachments: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishScheduled publishes scheduled posts whose time has come periodically until stop is closed.
+func (s *Server) publishScheduled(stop <-chan struct{}) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.postService.PublishDue()
+		if err != nil {
+			s.logger.Warnf("Failed to publish scheduled posts: %v", err)
+		}
+		if n > 0 {
+			s.logger.Infof("Published %d scheduled posts", n)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (s *Server) initRouter() {
 	root := s.engine
 
@@ -173,6 +308,8 @@ func (s *Server) initRouter() {
 		manage.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 	}
 
+	s.feeds.RegisterRoute(root.Group("/feeds"))
+
 	api := root.Group("/api/v1")
 	controllers := make([]string, 0, len(s.controllers))
 	for _, router := range s.controllers {
@@ -180,6 +317,19 @@ func (s *Server) initRouter() {
 		controllers = append(controllers, router.Name())
 	}
 	logrus.Infof("server enabled controllers: %v", controllers)
+
+	// api v2 serves the same controllers, its openapi document is generated from the routes
+	v2 := root.Group("/api/" + common.APIVersionV2)
+	doc := openapi.NewDocument(openapi.Info{Title: "Weave Server API", Version: "2.0"})
+	router := controller.NewRouter(v2, doc)
+	for _, c := range s.controllers {
+		if c, ok := c.(controller.ControllerV2); ok {
+			c.RegisterRouteV2(router)
+		}
+	}
+	v2.GET("/"+request.OpenAPIPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	})
 }
 
 func (s *Server) getRoutes() []string {
@@ -192,6 +342,37 @@ func (s *Server) getRoutes() []string {
 	return paths.Slice()
 }
 
+// reportInvalidRoles warns about roles whose rules reference unknown resources or operations,
+// such rules never match any request.
+func reportInvalidRoles(rbacService service.RBACService, logger *logrus.Logger) {
+	report, err := rbacService.CheckRoles()
+	if err != nil {
+		logger.Warnf("Failed to check roles: %v", err)
+		return
+	}
+	for role, errs := range report {
+		logger.Warnf("Role %s has invalid rules: %v", role, errs.ToAggregate())
+	}
+}
+
+// maxUploadSize returns the size of the largest request body, the form of the largest import or attachment,
+// bodies of requests with idempotency keys are buffered up to it.
+func maxUploadSize(conf *config.Config) int64 {
+	size := conf.Post.MaxImportSize
+	if size <= 0 {
+		size = service.DefaultMaxImportSize
+	}
+	attachment := conf.Attachment.MaxSize
+	if attachment <= 0 {
+		attachment = service.DefaultAttachmentMaxSize
+	}
+	if attachment > size {
+		size = attachment
+	}
+	// room for the rest of the multipart form
+	return size + 1<<20
+}
+
 type ServerStatus struct {
 	Ping         bool `json:"ping"`
 	DBRepository bool `json:"dbRepository"`
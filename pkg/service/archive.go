@@ -0,0 +1,493 @@
+package service
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/frontmatter"
+	"github.com/eastygh/webm-nas/pkg/utils/markdown"
+	"github.com/eastygh/webm-nas/pkg/validation"
+	"github.com/eastygh/webm-nas/pkg/watch"
+)
+
+const (
+	DefaultMaxImportSize = 100 << 20
+	// maxImportFileSize is the max size of a Markdown file of an import
+	maxImportFileSize = 8 << 20
+	// exportFile is the Markdown file of a post in its directory of an export, like the page bundles of Hugo
+	exportFile = "index.md"
+	// maxImportSummary is the max length of the summary of an imported post
+	maxImportSummary = 512
+)
+
+// linkDestRe matches the destinations of Markdown links and images, like images/cat.png of ![cat](images/cat.png "title").
+var linkDestRe = regexp.MustCompile(`(\]\(\s*)(<[^<>\n]+>|[^()\s]+)`)
+
+// ArchiveOptions configures imports and exports of posts.
+type ArchiveOptions struct {
+	// MaxImportSize is the max size of an imported zip in bytes, default 100 MiB
+	MaxImportSize int64
+}
+
+type archiveService struct {
+	postRepository repository.PostRepository
+	userRepository repository.UserRepository
+	attachments    AttachmentService
+	events         watch.Publisher
+	opts           ArchiveOptions
+}
+
+func NewArchiveService(repo repository.Repository, attachments AttachmentService, events watch.Publisher, opts ArchiveOptions) ArchiveService {
+	if opts.MaxImportSize <= 0 {
+		opts.MaxImportSize = DefaultMaxImportSize
+	}
+	return &archiveService{
+		postRepository: repo.Post(),
+		userRepository: repo.User(),
+		attachments:    attachments,
+		events:         events,
+		opts:           opts,
+	}
+}
+
+// Export lists the posts visible to the reader selected and sorted by opts without pagination, they are written by WriteZip.
+// The posts are listed before the zip is written so errors can be reported before the response is started.
+func (a *archiveService) Export(opts *model.ListOptions, reader model.PostReader) ([]model.Post, error) {
+	all := model.ListOptions{}
+	if opts != nil {
+		all = *opts
+	}
+	all.Limit, all.Page, all.Continue = 0, 0, ""
+	posts, _, err := a.postRepository.List(&all, reader)
+	return posts, err
+}
+
+// WriteZip writes the posts as a zip to w. Each post is a directory of a Markdown file with YAML front matter and
+// the attachments of the post, the urls of the attachments in the content are replaced by their relative paths.
+func (a *archiveService) WriteZip(w io.Writer, posts []model.Post) error {
+	zw := zip.NewWriter(w)
+	dirs := make(map[string]bool, len(posts))
+	for i := range posts {
+		post, err := a.postRepository.GetPostByID(posts[i].ID)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				// deleted after the posts were listed
+				continue
+			}
+			return err
+		}
+		dir := exportDir(post, dirs)
+		if err := a.writePost(zw, dir, post); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (a *archiveService) writePost(zw *zip.Writer, dir string, post *model.Post) error {
+	// paths of attachments are relative to the Markdown file, which is in the directory of the post
+	content, attachments, err := a.attachments.ExportContent(post, "")
+	if err != nil {
+		return err
+	}
+	src, err := frontmatter.Marshal(postFrontMatter(post), []byte(content))
+	if err != nil {
+		return err
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: path.Join(dir, exportFile), Method: zip.Deflate, Modified: post.UpdatedAt})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(src); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(attachments))
+	for name := range attachments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := a.writeAttachment(zw, path.Join(dir, name), attachments[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *archiveService) writeAttachment(zw *zip.Writer, name string, attachment *model.Attachment) error {
+	file, err := a.attachments.Open(attachment)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: attachment.CreatedAt})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// postFrontMatter returns the front matter of the post in an export.
+func postFrontMatter(post *model.Post) *model.PostFrontMatter {
+	matter := &model.PostFrontMatter{
+		Name:        post.Name,
+		Summary:     post.Summary,
+		Author:      post.Creator.Name,
+		Status:      post.Status,
+		PublishedAt: post.PublishedAt,
+		CreatedAt:   &post.CreatedAt,
+		UpdatedAt:   &post.UpdatedAt,
+	}
+	for _, tag := range post.Tags {
+		matter.Tags = append(matter.Tags, tag.Name)
+	}
+	for _, category := range post.Categories {
+		matter.Categories = append(matter.Categories, category.Name)
+	}
+	return matter
+}
+
+// exportDir returns the directory of the post in an export, the slug of its name or its id if the slug is used.
+func exportDir(post *model.Post, used map[string]bool) string {
+	dir := slug(post.Name)
+	if dir == "" || used[dir] {
+		dir = strings.TrimPrefix(fmt.Sprintf("%s-%d", dir, post.ID), "-")
+	}
+	used[dir] = true
+	return dir
+}
+
+// slug returns the lower case letters and digits of the name separated by dashes.
+func slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// Import creates the posts of the Markdown files in the zip, only editors may import posts.
+// Authors of the front matter are mapped to the users of their names, unknown authors are replaced by the user.
+// Tags and categories which do not exist are created. Files whose name is used by a post are conflicts and
+// are not imported. Files in the zip linked by the content, and the files of page bundles, are uploaded as attachments.
+func (a *archiveService) Import(user *model.User, content io.Reader, reader model.PostReader) (*model.ImportResult, error) {
+	if !reader.Editor {
+		return nil, apierrors.NewForbidden(fmt.Errorf("only editors may import posts"))
+	}
+
+	tmp, err := os.CreateTemp("", "import-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	// one more byte than allowed is read to detect a larger zip
+	size, err := io.Copy(tmp, io.LimitReader(content, a.opts.MaxImportSize+1))
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err)
+	}
+	if size > a.opts.MaxImportSize {
+		return nil, apierrors.NewRequestEntityTooLarge(fmt.Errorf("imports can be at most %d bytes", a.opts.MaxImportSize))
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Errorf("invalid zip: %w", err))
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	posts := make([]string, 0)
+	for _, file := range zr.File {
+		name := path.Clean(strings.ReplaceAll(file.Name, `\`, "/"))
+		if file.FileInfo().IsDir() || hiddenFile(name) {
+			continue
+		}
+		files[name] = file
+		if isMarkdown(name) {
+			posts = append(posts, name)
+		}
+	}
+	if len(posts) == 0 {
+		return nil, apierrors.NewBadRequest(fmt.Errorf("the zip has no Markdown files"))
+	}
+	sort.Strings(posts)
+
+	result := &model.ImportResult{Results: make([]model.ImportItemResult, 0, len(posts))}
+	for _, name := range posts {
+		item := a.importFile(user, name, files)
+		result.Results = append(result.Results, item)
+		switch item.Status {
+		case model.ImportCreated:
+			result.Summary.Created++
+		case model.ImportConflict:
+			result.Summary.Conflicts++
+		default:
+			result.Summary.Failed++
+		}
+	}
+	result.Summary.Total = len(result.Results)
+	return result, nil
+}
+
+// importFile creates the post of the Markdown file name of the zip.
+func (a *archiveService) importFile(user *model.User, name string, files map[string]*zip.File) model.ImportItemResult {
+	result := model.ImportItemResult{File: name}
+	failed := func(err error) model.ImportItemResult {
+		result.Status, result.Error = model.ImportFailed, err.Error()
+		return result
+	}
+
+	src, err := readZipFile(files[name], maxImportFileSize)
+	if err != nil {
+		return failed(err)
+	}
+	matter := &model.PostFrontMatter{}
+	content, err := frontmatter.Unmarshal(src, matter)
+	if err != nil {
+		return failed(fmt.Errorf("invalid front matter: %w", err))
+	}
+	post := importedPost(name, matter, string(content))
+	result.Name = post.Name
+
+	author := user
+	if matter.Author != "" && matter.Author != user.Name {
+		if author, err = a.userRepository.GetUserByName(matter.Author); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return failed(err)
+			}
+			author = user
+			result.Warnings = append(result.Warnings, fmt.Sprintf("author %s does not exist, the post is created by %s", matter.Author, user.Name))
+		}
+	}
+
+	if _, err := a.postRepository.GetPostByName(post.Name); err == nil {
+		result.Status, result.Error = model.ImportConflict, fmt.Sprintf("post %s already exists", post.Name)
+		return result
+	}
+	if err := validation.Struct(post); err != nil {
+		return failed(err)
+	}
+	if post.Status == model.PostScheduled {
+		if err := setPublishTime(post, time.Now()); err != nil {
+			return failed(err)
+		}
+	}
+
+	post, err = a.postRepository.Create(author, post)
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			result.Status, result.Error = model.ImportConflict, err.Error()
+			return result
+		}
+		return failed(err)
+	}
+	result.Status, result.ID = model.ImportCreated, post.ID
+
+	linked, warnings := a.importAttachments(author, post, name, files)
+	result.Warnings = append(result.Warnings, warnings...)
+	if linked != post.Content {
+		if err := a.postRepository.SetContent(post.ID, linked); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to link attachments: %v", err))
+		} else {
+			post.Content = linked
+		}
+	}
+	a.events.Publish(model.PostResource, watch.Created, strconv.Itoa(int(post.ID)), post)
+	return result
+}
+
+// importedPost returns the post of the front matter and content of the Markdown file name. The name of the post
+// defaults to the title and the file name, the status to published unless it is a draft of Hugo.
+func importedPost(name string, matter *model.PostFrontMatter, content string) *model.Post {
+	post := &model.Post{
+		Name:        strings.TrimSpace(matter.Name),
+		Content:     content,
+		Summary:     markdown.Summary(strings.TrimSpace(matter.Summary), maxImportSummary),
+		Status:      matter.Status,
+		PublishedAt: matter.PublishedAt,
+	}
+	if post.Name == "" {
+		post.Name = strings.TrimSpace(matter.Title)
+	}
+	if post.Name == "" {
+		post.Name = strings.TrimSuffix(path.Base(name), path.Ext(name))
+		if (post.Name == "index" || post.Name == "_index") && path.Dir(name) != "." {
+			post.Name = path.Base(path.Dir(name))
+		}
+	}
+	if post.Summary == "" {
+		post.Summary = getSummary(content)
+	}
+
+	if post.Status == "" {
+		post.Status = model.PostPublished
+		if matter.Draft {
+			post.Status = model.PostDraft
+		}
+	}
+	if post.PublishedAt == nil {
+		post.PublishedAt = matter.Date
+	}
+	switch post.Status {
+	case model.PostDraft:
+		post.PublishedAt = nil
+	case model.PostPublished:
+		if post.PublishedAt == nil {
+			now := time.Now()
+			post.PublishedAt = &now
+		}
+	}
+
+	if matter.CreatedAt != nil {
+		post.CreatedAt = *matter.CreatedAt
+	} else if matter.Date != nil {
+		post.CreatedAt = *matter.Date
+	}
+	if matter.UpdatedAt != nil {
+		post.UpdatedAt = *matter.UpdatedAt
+	} else {
+		post.UpdatedAt = post.CreatedAt
+	}
+
+	for _, tag := range matter.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			post.Tags = append(post.Tags, model.Tag{Name: tag})
+		}
+	}
+	for _, category := range matter.Categories {
+		if category = strings.TrimSpace(category); category != "" {
+			post.Categories = append(post.Categories, model.Category{Name: category})
+		}
+	}
+	return post
+}
+
+// importAttachments uploads the files of the zip linked by the content of the post, and the files of the directory of
+// a page bundle, and returns the content with the links replaced by the urls of the attachments.
+// Files which can not be uploaded are reported by the warnings and their links are kept.
+func (a *archiveService) importAttachments(author *model.User, post *model.Post, name string, files map[string]*zip.File) (string, []string) {
+	dir := path.Dir(name)
+	id := strconv.Itoa(int(post.ID))
+	reader := model.PostReader{UserID: author.ID, Editor: true}
+
+	urls := make(map[string]string)
+	warnings := make([]string, 0)
+	upload := func(file string) (string, bool) {
+		if u, ok := urls[file]; ok {
+			return u, u != ""
+		}
+		urls[file] = ""
+		zf, ok := files[file]
+		if !ok || isMarkdown(file) {
+			return "", false
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("attachment %s: %v", file, err))
+			return "", false
+		}
+		defer rc.Close()
+		attachment, err := a.attachments.Create(author, id, path.Base(file), rc, reader)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("attachment %s: %v", file, err))
+			return "", false
+		}
+		urls[file] = attachment.URL
+		return attachment.URL, true
+	}
+
+	content := linkDestRe.ReplaceAllStringFunc(post.Content, func(link string) string {
+		m := linkDestRe.FindStringSubmatch(link)
+		file, ok := localFile(dir, strings.TrimSuffix(strings.TrimPrefix(m[2], "<"), ">"))
+		if !ok {
+			return link
+		}
+		if u, ok := upload(file); ok {
+			return m[1] + u
+		}
+		return link
+	})
+
+	// the other files of a page bundle are its resources, like images of its front matter
+	if path.Base(name) == exportFile && dir != "." {
+		bundle := make([]string, 0)
+		for file := range files {
+			if strings.HasPrefix(file, dir+"/") {
+				bundle = append(bundle, file)
+			}
+		}
+		sort.Strings(bundle)
+		for _, file := range bundle {
+			upload(file)
+		}
+	}
+	return content, warnings
+}
+
+// localFile returns the path in the zip of a relative link of a Markdown file in the directory dir.
+func localFile(dir, dest string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+	file := path.Join(dir, u.Path)
+	if file == ".." || strings.HasPrefix(file, "../") {
+		return "", false
+	}
+	return file, true
+}
+
+// readZipFile reads the file of the zip, which may not be larger than max.
+func readZipFile(file *zip.File, max int64) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	src, err := io.ReadAll(io.LimitReader(rc, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(src)) > max {
+		return nil, fmt.Errorf("the file is larger than %d bytes", max)
+	}
+	return src, nil
+}
+
+func isMarkdown(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// hiddenFile reports files of hidden directories and the metadata of archivers, like the __MACOSX directory.
+func hiddenFile(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/watch"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newArchiveTest(t *testing.T) (repository.Repository, AttachmentService, ArchiveService) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	repo := repository.NewRepository(db)
+	require.Nil(t, repo.Migrate())
+	events := watch.NewBroadcaster(0)
+	attachments := NewAttachmentService(repo, AttachmentOptions{Dir: t.TempDir()})
+	return repo, attachments, NewArchiveService(repo, attachments, events, ArchiveOptions{})
+}
+
+func TestArchive(t *testing.T) {
+	repo, attachments, s := newArchiveTest(t)
+	alice, err := repo.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	post, err := repo.Post().Create(alice, &model.Post{
+		Name:       "Hello World",
+		Content:    "# Hello\n\n![cat](/api/v1/posts/1/attachments/1)",
+		Summary:    "hello",
+		Tags:       []model.Tag{{Name: "go"}},
+		Categories: []model.Category{{Name: "notes"}},
+	})
+	require.Nil(t, err)
+	editor := model.PostReader{UserID: alice.ID, Editor: true}
+	_, err = attachments.Create(alice, "1", "cat.png", bytes.NewReader(append(pngHeader, "data"...)), editor)
+	require.Nil(t, err)
+
+	posts, err := s.Export(&model.ListOptions{Limit: 1}, editor)
+	require.Nil(t, err)
+	require.Len(t, posts, 1)
+	buf := &bytes.Buffer{}
+	require.Nil(t, s.WriteZip(buf, posts))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.Nil(t, err)
+	require.Len(t, zr.File, 2)
+	assert.Equal(t, "hello-world/index.md", zr.File[0].Name)
+	assert.Equal(t, "hello-world/cat.png", zr.File[1].Name)
+	rc, err := zr.File[0].Open()
+	require.Nil(t, err)
+	src, err := io.ReadAll(rc)
+	require.Nil(t, err)
+	assert.Contains(t, string(src), "name: Hello World\n")
+	assert.Contains(t, string(src), "author: alice\n")
+	assert.Contains(t, string(src), "tags:\n- go\n")
+	assert.True(t, strings.HasSuffix(string(src), "---\n# Hello\n\n![cat](cat.png)"))
+
+	// posts whose name is used are conflicts
+	result, err := s.Import(alice, bytes.NewReader(buf.Bytes()), editor)
+	require.Nil(t, err)
+	assert.Equal(t, model.ImportSummary{Total: 1, Conflicts: 1}, result.Summary)
+	assert.Equal(t, model.ImportConflict, result.Results[0].Status)
+
+	_, err = s.Import(alice, bytes.NewReader(buf.Bytes()), model.PostReader{UserID: alice.ID})
+	assert.Equal(t, apierrors.ReasonForbidden, apierrors.ReasonForError(err))
+
+	// the export is imported by another server, authors are mapped by name and attachments are uploaded
+	repo2, attachments2, s2 := newArchiveTest(t)
+	admin, err := repo2.User().Create(&model.User{Name: "admin", Password: "123456"})
+	require.Nil(t, err)
+	alice2, err := repo2.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	result, err = s2.Import(admin, bytes.NewReader(buf.Bytes()), model.PostReader{UserID: admin.ID, Editor: true})
+	require.Nil(t, err)
+	assert.Equal(t, model.ImportSummary{Total: 1, Created: 1}, result.Summary)
+	assert.Empty(t, result.Results[0].Warnings)
+
+	imported, err := repo2.Post().GetPostByID(result.Results[0].ID)
+	require.Nil(t, err)
+	assert.Equal(t, "Hello World", imported.Name)
+	assert.Equal(t, alice2.ID, imported.CreatorID)
+	assert.Equal(t, "hello", imported.Summary)
+	assert.Equal(t, "go", imported.Tags[0].Name)
+	assert.Equal(t, "notes", imported.Categories[0].Name)
+	assert.Equal(t, post.CreatedAt.Unix(), imported.CreatedAt.Unix())
+	assert.Equal(t, "# Hello\n\n![cat](/api/v1/posts/1/attachments/1)", imported.Content)
+	list, err := attachments2.List("1", model.PostReader{Editor: true})
+	require.Nil(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "cat.png", list[0].Name)
+}
+
+func TestImportHugo(t *testing.T) {
+	repo, _, s := newArchiveTest(t)
+	admin, err := repo.User().Create(&model.User{Name: "admin", Password: "123456"})
+	require.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range map[string]string{
+		"content/posts/first.md":  "---\ntitle: First\ndraft: true\nauthor: bob\ntags: [a, b]\n---\nSee ![img](../../images/x.png) and [other](https://example.com/x.png).\n",
+		"content/posts/second.md": "---\ntitle: [broken\n---\ntext\n",
+		"content/posts/third.md":  "no front matter\n",
+		"images/x.png":            string(pngHeader),
+		"__MACOSX/content/._a.md": "junk",
+	} {
+		w, err := zw.Create(name)
+		require.Nil(t, err)
+		_, err = w.Write([]byte(content))
+		require.Nil(t, err)
+	}
+	require.Nil(t, zw.Close())
+
+	result, err := s.Import(admin, buf, model.PostReader{UserID: admin.ID, Editor: true})
+	require.Nil(t, err)
+	assert.Equal(t, model.ImportSummary{Total: 3, Created: 2, Failed: 1}, result.Summary)
+	assert.Equal(t, "content/posts/first.md", result.Results[0].File)
+	assert.Len(t, result.Results[0].Warnings, 1)
+	assert.Equal(t, model.ImportFailed, result.Results[1].Status)
+	assert.Equal(t, "third", result.Results[2].Name)
+
+	first, err := repo.Post().GetPostByID(result.Results[0].ID)
+	require.Nil(t, err)
+	assert.Equal(t, "First", first.Name)
+	assert.Equal(t, model.PostDraft, first.Status)
+	assert.Nil(t, first.PublishedAt)
+	assert.Equal(t, admin.ID, first.CreatorID)
+	assert.Len(t, first.Tags, 2)
+	assert.Equal(t, "See ![img](/api/v1/posts/1/attachments/1) and [other](https://example.com/x.png).\n", first.Content)
+
+	third, err := repo.Post().GetPostByID(result.Results[2].ID)
+	require.Nil(t, err)
+	assert.Equal(t, model.PostPublished, third.Status)
+	assert.WithinDuration(t, time.Now(), *third.PublishedAt, time.Minute)
+
+	_, err = s.Import(admin, strings.NewReader("not a zip"), model.PostReader{UserID: admin.ID, Editor: true})
+	assert.True(t, apierrors.IsBadRequest(err))
+}
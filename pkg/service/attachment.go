@@ -0,0 +1,355 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/utils/set"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAttachmentDir     = "attachments"
+	DefaultAttachmentMaxSize = 10 << 20
+	// uploadDir is the directory of uploads in progress in the attachment directory
+	uploadDir = "uploads"
+	// orphanAge is the age of files without attachments which are removed, younger files may be uploads in progress
+	orphanAge = time.Hour
+)
+
+// defaultAttachmentTypes are the media types which may be uploaded without configured types,
+// types browsers may run scripts of, like html and svg, are not allowed.
+var defaultAttachmentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"}
+
+// attachmentRefRe matches the download urls of attachments in the content of posts,
+// with the scheme and host of absolute urls and the version of the api.
+var attachmentRefRe = regexp.MustCompile(`(?:https?://[^/\s()<>"]+)?/api/v[12]/posts/(\d+)/attachments/(\d+)`)
+
+// AttachmentOptions configures the storage of attachments.
+type AttachmentOptions struct {
+	// Dir is the directory of the files, default attachments
+	Dir string
+	// MaxSize is the max size of a file in bytes, default 10 MiB
+	MaxSize int64
+	// AllowedTypes are the media types sniffed from the content which may be uploaded, default images, pdf and plain text
+	AllowedTypes []string
+}
+
+type attachmentService struct {
+	attachmentRepository repository.AttachmentRepository
+	postRepository       repository.PostRepository
+	opts                 AttachmentOptions
+	allowedTypes         set.String
+}
+
+func NewAttachmentService(repo repository.Repository, opts AttachmentOptions) AttachmentService {
+	if opts.Dir == "" {
+		opts.Dir = defaultAttachmentDir
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultAttachmentMaxSize
+	}
+	if len(opts.AllowedTypes) == 0 {
+		opts.AllowedTypes = defaultAttachmentTypes
+	}
+	return &attachmentService{
+		attachmentRepository: repo.Attachment(),
+		postRepository:       repo.Post(),
+		opts:                 opts,
+		allowedTypes:         set.NewString(opts.AllowedTypes...),
+	}
+}
+
+// List lists the attachments of the post visible to the reader.
+func (a *attachmentService) List(id string, reader model.PostReader) ([]model.Attachment, error) {
+	post, err := visiblePost(a.postRepository, id, reader)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := a.attachmentRepository.List(post.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range attachments {
+		setAttachmentURL(&attachments[i])
+	}
+	return attachments, nil
+}
+
+// Get returns the attachment of the post visible to the reader.
+func (a *attachmentService) Get(id, aid string, reader model.PostReader) (*model.Attachment, error) {
+	post, err := visiblePost(a.postRepository, id, reader)
+	if err != nil {
+		return nil, err
+	}
+	attachmentID, err := parseID(aid)
+	if err != nil {
+		return nil, err
+	}
+	attachment, err := a.attachmentRepository.Get(post.ID, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	setAttachmentURL(attachment)
+	return attachment, nil
+}
+
+// Open opens the content of the attachment.
+func (a *attachmentService) Open(attachment *model.Attachment) (*os.File, error) {
+	return os.Open(a.path(attachment.Key))
+}
+
+// Create uploads the content to the post, only the creator and editors may upload attachments.
+// The type of the content is sniffed and must be allowed, the content may not be larger than the max size.
+func (a *attachmentService) Create(user *model.User, id, name string, content io.Reader, reader model.PostReader) (*model.Attachment, error) {
+	post, err := a.editablePost(id, reader)
+	if err != nil {
+		return nil, err
+	}
+	name, err = attachmentName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(a.opts.Dir, uploadDir), 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(a.opts.Dir, uploadDir), "upload-")
+	if err != nil {
+		return nil, err
+	}
+	// the temporary file is renamed to the file of the attachment when the upload is complete
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.Is(err, io.EOF) {
+			return nil, apierrors.NewInvalid(field.ErrorList{field.Required(field.NewPath("file"), "must not be empty")})
+		}
+		return nil, apierrors.NewBadRequest(err)
+	}
+	contentType := http.DetectContentType(head[:n])
+	if mediaType, _, _ := mime.ParseMediaType(contentType); !a.allowedTypes.Has(mediaType) {
+		return nil, apierrors.NewUnsupportedMediaType(fmt.Errorf("files of type %s can not be uploaded, allowed types are %s",
+			mediaType, strings.Join(a.opts.AllowedTypes, ", ")))
+	}
+
+	hash := sha256.New()
+	w := io.MultiWriter(tmp, hash)
+	if _, err := w.Write(head[:n]); err != nil {
+		return nil, err
+	}
+	// one more byte than allowed is read to detect a larger content
+	copied, err := io.Copy(w, io.LimitReader(content, a.opts.MaxSize-int64(n)+1))
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err)
+	}
+	size := int64(n) + copied
+	if size > a.opts.MaxSize {
+		return nil, apierrors.NewRequestEntityTooLarge(fmt.Errorf("files can be at most %d bytes", a.opts.MaxSize))
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	key, err := attachmentKey()
+	if err != nil {
+		return nil, err
+	}
+	file := a.path(key)
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return nil, err
+	}
+
+	attachment := &model.Attachment{
+		PostID:      post.ID,
+		UserID:      user.ID,
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		Hash:        hex.EncodeToString(hash.Sum(nil)),
+		Key:         key,
+	}
+	if err := a.attachmentRepository.Create(attachment); err != nil {
+		os.Remove(file)
+		return nil, err
+	}
+	setAttachmentURL(attachment)
+	return attachment, nil
+}
+
+// Delete deletes the attachment and its content, only the creator and editors of the post may delete attachments.
+func (a *attachmentService) Delete(id, aid string, reader model.PostReader) error {
+	post, err := a.editablePost(id, reader)
+	if err != nil {
+		return err
+	}
+	attachmentID, err := parseID(aid)
+	if err != nil {
+		return err
+	}
+	attachment, err := a.attachmentRepository.Get(post.ID, attachmentID)
+	if err != nil {
+		return err
+	}
+	if err := a.attachmentRepository.Delete(post.ID, attachmentID); err != nil {
+		return err
+	}
+	// a file which is not removed is an orphan, it is removed by the cleanup
+	if err := os.Remove(a.path(attachment.Key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logrus.Warnf("failed to remove the file of attachment %d: %v", attachmentID, err)
+	}
+	return nil
+}
+
+// CleanupOrphans removes the files of the attachment directory which have no attachment, like the files of
+// the attachments of purged posts and abandoned uploads. It returns the number of removed files.
+func (a *attachmentService) CleanupOrphans() (int, error) {
+	keys, err := a.attachmentRepository.Keys()
+	if err != nil {
+		return 0, err
+	}
+	known := set.NewString(keys...)
+
+	removed := 0
+	before := time.Now().Add(-orphanAge)
+	err = filepath.WalkDir(a.opts.Dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || known.Has(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(before) {
+			return nil
+		}
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if removed > 0 {
+		logrus.Infof("removed %d orphaned attachment files", removed)
+	}
+	return removed, err
+}
+
+// ExportContent returns the content of the post with the urls of its attachments replaced by the paths
+// of the attachments in the directory dir of an export, and the attachments of the post by their paths.
+// Urls of attachments of other posts are kept, attachments are never exported as the Markdown file of the post.
+func (a *attachmentService) ExportContent(post *model.Post, dir string) (string, map[string]*model.Attachment, error) {
+	attachments, err := a.attachmentRepository.List(post.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	paths := make(map[uint]string, len(attachments))
+	exported := make(map[string]*model.Attachment, len(attachments))
+	used := set.NewString(path.Join(dir, exportFile))
+	for i := range attachments {
+		name := attachments[i].Name
+		p := path.Join(dir, name)
+		for used.Has(p) {
+			// attachments with a used name are prefixed by their ids
+			name = fmt.Sprintf("%d-%s", attachments[i].ID, name)
+			p = path.Join(dir, name)
+		}
+		used.Insert(p)
+		paths[attachments[i].ID] = p
+		exported[p] = &attachments[i]
+	}
+	return rewriteAttachments(post.Content, post.ID, paths), exported, nil
+}
+
+// rewriteAttachments replaces the urls of the attachments of the post in the content by their paths.
+func rewriteAttachments(content string, pid uint, paths map[uint]string) string {
+	return attachmentRefRe.ReplaceAllStringFunc(content, func(url string) string {
+		m := attachmentRefRe.FindStringSubmatch(url)
+		if m[1] != strconv.Itoa(int(pid)) {
+			return url
+		}
+		id, err := strconv.ParseUint(m[2], 10, 64)
+		if err != nil {
+			return url
+		}
+		if p, ok := paths[uint(id)]; ok {
+			return p
+		}
+		return url
+	})
+}
+
+// editablePost returns the post visible to the reader if the reader is its creator or an editor.
+func (a *attachmentService) editablePost(id string, reader model.PostReader) (*model.Post, error) {
+	post, err := visiblePost(a.postRepository, id, reader)
+	if err != nil {
+		return nil, err
+	}
+	if !reader.Editor && post.CreatorID != reader.UserID {
+		return nil, apierrors.NewForbidden(fmt.Errorf("only the creator and editors may change the attachments of post %d", post.ID))
+	}
+	return post, nil
+}
+
+// path returns the file of the key, files are spread over directories by the first two characters of their keys.
+func (a *attachmentService) path(key string) string {
+	return filepath.Join(a.opts.Dir, key[:2], key)
+}
+
+// attachmentName returns the base name of the file name of an upload.
+func attachmentName(name string) (string, error) {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, `\`, "/")))
+	p := field.NewPath("name")
+	switch {
+	case name == "" || name == "." || name == "/":
+		return "", apierrors.NewInvalid(field.ErrorList{field.Required(p, "the file must have a name")})
+	case name == "..":
+		return "", apierrors.NewInvalid(field.ErrorList{field.Invalid(p, name, "must not be a parent directory")})
+	case utf8.RuneCountInString(name) > 256:
+		return "", apierrors.NewInvalid(field.ErrorList{field.Invalid(p, name, "must be at most 256 characters")})
+	}
+	return name, nil
+}
+
+// attachmentKey returns a random key of the content of an attachment.
+func attachmentKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func setAttachmentURL(attachment *model.Attachment) {
+	attachment.URL = model.AttachmentURL(attachment.PostID, attachment.ID)
+}
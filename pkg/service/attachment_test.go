@@ -0,0 +1,130 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// pngHeader is the signature of png files, the content is sniffed as image/png.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+func TestAttachments(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	repo := repository.NewRepository(db)
+	require.Nil(t, repo.Migrate())
+
+	alice, err := repo.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	post, err := repo.Post().Create(alice, &model.Post{Name: "first", Content: "one"})
+	require.Nil(t, err)
+
+	dir := t.TempDir()
+	s := NewAttachmentService(repo, AttachmentOptions{Dir: dir, MaxSize: 64})
+	creator := model.PostReader{UserID: alice.ID}
+	id := "1"
+
+	image, err := s.Create(alice, id, `C:\images\cat.png`, bytes.NewReader(append(pngHeader, "data"...)), creator)
+	require.Nil(t, err)
+	assert.Equal(t, "cat.png", image.Name)
+	assert.Equal(t, "image/png", image.ContentType)
+	assert.Equal(t, int64(12), image.Size)
+	assert.Equal(t, "/api/v1/posts/1/attachments/1", image.URL)
+
+	_, err = s.Create(alice, id, "page.png", strings.NewReader("<html><script>alert(1)</script>"), creator)
+	assert.Equal(t, apierrors.ReasonUnsupportedMediaType, apierrors.ReasonForError(err))
+	_, err = s.Create(alice, id, "large.txt", strings.NewReader(strings.Repeat("a", 65)), creator)
+	assert.Equal(t, apierrors.ReasonTooLarge, apierrors.ReasonForError(err))
+	_, err = s.Create(alice, id, "empty.txt", strings.NewReader(""), creator)
+	assert.True(t, apierrors.IsInvalid(err))
+	_, err = s.Create(alice, id, "cat.png", bytes.NewReader(pngHeader), model.PostReader{UserID: alice.ID + 1})
+	assert.Equal(t, apierrors.ReasonForbidden, apierrors.ReasonForError(err))
+
+	text, err := s.Create(alice, id, "notes.txt", strings.NewReader(strings.Repeat("a", 64)), creator)
+	require.Nil(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", text.ContentType)
+
+	attachments, err := s.List(id, creator)
+	require.Nil(t, err)
+	require.Len(t, attachments, 2)
+	file, err := s.Open(&attachments[0])
+	require.Nil(t, err)
+	defer file.Close()
+	info, err := file.Stat()
+	require.Nil(t, err)
+	assert.Equal(t, image.Size, info.Size())
+
+	// urls of attachments of the post are rewritten, others are kept
+	post.Content = "![cat](/api/v1/posts/1/attachments/1) [notes](https://example.com/api/v2/posts/1/attachments/2) " +
+		"![other](/api/v1/posts/2/attachments/1)"
+	content, exported, err := s.ExportContent(post, "attachments")
+	require.Nil(t, err)
+	assert.Equal(t, "![cat](attachments/cat.png) [notes](attachments/notes.txt) ![other](/api/v1/posts/2/attachments/1)", content)
+	assert.Len(t, exported, 2)
+	assert.Equal(t, image.ID, exported["attachments/cat.png"].ID)
+
+	// names of files of the parent directory are invalid
+	for _, name := range []string{"..", `..\`, "a/.."} {
+		_, err = s.Create(alice, id, name, strings.NewReader("a"), creator)
+		assert.True(t, apierrors.IsInvalid(err), name)
+	}
+
+	// attachments are not exported as the Markdown file of the post or with the names of other attachments
+	_, err = s.Create(alice, id, exportFile, strings.NewReader("index"), creator)
+	require.Nil(t, err)
+	for _, name := range []string{"5-cat.png", "cat.png"} {
+		_, err = s.Create(alice, id, name, bytes.NewReader(pngHeader), creator)
+		require.Nil(t, err)
+	}
+	post.Content = "[index](/api/v1/posts/1/attachments/3)"
+	content, exported, err = s.ExportContent(post, "")
+	require.Nil(t, err)
+	assert.Equal(t, "[index](3-index.md)", content)
+	paths := make([]string, 0, len(exported))
+	for p, attachment := range exported {
+		paths = append(paths, fmt.Sprintf("%s %d", p, attachment.ID))
+	}
+	assert.ElementsMatch(t, []string{"cat.png 1", "notes.txt 2", "3-index.md 3", "5-cat.png 4", "5-5-cat.png 5"}, paths)
+	for _, aid := range []string{"3", "4", "5"} {
+		require.Nil(t, s.Delete(id, aid, creator))
+	}
+
+	require.Nil(t, s.Delete(id, "2", creator))
+	_, err = s.Get(id, "2", creator)
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// the files of purged posts are orphans, they are removed when they are old enough
+	require.Nil(t, repo.Post().Delete(post.ID, 0))
+	require.Nil(t, repo.Trash().Purge(model.PostResource, post.ID))
+	removed, err := s.CleanupOrphans()
+	require.Nil(t, err)
+	assert.Equal(t, 0, removed)
+
+	old := time.Now().Add(-2 * orphanAge)
+	files := 0
+	require.Nil(t, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files++
+			return os.Chtimes(path, old, old)
+		}
+		return err
+	}))
+	assert.Equal(t, 1, files)
+	removed, err = s.CleanupOrphans()
+	require.Nil(t, err)
+	assert.Equal(t, 1, removed)
+}
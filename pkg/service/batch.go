@@ -0,0 +1,424 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/authorization"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/request"
+	"github.com/eastygh/webm-nas/pkg/validation"
+	"github.com/eastygh/webm-nas/pkg/watch"
+)
+
+// batchHandler runs an operation of a batch with the services of its transaction,
+// it returns the object which is the data of the result.
+type batchHandler func(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error)
+
+// batchHandlers are the supported operations of batches, keyed by the verb, resource and subresource
+// like "create posts" or "delete groups/users".
+var batchHandlers = map[string]batchHandler{
+	batchKey(request.CreateOperation, model.UserResource, ""):       createUser,
+	batchKey(request.DeleteOperation, model.UserResource, ""):       deleteUser,
+	batchKey(request.CreateOperation, model.GroupResource, ""):      createGroup,
+	batchKey(request.DeleteOperation, model.GroupResource, ""):      deleteGroup,
+	batchKey(request.CreateOperation, model.PostResource, ""):       createPost,
+	batchKey(request.DeleteOperation, model.PostResource, ""):       deletePost,
+	batchKey(request.CreateOperation, model.RoleResource, ""):       createRole,
+	batchKey(request.DeleteOperation, model.RoleResource, ""):       deleteRole,
+	batchKey(request.CreateOperation, model.GroupResource, "users"): addGroupUser,
+	batchKey(request.UpdateOperation, model.GroupResource, "users"): addGroupUser,
+	batchKey(request.DeleteOperation, model.GroupResource, "users"): delGroupUser,
+	batchKey(request.CreateOperation, model.GroupResource, "roles"): addGroupRole,
+	batchKey(request.UpdateOperation, model.GroupResource, "roles"): addGroupRole,
+	batchKey(request.DeleteOperation, model.GroupResource, "roles"): delGroupRole,
+	batchKey(request.CreateOperation, model.UserResource, "roles"):  addUserRole,
+	batchKey(request.UpdateOperation, model.UserResource, "roles"):  addUserRole,
+	batchKey(request.DeleteOperation, model.UserResource, "roles"):  delUserRole,
+}
+
+type batchService struct {
+	repository repository.Repository
+	events     watch.Publisher
+	resolver   request.RequestInfoResolver
+}
+
+func NewBatchService(repository repository.Repository, events watch.Publisher, resolver request.RequestInfoResolver) BatchService {
+	return &batchService{
+		repository: repository,
+		events:     events,
+		resolver:   resolver,
+	}
+}
+
+// batchServices are the services of a transaction, the events are published after the transaction is committed.
+type batchServices struct {
+	user  *userService
+	group *groupService
+	post  *postService
+	rbac  *rbacService
+}
+
+func newBatchServices(repo repository.Repository, events watch.Publisher) *batchServices {
+	return &batchServices{
+		user:  newUserService(repo.User(), events),
+		group: newGroupService(repo.Group(), repo.User(), repo.RBAC(), events),
+		post:  newPostService(repo.Post(), events),
+		rbac:  newRBACService(repo.RBAC(), events),
+	}
+}
+
+// batchItem is a prepared operation, err is set if the operation can not be run.
+type batchItem struct {
+	op      *model.BatchOperation
+	ri      *request.RequestInfo
+	handler batchHandler
+	err     error
+}
+
+// Run authorizes and runs the operations of the batch, the results are in the order of the operations.
+func (b *batchService) Run(user *model.User, batch *model.Batch) *model.BatchResult {
+	if user == nil {
+		user = &model.User{}
+	}
+
+	items := make([]batchItem, len(batch.Operations))
+	for i := range batch.Operations {
+		items[i] = b.prepare(user, &batch.Operations[i])
+	}
+
+	result := &model.BatchResult{Results: make([]model.BatchItemResult, len(items))}
+	if batch.Atomic {
+		result.Summary.RolledBack = b.runAtomic(user, items, result.Results)
+	} else {
+		b.runEach(user, items, result.Results)
+	}
+
+	result.Summary.Total = len(result.Results)
+	for i := range result.Results {
+		if result.Results[i].Succeeded() {
+			result.Summary.Succeeded++
+		} else {
+			result.Summary.Failed++
+		}
+	}
+	return result
+}
+
+// prepare resolves and authorizes the operation like the request of its path.
+func (b *batchService) prepare(user *model.User, op *model.BatchOperation) batchItem {
+	item := batchItem{op: op}
+
+	req, err := http.NewRequest(op.Method, op.Path, nil)
+	if err != nil {
+		item.err = apierrors.NewBadRequest(err)
+		return item
+	}
+	ri, err := b.resolver.NewRequestInfo(req)
+	if err != nil {
+		item.err = apierrors.NewBadRequest(err)
+		return item
+	}
+	item.ri = ri
+
+	// creates of resources have no name, all other operations are on a named object
+	handler, ok := batchHandlers[batchKey(ri.Verb, ri.Resource, ri.Subresource)]
+	if !ok || !ri.IsResourceRequest || (ri.Verb == request.CreateOperation && ri.Subresource == "") != (ri.Name == "") {
+		item.err = apierrors.NewBadRequest(fmt.Errorf("operation %s %s is not supported in batches", op.Method, op.Path))
+		return item
+	}
+
+	allowed, err := authorization.Authorize(user, ri)
+	if err != nil {
+		item.err = err
+		return item
+	}
+	if !allowed {
+		if user.Name == "" {
+			item.err = apierrors.NewUnauthorized(errors.New("unauthorized"))
+		} else {
+			item.err = apierrors.NewForbidden(fmt.Errorf("user [%s] is forbidden for resource %s in namespace %s", user.Name, ri.Resource, ri.Namespace))
+		}
+		return item
+	}
+
+	item.handler = handler
+	return item
+}
+
+// runAtomic runs all operations in a single transaction, it returns true if the transaction is rolled back.
+// If an operation fails, the other operations are reported as failed dependencies.
+func (b *batchService) runAtomic(user *model.User, items []batchItem, results []model.BatchItemResult) bool {
+	failed := -1
+	for i := range items {
+		if items[i].err != nil {
+			results[i] = failedResult(items[i].err)
+			if failed < 0 {
+				failed = i
+			}
+		}
+	}
+	if failed >= 0 {
+		setNotRun(items, results, failed, 0)
+		return false
+	}
+
+	events := &eventBuffer{}
+	err := b.repository.Transaction(func(repo repository.Repository) error {
+		services := newBatchServices(repo, events)
+		for i := range items {
+			data, err := items[i].handler(services, user, items[i].ri, items[i].op)
+			if err != nil {
+				failed = i
+				return err
+			}
+			results[i] = succeededResult(data)
+		}
+		return nil
+	})
+	if err == nil {
+		events.flush(b.events)
+		return false
+	}
+
+	if failed < 0 {
+		// all operations succeeded but the transaction failed to commit
+		for i := range results {
+			results[i] = failedResult(err)
+		}
+		return true
+	}
+
+	results[failed] = failedResult(err)
+	for i := 0; i < failed; i++ {
+		results[i] = failedResult(apierrors.NewFailedDependency(fmt.Errorf("rolled back, operation %d failed", failed)))
+	}
+	setNotRun(items, results, failed, failed+1)
+	return true
+}
+
+// runEach runs each operation in a transaction of its own.
+func (b *batchService) runEach(user *model.User, items []batchItem, results []model.BatchItemResult) {
+	for i := range items {
+		if items[i].err != nil {
+			results[i] = failedResult(items[i].err)
+			continue
+		}
+
+		var data interface{}
+		events := &eventBuffer{}
+		err := b.repository.Transaction(func(repo repository.Repository) error {
+			var err error
+			data, err = items[i].handler(newBatchServices(repo, events), user, items[i].ri, items[i].op)
+			return err
+		})
+		if err != nil {
+			results[i] = failedResult(err)
+			continue
+		}
+		events.flush(b.events)
+		results[i] = succeededResult(data)
+	}
+}
+
+// setNotRun reports the operations from start on, except the failed ones, as not run because of the failed operation.
+func setNotRun(items []batchItem, results []model.BatchItemResult, failed, start int) {
+	for i := start; i < len(items); i++ {
+		if i != failed && items[i].err == nil {
+			results[i] = failedResult(apierrors.NewFailedDependency(fmt.Errorf("not run, operation %d failed", failed)))
+		}
+	}
+}
+
+func succeededResult(data interface{}) model.BatchItemResult {
+	return model.BatchItemResult{Code: http.StatusOK, Msg: "success", Data: data}
+}
+
+// failedResult returns the result of the error like the response of a failed request.
+func failedResult(err error) model.BatchItemResult {
+	status := apierrors.FromError(err)
+	if status == nil {
+		status = apierrors.NewInternalError(err)
+	}
+	return model.BatchItemResult{Code: status.Code, Reason: status.Reason, Msg: err.Error(), Data: status.Details}
+}
+
+func batchKey(verb, resource, subresource string) string {
+	if subresource != "" {
+		resource += "/" + subresource
+	}
+	return verb + " " + resource
+}
+
+// decodeBody decodes the body of the operation into obj and validates its struct tags.
+func decodeBody(op *model.BatchOperation, obj interface{}) error {
+	if len(op.Body) == 0 {
+		return apierrors.NewBadRequest(errors.New("missing body"))
+	}
+	if err := json.Unmarshal(op.Body, obj); err != nil {
+		return apierrors.NewBadRequest(err)
+	}
+	return validation.Struct(obj)
+}
+
+func createUser(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	createdUser := new(model.CreatedUser)
+	if err := decodeBody(op, createdUser); err != nil {
+		return nil, err
+	}
+	newUser := createdUser.GetUser()
+	if err := s.user.Validate(newUser); err != nil {
+		return nil, err
+	}
+	s.user.Default(newUser)
+	return s.user.Create(newUser)
+}
+
+func deleteUser(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	return nil, s.user.Delete(ri.Name, op.Version)
+}
+
+func createGroup(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	createdGroup := new(model.CreatedGroup)
+	if err := decodeBody(op, createdGroup); err != nil {
+		return nil, err
+	}
+	return s.group.Create(user, createdGroup.GetGroup(user.ID))
+}
+
+func deleteGroup(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	return nil, s.group.Delete(ri.Name, op.Version)
+}
+
+func createPost(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	post := new(model.Post)
+	if err := decodeBody(op, post); err != nil {
+		return nil, err
+	}
+	return s.post.Create(user, post)
+}
+
+func deletePost(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	return nil, s.post.Delete(ri.Name, op.Version)
+}
+
+func createRole(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	role := new(model.Role)
+	if err := decodeBody(op, role); err != nil {
+		return nil, err
+	}
+	if err := s.rbac.Validate(role); err != nil {
+		return nil, err
+	}
+	return s.rbac.Create(role)
+}
+
+func deleteRole(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	return nil, s.rbac.Delete(ri.Name, op.Version)
+}
+
+// addGroupUser adds a user to the group like POST /groups/{id}/users with the user in the body,
+// or PUT /groups/{id}/users/{userId}.
+func addGroupUser(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	member, err := batchMember(ri, op)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.group.AddUser(member, ri.Name)
+}
+
+// delGroupUser deletes a user from the group like DELETE /groups/{id}/users/{userId},
+// or DELETE /groups/{id}/users with the user in the body.
+func delGroupUser(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	member, err := batchMember(ri, op)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.group.DelUser(member, ri.Name)
+}
+
+func addGroupRole(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	rid, err := batchSubresourceName(ri)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.group.AddRole(ri.Name, rid)
+}
+
+func delGroupRole(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	rid, err := batchSubresourceName(ri)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.group.DelRole(ri.Name, rid)
+}
+
+func addUserRole(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	rid, err := batchSubresourceName(ri)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.user.AddRole(ri.Name, rid)
+}
+
+func delUserRole(s *batchServices, user *model.User, ri *request.RequestInfo, op *model.BatchOperation) (interface{}, error) {
+	rid, err := batchSubresourceName(ri)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.user.DelRole(ri.Name, rid)
+}
+
+// batchMember returns the user of a group membership operation, the user id of the path or the user of the body.
+func batchMember(ri *request.RequestInfo, op *model.BatchOperation) (*model.User, error) {
+	if len(ri.Parts) > 3 {
+		uid, err := parseID(ri.Parts[3])
+		if err != nil {
+			return nil, err
+		}
+		return &model.User{ID: uid}, nil
+	}
+	member := new(model.User)
+	if len(op.Body) == 0 {
+		return nil, apierrors.NewBadRequest(errors.New("missing body"))
+	}
+	if err := json.Unmarshal(op.Body, member); err != nil {
+		return nil, apierrors.NewBadRequest(err)
+	}
+	return member, nil
+}
+
+// batchSubresourceName returns the name of the subresource object of the path, like the role id of /groups/1/roles/2.
+func batchSubresourceName(ri *request.RequestInfo) (string, error) {
+	if len(ri.Parts) < 4 {
+		return "", apierrors.NewBadRequest(fmt.Errorf("missing %s id", ri.Subresource))
+	}
+	return ri.Parts[3], nil
+}
+
+type bufferedEvent struct {
+	resource  string
+	eventType watch.EventType
+	name      string
+	object    interface{}
+}
+
+// eventBuffer keeps the events of a transaction, they are only published if the transaction is committed.
+type eventBuffer struct {
+	events []bufferedEvent
+}
+
+func (e *eventBuffer) Publish(resource string, eventType watch.EventType, name string, object interface{}) {
+	e.events = append(e.events, bufferedEvent{resource: resource, eventType: eventType, name: name, object: object})
+}
+
+func (e *eventBuffer) flush(publisher watch.Publisher) {
+	for _, event := range e.events {
+		publisher.Publish(event.resource, event.eventType, event.name, event.object)
+	}
+	e.events = nil
+}
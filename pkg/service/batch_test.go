@@ -0,0 +1,203 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/authorization"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/request"
+	"github.com/eastygh/webm-nas/pkg/utils/set"
+	"github.com/eastygh/webm-nas/pkg/watch"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type batchTest struct {
+	repo  repository.Repository
+	batch BatchService
+	admin *model.User
+	bob   *model.User
+	group *model.Group
+	role  *model.Role
+	users []*model.User
+}
+
+func newBatchTest(t *testing.T) *batchTest {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	repo := repository.NewRepository(db)
+	require.Nil(t, repo.Migrate())
+	require.Nil(t, repo.Init())
+	require.Nil(t, authorization.InitAuthorization(repo))
+
+	bt := &batchTest{repo: repo}
+	bt.role, err = repo.RBAC().Create(&model.Role{Name: "admin", Scope: model.ClusterScope, Rules: model.Rules{{Resource: model.All, Operation: model.All}}})
+	require.Nil(t, err)
+	bt.admin, err = repo.User().Create(&model.User{Name: "admin", Password: "123456"})
+	require.Nil(t, err)
+	require.Nil(t, repo.User().AddRole(bt.role, bt.admin))
+	bt.bob, err = repo.User().Create(&model.User{Name: "bob", Password: "123456"})
+	require.Nil(t, err)
+	for _, name := range []string{"u1", "u2", "u3"} {
+		user, err := repo.User().Create(&model.User{Name: name, Password: "123456"})
+		require.Nil(t, err)
+		bt.users = append(bt.users, user)
+	}
+	bt.group, err = repo.Group().Create(bt.admin, &model.Group{Name: "team", Kind: model.CustomGroup})
+	require.Nil(t, err)
+
+	resolver := &request.RequestInfoFactory{APIPrefixes: set.NewString("api")}
+	bt.batch = NewBatchService(repo, watch.NewBroadcaster(0), resolver)
+	return bt
+}
+
+// path formats the path with the id of the group.
+func (bt *batchTest) path(format string) string {
+	return fmt.Sprintf(format, bt.group.ID)
+}
+
+func (bt *batchTest) members(t *testing.T) []string {
+	users, err := bt.repo.Group().GetUsers(bt.group)
+	require.Nil(t, err)
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	return names
+}
+
+func batchOp(method, path string, body interface{}) model.BatchOperation {
+	op := model.BatchOperation{Method: method, Path: path}
+	if body != nil {
+		op.Body, _ = json.Marshal(body)
+	}
+	return op
+}
+
+func codes(result *model.BatchResult) []int {
+	codes := make([]int, 0, len(result.Results))
+	for _, r := range result.Results {
+		codes = append(codes, r.Code)
+	}
+	return codes
+}
+
+func TestBatchGroupMembers(t *testing.T) {
+	bt := newBatchTest(t)
+
+	result := bt.batch.Run(bt.admin, &model.Batch{Atomic: true, Operations: []model.BatchOperation{
+		batchOp("POST", bt.path("/api/v1/groups/%d/users"), map[string]uint{"id": bt.users[0].ID}),
+		batchOp("PUT", bt.path("/api/v2/groups/%d/users/4"), nil),
+		batchOp("PUT", bt.path("/api/v2/groups/%d/users/5"), nil),
+		batchOp("POST", bt.path("/api/v1/groups/%d/roles/1"), nil),
+		batchOp("POST", "/api/v1/users/2/roles/1", nil),
+	}})
+	assert.Equal(t, []int{200, 200, 200, 200, 200}, codes(result))
+	assert.ElementsMatch(t, []string{"admin", "u1", "u2", "u3"}, bt.members(t))
+	group, err := bt.repo.Group().GetGroupByID(bt.group.ID)
+	require.Nil(t, err)
+	require.Len(t, group.Roles, 1)
+	assert.Equal(t, "admin", group.Roles[0].Name)
+
+	result = bt.batch.Run(bt.admin, &model.Batch{Operations: []model.BatchOperation{
+		batchOp("DELETE", bt.path("/api/v2/groups/%d/users/3"), nil),
+		batchOp("DELETE", bt.path("/api/v1/groups/%d/users"), map[string]uint{"id": bt.users[1].ID}),
+		batchOp("DELETE", bt.path("/api/v1/groups/%d/roles/1"), nil),
+		batchOp("DELETE", "/api/v1/users/2/roles/1", nil),
+		// subresources without a handler and operations of the wrong form are rejected
+		batchOp("POST", "/api/v1/posts/1/comment", map[string]string{"content": "hi"}),
+		batchOp("DELETE", bt.path("/api/v1/groups/%d/roles"), nil),
+		batchOp("PUT", "/api/v1/posts/1", nil),
+	}})
+	assert.Equal(t, []int{200, 200, 200, 200, 400, 400, 400}, codes(result))
+	assert.ElementsMatch(t, []string{"admin", "u3"}, bt.members(t))
+	group, err = bt.repo.Group().GetGroupByID(bt.group.ID)
+	require.Nil(t, err)
+	assert.Empty(t, group.Roles)
+}
+
+func TestBatchAtomicRollback(t *testing.T) {
+	bt := newBatchTest(t)
+
+	result := bt.batch.Run(bt.admin, &model.Batch{Atomic: true, Operations: []model.BatchOperation{
+		batchOp("POST", bt.path("/api/v1/groups/%d/users"), map[string]uint{"id": bt.users[0].ID}),
+		batchOp("POST", "/api/v1/posts", map[string]string{"name": "first", "content": "hello"}),
+		{Method: "DELETE", Path: "/api/v1/posts/100", Version: 1},
+		batchOp("POST", "/api/v1/posts", map[string]string{"name": "second", "content": "hello"}),
+	}})
+	assert.True(t, result.Summary.RolledBack)
+	assert.Equal(t, []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusNotFound, http.StatusFailedDependency}, codes(result))
+	assert.Equal(t, model.BatchSummary{Total: 4, Failed: 4, RolledBack: true}, result.Summary)
+
+	// nothing of the batch is kept
+	assert.Equal(t, []string{"admin"}, bt.members(t))
+	_, err := bt.repo.Post().GetPostByName("first")
+	assert.NotNil(t, err)
+}
+
+func TestBatchPartialFailure(t *testing.T) {
+	bt := newBatchTest(t)
+
+	result := bt.batch.Run(bt.admin, &model.Batch{Operations: []model.BatchOperation{
+		batchOp("POST", bt.path("/api/v1/groups/%d/users"), map[string]uint{"id": bt.users[0].ID}),
+		batchOp("POST", "/api/v1/posts", map[string]string{"name": "first", "content": "hello"}),
+		{Method: "DELETE", Path: "/api/v1/posts/100", Version: 1},
+		batchOp("POST", "/api/v1/posts", map[string]string{"name": "first", "content": "again"}),
+		batchOp("PUT", bt.path("/api/v2/groups/%d/users/5"), nil),
+	}})
+	assert.False(t, result.Summary.RolledBack)
+	assert.Equal(t, []int{200, 200, http.StatusNotFound, http.StatusBadRequest, 200}, codes(result))
+	assert.Equal(t, model.BatchSummary{Total: 5, Succeeded: 3, Failed: 2}, result.Summary)
+
+	assert.ElementsMatch(t, []string{"admin", "u1", "u3"}, bt.members(t))
+	post, err := bt.repo.Post().GetPostByName("first")
+	require.Nil(t, err)
+	assert.Equal(t, "hello", post.Content)
+}
+
+func TestBatchAuthorization(t *testing.T) {
+	bt := newBatchTest(t)
+
+	// bob may create posts but may not change groups or roles
+	require.Nil(t, bt.repo.User().AddRole(mustCreateRole(t, bt.repo, "poster", model.Rules{{Resource: model.PostResource, Operation: model.EditOperation}}), bt.bob))
+
+	result := bt.batch.Run(bt.bob, &model.Batch{Operations: []model.BatchOperation{
+		batchOp("POST", "/api/v1/posts", map[string]string{"name": "first", "content": "hello"}),
+		batchOp("POST", bt.path("/api/v1/groups/%d/users"), map[string]uint{"id": bt.bob.ID}),
+		batchOp("POST", "/api/v1/users/2/roles/1", nil),
+	}})
+	assert.Equal(t, []int{200, http.StatusForbidden, http.StatusForbidden}, codes(result))
+	assert.Equal(t, []string{"admin"}, bt.members(t))
+	bob, err := bt.repo.User().GetUserByID(bt.bob.ID)
+	require.Nil(t, err)
+	assert.Len(t, bob.Roles, 1)
+
+	// a denied operation of an atomic batch fails the batch before anything is run
+	result = bt.batch.Run(bt.bob, &model.Batch{Atomic: true, Operations: []model.BatchOperation{
+		batchOp("POST", "/api/v1/posts", map[string]string{"name": "second", "content": "hello"}),
+		batchOp("DELETE", bt.path("/api/v1/groups/%d/users"), map[string]uint{"id": bt.users[0].ID}),
+	}})
+	assert.False(t, result.Summary.RolledBack)
+	assert.Equal(t, []int{http.StatusFailedDependency, http.StatusForbidden}, codes(result))
+	_, err = bt.repo.Post().GetPostByName("second")
+	assert.NotNil(t, err)
+
+	// anonymous users are unauthorized
+	result = bt.batch.Run(nil, &model.Batch{Operations: []model.BatchOperation{
+		batchOp("POST", "/api/v1/posts", map[string]string{"name": "third", "content": "hello"}),
+	}})
+	assert.Equal(t, []int{http.StatusUnauthorized}, codes(result))
+}
+
+func mustCreateRole(t *testing.T, repo repository.Repository, name string, rules model.Rules) *model.Role {
+	role, err := repo.RBAC().Create(&model.Role{Name: name, Scope: model.ClusterScope, Rules: rules})
+	require.Nil(t, err)
+	return role
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/validation"
+)
+
+type categoryService struct {
+	categoryRepository repository.CategoryRepository
+	postRepository     repository.PostRepository
+}
+
+func NewCategoryService(categoryRepository repository.CategoryRepository, postRepository repository.PostRepository) CategoryService {
+	return &categoryService{
+		categoryRepository: categoryRepository,
+		postRepository:     postRepository,
+	}
+}
+
+func (c *categoryService) List(opts *model.ListOptions) ([]model.Category, *model.ListMeta, error) {
+	return c.categoryRepository.List(opts)
+}
+
+// Get returns the category with its children.
+func (c *categoryService) Get(id string) (*model.Category, error) {
+	cid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	return c.categoryRepository.GetCategoryByID(cid)
+}
+
+// Create creates a category, the parent must exist.
+func (c *categoryService) Create(category *model.Category) (*model.Category, error) {
+	category.ID, category.Children = 0, nil
+	if err := c.validate(category); err != nil {
+		return nil, err
+	}
+	return c.categoryRepository.Create(category)
+}
+
+// Update renames the category or moves it to another parent, a category can not be moved into its subcategories.
+func (c *categoryService) Update(id string, category *model.Category) (*model.Category, error) {
+	cid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.categoryRepository.GetCategoryByID(cid); err != nil {
+		return nil, err
+	}
+
+	category.ID, category.Children = cid, nil
+	if err := c.validate(category); err != nil {
+		return nil, err
+	}
+	if _, err := c.categoryRepository.Update(category); err != nil {
+		return nil, err
+	}
+	return c.categoryRepository.GetCategoryByID(cid)
+}
+
+// validate checks the fields of the category and that its parent exists and is not the category or one of its subcategories.
+func (c *categoryService) validate(category *model.Category) error {
+	if err := validation.Struct(category); err != nil {
+		return err
+	}
+	if category.ParentID == nil {
+		return nil
+	}
+
+	path := field.NewPath("parentId")
+	if _, err := c.categoryRepository.GetCategoryByID(*category.ParentID); apierrors.IsNotFound(err) {
+		return apierrors.NewInvalid(field.ErrorList{field.Invalid(path, *category.ParentID, "must be an existing category")})
+	} else if err != nil {
+		return err
+	}
+	if category.ID == 0 {
+		return nil
+	}
+
+	descendants, err := c.categoryRepository.Descendants(category.ID)
+	if err != nil {
+		return err
+	}
+	for _, id := range descendants {
+		if id == *category.ParentID {
+			return apierrors.NewInvalid(field.ErrorList{field.Invalid(path, *category.ParentID, "can not be the category or one of its subcategories")})
+		}
+	}
+	return nil
+}
+
+// Delete deletes the category, its children become children of its parent.
+func (c *categoryService) Delete(id string) error {
+	cid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	return c.categoryRepository.Delete(cid)
+}
+
+// ListPosts lists the posts in the category or its subcategories visible to the reader.
+func (c *categoryService) ListPosts(id string, opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error) {
+	cid, err := parseID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := c.categoryRepository.GetCategoryByID(cid); err != nil {
+		return nil, nil, err
+	}
+
+	ids, err := c.categoryRepository.Descendants(cid)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.postRepository.ListByCategories(ids, opts, reader)
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/validation"
+)
+
+// AddComment adds a comment or a reply to a comment of the post. Comments of new users are held for moderation,
+// replies to deleted comments and replies deeper than the max depth are invalid.
+func (p *postService) AddComment(user *model.User, id string, comment *model.Comment, reader model.PostReader) (*model.Comment, error) {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	comment.ID, comment.PostID, comment.UserID, comment.User = 0, post.ID, user.ID, *user
+	comment.Parent, comment.Replies, comment.Depth, comment.EditedAt, comment.DeletedAt = nil, nil, 0, nil, nil
+	if err := validation.Struct(comment); err != nil {
+		return nil, err
+	}
+	if comment.ParentID != nil {
+		if err := p.setParent(comment, reader); err != nil {
+			return nil, err
+		}
+	}
+
+	comment.Status = model.CommentApproved
+	if p.held(user, reader) {
+		comment.Status = model.CommentPending
+	}
+	return withComment(p.postRepository.AddComment(comment))
+}
+
+// setParent checks the parent of the reply and sets its depth.
+func (p *postService) setParent(comment *model.Comment, reader model.PostReader) error {
+	path := field.NewPath("parentId")
+	parent, err := p.postRepository.GetComment(comment.PostID, *comment.ParentID)
+	if apierrors.IsNotFound(err) || (err == nil && !parent.VisibleTo(reader)) {
+		return apierrors.NewInvalid(field.ErrorList{field.Invalid(path, *comment.ParentID, "must be a comment of the post")})
+	}
+	if err != nil {
+		return err
+	}
+	if parent.DeletedAt != nil {
+		return apierrors.NewInvalid(field.ErrorList{field.Invalid(path, *comment.ParentID, "can not reply to a deleted comment")})
+	}
+
+	comment.Depth = parent.Depth + 1
+	if max := p.opts.MaxCommentDepth; max > 0 && comment.Depth > uint(max) {
+		return apierrors.NewInvalid(field.ErrorList{field.Invalid(path, *comment.ParentID, fmt.Sprintf("replies can be nested at most %d levels deep", max))})
+	}
+	return nil
+}
+
+// held reports whether the comments of the user are held for moderation, editors are never held.
+func (p *postService) held(user *model.User, reader model.PostReader) bool {
+	return !reader.Editor && p.opts.HoldNewUsers > 0 && time.Since(user.CreatedAt) < p.opts.HoldNewUsers
+}
+
+// ListComments lists the comments on the post with their replies as trees, pages only count the comments on the post.
+func (p *postService) ListComments(id string, opts *model.ListOptions, reader model.PostReader) ([]model.Comment, *model.ListMeta, error) {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	comments, meta, err := p.postRepository.ListComments(post.ID, opts, reader)
+	renderComments(comments)
+	return comments, meta, err
+}
+
+// UpdateComment edits the content of the comment, edited comments have the time of the last edit.
+// Only the author and editors may edit a comment, deleted comments can not be edited.
+func (p *postService) UpdateComment(user *model.User, id, cid string, update *model.CommentUpdate, reader model.PostReader) (*model.Comment, error) {
+	comment, err := p.visibleComment(id, cid, reader)
+	if err != nil {
+		return nil, err
+	}
+	if !reader.Editor && comment.UserID != user.ID {
+		return nil, apierrors.NewForbidden(fmt.Errorf("only the author and editors may edit comment %d", comment.ID))
+	}
+	if err := validation.Struct(update); err != nil {
+		return nil, err
+	}
+	if update.Content == comment.Content {
+		return withComment(comment, nil)
+	}
+
+	now := time.Now()
+	comment.Content, comment.EditedAt, comment.UpdatedAt = update.Content, &now, now
+	return withComment(p.postRepository.UpdateComment(comment))
+}
+
+// ApproveComment makes the comment visible to everyone, only editors may moderate comments.
+func (p *postService) ApproveComment(id, cid string, reader model.PostReader) (*model.Comment, error) {
+	return p.moderateComment(id, cid, reader, model.CommentApproved)
+}
+
+// RejectComment hides the comment from everyone but its author and editors, only editors may moderate comments.
+func (p *postService) RejectComment(id, cid string, reader model.PostReader) (*model.Comment, error) {
+	return p.moderateComment(id, cid, reader, model.CommentRejected)
+}
+
+func (p *postService) moderateComment(id, cid string, reader model.PostReader, status model.CommentStatus) (*model.Comment, error) {
+	if !reader.Editor {
+		return nil, apierrors.NewForbidden(fmt.Errorf("only editors may moderate comments"))
+	}
+	comment, err := p.visibleComment(id, cid, reader)
+	if err != nil {
+		return nil, err
+	}
+	if comment.Status == status {
+		return withComment(comment, nil)
+	}
+
+	comment.Status, comment.UpdatedAt = status, time.Now()
+	return withComment(p.postRepository.UpdateCommentStatus(comment))
+}
+
+// ListCommentsByStatus lists the comments of all posts with the status, an empty status lists the pending comments.
+// Only editors may list the comments to moderate.
+func (p *postService) ListCommentsByStatus(status string, opts *model.ListOptions, reader model.PostReader) ([]model.Comment, *model.ListMeta, error) {
+	if !reader.Editor {
+		return nil, nil, apierrors.NewForbidden(fmt.Errorf("only editors may moderate comments"))
+	}
+
+	s := model.CommentStatus(status)
+	switch s {
+	case "":
+		s = model.CommentPending
+	case model.CommentPending, model.CommentApproved, model.CommentRejected:
+	default:
+		return nil, nil, apierrors.NewBadRequest(fmt.Errorf("invalid status %q, expect pending, approved or rejected", status))
+	}
+	comments, meta, err := p.postRepository.ListCommentsByStatus(s, opts)
+	renderComments(comments)
+	return comments, meta, err
+}
+
+func (p *postService) DelComment(id, cid string) error {
+	pid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	commentId, err := parseID(cid)
+	if err != nil {
+		return err
+	}
+
+	return p.postRepository.DelComment(pid, commentId)
+}
+
+// visibleComment returns the comment which is not deleted, a comment which is not visible to the reader is not found.
+func (p *postService) visibleComment(id, cid string, reader model.PostReader) (*model.Comment, error) {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return nil, err
+	}
+	commentID, err := parseID(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := p.postRepository.GetComment(post.ID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.DeletedAt != nil || !comment.VisibleTo(reader) {
+		return nil, apierrors.NewNotFound("comments", commentID)
+	}
+	return comment, nil
+}
@@ -0,0 +1,17 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+)
+
+// parseID parses the id of an object in the request path, an invalid id is a BadRequest.
+func parseID(id string) (uint, error) {
+	n, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return 0, apierrors.NewBadRequest(fmt.Errorf("invalid id %q", id))
+	}
+	return uint(n), nil
+}
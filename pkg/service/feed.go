@@ -0,0 +1,199 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/feed"
+)
+
+const (
+	defaultFeedTitle = "Posts"
+	defaultFeedLimit = 20
+)
+
+// FeedOptions configures the feeds of posts.
+type FeedOptions struct {
+	// Title of the feeds, default Posts
+	Title string
+	// Limit is the number of the latest posts in a feed, default 20
+	Limit int
+}
+
+type feedService struct {
+	postRepository      repository.PostRepository
+	tagRepository       repository.TagRepository
+	categoryRepository  repository.CategoryRepository
+	userRepository      repository.UserRepository
+	feedTokenRepository repository.FeedTokenRepository
+	opts                FeedOptions
+}
+
+func NewFeedService(repo repository.Repository, opts FeedOptions) FeedService {
+	if opts.Title == "" {
+		opts.Title = defaultFeedTitle
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultFeedLimit
+	}
+	return &feedService{
+		postRepository:      repo.Post(),
+		tagRepository:       repo.Tag(),
+		categoryRepository:  repo.Category(),
+		userRepository:      repo.User(),
+		feedTokenRepository: repo.FeedToken(),
+		opts:                opts,
+	}
+}
+
+// Posts returns the feed of the latest published posts, or of the posts of a tag or category if resource is tags or categories.
+// The posts of a category include the posts of its subcategories.
+// Links of posts are the urls of the posts in api v2 on base, self is the url of the feed.
+func (f *feedService) Posts(resource, id, base, self string) (*feed.Feed, error) {
+	name, path, posts, err := f.list(resource, id)
+	if err != nil {
+		return nil, err
+	}
+
+	title := f.opts.Title
+	if name != "" {
+		title = fmt.Sprintf("%s: %s", title, name)
+	}
+	result := &feed.Feed{
+		Title:       title,
+		Description: title,
+		Link:        base + path,
+		FeedURL:     self,
+		// an empty feed has a fixed time, so it can be cached
+		Updated: time.Unix(0, 0),
+		Items:   make([]feed.Item, len(posts)),
+	}
+	for i, post := range posts {
+		result.Items[i] = feedItem(&post, base)
+		if result.Items[i].Updated.After(result.Updated) {
+			result.Updated = result.Items[i].Updated
+		}
+	}
+	return result, nil
+}
+
+// list lists the latest published posts of the tag or category, it returns the name of the tag or category
+// and the path of the posts in api v2.
+func (f *feedService) list(resource, id string) (string, string, []model.Post, error) {
+	opts := &model.ListOptions{Limit: f.opts.Limit, SortBy: "publishedAt", Desc: true}
+	// feeds never list drafts, even to their creators
+	reader := model.PostReader{}
+
+	switch resource {
+	case model.TagResource:
+		tag, err := f.getTag(id)
+		if err != nil {
+			return "", "", nil, err
+		}
+		posts, _, err := f.postRepository.ListByTag(tag.ID, opts, reader)
+		return tag.Name, fmt.Sprintf("/api/v2/tags/%d/posts", tag.ID), posts, err
+	case model.CategoryResource:
+		category, err := f.getCategory(id)
+		if err != nil {
+			return "", "", nil, err
+		}
+		ids, err := f.categoryRepository.Descendants(category.ID)
+		if err != nil {
+			return "", "", nil, err
+		}
+		posts, _, err := f.postRepository.ListByCategories(ids, opts, reader)
+		return category.Name, fmt.Sprintf("/api/v2/categories/%d/posts", category.ID), posts, err
+	default:
+		posts, _, err := f.postRepository.List(opts, reader)
+		return "", "/api/v2/posts", posts, err
+	}
+}
+
+func (f *feedService) getTag(id string) (*model.Tag, error) {
+	tid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	return f.tagRepository.GetTagByID(tid)
+}
+
+func (f *feedService) getCategory(id string) (*model.Category, error) {
+	cid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	return f.categoryRepository.GetCategoryByID(cid)
+}
+
+func feedItem(post *model.Post, base string) feed.Item {
+	link := fmt.Sprintf("%s/api/v2/posts/%d", base, post.ID)
+	published := post.CreatedAt
+	if post.PublishedAt != nil {
+		published = *post.PublishedAt
+	}
+	updated := post.UpdatedAt
+	if published.After(updated) {
+		updated = published
+	}
+
+	item := feed.Item{
+		ID:        link,
+		Title:     post.Name,
+		Link:      link,
+		Summary:   post.Summary,
+		Author:    post.Creator.Name,
+		Published: published,
+		Updated:   updated,
+	}
+	for _, category := range post.Categories {
+		item.Categories = append(item.Categories, category.Name)
+	}
+	return item
+}
+
+// CreateToken creates a new feed token of the user, the previous token of the user stops working.
+func (f *feedService) CreateToken(user *model.User) (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(data)
+	if err := f.feedTokenRepository.Create(&model.FeedToken{UserID: user.ID, Hash: hashFeedToken(token)}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (f *feedService) DeleteToken(user *model.User) error {
+	return f.feedTokenRepository.Delete(user.ID)
+}
+
+// GetTokenUser returns the user of the feed token, an Unauthorized error is returned if the token or its user does not exist.
+func (f *feedService) GetTokenUser(token string) (*model.User, error) {
+	invalid := apierrors.NewUnauthorized(errors.New("invalid feed token"))
+	ft, err := f.feedTokenRepository.GetByHash(hashFeedToken(token))
+	if apierrors.IsNotFound(err) {
+		return nil, invalid
+	} else if err != nil {
+		return nil, err
+	}
+
+	user, err := f.userRepository.GetUserByID(ft.UserID)
+	if apierrors.IsNotFound(err) {
+		return nil, invalid
+	}
+	return user, err
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -4,25 +4,36 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/eastygh/webm-nas/pkg/apierrors"
 	"github.com/eastygh/webm-nas/pkg/model"
 	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/utils/jsonpatch"
+	"github.com/eastygh/webm-nas/pkg/watch"
 )
 
 type groupService struct {
 	userRepository  repository.UserRepository
 	groupRepository repository.GroupRepository
 	rbacRepository  repository.RBACRepository
+	events          watch.Publisher
 }
 
-func NewGroupService(groupRepository repository.GroupRepository, userRepository repository.UserRepository) GroupService {
+func NewGroupService(groupRepository repository.GroupRepository, userRepository repository.UserRepository, rbacRepository repository.RBACRepository, events watch.Publisher) GroupService {
+	return newGroupService(groupRepository, userRepository, rbacRepository, events)
+}
+
+func newGroupService(groupRepository repository.GroupRepository, userRepository repository.UserRepository, rbacRepository repository.RBACRepository, events watch.Publisher) *groupService {
 	return &groupService{
 		groupRepository: groupRepository,
 		userRepository:  userRepository,
+		rbacRepository:  rbacRepository,
+		events:          events,
 	}
 }
 
-func (g *groupService) List() ([]model.Group, error) {
-	return g.groupRepository.List()
+func (g *groupService) List(opts *model.ListOptions) ([]model.Group, *model.ListMeta, error) {
+	return g.groupRepository.List(opts)
 }
 
 func (g *groupService) Create(user *model.User, group *model.Group) (*model.Group, error) {
@@ -36,98 +47,171 @@ func (g *groupService) Create(user *model.User, group *model.Group) (*model.Grou
 		return nil, err
 	}
 
+	g.events.Publish(model.GroupResource, watch.Created, strconv.Itoa(int(group.ID)), group)
 	return group, nil
 }
 
 func (g *groupService) Get(id string) (*model.Group, error) {
-	gid, err := strconv.Atoi(id)
+	gid, err := parseID(id)
 	if err != nil {
 		return nil, err
 	}
-	return g.groupRepository.GetGroupByID(uint(gid))
+	return g.groupRepository.GetGroupByID(gid)
 }
 
+// Update replaces the describe of the group, the name can not be changed.
 func (g *groupService) Update(id string, group *model.Group) (*model.Group, error) {
-	gid, err := strconv.Atoi(id)
+	gid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	old, err := g.groupRepository.GetGroupByID(gid)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectVersion(&group.Version, old.Version, old); err != nil {
+		return nil, err
+	}
+	if group.Name != "" && group.Name != old.Name {
+		return nil, apierrors.NewInvalid(field.ErrorList{field.Invalid(field.NewPath("name"), group.Name, "group name can not be changed")})
+	}
+
+	group.ID = old.ID
+	group.Name = old.Name
+	if _, err := g.groupRepository.Update(group); err != nil {
+		return nil, withCurrent(err, func() (*model.Group, error) { return g.groupRepository.GetGroupByID(old.ID) })
+	}
+	if group, err = g.groupRepository.GetGroupByID(old.ID); err != nil {
+		return nil, err
+	}
+	g.events.Publish(model.GroupResource, watch.Updated, id, group)
+	return group, nil
+}
+
+// Patch applies the patch to the group, a non zero version must be the current version.
+func (g *groupService) Patch(user *model.User, id string, version uint64, patchType jsonpatch.PatchType, patch []byte) (*model.Group, error) {
+	gid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	old, err := g.groupRepository.GetGroupByID(gid)
 	if err != nil {
 		return nil, err
 	}
-	group.ID = uint(gid)
-	return g.groupRepository.Update(group)
+
+	updated := new(model.UpdatedGroup)
+	if err := patchObject(old, patchType, patch, updated); err != nil {
+		return nil, err
+	}
+	group := updated.GetGroup(user.ID)
+	if version != 0 {
+		group.Version = version
+	}
+	return g.Update(id, group)
 }
 
-func (g *groupService) Delete(id string) error {
-	gid, err := strconv.Atoi(id)
+// Delete deletes the group, a non zero version must be the current version.
+func (g *groupService) Delete(id string, version uint64) error {
+	gid, err := parseID(id)
 	if err != nil {
 		return err
 	}
 
-	return g.groupRepository.Delete(uint(gid))
+	if err := g.groupRepository.Delete(gid, version); err != nil {
+		return withCurrent(err, func() (*model.Group, error) { return g.groupRepository.GetGroupByID(gid) })
+	}
+	g.events.Publish(model.GroupResource, watch.Deleted, id, &model.Group{ID: gid})
+	return nil
 }
 
 func (g *groupService) GetUsers(id string) (model.Users, error) {
-	gid, err := strconv.Atoi(id)
+	gid, err := parseID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	return g.groupRepository.GetUsers(&model.Group{ID: uint(gid)})
+	return g.groupRepository.GetUsers(&model.Group{ID: gid})
 }
 
 func (g *groupService) AddUser(user *model.User, id string) error {
 	var err error
 	if user.ID == 0 {
-		return fmt.Errorf("invaild user info")
+		return apierrors.NewBadRequest(fmt.Errorf("invaild user info"))
 	}
 
-	gid, err := strconv.Atoi(id)
+	gid, err := parseID(id)
 	if err != nil {
 		return err
 	}
 
-	return g.groupRepository.AddUser(user, &model.Group{ID: uint(gid)})
+	if err := g.groupRepository.AddUser(user, &model.Group{ID: gid}); err != nil {
+		return err
+	}
+	g.publishUpdated(gid)
+	return nil
 }
 
 func (g *groupService) DelUser(user *model.User, id string) error {
 	var err error
 	if user.ID == 0 {
-		return fmt.Errorf("invaild user info")
+		return apierrors.NewBadRequest(fmt.Errorf("invaild user info"))
 	}
 
-	gid, err := strconv.Atoi(id)
+	gid, err := parseID(id)
 	if err != nil {
 		return err
 	}
 
-	return g.groupRepository.DelUser(user, &model.Group{ID: uint(gid)})
+	if err := g.groupRepository.DelUser(user, &model.Group{ID: gid}); err != nil {
+		return err
+	}
+	g.publishUpdated(gid)
+	return nil
 }
 
 func (g *groupService) AddRole(id, rid string) error {
-	gid, err := strconv.Atoi(id)
+	gid, err := parseID(id)
 	if err != nil {
 		return err
 	}
 
-	roleId, err := strconv.Atoi(rid)
+	roleId, err := parseID(rid)
 	if err != nil {
 		return err
 	}
 
-	return g.groupRepository.AddRole(&model.Role{ID: uint(roleId)}, &model.Group{ID: uint(gid)})
+	if err := g.groupRepository.AddRole(&model.Role{ID: roleId}, &model.Group{ID: gid}); err != nil {
+		return err
+	}
+	g.publishUpdated(gid)
+	return nil
 }
 
 func (g *groupService) DelRole(id, rid string) error {
-	gid, err := strconv.Atoi(id)
+	gid, err := parseID(id)
 	if err != nil {
 		return err
 	}
 
-	roleId, err := strconv.Atoi(rid)
+	roleId, err := parseID(rid)
 	if err != nil {
 		return err
 	}
 
-	return g.groupRepository.DelRole(&model.Role{ID: uint(roleId)}, &model.Group{ID: uint(gid)})
+	if err := g.groupRepository.DelRole(&model.Role{ID: roleId}, &model.Group{ID: gid}); err != nil {
+		return err
+	}
+	g.publishUpdated(gid)
+	return nil
+}
+
+// publishUpdated notifies watchers that the members or roles of a group changed.
+func (g *groupService) publishUpdated(gid uint) {
+	group, err := g.groupRepository.GetGroupByID(gid)
+	if err != nil {
+		return
+	}
+	g.events.Publish(model.GroupResource, watch.Updated, strconv.Itoa(int(gid)), group)
 }
 
 func (g *groupService) createDefaultRoles(group *model.Group) error {
@@ -168,6 +252,13 @@ func (g *groupService) createDefaultRoles(group *model.Group) error {
 	}
 
 	for i := range roles {
+		// the roles of a group in the trash are kept, they are used again if the name of the group is reused
+		if role, err := g.rbacRepository.GetRoleByName(roles[i].Name); err == nil {
+			roles[i] = *role
+			continue
+		} else if !apierrors.IsNotFound(err) {
+			return err
+		}
 		if _, err := g.rbacRepository.Create(&roles[i]); err != nil {
 			return err
 		}
@@ -1,14 +1,23 @@
 package service
 
-import "github.com/eastygh/webm-nas/pkg/model"
+import (
+	"io"
+	"os"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/utils/feed"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/utils/jsonpatch"
+)
 
 type UserService interface {
-	List() (model.Users, error)
+	List(opts *model.ListOptions) (model.Users, *model.ListMeta, error)
 	Create(*model.User) (*model.User, error)
 	Get(string) (*model.User, error)
 	CreateOAuthUser(user *model.User) (*model.User, error)
 	Update(string, *model.User) (*model.User, error)
-	Delete(string) error
+	Patch(id string, version uint64, patchType jsonpatch.PatchType, patch []byte) (*model.User, error)
+	Delete(id string, version uint64) error
 	Validate(*model.User) error
 	Auth(*model.AuthUser) (*model.User, error)
 	Default(*model.User)
@@ -18,11 +27,12 @@ type UserService interface {
 }
 
 type GroupService interface {
-	List() ([]model.Group, error)
+	List(opts *model.ListOptions) ([]model.Group, *model.ListMeta, error)
 	Create(*model.User, *model.Group) (*model.Group, error)
 	Get(string) (*model.Group, error)
 	Update(string, *model.Group) (*model.Group, error)
-	Delete(string) error
+	Patch(user *model.User, id string, version uint64, patchType jsonpatch.PatchType, patch []byte) (*model.Group, error)
+	Delete(id string, version uint64) error
 	GetUsers(gid string) (model.Users, error)
 	AddUser(user *model.User, gid string) error
 	DelUser(user *model.User, gid string) error
@@ -31,25 +41,109 @@ type GroupService interface {
 }
 
 type PostService interface {
-	List() ([]model.Post, error)
+	List(opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error)
 	Create(*model.User, *model.Post) (*model.Post, error)
-	Get(user *model.User, id string) (*model.Post, error)
-	Update(id string, post *model.Post) (*model.Post, error)
+	Get(user *model.User, id string, reader model.PostReader, viewer string) (*model.Post, error)
+	Update(user *model.User, id string, post *model.Post) (*model.Post, error)
+	Patch(user *model.User, id string, version uint64, patchType jsonpatch.PatchType, patch []byte) (*model.Post, error)
+	Delete(id string, version uint64) error
+	GetTags(id string, reader model.PostReader) ([]model.Tag, error)
+	GetCategories(id string, reader model.PostReader) ([]model.Category, error)
+	AddLike(user *model.User, pid string, reader model.PostReader) error
+	DelLike(user *model.User, pid string, reader model.PostReader) error
+	AddComment(user *model.User, pid string, comment *model.Comment, reader model.PostReader) (*model.Comment, error)
+	DelComment(pid, cid string) error
+	ListComments(pid string, opts *model.ListOptions, reader model.PostReader) ([]model.Comment, *model.ListMeta, error)
+	UpdateComment(user *model.User, pid, cid string, update *model.CommentUpdate, reader model.PostReader) (*model.Comment, error)
+	ApproveComment(pid, cid string, reader model.PostReader) (*model.Comment, error)
+	RejectComment(pid, cid string, reader model.PostReader) (*model.Comment, error)
+	ListCommentsByStatus(status string, opts *model.ListOptions, reader model.PostReader) ([]model.Comment, *model.ListMeta, error)
+	Search(q *model.SearchQuery, opts *model.ListOptions, reader model.PostReader) (*model.SearchResult, error)
+	Publish(id string, version uint64, reader model.PostReader, opts *model.PublishOptions) (*model.Post, error)
+	Unpublish(id string, version uint64, reader model.PostReader, opts *model.UnpublishOptions) (*model.Post, error)
+	PublishDue() (int, error)
+	ListRevisions(id string, opts *model.ListOptions, reader model.PostReader) ([]model.PostRevision, *model.ListMeta, error)
+	GetRevision(id, revision string, reader model.PostReader) (*model.PostRevision, error)
+	DiffRevisions(id, from, to string, reader model.PostReader) (*model.RevisionDiff, error)
+	RestoreRevision(user *model.User, id, revision string, version uint64, reader model.PostReader) (*model.Post, error)
+}
+
+type TagService interface {
+	List(opts *model.ListOptions) ([]model.Tag, *model.ListMeta, error)
+	Get(id string) (*model.Tag, error)
+	Create(tag *model.Tag) (*model.Tag, error)
+	Update(id string, tag *model.Tag) (*model.Tag, error)
+	Delete(id string) error
+	Merge(id string, merge *model.TagMerge) (*model.Tag, error)
+	ListPosts(id string, opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error)
+}
+
+type CategoryService interface {
+	List(opts *model.ListOptions) ([]model.Category, *model.ListMeta, error)
+	Get(id string) (*model.Category, error)
+	Create(category *model.Category) (*model.Category, error)
+	Update(id string, category *model.Category) (*model.Category, error)
 	Delete(id string) error
-	GetTags(id string) ([]model.Tag, error)
-	GetCategories(id string) ([]model.Category, error)
-	AddLike(user *model.User, pid string) error
-	DelLike(user *model.User, pid string) error
-	AddComment(user *model.User, pid string, comment *model.Comment) (*model.Comment, error)
-	DelComment(id string) error
+	ListPosts(id string, opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error)
+}
+
+type FeedService interface {
+	Posts(resource, id, base, self string) (*feed.Feed, error)
+	CreateToken(user *model.User) (string, error)
+	DeleteToken(user *model.User) error
+	GetTokenUser(token string) (*model.User, error)
+}
+
+type AttachmentService interface {
+	List(id string, reader model.PostReader) ([]model.Attachment, error)
+	Get(id, aid string, reader model.PostReader) (*model.Attachment, error)
+	Open(attachment *model.Attachment) (*os.File, error)
+	Create(user *model.User, id, name string, content io.Reader, reader model.PostReader) (*model.Attachment, error)
+	Delete(id, aid string, reader model.PostReader) error
+	CleanupOrphans() (int, error)
+	ExportContent(post *model.Post, dir string) (string, map[string]*model.Attachment, error)
+}
+
+type StatsService interface {
+	Get(id string, q *model.StatsQuery, reader model.PostReader) (*model.PostStats, error)
+	Top(by string, q *model.StatsQuery, reader model.PostReader) ([]model.TopPost, error)
+}
+
+type ArchiveService interface {
+	Export(opts *model.ListOptions, reader model.PostReader) ([]model.Post, error)
+	WriteZip(w io.Writer, posts []model.Post) error
+	Import(user *model.User, content io.Reader, reader model.PostReader) (*model.ImportResult, error)
 }
 
 type RBACService interface {
-	List() ([]model.Role, error)
+	List(opts *model.ListOptions) ([]model.Role, *model.ListMeta, error)
 	Create(role *model.Role) (*model.Role, error)
 	Get(id string) (*model.Role, error)
 	Update(id string, role *model.Role) (*model.Role, error)
-	Delete(id string) error
+	Patch(id string, version uint64, patchType jsonpatch.PatchType, patch []byte) (*model.Role, error)
+	Delete(id string, version uint64) error
+	Validate(role *model.Role) error
+	CheckRoles() (map[string]field.ErrorList, error)
 	ListResources() ([]model.Resource, error)
 	ListOperations() ([]model.Operation, error)
 }
+
+type RBACPolicyService interface {
+	Export() (*model.RBACPolicy, error)
+	Apply(policy *model.RBACPolicy, opts model.ApplyOptions) (*model.RBACPlan, error)
+}
+
+type AuditService interface {
+	List(query *model.AuditQuery) ([]model.AuditEvent, error)
+}
+
+type BatchService interface {
+	Run(user *model.User, batch *model.Batch) *model.BatchResult
+}
+
+type TrashService interface {
+	List(resource string, opts *model.ListOptions) ([]model.TrashItem, *model.ListMeta, error)
+	Restore(resource, id string) (interface{}, error)
+	Purge(resource, id string) error
+	PurgeExpired() (int64, error)
+}
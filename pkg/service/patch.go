@@ -0,0 +1,34 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/utils/jsonpatch"
+	"github.com/eastygh/webm-nas/pkg/validation"
+)
+
+// patchObject applies the patch to the json of the current object and decodes the result into updated,
+// so a patch is validated and saved like a full update with the patched object.
+// The struct tags of updated are validated.
+func patchObject(current interface{}, patchType jsonpatch.PatchType, patch []byte, updated interface{}) error {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	result, err := jsonpatch.Apply(patchType, doc, patch)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return apierrors.NewConflict(err, nil)
+	}
+	if err != nil {
+		return apierrors.NewBadRequest(err)
+	}
+
+	if err := json.Unmarshal(result, updated); err != nil {
+		return apierrors.NewBadRequest(fmt.Errorf("%w: %v", jsonpatch.ErrInvalidPatch, err))
+	}
+	return validation.Struct(updated)
+}
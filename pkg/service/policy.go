@@ -0,0 +1,559 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/utils/set"
+	"github.com/eastygh/webm-nas/pkg/watch"
+)
+
+const (
+	policyRoleKind  = "role"
+	policyGroupKind = "group"
+	policyUserKind  = "user"
+)
+
+type rbacPolicyService struct {
+	repository repository.Repository
+	events     watch.Publisher
+}
+
+func NewRBACPolicyService(repository repository.Repository, events watch.Publisher) RBACPolicyService {
+	return &rbacPolicyService{
+		repository: repository,
+		events:     events,
+	}
+}
+
+type policyStep func(repo repository.Repository) error
+
+type policyState struct {
+	resources set.String
+	roles     map[string]model.Role
+	groups    map[string]model.Group
+	users     map[string]model.User
+	// members maps group name to the names of its users
+	members map[string]set.String
+}
+
+func (p *rbacPolicyService) Export() (*model.RBACPolicy, error) {
+	state, err := p.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &model.RBACPolicy{
+		Roles:  make([]model.PolicyRole, 0, len(state.roles)),
+		Groups: make([]model.PolicyGroup, 0, len(state.groups)),
+		Users:  make([]model.PolicyUser, 0),
+	}
+
+	for _, name := range sortedKeys(state.roles) {
+		role := state.roles[name]
+		policy.Roles = append(policy.Roles, model.PolicyRole{
+			Name:      role.Name,
+			Scope:     role.Scope,
+			Namespace: role.Namespace,
+			Rules:     role.Rules,
+		})
+	}
+
+	for _, name := range sortedKeys(state.groups) {
+		group := state.groups[name]
+		policy.Groups = append(policy.Groups, model.PolicyGroup{
+			Name:     group.Name,
+			Kind:     group.Kind,
+			Describe: group.Describe,
+			Members:  state.members[name].Slice(),
+			Roles:    roleNames(group.Roles).Slice(),
+		})
+	}
+
+	for _, name := range sortedKeys(state.users) {
+		user := state.users[name]
+		if len(user.Roles) == 0 {
+			continue
+		}
+		policy.Users = append(policy.Users, model.PolicyUser{
+			Name:  user.Name,
+			Roles: roleNames(user.Roles).Slice(),
+		})
+	}
+
+	return policy, nil
+}
+
+func (p *rbacPolicyService) Apply(policy *model.RBACPolicy, opts model.ApplyOptions) (*model.RBACPlan, error) {
+	if policy == nil {
+		return nil, fmt.Errorf("policy is empty")
+	}
+
+	state, err := p.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validatePolicy(policy, state); err != nil {
+		return nil, err
+	}
+
+	plan := &model.RBACPlan{
+		DryRun: opts.DryRun,
+		Prune:  opts.Prune,
+		Items:  make([]model.PlanItem, 0),
+	}
+
+	pruned := set.NewString()
+	if opts.Prune {
+		pruned = prunedRoles(policy, state)
+	}
+
+	// steps are ordered so that roles exist before they are bound and
+	// bindings are removed before the objects they point to are pruned
+	var steps []policyStep
+	steps = append(steps, planRoles(plan, policy, state)...)
+	steps = append(steps, planGroups(plan, policy, state)...)
+	steps = append(steps, planUsers(plan, policy, state, pruned)...)
+	if opts.Prune {
+		steps = append(steps, pruneGroups(plan, policy, state)...)
+		steps = append(steps, pruneRoles(plan, state, pruned)...)
+	}
+
+	if opts.DryRun || len(steps) == 0 {
+		return plan, nil
+	}
+
+	err = p.repository.Transaction(func(repo repository.Repository) error {
+		for _, step := range steps {
+			if err := step(repo); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Applied = true
+	p.publish(plan, state)
+	return plan, nil
+}
+
+// publish notifies watchers of the roles, groups and users changed by the applied plan.
+func (p *rbacPolicyService) publish(plan *model.RBACPlan, state *policyState) {
+	for _, item := range plan.Items {
+		eventType := watch.Updated
+		switch item.Action {
+		case model.PlanCreate:
+			eventType = watch.Created
+		case model.PlanDelete:
+			eventType = watch.Deleted
+		}
+
+		switch item.Kind {
+		case policyRoleKind:
+			if eventType == watch.Deleted {
+				id := state.roles[item.Name].ID
+				p.events.Publish(model.RoleResource, eventType, strconv.Itoa(int(id)), &model.Role{ID: id})
+			} else if role, err := p.repository.RBAC().GetRoleByName(item.Name); err == nil {
+				p.events.Publish(model.RoleResource, eventType, strconv.Itoa(int(role.ID)), role)
+			}
+		case policyGroupKind:
+			if eventType == watch.Deleted {
+				id := state.groups[item.Name].ID
+				p.events.Publish(model.GroupResource, eventType, strconv.Itoa(int(id)), &model.Group{ID: id})
+			} else if group, err := p.repository.Group().GetGroupByName(item.Name); err == nil {
+				p.events.Publish(model.GroupResource, eventType, strconv.Itoa(int(group.ID)), group)
+			}
+		case policyUserKind:
+			if user, err := p.repository.User().GetUserByID(state.users[item.Name].ID); err == nil {
+				p.events.Publish(model.UserResource, eventType, strconv.Itoa(int(user.ID)), user)
+			}
+		}
+	}
+}
+
+func (p *rbacPolicyService) loadState() (*policyState, error) {
+	resources, err := p.repository.RBAC().ListResources()
+	if err != nil {
+		return nil, err
+	}
+	roles, _, err := p.repository.RBAC().List(nil)
+	if err != nil {
+		return nil, err
+	}
+	groups, _, err := p.repository.Group().List(nil)
+	if err != nil {
+		return nil, err
+	}
+	users, _, err := p.repository.User().List(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &policyState{
+		resources: resourceNames(resources),
+		roles:     make(map[string]model.Role, len(roles)),
+		groups:    make(map[string]model.Group, len(groups)),
+		users:     make(map[string]model.User, len(users)),
+		members:   make(map[string]set.String, len(groups)),
+	}
+	for _, role := range roles {
+		state.roles[role.Name] = role
+	}
+	for _, group := range groups {
+		state.groups[group.Name] = group
+		state.members[group.Name] = set.NewString()
+	}
+	for _, user := range users {
+		state.users[user.Name] = user
+		for _, group := range user.Groups {
+			if members, ok := state.members[group.Name]; ok {
+				members.Insert(user.Name)
+			}
+		}
+	}
+
+	return state, nil
+}
+
+func validatePolicy(policy *model.RBACPolicy, state *policyState) error {
+	var errs field.ErrorList
+
+	roles := set.NewString()
+	for i := range policy.Roles {
+		role := policy.Roles[i]
+		path := field.NewPath("roles").Index(i)
+		errs = append(errs, validateRole(&model.Role{
+			Name:      role.Name,
+			Scope:     role.Scope,
+			Namespace: role.Namespace,
+			Rules:     role.Rules,
+		}, path, state.resources)...)
+		if roles.Has(role.Name) {
+			errs = append(errs, field.Duplicate(path.Child("name"), role.Name))
+		}
+		roles.Insert(role.Name)
+	}
+
+	validateRoleRefs := func(refs []string, path *field.Path) {
+		for i, role := range refs {
+			if _, ok := state.roles[role]; !ok && !roles.Has(role) {
+				errs = append(errs, field.Invalid(path.Index(i), role, "unknown role"))
+			}
+		}
+	}
+
+	groups := set.NewString()
+	for i, group := range policy.Groups {
+		path := field.NewPath("groups").Index(i)
+		if group.Name == "" {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		} else if groups.Has(group.Name) {
+			errs = append(errs, field.Duplicate(path.Child("name"), group.Name))
+		}
+		groups.Insert(group.Name)
+		validateRoleRefs(group.Roles, path.Child("roles"))
+		for j, member := range group.Members {
+			if _, ok := state.users[member]; !ok {
+				errs = append(errs, field.Invalid(path.Child("members").Index(j), member, "unknown user"))
+			}
+		}
+	}
+
+	users := set.NewString()
+	for i, user := range policy.Users {
+		path := field.NewPath("users").Index(i)
+		if _, ok := state.users[user.Name]; !ok {
+			errs = append(errs, field.Invalid(path.Child("name"), user.Name, "unknown user"))
+		} else if users.Has(user.Name) {
+			errs = append(errs, field.Duplicate(path.Child("name"), user.Name))
+		}
+		users.Insert(user.Name)
+		validateRoleRefs(user.Roles, path.Child("roles"))
+	}
+
+	return errs.ToAggregate()
+}
+
+func planRoles(plan *model.RBACPlan, policy *model.RBACPolicy, state *policyState) []policyStep {
+	var steps []policyStep
+	for _, want := range policy.Roles {
+		role := &model.Role{
+			Name:      want.Name,
+			Scope:     want.Scope,
+			Namespace: want.Namespace,
+			Rules:     want.Rules,
+		}
+		if role.Rules == nil {
+			role.Rules = model.Rules{}
+		}
+
+		current, ok := state.roles[want.Name]
+		if !ok {
+			plan.Items = append(plan.Items, model.PlanItem{Kind: policyRoleKind, Name: role.Name, Action: model.PlanCreate})
+			steps = append(steps, func(repo repository.Repository) error {
+				_, err := repo.RBAC().Create(role)
+				return err
+			})
+			continue
+		}
+
+		var changes []string
+		if current.Scope != role.Scope {
+			changes = append(changes, fmt.Sprintf("scope: %q -> %q", current.Scope, role.Scope))
+		}
+		if current.Namespace != role.Namespace {
+			changes = append(changes, fmt.Sprintf("namespace: %q -> %q", current.Namespace, role.Namespace))
+		}
+		if !equalRules(current.Rules, role.Rules) {
+			changes = append(changes, fmt.Sprintf("rules: %v -> %v", current.Rules, role.Rules))
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		role.ID = current.ID
+		role.Version = current.Version
+		plan.Items = append(plan.Items, model.PlanItem{Kind: policyRoleKind, Name: role.Name, Action: model.PlanUpdate, Changes: changes})
+		steps = append(steps, func(repo repository.Repository) error {
+			_, err := repo.RBAC().Update(role)
+			return err
+		})
+	}
+	return steps
+}
+
+func planGroups(plan *model.RBACPlan, policy *model.RBACPolicy, state *policyState) []policyStep {
+	var steps []policyStep
+	for _, want := range policy.Groups {
+		name := want.Name
+		current, exists := state.groups[name]
+
+		var changes []string
+		if !exists {
+			kind := want.Kind
+			if kind == "" {
+				kind = model.CustomGroup
+			}
+			group := model.Group{Name: name, Kind: kind, Describe: want.Describe}
+			steps = append(steps, func(repo repository.Repository) error {
+				return repo.Group().CreateGroups([]model.Group{group})
+			})
+		} else if current.Describe != want.Describe {
+			changes = append(changes, fmt.Sprintf("describe: %q -> %q", current.Describe, want.Describe))
+			group := &model.Group{ID: current.ID, Describe: want.Describe, Version: current.Version}
+			steps = append(steps, func(repo repository.Repository) error {
+				_, err := repo.Group().Update(group)
+				return err
+			})
+		}
+
+		added, removed := diffNames(roleNames(current.Roles), set.NewString(want.Roles...))
+		for _, role := range added {
+			role := role
+			changes = append(changes, "+role "+role)
+			steps = append(steps, func(repo repository.Repository) error {
+				r, err := repo.RBAC().GetRoleByName(role)
+				if err != nil {
+					return err
+				}
+				return repo.Group().AddRole(r, &model.Group{Name: name})
+			})
+		}
+		for _, role := range removed {
+			role := role
+			changes = append(changes, "-role "+role)
+			steps = append(steps, func(repo repository.Repository) error {
+				r, err := repo.RBAC().GetRoleByName(role)
+				if err != nil {
+					return err
+				}
+				return repo.Group().DelRole(r, &model.Group{Name: name})
+			})
+		}
+
+		added, removed = diffNames(state.members[name], set.NewString(want.Members...))
+		for _, member := range added {
+			user := state.users[member]
+			changes = append(changes, "+member "+member)
+			steps = append(steps, func(repo repository.Repository) error {
+				group, err := repo.Group().GetGroupByName(name)
+				if err != nil {
+					return err
+				}
+				return repo.Group().AddUser(&model.User{ID: user.ID}, group)
+			})
+		}
+		for _, member := range removed {
+			user := state.users[member]
+			changes = append(changes, "-member "+member)
+			steps = append(steps, func(repo repository.Repository) error {
+				return repo.Group().DelUser(&model.User{ID: user.ID}, &model.Group{ID: current.ID})
+			})
+		}
+
+		switch {
+		case !exists:
+			plan.Items = append(plan.Items, model.PlanItem{Kind: policyGroupKind, Name: name, Action: model.PlanCreate, Changes: changes})
+		case len(changes) > 0:
+			plan.Items = append(plan.Items, model.PlanItem{Kind: policyGroupKind, Name: name, Action: model.PlanUpdate, Changes: changes})
+		}
+	}
+	return steps
+}
+
+// planUsers binds the users of the policy to their roles, the roles of other users are kept
+// unless they are pruned.
+func planUsers(plan *model.RBACPlan, policy *model.RBACPolicy, state *policyState, pruned set.String) []policyStep {
+	wanted := make(map[string][]string, len(policy.Users))
+	for _, user := range policy.Users {
+		wanted[user.Name] = user.Roles
+	}
+
+	var steps []policyStep
+	for _, name := range sortedKeys(state.users) {
+		user := state.users[name]
+		current := roleNames(user.Roles)
+		roles, ok := wanted[name]
+		if !ok {
+			// only the bindings to pruned roles are removed
+			roles = nil
+			for _, role := range current.Slice() {
+				if !pruned.Has(role) {
+					roles = append(roles, role)
+				}
+			}
+		}
+
+		var changes []string
+		added, removed := diffNames(current, set.NewString(roles...))
+		for _, role := range added {
+			role := role
+			changes = append(changes, "+role "+role)
+			steps = append(steps, func(repo repository.Repository) error {
+				r, err := repo.RBAC().GetRoleByName(role)
+				if err != nil {
+					return err
+				}
+				return repo.User().AddRole(r, &model.User{ID: user.ID})
+			})
+		}
+		for _, role := range removed {
+			r := state.roles[role]
+			changes = append(changes, "-role "+role)
+			steps = append(steps, func(repo repository.Repository) error {
+				return repo.User().DelRole(&model.Role{ID: r.ID}, &model.User{ID: user.ID})
+			})
+		}
+
+		if len(changes) > 0 {
+			plan.Items = append(plan.Items, model.PlanItem{Kind: policyUserKind, Name: name, Action: model.PlanUpdate, Changes: changes})
+		}
+	}
+	return steps
+}
+
+func pruneGroups(plan *model.RBACPlan, policy *model.RBACPolicy, state *policyState) []policyStep {
+	wanted := set.NewString()
+	for _, group := range policy.Groups {
+		wanted.Insert(group.Name)
+	}
+
+	var steps []policyStep
+	for _, name := range sortedKeys(state.groups) {
+		group := state.groups[name]
+		// system groups are created on startup and never pruned
+		if wanted.Has(name) || group.Kind == model.SystemGroup {
+			continue
+		}
+		plan.Items = append(plan.Items, model.PlanItem{Kind: policyGroupKind, Name: name, Action: model.PlanDelete})
+		steps = append(steps, func(repo repository.Repository) error {
+			return repo.Group().Delete(group.ID, group.Version)
+		})
+	}
+	return steps
+}
+
+// prunedRoles returns the names of the roles which are pruned by the policy.
+func prunedRoles(policy *model.RBACPolicy, state *policyState) set.String {
+	wanted := set.NewString()
+	for _, role := range policy.Roles {
+		wanted.Insert(role.Name)
+	}
+
+	// like system groups, the roles of system groups and cluster-admin are never pruned,
+	// deleting them would lock every admin out
+	for _, group := range state.groups {
+		if group.Kind == model.SystemGroup {
+			wanted.Insert(roleNames(group.Roles).Slice()...)
+		}
+	}
+	wanted.Insert(model.ClusterAdminRole)
+
+	pruned := set.NewString()
+	for name := range state.roles {
+		if !wanted.Has(name) {
+			pruned.Insert(name)
+		}
+	}
+	return pruned
+}
+
+func pruneRoles(plan *model.RBACPlan, state *policyState, pruned set.String) []policyStep {
+	var steps []policyStep
+	for _, name := range pruned.Slice() {
+		role := state.roles[name]
+		plan.Items = append(plan.Items, model.PlanItem{Kind: policyRoleKind, Name: name, Action: model.PlanDelete})
+		steps = append(steps, func(repo repository.Repository) error {
+			return repo.RBAC().Delete(role.ID, role.Version)
+		})
+	}
+	return steps
+}
+
+func roleNames(roles []model.Role) set.String {
+	names := set.NewString()
+	for _, role := range roles {
+		names.Insert(role.Name)
+	}
+	return names
+}
+
+// diffNames returns the sorted names which are only in want and only in current
+func diffNames(current, want set.String) (added, removed []string) {
+	for _, name := range want.Slice() {
+		if !current.Has(name) {
+			added = append(added, name)
+		}
+	}
+	for _, name := range current.Slice() {
+		if !want.Has(name) {
+			removed = append(removed, name)
+		}
+	}
+	return added, removed
+}
+
+func equalRules(a, b model.Rules) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newPolicyTest creates the roles of scripts/db.sql, a custom group dev with the role viewer and the users alice and bob.
+func newPolicyTest(t *testing.T) (repository.Repository, RBACPolicyService, *eventBuffer) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	repo := repository.NewRepository(db)
+	require.Nil(t, repo.Migrate())
+	require.Nil(t, repo.Init())
+
+	roles := map[string]model.Rules{
+		model.ClusterAdminRole: {{Resource: model.All, Operation: model.AllOperation}},
+		"authenticated":        {{Resource: model.PostResource, Operation: model.EditOperation}},
+		"viewer":               {{Resource: model.PostResource, Operation: model.ViewOperation}},
+		"stale":                {{Resource: model.UserResource, Operation: model.ViewOperation}},
+	}
+	for _, name := range sortedKeys(roles) {
+		_, err := repo.RBAC().Create(&model.Role{Name: name, Scope: model.ClusterScope, Rules: roles[name]})
+		require.Nil(t, err)
+	}
+	bind := func(group, role string) {
+		r, err := repo.RBAC().GetRoleByName(role)
+		require.Nil(t, err)
+		require.Nil(t, repo.Group().AddRole(r, &model.Group{Name: group}))
+	}
+	bind(model.RootGroup, model.ClusterAdminRole)
+	bind(model.AuthenticatedGroup, "authenticated")
+
+	alice, err := repo.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	_, err = repo.User().Create(&model.User{Name: "bob", Password: "123456"})
+	require.Nil(t, err)
+	_, err = repo.Group().Create(alice, &model.Group{Name: "dev", Kind: model.CustomGroup})
+	require.Nil(t, err)
+	bind("dev", "viewer")
+
+	events := &eventBuffer{}
+	return repo, NewRBACPolicyService(repo, events), events
+}
+
+// eventNames returns the resource, type and object name of the events.
+func eventNames(events *eventBuffer) []string {
+	names := make([]string, 0, len(events.events))
+	for _, event := range events.events {
+		var name string
+		switch obj := event.object.(type) {
+		case *model.Role:
+			name = obj.Name
+		case *model.Group:
+			name = obj.Name
+		case *model.User:
+			name = obj.Name
+		}
+		names = append(names, fmt.Sprintf("%s %s %s", event.resource, event.eventType, name))
+	}
+	return names
+}
+
+func TestPolicyApply(t *testing.T) {
+	repo, s, events := newPolicyTest(t)
+
+	exported, err := s.Export()
+	require.Nil(t, err)
+
+	// applying the exported policy changes nothing
+	plan, err := s.Apply(exported, model.ApplyOptions{Prune: true})
+	require.Nil(t, err)
+	assert.Empty(t, plan.Items)
+	assert.False(t, plan.Applied)
+
+	policy := &model.RBACPolicy{
+		Roles: []model.PolicyRole{
+			{Name: "viewer", Scope: model.ClusterScope, Rules: model.Rules{{Resource: model.All, Operation: model.ViewOperation}}},
+			{Name: "editor", Scope: model.ClusterScope, Rules: model.Rules{{Resource: model.PostResource, Operation: model.EditOperation}}},
+		},
+		Groups: []model.PolicyGroup{
+			{Name: "dev", Members: []string{"alice", "bob"}, Roles: []string{"editor"}},
+			{Name: "ops", Describe: "operators", Members: []string{"bob"}, Roles: []string{"viewer"}},
+		},
+		Users: []model.PolicyUser{{Name: "alice", Roles: []string{"editor"}}},
+	}
+	expected := []model.PlanItem{
+		{Kind: policyRoleKind, Name: "viewer", Action: model.PlanUpdate, Changes: []string{"rules: [{posts view}] -> [{* view}]"}},
+		{Kind: policyRoleKind, Name: "editor", Action: model.PlanCreate},
+		{Kind: policyGroupKind, Name: "dev", Action: model.PlanUpdate, Changes: []string{"+role editor", "-role viewer", "+member bob"}},
+		{Kind: policyGroupKind, Name: "ops", Action: model.PlanCreate, Changes: []string{"+role viewer", "+member bob"}},
+		{Kind: policyUserKind, Name: "alice", Action: model.PlanUpdate, Changes: []string{"+role editor"}},
+	}
+
+	// a dry run only returns the plan
+	plan, err = s.Apply(policy, model.ApplyOptions{DryRun: true})
+	require.Nil(t, err)
+	assert.True(t, plan.DryRun)
+	assert.False(t, plan.Applied)
+	assert.Equal(t, expected, plan.Items)
+	_, err = repo.RBAC().GetRoleByName("editor")
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Empty(t, events.events)
+
+	plan, err = s.Apply(policy, model.ApplyOptions{})
+	require.Nil(t, err)
+	assert.True(t, plan.Applied)
+	assert.Equal(t, expected, plan.Items)
+	assert.Equal(t, []string{
+		"roles update viewer", "roles create editor", "groups update dev", "groups create ops", "users update alice",
+	}, eventNames(events))
+
+	ops, err := repo.Group().GetGroupByName("ops")
+	require.Nil(t, err)
+	assert.Equal(t, "operators", ops.Describe)
+	assert.Equal(t, []string{"viewer"}, roleNames(ops.Roles).Slice())
+	dev, err := repo.Group().GetGroupByName("dev")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"editor"}, roleNames(dev.Roles).Slice())
+	users, err := repo.Group().GetUsers(dev)
+	require.Nil(t, err)
+	assert.Len(t, users, 2)
+
+	// the policy is applied now
+	plan, err = s.Apply(policy, model.ApplyOptions{})
+	require.Nil(t, err)
+	assert.Empty(t, plan.Items)
+}
+
+func TestPolicyPrune(t *testing.T) {
+	repo, s, events := newPolicyTest(t)
+	// users which are not in the policy keep their roles, unless the roles are pruned
+	bindUser := func(user, role string) {
+		u, err := repo.User().GetUserByName(user)
+		require.Nil(t, err)
+		r, err := repo.RBAC().GetRoleByName(role)
+		require.Nil(t, err)
+		require.Nil(t, repo.User().AddRole(r, u))
+	}
+	bindUser("alice", "stale")
+	bindUser("bob", "viewer")
+
+	// the policy has neither cluster-admin nor the roles of system groups
+	policy := &model.RBACPolicy{
+		Roles: []model.PolicyRole{
+			{Name: "viewer", Scope: model.ClusterScope, Rules: model.Rules{{Resource: model.PostResource, Operation: model.ViewOperation}}},
+		},
+	}
+	expected := []model.PlanItem{
+		{Kind: policyUserKind, Name: "alice", Action: model.PlanUpdate, Changes: []string{"-role stale"}},
+		{Kind: policyGroupKind, Name: "dev", Action: model.PlanDelete},
+		{Kind: policyRoleKind, Name: "stale", Action: model.PlanDelete},
+	}
+
+	plan, err := s.Apply(policy, model.ApplyOptions{Prune: true, DryRun: true})
+	require.Nil(t, err)
+	assert.Equal(t, expected, plan.Items)
+	_, err = repo.RBAC().GetRoleByName("stale")
+	require.Nil(t, err)
+
+	plan, err = s.Apply(policy, model.ApplyOptions{Prune: true})
+	require.Nil(t, err)
+	assert.True(t, plan.Applied)
+	assert.Equal(t, expected, plan.Items)
+	assert.Equal(t, []string{"users update alice", "groups delete ", "roles delete "}, eventNames(events))
+	bob, err := repo.User().GetUserByName("bob")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"viewer"}, roleNames(bob.Roles).Slice())
+
+	_, err = repo.RBAC().GetRoleByName("stale")
+	assert.True(t, apierrors.IsNotFound(err))
+	_, err = repo.Group().GetGroupByName("dev")
+	assert.True(t, apierrors.IsNotFound(err))
+	for _, name := range []string{model.ClusterAdminRole, "authenticated", "viewer"} {
+		_, err := repo.RBAC().GetRoleByName(name)
+		assert.Nil(t, err, name)
+	}
+	root, err := repo.Group().GetGroupByName(model.RootGroup)
+	require.Nil(t, err)
+	assert.Equal(t, []string{model.ClusterAdminRole}, roleNames(root.Roles).Slice())
+}
+
+func TestPolicyInvalid(t *testing.T) {
+	_, s, _ := newPolicyTest(t)
+
+	_, err := s.Apply(&model.RBACPolicy{
+		Roles:  []model.PolicyRole{{Name: "viewer", Scope: model.ClusterScope}, {Name: "viewer", Scope: model.ClusterScope}},
+		Groups: []model.PolicyGroup{{Name: "dev", Members: []string{"carol"}, Roles: []string{"missing"}}},
+		Users:  []model.PolicyUser{{Name: "carol"}},
+	}, model.ApplyOptions{})
+	require.NotNil(t, err)
+	assert.Equal(t, "roles[1].name: duplicate value: viewer; groups[0].roles[0]: unknown role: missing; "+
+		"groups[0].members[0]: unknown user: carol; users[0].name: unknown user: carol", err.Error())
+}
@@ -1,134 +1,368 @@
 package service
 
 import (
-	"regexp"
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/eastygh/webm-nas/pkg/analytics"
+	"github.com/eastygh/webm-nas/pkg/apierrors"
 	"github.com/eastygh/webm-nas/pkg/model"
 	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/utils/jsonpatch"
+	"github.com/eastygh/webm-nas/pkg/validation"
+	"github.com/eastygh/webm-nas/pkg/watch"
+
+	lru "github.com/hashicorp/golang-lru/v2"
 )
 
 type postService struct {
 	postRepository repository.PostRepository
+	events         watch.Publisher
+	opts           PostOptions
+	// analytics counts views and likes, services of batch transactions do not count them
+	analytics *analytics.Recorder
+	// rendered caches the html of posts, services of batch transactions have no cache
+	rendered *lru.Cache[uint, *renderedPost]
+}
+
+// PostOptions are the limits of posts and comments, zero values are no limits.
+type PostOptions struct {
+	// MaxRevisions is the number of revisions kept for each post
+	MaxRevisions int
+	// MaxCommentDepth is the depth of the deepest replies, comments on the post have depth 0
+	MaxCommentDepth int
+	// HoldNewUsers holds the comments of users registered within the duration for moderation
+	HoldNewUsers time.Duration
+	// RenderCacheSize is the number of rendered posts cached, 0 is 1024 posts
+	RenderCacheSize int
 }
 
-func NewPostService(postRepository repository.PostRepository) PostService {
+func NewPostService(postRepository repository.PostRepository, recorder *analytics.Recorder, events watch.Publisher, opts PostOptions) PostService {
+	p := newPostService(postRepository, events)
+	p.analytics = recorder
+	p.opts = opts
+	p.rendered = newRenderCache(opts.RenderCacheSize)
+	return p
+}
+
+// newPostService returns a post service without options, like the services of a batch transaction.
+func newPostService(postRepository repository.PostRepository, events watch.Publisher) *postService {
 	return &postService{
 		postRepository: postRepository,
+		events:         events,
+	}
+}
+
+// duplicateName converts the AlreadyExists error of the unique index of post names
+// to an Invalid error of the name, like the other invalid fields of a post.
+func duplicateName(err error, name string) error {
+	if apierrors.IsAlreadyExists(err) {
+		return apierrors.NewInvalid(field.ErrorList{field.Duplicate(field.NewPath("name"), name)})
 	}
+	return err
 }
 
-func (p *postService) List() ([]model.Post, error) {
-	return p.postRepository.List()
+func (p *postService) List(opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error) {
+	return p.postRepository.List(opts, reader)
 }
 
+// Create creates a post, the status defaults to published. Scheduled posts need a publish time in the future.
 func (p *postService) Create(user *model.User, post *model.Post) (*model.Post, error) {
+	if err := validation.Struct(post); err != nil {
+		return nil, err
+	}
+	if err := setPublishTime(post, time.Now()); err != nil {
+		return nil, err
+	}
 	if len(post.Summary) == 0 {
 		post.Summary = getSummary(post.Content)
 	}
-	return p.postRepository.Create(user, post)
+	post, err := p.postRepository.Create(user, post)
+	if err != nil {
+		return nil, duplicateName(err, post.Name)
+	}
+	p.events.Publish(model.PostResource, watch.Created, strconv.Itoa(int(post.ID)), post)
+	p.renderPost(post)
+	return post, nil
 }
 
-func (p *postService) Get(user *model.User, id string) (*model.Post, error) {
-	pid, err := strconv.Atoi(id)
+// Get returns the post with its rendered content, a post which is not visible to the reader is not found.
+// The view is counted once per window of the viewer, views of the creator are not counted.
+func (p *postService) Get(user *model.User, id string, reader model.PostReader, viewer string) (*model.Post, error) {
+	pid, err := parseID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := p.postRepository.IncView(uint(pid)); err != nil {
+	post, err := p.postRepository.GetPostByID(pid)
+	if err != nil {
 		return nil, err
 	}
+	if !post.VisibleTo(reader) {
+		return nil, apierrors.NewNotFound(model.PostResource, pid)
+	}
 
-	post, err := p.postRepository.GetPostByID(uint(pid))
-	if err != nil {
-		return nil, err
+	if p.analytics != nil {
+		if post.CreatorID != user.ID {
+			p.analytics.View(pid, viewer)
+		}
+		// views are written in batches, the views which are not written yet are counted
+		post.Views += uint(p.analytics.Pending(pid))
 	}
 
-	post.UserLiked, _ = p.postRepository.GetLike(uint(pid), user.ID)
+	post.UserLiked, _ = p.postRepository.GetLike(pid, user.ID)
+	p.renderPost(post)
 
 	return post, nil
 }
 
-func (p *postService) Update(id string, post *model.Post) (*model.Post, error) {
-	pid, err := strconv.Atoi(id)
+// Update replaces name, content and summary of the post, an empty summary is generated from the content.
+// The user is the author of the revision of the update.
+func (p *postService) Update(user *model.User, id string, post *model.Post) (*model.Post, error) {
+	pid, err := parseID(id)
 	if err != nil {
 		return nil, err
 	}
-	post.ID = uint(pid)
-	return p.postRepository.Update(post)
+	return p.update(pid, post, &model.PostRevision{AuthorID: user.ID})
 }
 
-func (p *postService) Delete(id string) error {
-	pid, err := strconv.Atoi(id)
+func (p *postService) update(pid uint, post *model.Post, revision *model.PostRevision) (*model.Post, error) {
+	old, err := p.postRepository.GetPostByID(pid)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := expectVersion(&post.Version, old.Version, old); err != nil {
+		return nil, err
+	}
+	post.ID = pid
+	if err := validation.Struct(post); err != nil {
+		return nil, err
+	}
+	if len(post.Summary) == 0 {
+		post.Summary = getSummary(post.Content)
 	}
 
-	return p.postRepository.Delete(uint(pid))
+	if _, err := p.postRepository.Update(post, revision, p.opts.MaxRevisions); err != nil {
+		return nil, withCurrent(duplicateName(err, post.Name), func() (*model.Post, error) { return p.postRepository.GetPostByID(pid) })
+	}
+	p.invalidate(pid)
+	if post, err = p.postRepository.GetPostByID(pid); err != nil {
+		return nil, err
+	}
+	p.events.Publish(model.PostResource, watch.Updated, strconv.Itoa(int(pid)), post)
+	p.renderPost(post)
+	return post, nil
 }
 
-func (p *postService) GetTags(id string) ([]model.Tag, error) {
-	pid, err := strconv.Atoi(id)
+// Patch applies the patch to the post, a non zero version must be the current version.
+func (p *postService) Patch(user *model.User, id string, version uint64, patchType jsonpatch.PatchType, patch []byte) (*model.Post, error) {
+	pid, err := parseID(id)
 	if err != nil {
 		return nil, err
 	}
-
-	return p.postRepository.GetTags(&model.Post{ID: uint(pid)})
-}
-
-func (p *postService) GetCategories(id string) ([]model.Category, error) {
-	pid, err := strconv.Atoi(id)
+	old, err := p.postRepository.GetPostByID(pid)
 	if err != nil {
 		return nil, err
 	}
 
-	return p.postRepository.GetCategories(&model.Post{ID: uint(pid)})
+	updated := new(model.Post)
+	if err := patchObject(old, patchType, patch, updated); err != nil {
+		return nil, err
+	}
+	if version != 0 {
+		updated.Version = version
+	}
+	return p.Update(user, id, updated)
 }
 
-func (p *postService) AddLike(user *model.User, id string) error {
-	pid, err := strconv.Atoi(id)
+// Delete deletes the post, a non zero version must be the current version.
+func (p *postService) Delete(id string, version uint64) error {
+	pid, err := parseID(id)
 	if err != nil {
 		return err
 	}
 
-	return p.postRepository.AddLike(uint(pid), user.ID)
+	if err := p.postRepository.Delete(pid, version); err != nil {
+		return withCurrent(err, func() (*model.Post, error) { return p.postRepository.GetPostByID(pid) })
+	}
+	p.invalidate(pid)
+	p.events.Publish(model.PostResource, watch.Deleted, id, &model.Post{ID: pid})
+	return nil
 }
 
-func (p *postService) DelLike(user *model.User, id string) error {
-	pid, err := strconv.Atoi(id)
+// Publish publishes the post now, or schedules it if opts has a publish time in the future.
+// Only the creator and editors may publish a post, a non zero version must be the current version.
+func (p *postService) Publish(id string, version uint64, reader model.PostReader, opts *model.PublishOptions) (*model.Post, error) {
+	now := time.Now()
+	return p.updateStatus(id, version, reader, func(post *model.Post) error {
+		if opts != nil && opts.PublishAt != nil && opts.PublishAt.After(now) {
+			post.Status, post.PublishedAt = model.PostScheduled, opts.PublishAt
+			return nil
+		}
+		if post.Status != model.PostPublished {
+			post.Status, post.PublishedAt = model.PostPublished, &now
+		}
+		return nil
+	})
+}
+
+// Unpublish makes the post a draft, or archives it. Only the creator and editors may unpublish a post,
+// a non zero version must be the current version.
+func (p *postService) Unpublish(id string, version uint64, reader model.PostReader, opts *model.UnpublishOptions) (*model.Post, error) {
+	return p.updateStatus(id, version, reader, func(post *model.Post) error {
+		if opts != nil && opts.Archive {
+			// archived posts keep the time they were published
+			if post.Status == model.PostScheduled {
+				post.PublishedAt = nil
+			}
+			post.Status = model.PostArchived
+			return nil
+		}
+		post.Status, post.PublishedAt = model.PostDraft, nil
+		return nil
+	})
+}
+
+func (p *postService) updateStatus(id string, version uint64, reader model.PostReader, update func(post *model.Post) error) (*model.Post, error) {
+	post, err := p.visiblePost(id, reader)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	pid := post.ID
+	if !reader.Editor && post.CreatorID != reader.UserID {
+		return nil, apierrors.NewForbidden(fmt.Errorf("only the creator and editors may change the status of post %d", pid))
+	}
+	if err := expectVersion(&version, post.Version, post); err != nil {
+		return nil, err
 	}
 
-	return p.postRepository.DelLike(uint(pid), user.ID)
+	if err := update(post); err != nil {
+		return nil, err
+	}
+	if _, err := p.postRepository.UpdateStatus(post); err != nil {
+		return nil, withCurrent(err, func() (*model.Post, error) { return p.postRepository.GetPostByID(pid) })
+	}
+	p.events.Publish(model.PostResource, watch.Updated, id, post)
+	return post, nil
+}
+
+// visiblePost returns the post, a post which is not visible to the reader is not found.
+func (p *postService) visiblePost(id string, reader model.PostReader) (*model.Post, error) {
+	return visiblePost(p.postRepository, id, reader)
 }
 
-func (p *postService) AddComment(user *model.User, id string, comment *model.Comment) (*model.Comment, error) {
-	pid, err := strconv.Atoi(id)
+func visiblePost(postRepository repository.PostRepository, id string, reader model.PostReader) (*model.Post, error) {
+	pid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	post, err := postRepository.GetPostByID(pid)
 	if err != nil {
 		return nil, err
 	}
+	if !post.VisibleTo(reader) {
+		return nil, apierrors.NewNotFound(model.PostResource, pid)
+	}
+	return post, nil
+}
 
-	comment.PostID = uint(pid)
-	comment.UserID = user.ID
+// PublishDue publishes the scheduled posts whose time has come, it returns the number of published posts.
+// Posts which fail to be published do not stop the others, they are published by the next call.
+func (p *postService) PublishDue() (int, error) {
+	posts, err := p.postRepository.PublishDue(time.Now())
+	for i := range posts {
+		post, getErr := p.postRepository.GetPostByID(posts[i].ID)
+		if getErr != nil {
+			// the post is published, watchers get the post without its creator and taxonomies
+			post = &posts[i]
+		}
+		p.events.Publish(model.PostResource, watch.Updated, strconv.Itoa(int(post.ID)), post)
+	}
+	return len(posts), err
+}
 
-	return p.postRepository.AddComment(comment)
+// setPublishTime checks the publish time of a new post, published posts are published now.
+func setPublishTime(post *model.Post, now time.Time) error {
+	switch post.Status {
+	case "", model.PostPublished:
+		post.Status, post.PublishedAt = model.PostPublished, &now
+	case model.PostScheduled:
+		if post.PublishedAt == nil || !post.PublishedAt.After(now) {
+			return apierrors.NewInvalid(field.ErrorList{
+				field.Invalid(field.NewPath("publishedAt"), post.PublishedAt, "must be in the future for scheduled posts"),
+			})
+		}
+	default:
+		post.PublishedAt = nil
+	}
+	return nil
+}
+
+// GetTags returns the tags of the post visible to the reader.
+func (p *postService) GetTags(id string, reader model.PostReader) ([]model.Tag, error) {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return nil, err
+	}
+	return p.postRepository.GetTags(post)
 }
 
-func (p *postService) DelComment(id string) error {
-	return p.postRepository.DelComment(id)
+// GetCategories returns the categories of the post visible to the reader.
+func (p *postService) GetCategories(id string, reader model.PostReader) ([]model.Category, error) {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return nil, err
+	}
+	return p.postRepository.GetCategories(post)
 }
 
-const summaryLen = 128
+// AddLike likes the post, a post which is not visible to the user is not found.
+func (p *postService) AddLike(user *model.User, id string, reader model.PostReader) error {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return err
+	}
+
+	if err := p.postRepository.AddLike(post.ID, user.ID); err != nil {
+		return err
+	}
+	if p.analytics != nil {
+		p.analytics.Like(post.ID, 1)
+	}
+	return nil
+}
 
-var summaryRe = regexp.MustCompile(`(#.+\n)|(\n)|(<.+>)|(\(.+\))|(\[.+\])`)
+// DelLike unlikes the post, a post which is not visible to the user is not found.
+func (p *postService) DelLike(user *model.User, id string, reader model.PostReader) error {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return err
+	}
 
-func getSummary(content string) string {
-	l := summaryLen
-	if len(content) < l {
-		l = len(content)
+	liked, err := p.postRepository.GetLike(post.ID, user.ID)
+	if err != nil || !liked {
+		return err
 	}
+	if err := p.postRepository.DelLike(post.ID, user.ID); err != nil {
+		return err
+	}
+	if p.analytics != nil {
+		p.analytics.Like(post.ID, -1)
+	}
+	return nil
+}
 
-	sum := string([]rune(content)[:l])
-	return summaryRe.ReplaceAllString(sum, "")
+// Search searches posts by the words of the query, hits are sorted by score so lists can not be sorted or selected.
+func (p *postService) Search(q *model.SearchQuery, opts *model.ListOptions, reader model.PostReader) (*model.SearchResult, error) {
+	if strings.TrimSpace(q.Q) == "" {
+		return nil, apierrors.NewBadRequest(errors.New("query q is required"))
+	}
+	if opts != nil && (opts.SortBy != "" || len(opts.Selectors) > 0) {
+		return nil, apierrors.NewBadRequest(fmt.Errorf("%w: search results can not be sorted or selected", model.ErrInvalidListOptions))
+	}
+	return p.postRepository.Search(q, opts, reader)
 }
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/watch"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newPostTest(t *testing.T) (repository.Repository, *postService, *model.User) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	repo := repository.NewRepository(db)
+	require.Nil(t, repo.Migrate())
+	alice, err := repo.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	return repo, NewPostService(repo.Post(), nil, watch.NewBroadcaster(0), PostOptions{}).(*postService), alice
+}
+
+func TestPostDuplicateName(t *testing.T) {
+	// services of other databases do not affect each other
+	repo, s, alice := newPostTest(t)
+	_, other, bob := newPostTest(t)
+
+	first, err := s.Create(alice, &model.Post{Name: "first", Content: "one"})
+	require.Nil(t, err)
+	_, err = other.Create(bob, &model.Post{Name: "first", Content: "one"})
+	require.Nil(t, err)
+
+	isDuplicate := func(err error) bool {
+		status := apierrors.FromError(err)
+		if !apierrors.IsInvalid(err) || status == nil {
+			return false
+		}
+		errs, ok := status.Details.(field.ErrorList)
+		return ok && len(errs) == 1 && errs[0].Type == field.ErrorTypeDuplicate && errs[0].Field == "name"
+	}
+
+	_, err = s.Create(alice, &model.Post{Name: "first", Content: "again"})
+	assert.True(t, isDuplicate(err), err)
+
+	second, err := s.Create(alice, &model.Post{Name: "second", Content: "two"})
+	require.Nil(t, err)
+	_, err = s.Update(alice, "2", &model.Post{Name: "first", Content: "two", Version: second.Version})
+	assert.True(t, isDuplicate(err), err)
+
+	// a post may keep its own name
+	_, err = s.Update(alice, "1", &model.Post{Name: "first", Content: "changed", Version: first.Version})
+	require.Nil(t, err)
+
+	// names of deleted posts may be reused
+	require.Nil(t, s.Delete("2", 0))
+	_, err = s.Create(alice, &model.Post{Name: "second", Content: "again"})
+	require.Nil(t, err)
+	_, err = repo.Post().GetPostByName("second")
+	require.Nil(t, err)
+}
+
+func TestPostVisibility(t *testing.T) {
+	repo, s, alice := newPostTest(t)
+	bob, err := repo.User().Create(&model.User{Name: "bob", Password: "123456"})
+	require.Nil(t, err)
+
+	published, err := s.Create(alice, &model.Post{Name: "published", Content: "one"})
+	require.Nil(t, err)
+	_, err = s.Create(alice, &model.Post{Name: "draft", Content: "two", Status: model.PostDraft})
+	require.Nil(t, err)
+	publishAt := time.Now().Add(time.Hour)
+	_, err = s.Create(alice, &model.Post{Name: "scheduled", Content: "three", Status: model.PostScheduled, PublishedAt: &publishAt})
+	require.Nil(t, err)
+
+	creator := model.PostReader{UserID: alice.ID}
+	other := model.PostReader{UserID: bob.ID}
+	editor := model.PostReader{UserID: bob.ID, Editor: true}
+	testCases := []struct {
+		name    string
+		reader  model.PostReader
+		visible []string
+	}{
+		{name: "anonymous", reader: model.PostReader{}, visible: []string{"published"}},
+		{name: "other user", reader: other, visible: []string{"published"}},
+		{name: "creator", reader: creator, visible: []string{"published", "draft", "scheduled"}},
+		{name: "editor", reader: editor, visible: []string{"published", "draft", "scheduled"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			posts, _, err := s.List(&model.ListOptions{}, tc.reader)
+			require.Nil(t, err)
+			names := make([]string, 0, len(posts))
+			for _, post := range posts {
+				names = append(names, post.Name)
+			}
+			assert.ElementsMatch(t, tc.visible, names)
+
+			for _, id := range []string{"1", "2", "3"} {
+				post, err := s.Get(&model.User{ID: tc.reader.UserID}, id, tc.reader, "")
+				if err != nil {
+					assert.True(t, apierrors.IsNotFound(err), err)
+					continue
+				}
+				assert.Contains(t, tc.visible, post.Name)
+			}
+		})
+	}
+
+	// posts which are not visible can not be liked or unliked, the like of another post does not tell they exist
+	for _, pid := range []uint{2, 3} {
+		id := strconv.Itoa(int(pid))
+		assert.True(t, apierrors.IsNotFound(s.AddLike(bob, id, other)), id)
+		assert.True(t, apierrors.IsNotFound(s.DelLike(bob, id, other)), id)
+		liked, err := repo.Post().GetLike(pid, bob.ID)
+		require.Nil(t, err)
+		assert.False(t, liked)
+	}
+	require.Nil(t, s.AddLike(bob, "1", other))
+	require.Nil(t, s.AddLike(alice, "2", creator))
+	post, err := s.Get(bob, "1", other, "")
+	require.Nil(t, err)
+	assert.True(t, post.UserLiked)
+	require.Nil(t, s.DelLike(bob, "1", other))
+	// unliking twice is allowed
+	require.Nil(t, s.DelLike(bob, "1", other))
+
+	// only the creator and editors may change the status, other users do not find drafts
+	_, err = s.Publish("2", 0, other, nil)
+	assert.True(t, apierrors.IsNotFound(err))
+	_, err = s.Unpublish("1", 0, other, nil)
+	assert.True(t, apierrors.IsForbidden(err))
+	post, err = s.Publish("2", 0, editor, nil)
+	require.Nil(t, err)
+	assert.Equal(t, model.PostPublished, post.Status)
+	assert.NotNil(t, post.PublishedAt)
+	post, err = s.Unpublish("1", published.Version, creator, &model.UnpublishOptions{Archive: true})
+	require.Nil(t, err)
+	assert.Equal(t, model.PostArchived, post.Status)
+	_, err = s.Get(bob, "1", other, "")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestPublishDue(t *testing.T) {
+	repo, s, alice := newPostTest(t)
+
+	now := time.Now()
+	publishAt := now.Add(time.Hour)
+	var scheduled []*model.Post
+	for _, name := range []string{"due", "also due", "later"} {
+		post, err := s.Create(alice, &model.Post{Name: name, Content: name, Status: model.PostScheduled, PublishedAt: &publishAt})
+		require.Nil(t, err)
+		scheduled = append(scheduled, post)
+	}
+	// scheduled posts may not be published in the past, the time of the first two posts comes
+	for _, post := range scheduled[:2] {
+		past := now.Add(-time.Minute)
+		post.PublishedAt = &past
+		_, err := repo.Post().UpdateStatus(post)
+		require.Nil(t, err)
+	}
+
+	n, err := s.PublishDue()
+	require.Nil(t, err)
+	assert.Equal(t, 2, n)
+	for i, post := range scheduled {
+		current, err := repo.Post().GetPostByID(post.ID)
+		require.Nil(t, err)
+		if i < 2 {
+			assert.Equal(t, model.PostPublished, current.Status, current.Name)
+			assert.Equal(t, post.Version+1, current.Version, current.Name)
+		} else {
+			assert.Equal(t, model.PostScheduled, current.Status, current.Name)
+		}
+	}
+
+	// published posts are visible to everyone
+	posts, _, err := s.List(&model.ListOptions{}, model.PostReader{})
+	require.Nil(t, err)
+	assert.Len(t, posts, 2)
+
+	n, err = s.PublishDue()
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -3,31 +3,68 @@ package service
 import (
 	"strconv"
 
+	"github.com/eastygh/webm-nas/pkg/apierrors"
 	"github.com/eastygh/webm-nas/pkg/model"
 	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/utils/jsonpatch"
 	"github.com/eastygh/webm-nas/pkg/utils/request"
+	"github.com/eastygh/webm-nas/pkg/utils/set"
+	"github.com/eastygh/webm-nas/pkg/watch"
+)
+
+var (
+	knownOperations = []model.Operation{
+		model.AllOperation,
+		model.EditOperation,
+		model.ViewOperation,
+		model.ModerateOperation,
+		request.CreateOperation,
+		request.PatchOperation,
+		request.UpdateOperation,
+		request.GetOperation,
+		request.ListOperation,
+		request.WatchOperation,
+		request.DeleteOperation,
+		"log",
+		"exec",
+		"proxy",
+	}
+
+	knownScopes = []string{string(model.ClusterScope), string(model.NamespaceScope)}
 )
 
 type rbacService struct {
 	rbacRepository repository.RBACRepository
+	events         watch.Publisher
+}
+
+func NewRBACService(rbacRepository repository.RBACRepository, events watch.Publisher) RBACService {
+	return newRBACService(rbacRepository, events)
 }
 
-func NewRBACService(rbacRepository repository.RBACRepository) RBACService {
+func newRBACService(rbacRepository repository.RBACRepository, events watch.Publisher) *rbacService {
 	return &rbacService{
 		rbacRepository: rbacRepository,
+		events:         events,
 	}
 }
 
-func (rbac *rbacService) List() ([]model.Role, error) {
-	return rbac.rbacRepository.List()
+func (rbac *rbacService) List(opts *model.ListOptions) ([]model.Role, *model.ListMeta, error) {
+	return rbac.rbacRepository.List(opts)
 }
 
 func (rbac *rbacService) Create(role *model.Role) (*model.Role, error) {
-	return rbac.rbacRepository.Create(role)
+	role, err := rbac.rbacRepository.Create(role)
+	if err != nil {
+		return nil, err
+	}
+	rbac.events.Publish(model.RoleResource, watch.Created, strconv.Itoa(int(role.ID)), role)
+	return role, nil
 }
 
 func (rbac *rbacService) Get(id string) (*model.Role, error) {
-	rid, err := strconv.Atoi(id)
+	rid, err := parseID(id)
 	if err != nil {
 		return nil, err
 	}
@@ -35,21 +72,93 @@ func (rbac *rbacService) Get(id string) (*model.Role, error) {
 }
 
 func (rbac *rbacService) Update(id string, role *model.Role) (*model.Role, error) {
-	rid, err := strconv.Atoi(id)
+	rid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	old, err := rbac.rbacRepository.GetRoleByID(rid)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectVersion(&role.Version, old.Version, old); err != nil {
+		return nil, err
+	}
+
+	role.ID = rid
+	role, err = rbac.rbacRepository.Update(role)
+	if err != nil {
+		return nil, withCurrent(err, func() (*model.Role, error) { return rbac.rbacRepository.GetRoleByID(rid) })
+	}
+	rbac.events.Publish(model.RoleResource, watch.Updated, id, role)
+	return role, nil
+}
+
+// Patch applies the patch to the role, the patched role is validated before it is saved.
+// A non zero version must be the current version.
+func (rbac *rbacService) Patch(id string, version uint64, patchType jsonpatch.PatchType, patch []byte) (*model.Role, error) {
+	old, err := rbac.Get(id)
 	if err != nil {
 		return nil, err
 	}
-	role.ID = uint(rid)
-	return rbac.rbacRepository.Update(role)
+
+	updated := new(model.Role)
+	if err := patchObject(old, patchType, patch, updated); err != nil {
+		return nil, err
+	}
+	if err := rbac.Validate(updated); err != nil {
+		return nil, err
+	}
+	if version != 0 {
+		updated.Version = version
+	}
+	return rbac.Update(id, updated)
+}
+
+// Delete deletes the role, a non zero version must be the current version.
+func (rbac *rbacService) Delete(id string, version uint64) error {
+	rid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := rbac.rbacRepository.Delete(rid, version); err != nil {
+		return withCurrent(err, func() (*model.Role, error) { return rbac.rbacRepository.GetRoleByID(rid) })
+	}
+	rbac.events.Publish(model.RoleResource, watch.Deleted, id, &model.Role{ID: rid})
+	return nil
 }
 
-func (rbac *rbacService) Delete(id string) error {
-	rid, err := strconv.Atoi(id)
+func (rbac *rbacService) Validate(role *model.Role) error {
+	resources, err := rbac.resourceNames()
 	if err != nil {
 		return err
 	}
+	if errs := validateRole(role, nil, resources); len(errs) > 0 {
+		return apierrors.NewInvalid(errs)
+	}
+	return nil
+}
+
+// CheckRoles returns the validation errors of stored roles by role name,
+// roles may reference resources which were removed or never registered.
+func (rbac *rbacService) CheckRoles() (map[string]field.ErrorList, error) {
+	resources, err := rbac.resourceNames()
+	if err != nil {
+		return nil, err
+	}
+
+	roles, _, err := rbac.rbacRepository.List(nil)
+	if err != nil {
+		return nil, err
+	}
 
-	return rbac.rbacRepository.Delete(uint(rid))
+	report := make(map[string]field.ErrorList)
+	for i := range roles {
+		if errs := validateRules(roles[i].Rules, field.NewPath("rules"), resources); len(errs) > 0 {
+			report[roles[i].Name] = errs
+		}
+	}
+	return report, nil
 }
 
 func (rbac *rbacService) ListResources() ([]model.Resource, error) {
@@ -57,18 +166,71 @@ func (rbac *rbacService) ListResources() ([]model.Resource, error) {
 }
 
 func (rbac *rbacService) ListOperations() ([]model.Operation, error) {
-	return []model.Operation{
-		model.AllOperation,
-		model.EditOperation,
-		model.ViewOperation,
-		request.CreateOperation,
-		request.PatchOperation,
-		request.UpdateOperation,
-		request.GetOperation,
-		request.ListOperation,
-		request.DeleteOperation,
-		"log",
-		"exec",
-		"proxy",
-	}, nil
+	return knownOperations, nil
+}
+
+func (rbac *rbacService) resourceNames() (set.String, error) {
+	resources, err := rbac.rbacRepository.ListResources()
+	if err != nil {
+		return nil, err
+	}
+	return resourceNames(resources), nil
+}
+
+func resourceNames(resources []model.Resource) set.String {
+	names := set.NewString(model.All)
+	for _, resource := range resources {
+		names.Insert(resource.Name)
+	}
+	return names
+}
+
+// validateRole validates a role, the field paths are relative to root which may be nil
+func validateRole(role *model.Role, root *field.Path, resources set.String) field.ErrorList {
+	child := func(name string) *field.Path {
+		if root == nil {
+			return field.NewPath(name)
+		}
+		return root.Child(name)
+	}
+
+	var errs field.ErrorList
+	if role.Name == "" {
+		errs = append(errs, field.Required(child("name"), ""))
+	}
+
+	switch role.Scope {
+	case model.ClusterScope:
+	case model.NamespaceScope:
+		if role.Namespace == "" {
+			errs = append(errs, field.Required(child("namespace"), "namespace is required for namespace scope"))
+		}
+	default:
+		errs = append(errs, field.NotSupported(child("scope"), role.Scope, knownScopes))
+	}
+
+	return append(errs, validateRules(role.Rules, child("rules"), resources)...)
+}
+
+func validateRules(rules model.Rules, path *field.Path, resources set.String) field.ErrorList {
+	operations := set.NewString()
+	for _, op := range knownOperations {
+		operations.Insert(string(op))
+	}
+
+	var errs field.ErrorList
+	for i, rule := range rules {
+		if rule.Resource == "" {
+			errs = append(errs, field.Required(path.Index(i).Child("resource"), ""))
+		} else if !resources.Has(rule.Resource) {
+			errs = append(errs, field.NotSupported(path.Index(i).Child("resource"), rule.Resource, resources.Slice()))
+		}
+
+		if rule.Operation == "" {
+			errs = append(errs, field.Required(path.Index(i).Child("operation"), ""))
+		} else if !operations.Has(string(rule.Operation)) {
+			errs = append(errs, field.NotSupported(path.Index(i).Child("operation"), rule.Operation, operations.Slice()))
+		}
+	}
+	return errs
 }
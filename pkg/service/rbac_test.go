@@ -0,0 +1,61 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/utils/set"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRole(t *testing.T) {
+	resources := set.NewString(model.All, "posts", "containers", "containers/exec")
+
+	testCases := []struct {
+		name           string
+		role           *model.Role
+		expectedFields []string
+	}{
+		{
+			name: "valid role",
+			role: &model.Role{Name: "editor", Scope: model.ClusterScope, Rules: model.Rules{
+				{Resource: "posts", Operation: model.EditOperation},
+				{Resource: "containers/exec", Operation: "exec"},
+			}},
+		},
+		{
+			name:           "missing name and scope",
+			role:           &model.Role{},
+			expectedFields: []string{"name", "scope"},
+		},
+		{
+			name:           "namespace scope without namespace",
+			role:           &model.Role{Name: "ns", Scope: model.NamespaceScope},
+			expectedFields: []string{"namespace"},
+		},
+		{
+			name: "unknown resource and operation",
+			role: &model.Role{Name: "typo", Scope: model.ClusterScope, Rules: model.Rules{
+				{Resource: "posts", Operation: model.ViewOperation},
+				{Resource: "post", Operation: "veiw"},
+				{Operation: model.EditOperation},
+			}},
+			expectedFields: []string{"rules[1].resource", "rules[1].operation", "rules[2].resource"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := validateRole(tc.role, nil, resources)
+			fields := make([]string, 0, len(errs))
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			if len(tc.expectedFields) == 0 {
+				assert.Empty(t, fields)
+			} else {
+				assert.Equal(t, tc.expectedFields, fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/utils/markdown"
+)
+
+const (
+	summaryLen = 128
+	// defaultRenderCacheSize is the number of rendered posts cached without a configured size
+	defaultRenderCacheSize = 1024
+)
+
+// renderedPost is the rendered content of a version of a post.
+type renderedPost struct {
+	version uint64
+	html    string
+	toc     []markdown.Heading
+}
+
+// newRenderCache returns the cache of rendered posts by id, a size of 0 is the default size.
+func newRenderCache(size int) *lru.Cache[uint, *renderedPost] {
+	if size <= 0 {
+		size = defaultRenderCacheSize
+	}
+	cache, _ := lru.New[uint, *renderedPost](size)
+	return cache
+}
+
+// renderPost sets the html and the table of contents of the post and the html of its comments. The rendered content
+// is cached by the version of the post, so a post updated by another service is rendered again.
+func (p *postService) renderPost(post *model.Post) {
+	renderComments(post.Comments)
+	if p.rendered != nil {
+		if r, ok := p.rendered.Get(post.ID); ok && r.version == post.Version {
+			post.HTML, post.TOC = r.html, r.toc
+			return
+		}
+	}
+
+	result := markdown.Render(post.Content, markdown.Options{HeadingIDs: true})
+	post.HTML, post.TOC = result.HTML, result.TOC
+	if p.rendered != nil {
+		p.rendered.Add(post.ID, &renderedPost{version: post.Version, html: result.HTML, toc: result.TOC})
+	}
+}
+
+// invalidate removes the rendered content of the post from the cache.
+func (p *postService) invalidate(pid uint) {
+	if p.rendered != nil {
+		p.rendered.Remove(pid)
+	}
+}
+
+// renderComments sets the html of the comments and their replies, headings of comments have no anchors.
+func renderComments(comments []model.Comment) {
+	for i := range comments {
+		renderComment(&comments[i])
+	}
+}
+
+func renderComment(comment *model.Comment) {
+	comment.HTML = markdown.Render(comment.Content, markdown.Options{}).HTML
+	renderComments(comment.Replies)
+}
+
+// withComment renders the comment returned with a nil error.
+func withComment(comment *model.Comment, err error) (*model.Comment, error) {
+	if err != nil {
+		return nil, err
+	}
+	renderComment(comment)
+	return comment, nil
+}
+
+// getSummary returns the plain text of the rendered content cut to the summary length.
+func getSummary(content string) string {
+	return markdown.Summary(markdown.Render(content, markdown.Options{}).Text, summaryLen)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/utils/diff"
+)
+
+// ListRevisions lists the revisions of the post without their content, the latest first.
+func (p *postService) ListRevisions(id string, opts *model.ListOptions, reader model.PostReader) ([]model.PostRevision, *model.ListMeta, error) {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.postRepository.ListRevisions(post.ID, opts)
+}
+
+func (p *postService) GetRevision(id, revision string, reader model.PostReader) (*model.PostRevision, error) {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return nil, err
+	}
+	rev, err := parseID(revision)
+	if err != nil {
+		return nil, err
+	}
+	return p.postRepository.GetRevision(post.ID, rev)
+}
+
+// DiffRevisions diffs the fields changed from a revision to another one. An empty to is the latest revision,
+// an empty from is the revision before to, and revision 0 is an empty post.
+func (p *postService) DiffRevisions(id, from, to string, reader model.PostReader) (*model.RevisionDiff, error) {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	newer, err := p.revisionOrLatest(post.ID, to)
+	if err != nil {
+		return nil, err
+	}
+	older := &model.PostRevision{}
+	if from == "" && newer.Revision > 1 {
+		from = fmt.Sprint(newer.Revision - 1)
+	}
+	if from != "" && from != "0" {
+		if older, err = p.GetRevision(id, from, reader); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &model.RevisionDiff{From: older.Revision, To: newer.Revision, Fields: make([]model.FieldDiff, 0)}
+	for _, f := range []struct {
+		name     string
+		old, new string
+	}{
+		{name: "name", old: older.Name, new: newer.Name},
+		{name: "summary", old: older.Summary, new: newer.Summary},
+		{name: "content", old: older.Content, new: newer.Content},
+	} {
+		lines := diff.Lines(f.old, f.new)
+		if diff.Changed(lines) {
+			result.Fields = append(result.Fields, model.FieldDiff{Field: f.name, Lines: lines, Unified: diff.Unified(lines)})
+		}
+	}
+	return result, nil
+}
+
+// revisionOrLatest returns the revision of the post, or the latest revision if revision is empty.
+func (p *postService) revisionOrLatest(pid uint, revision string) (*model.PostRevision, error) {
+	if revision != "" {
+		rev, err := parseID(revision)
+		if err != nil {
+			return nil, err
+		}
+		return p.postRepository.GetRevision(pid, rev)
+	}
+
+	latest, _, err := p.postRepository.ListRevisions(pid, &model.ListOptions{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(latest) == 0 {
+		return nil, apierrors.NewNotFound("revisions", pid)
+	}
+	return p.postRepository.GetRevision(pid, latest[0].Revision)
+}
+
+// RestoreRevision updates the post to the name, summary and content of the revision, which saves them as a new revision.
+// Only the creator and editors may restore a revision, a non zero version must be the current version.
+func (p *postService) RestoreRevision(user *model.User, id, revision string, version uint64, reader model.PostReader) (*model.Post, error) {
+	post, err := p.visiblePost(id, reader)
+	if err != nil {
+		return nil, err
+	}
+	if !reader.Editor && post.CreatorID != reader.UserID {
+		return nil, apierrors.NewForbidden(fmt.Errorf("only the creator and editors may restore revisions of post %d", post.ID))
+	}
+	rev, err := p.GetRevision(id, revision, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != 0 {
+		post.Version = version
+	}
+	post.Name, post.Summary, post.Content = rev.Name, rev.Summary, rev.Content
+	return p.update(post.ID, post, &model.PostRevision{AuthorID: user.ID, RestoredFrom: &rev.Revision})
+}
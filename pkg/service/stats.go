@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+)
+
+const (
+	// defaultStatsDays is the number of days of stats without a query
+	defaultStatsDays = 30
+	maxStatsDays     = 366
+	defaultTopLimit  = 10
+	maxTopLimit      = 100
+)
+
+type statsService struct {
+	statsRepository repository.StatsRepository
+	postRepository  repository.PostRepository
+}
+
+func NewStatsService(repo repository.Repository) StatsService {
+	return &statsService{
+		statsRepository: repo.Stats(),
+		postRepository:  repo.Post(),
+	}
+}
+
+// Get returns the daily views and likes of the post visible to the reader, every day of the query has a stat.
+func (s *statsService) Get(id string, q *model.StatsQuery, reader model.PostReader) (*model.PostStats, error) {
+	post, err := visiblePost(s.postRepository, id, reader)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := statsDays(q, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.statsRepository.List(post.ID, from.Format(model.StatsDayLayout), to.Format(model.StatsDayLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	byDay := make(map[string]model.PostStat, len(stats))
+	for _, stat := range stats {
+		byDay[stat.Day] = stat
+	}
+	series := make([]model.PostStat, 0, int(to.Sub(from).Hours()/24)+1)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		d := day.Format(model.StatsDayLayout)
+		stat, ok := byDay[d]
+		if !ok {
+			stat = model.PostStat{PostID: post.ID, Day: d}
+		}
+		series = append(series, stat)
+	}
+	return &model.PostStats{
+		PostID: post.ID,
+		Views:  post.Views,
+		Likes:  post.Likes,
+		From:   from.Format(model.StatsDayLayout),
+		To:     to.Format(model.StatsDayLayout),
+		Series: series,
+	}, nil
+}
+
+// Top returns the posts visible to the reader with the most views or likes of the days of the query.
+func (s *statsService) Top(by string, q *model.StatsQuery, reader model.PostReader) ([]model.TopPost, error) {
+	from, to, err := statsDays(q, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	switch {
+	case limit == 0:
+		limit = defaultTopLimit
+	case limit < 0 || limit > maxTopLimit:
+		return nil, apierrors.NewInvalid(field.ErrorList{
+			field.Invalid(field.NewPath("limit"), limit, fmt.Sprintf("must be between 1 and %d", maxTopLimit)),
+		})
+	}
+	return s.statsRepository.Top(by, from.Format(model.StatsDayLayout), to.Format(model.StatsDayLayout), limit, reader)
+}
+
+// statsDays returns the first and the last day of the query, the last day defaults to today in UTC
+// and the first day to the last 30 days.
+func statsDays(q *model.StatsQuery, now time.Time) (time.Time, time.Time, error) {
+	to := now.UTC().Truncate(24 * time.Hour)
+	if q.To != "" {
+		t, err := time.Parse(model.StatsDayLayout, q.To)
+		if err != nil {
+			return to, to, apierrors.NewInvalid(field.ErrorList{field.Invalid(field.NewPath("to"), q.To, "must be a day like 2006-01-02")})
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, 1-defaultStatsDays)
+	if q.From != "" {
+		t, err := time.Parse(model.StatsDayLayout, q.From)
+		if err != nil {
+			return from, to, apierrors.NewInvalid(field.ErrorList{field.Invalid(field.NewPath("from"), q.From, "must be a day like 2006-01-02")})
+		}
+		from = t
+	}
+	if from.After(to) || to.Sub(from) >= maxStatsDays*24*time.Hour {
+		return from, to, apierrors.NewInvalid(field.ErrorList{
+			field.Invalid(field.NewPath("from"), q.From, fmt.Sprintf("must be at most %d days before to", maxStatsDays-1)),
+		})
+	}
+	return from, to, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestStatsDays(t *testing.T) {
+	now := time.Date(2026, 3, 10, 22, 0, 0, 0, time.FixedZone("", -5*3600))
+	from, to, err := statsDays(&model.StatsQuery{}, now)
+	require.Nil(t, err)
+	assert.Equal(t, "2026-02-10", from.Format(model.StatsDayLayout))
+	assert.Equal(t, "2026-03-11", to.Format(model.StatsDayLayout))
+
+	from, to, err = statsDays(&model.StatsQuery{From: "2026-01-01", To: "2026-01-01"}, now)
+	require.Nil(t, err)
+	assert.Equal(t, from, to)
+
+	for _, q := range []model.StatsQuery{{From: "yesterday"}, {To: "2026-13-01"}, {From: "2026-01-02", To: "2026-01-01"}, {From: "2024-12-31", To: "2026-01-01"}} {
+		_, _, err := statsDays(&q, now)
+		assert.True(t, apierrors.IsInvalid(err), q)
+	}
+}
+
+func TestStats(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{TranslateError: true})
+	require.Nil(t, err)
+	repo := repository.NewRepository(db)
+	require.Nil(t, repo.Migrate())
+	alice, err := repo.User().Create(&model.User{Name: "alice", Password: "123456"})
+	require.Nil(t, err)
+	post, err := repo.Post().Create(alice, &model.Post{Name: "draft", Content: "one", Status: model.PostDraft})
+	require.Nil(t, err)
+	require.Nil(t, repo.Stats().Add([]model.PostStat{{PostID: post.ID, Day: "2026-01-02", Views: 4, Likes: 1}}))
+
+	s := NewStatsService(repo)
+	q := &model.StatsQuery{From: "2026-01-01", To: "2026-01-03"}
+	stats, err := s.Get("1", q, model.PostReader{UserID: alice.ID})
+	require.Nil(t, err)
+	assert.Equal(t, uint(4), stats.Views)
+	assert.Equal(t, []model.PostStat{
+		{PostID: post.ID, Day: "2026-01-01"},
+		{PostID: post.ID, Day: "2026-01-02", Views: 4, Likes: 1},
+		{PostID: post.ID, Day: "2026-01-03"},
+	}, stats.Series)
+
+	// stats of drafts are only visible to their creator and editors
+	_, err = s.Get("1", q, model.PostReader{UserID: alice.ID + 1})
+	assert.True(t, apierrors.IsNotFound(err))
+	top, err := s.Top("views", q, model.PostReader{UserID: alice.ID + 1})
+	require.Nil(t, err)
+	assert.Empty(t, top)
+	top, err = s.Top("views", q, model.PostReader{Editor: true})
+	require.Nil(t, err)
+	assert.Len(t, top, 1)
+
+	_, err = s.Top("views", &model.StatsQuery{Limit: 101}, model.PostReader{})
+	assert.True(t, apierrors.IsInvalid(err))
+}
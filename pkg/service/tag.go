@@ -0,0 +1,98 @@
+package service
+
+import (
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/validation"
+)
+
+type tagService struct {
+	tagRepository  repository.TagRepository
+	postRepository repository.PostRepository
+}
+
+func NewTagService(tagRepository repository.TagRepository, postRepository repository.PostRepository) TagService {
+	return &tagService{
+		tagRepository:  tagRepository,
+		postRepository: postRepository,
+	}
+}
+
+func (t *tagService) List(opts *model.ListOptions) ([]model.Tag, *model.ListMeta, error) {
+	return t.tagRepository.List(opts)
+}
+
+func (t *tagService) Get(id string) (*model.Tag, error) {
+	tid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	return t.tagRepository.GetTagByID(tid)
+}
+
+func (t *tagService) Create(tag *model.Tag) (*model.Tag, error) {
+	tag.ID = 0
+	if err := validation.Struct(tag); err != nil {
+		return nil, err
+	}
+	return t.tagRepository.Create(tag)
+}
+
+// Update renames the tag.
+func (t *tagService) Update(id string, tag *model.Tag) (*model.Tag, error) {
+	tid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Struct(tag); err != nil {
+		return nil, err
+	}
+
+	tag.ID = tid
+	if _, err := t.tagRepository.Update(tag); err != nil {
+		return nil, err
+	}
+	return t.tagRepository.GetTagByID(tid)
+}
+
+func (t *tagService) Delete(id string) error {
+	tid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	return t.tagRepository.Delete(tid)
+}
+
+// Merge merges the tag into the tag merge.Into, it returns the tag merge.Into with its posts.
+func (t *tagService) Merge(id string, merge *model.TagMerge) (*model.Tag, error) {
+	tid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Struct(merge); err != nil {
+		return nil, err
+	}
+	if merge.Into == tid {
+		return nil, apierrors.NewInvalid(field.ErrorList{field.Invalid(field.NewPath("into"), merge.Into, "can not merge a tag into itself")})
+	}
+	if _, err := t.tagRepository.GetTagByID(tid); err != nil {
+		return nil, err
+	}
+	if _, err := t.tagRepository.GetTagByID(merge.Into); apierrors.IsNotFound(err) {
+		return nil, apierrors.NewInvalid(field.ErrorList{field.Invalid(field.NewPath("into"), merge.Into, "must be an existing tag")})
+	} else if err != nil {
+		return nil, err
+	}
+	return t.tagRepository.Merge(tid, merge.Into)
+}
+
+// ListPosts lists the posts with the tag visible to the reader.
+func (t *tagService) ListPosts(id string, opts *model.ListOptions, reader model.PostReader) ([]model.Post, *model.ListMeta, error) {
+	tag, err := t.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t.postRepository.ListByTag(tag.ID, opts, reader)
+}
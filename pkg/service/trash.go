@@ -0,0 +1,87 @@
+package service
+
+import (
+	"time"
+
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/watch"
+
+	"github.com/sirupsen/logrus"
+)
+
+type trashService struct {
+	repository repository.Repository
+	retention  time.Duration
+	events     watch.Publisher
+}
+
+// NewTrashService returns the service of soft deleted objects, they are purged after the retention.
+// A zero retention keeps them forever.
+func NewTrashService(repository repository.Repository, retention time.Duration, events watch.Publisher) TrashService {
+	return &trashService{
+		repository: repository,
+		retention:  retention,
+		events:     events,
+	}
+}
+
+func (t *trashService) List(resource string, opts *model.ListOptions) ([]model.TrashItem, *model.ListMeta, error) {
+	items, meta, err := t.repository.Trash().List(resource, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.retention > 0 {
+		for i := range items {
+			purgeAt := items[i].DeletedAt.Add(t.retention)
+			items[i].PurgeAt = &purgeAt
+		}
+	}
+	return items, meta, nil
+}
+
+// Restore moves the object out of the trash and returns it, the watchers of the resource get a created event.
+func (t *trashService) Restore(resource, id string) (interface{}, error) {
+	oid, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.repository.Trash().Restore(resource, oid); err != nil {
+		return nil, err
+	}
+
+	var obj interface{}
+	switch resource {
+	case model.UserResource:
+		obj, err = t.repository.User().GetUserByID(oid)
+	case model.GroupResource:
+		obj, err = t.repository.Group().GetGroupByID(oid)
+	case model.PostResource:
+		obj, err = t.repository.Post().GetPostByID(oid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.events.Publish(resource, watch.Created, id, obj)
+	return obj, nil
+}
+
+func (t *trashService) Purge(resource, id string) error {
+	oid, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	return t.repository.Trash().Purge(resource, oid)
+}
+
+// PurgeExpired purges the objects which are in the trash longer than the retention.
+func (t *trashService) PurgeExpired() (int64, error) {
+	if t.retention <= 0 {
+		return 0, nil
+	}
+	count, err := t.repository.Trash().PurgeBefore(time.Now().Add(-t.retention))
+	if count > 0 {
+		logrus.Infof("purged %d objects from the trash", count)
+	}
+	return count, err
+}
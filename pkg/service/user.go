@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/eastygh/webm-nas/pkg/apierrors"
 	"github.com/eastygh/webm-nas/pkg/model"
 	"github.com/eastygh/webm-nas/pkg/repository"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+	"github.com/eastygh/webm-nas/pkg/utils/jsonpatch"
+	"github.com/eastygh/webm-nas/pkg/watch"
 
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 const (
@@ -18,16 +21,22 @@ const (
 
 type userService struct {
 	userRepository repository.UserRepository
+	events         watch.Publisher
 }
 
-func NewUserService(userRepository repository.UserRepository) UserService {
+func NewUserService(userRepository repository.UserRepository, events watch.Publisher) UserService {
+	return newUserService(userRepository, events)
+}
+
+func newUserService(userRepository repository.UserRepository, events watch.Publisher) *userService {
 	return &userService{
 		userRepository: userRepository,
+		events:         events,
 	}
 }
 
-func (u *userService) List() (model.Users, error) {
-	return u.userRepository.List()
+func (u *userService) List(opts *model.ListOptions) (model.Users, *model.ListMeta, error) {
+	return u.userRepository.List(opts)
 }
 
 func (u *userService) Create(user *model.User) (*model.User, error) {
@@ -36,13 +45,19 @@ func (u *userService) Create(user *model.User) (*model.User, error) {
 		return nil, err
 	}
 	user.Password = string(password)
-	return u.userRepository.Create(user)
+	user, err = u.userRepository.Create(user)
+	if err != nil {
+		return nil, err
+	}
+	u.events.Publish(model.UserResource, watch.Created, strconv.Itoa(int(user.ID)), user)
+	return user, nil
 }
 
 func (u *userService) Get(id string) (*model.User, error) {
 	return u.getUserByID(id)
 }
 
+// Update replaces the user, the password is kept if it is empty.
 func (u *userService) Update(id string, new *model.User) (*model.User, error) {
 	old, err := u.getUserByID(id)
 	if err != nil {
@@ -50,9 +65,16 @@ func (u *userService) Update(id string, new *model.User) (*model.User, error) {
 	}
 
 	if new.ID != 0 && old.ID != new.ID {
-		return nil, fmt.Errorf("update user %s not match", id)
+		return nil, apierrors.NewBadRequest(fmt.Errorf("update user %s not match", id))
 	}
 	new.ID = old.ID
+	if err := expectVersion(&new.Version, old.Version, old); err != nil {
+		return nil, err
+	}
+
+	if errs := validateUpdatedUser(new); len(errs) > 0 {
+		return nil, apierrors.NewInvalid(errs)
+	}
 
 	if len(new.Password) > 0 {
 		password, err := bcrypt.GenerateFromPassword([]byte(new.Password), bcrypt.DefaultCost)
@@ -62,15 +84,48 @@ func (u *userService) Update(id string, new *model.User) (*model.User, error) {
 		new.Password = string(password)
 	}
 
-	return u.userRepository.Update(new)
+	if _, err := u.userRepository.Update(new); err != nil {
+		return nil, withCurrent(err, func() (*model.User, error) { return u.getUserByID(id) })
+	}
+	user, err := u.userRepository.GetUserByID(new.ID)
+	if err != nil {
+		return nil, err
+	}
+	u.events.Publish(model.UserResource, watch.Updated, id, user)
+	return user, nil
+}
+
+// Patch applies the patch to the user, the password can be set by the patch but is never in the patched document.
+// A non zero version must be the current version.
+func (u *userService) Patch(id string, version uint64, patchType jsonpatch.PatchType, patch []byte) (*model.User, error) {
+	old, err := u.getUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := new(model.UpdatedUser)
+	if err := patchObject(old, patchType, patch, updated); err != nil {
+		return nil, err
+	}
+	user := updated.GetUser()
+	if version != 0 {
+		user.Version = version
+	}
+	return u.Update(id, user)
 }
 
-func (u *userService) Delete(id string) error {
+// Delete deletes the user, a non zero version must be the current version.
+func (u *userService) Delete(id string, version uint64) error {
 	user, err := u.getUser(id)
 	if err != nil {
 		return err
 	}
-	return u.userRepository.Delete(user)
+	user.Version = version
+	if err := u.userRepository.Delete(user); err != nil {
+		return withCurrent(err, func() (*model.User, error) { return u.getUserByID(id) })
+	}
+	u.events.Publish(model.UserResource, watch.Deleted, id, user)
+	return nil
 }
 
 func (u *userService) Validate(user *model.User) error {
@@ -86,6 +141,17 @@ func (u *userService) Validate(user *model.User) error {
 	return nil
 }
 
+func validateUpdatedUser(user *model.User) field.ErrorList {
+	errs := field.ErrorList{}
+	if user.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("name"), "user name is empty"))
+	}
+	if user.Password != "" && len(user.Password) < MinPasswordLength {
+		errs = append(errs, field.Invalid(field.NewPath("password"), "", fmt.Sprintf("password length must great than %d", MinPasswordLength)))
+	}
+	return errs
+}
+
 func (u *userService) Default(user *model.User) {
 	if user == nil || user.Name == "" {
 		return
@@ -97,16 +163,19 @@ func (u *userService) Default(user *model.User) {
 
 func (u *userService) Auth(auser *model.AuthUser) (*model.User, error) {
 	if auser == nil || auser.Name == "" || auser.Password == "" {
-		return nil, fmt.Errorf("name or password is empty")
+		return nil, apierrors.NewBadRequest(fmt.Errorf("name or password is empty"))
 	}
 
 	user, err := u.userRepository.GetUserByName(auser.Name)
+	if apierrors.IsNotFound(err) {
+		return nil, apierrors.NewUnauthorized(errors.New("invalid name or password"))
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(auser.Password)); err != nil {
-		return nil, err
+		return nil, apierrors.NewUnauthorized(errors.New("invalid name or password"))
 	}
 
 	user.Password = ""
@@ -121,7 +190,7 @@ func (u *userService) CreateOAuthUser(user *model.User) (*model.User, error) {
 	authInfo := user.AuthInfos[0]
 	old, err := u.userRepository.GetUserByAuthID(authInfo.AuthType, authInfo.AuthId)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if apierrors.IsNotFound(err) {
 			return u.userRepository.Create(user)
 		}
 		return nil, err
@@ -139,45 +208,45 @@ func (u *userService) GetGroups(id string) ([]model.Group, error) {
 }
 
 func (u *userService) AddRole(id, rid string) error {
-	uid, err := strconv.Atoi(id)
+	uid, err := parseID(id)
 	if err != nil {
 		return err
 	}
 
-	roleId, err := strconv.Atoi(rid)
+	roleId, err := parseID(rid)
 	if err != nil {
 		return err
 	}
 
-	return u.userRepository.AddRole(&model.Role{ID: uint(roleId)}, &model.User{ID: uint(uid)})
+	return u.userRepository.AddRole(&model.Role{ID: roleId}, &model.User{ID: uid})
 }
 
 func (u *userService) DelRole(id, rid string) error {
-	uid, err := strconv.Atoi(id)
+	uid, err := parseID(id)
 	if err != nil {
 		return err
 	}
 
-	roleId, err := strconv.Atoi(rid)
+	roleId, err := parseID(rid)
 	if err != nil {
 		return err
 	}
 
-	return u.userRepository.DelRole(&model.Role{ID: uint(roleId)}, &model.User{ID: uint(uid)})
+	return u.userRepository.DelRole(&model.Role{ID: roleId}, &model.User{ID: uid})
 }
 
 func (u *userService) getUserByID(id string) (*model.User, error) {
-	uid, err := strconv.Atoi(id)
+	uid, err := parseID(id)
 	if err != nil {
 		return nil, err
 	}
-	return u.userRepository.GetUserByID(uint(uid))
+	return u.userRepository.GetUserByID(uid)
 }
 
 func (u *userService) getUser(id string) (*model.User, error) {
-	uid, err := strconv.Atoi(id)
+	uid, err := parseID(id)
 	if err != nil {
 		return nil, err
 	}
-	return &model.User{ID: uint(uid)}, nil
+	return &model.User{ID: uid}, nil
 }
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+)
+
+// expectVersion sets the version of an update to the current version if it is empty,
+// a different version means the update is based on an old object.
+func expectVersion(version *uint64, current uint64, object interface{}) error {
+	if *version == 0 {
+		*version = current
+		return nil
+	}
+	if *version != current {
+		return apierrors.NewConflict(apierrors.ErrModified, object)
+	}
+	return nil
+}
+
+// withCurrent adds the current object to a conflict returned by the repository,
+// the object changed or was deleted between reading and writing it.
+func withCurrent[T any](err error, get func() (T, error)) error {
+	if !errors.Is(err, apierrors.ErrModified) {
+		return err
+	}
+	current, getErr := get()
+	if getErr != nil {
+		return getErr
+	}
+	return apierrors.NewConflict(apierrors.ErrModified, current)
+}
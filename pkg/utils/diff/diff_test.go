@@ -0,0 +1,63 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLines(t *testing.T) {
+	lines := Lines("a\nb\nc\nd", "a\nc\nx\nd")
+	assert.Equal(t, []Line{
+		{Op: Equal, Text: "a", Old: 1, New: 1},
+		{Op: Delete, Text: "b", Old: 2},
+		{Op: Equal, Text: "c", Old: 3, New: 2},
+		{Op: Insert, Text: "x", New: 3},
+		{Op: Equal, Text: "d", Old: 4, New: 4},
+	}, lines)
+	assert.True(t, Changed(lines))
+	assert.Equal(t, " a\n-b\n c\n+x\n d\n", Unified(lines))
+
+	assert.Equal(t, []Line{{Op: Insert, Text: "a", New: 1}}, Lines("", "a"))
+	assert.Equal(t, []Line{{Op: Delete, Text: "a", Old: 1}}, Lines("a", ""))
+	assert.Empty(t, Lines("", ""))
+	assert.False(t, Changed(Lines("a\nb", "a\nb")))
+}
+
+func TestLinesShortest(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{a: "abcabba", b: "cbabac", edits: 5},
+		{a: "abc", b: "xyz", edits: 6},
+		{a: "aaaa", b: "aa", edits: 2},
+		{a: "xaxbxc", b: "abc", edits: 3},
+	}
+	for _, test := range tests {
+		a, b := chars(test.a), chars(test.b)
+		lines := Lines(a, b)
+
+		edits := 0
+		old, new := []string{}, []string{}
+		for _, l := range lines {
+			if l.Op != Equal {
+				edits++
+			}
+			if l.Op != Insert {
+				old = append(old, l.Text)
+			}
+			if l.Op != Delete {
+				new = append(new, l.Text)
+			}
+		}
+		assert.Equal(t, test.edits, edits, test.a+" "+test.b)
+		assert.Equal(t, a, strings.Join(old, "\n"))
+		assert.Equal(t, b, strings.Join(new, "\n"))
+	}
+}
+
+func chars(s string) string {
+	return strings.Join(strings.Split(s, ""), "\n")
+}
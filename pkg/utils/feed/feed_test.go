@@ -0,0 +1,78 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFeed() *Feed {
+	published := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	return &Feed{
+		Title:   "posts",
+		Link:    "http://localhost/api/v2/posts",
+		FeedURL: "http://localhost/feeds/posts.atom",
+		Updated: published.Add(time.Hour),
+		Items: []Item{{
+			ID:         "http://localhost/api/v2/posts/1",
+			Title:      "Fish & chips",
+			Link:       "http://localhost/api/v2/posts/1",
+			Summary:    "<b>crispy</b>",
+			Author:     "alice",
+			Categories: []string{"food", "uk"},
+			Published:  published,
+			Updated:    published.Add(time.Hour),
+		}},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	data, err := Write(testFeed(), Atom)
+	require.Nil(t, err)
+	doc := string(data)
+	assert.True(t, strings.HasPrefix(doc, xml.Header))
+	assert.Contains(t, doc, `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, doc, `<link href="http://localhost/feeds/posts.atom" rel="self"></link>`)
+	assert.Contains(t, doc, `<title>Fish &amp; chips</title>`)
+	assert.Contains(t, doc, `<summary>&lt;b&gt;crispy&lt;/b&gt;</summary>`)
+	assert.Contains(t, doc, `<updated>2026-01-02T09:00:00Z</updated>`)
+	assert.Contains(t, doc, `<category term="uk"></category>`)
+
+	feed := new(atomFeed)
+	require.Nil(t, xml.Unmarshal(data, feed))
+	require.Len(t, feed.Entries, 1)
+	assert.Equal(t, "alice", feed.Entries[0].Author.Name)
+}
+
+func TestRSS(t *testing.T) {
+	data, err := Write(testFeed(), RSS)
+	require.Nil(t, err)
+	doc := string(data)
+	assert.Contains(t, doc, `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/">`)
+	assert.Contains(t, doc, `<atom:link href="http://localhost/feeds/posts.atom" rel="self" type="application/rss+xml"></atom:link>`)
+	assert.Contains(t, doc, `<guid isPermaLink="true">http://localhost/api/v2/posts/1</guid>`)
+	assert.Contains(t, doc, `<dc:creator>alice</dc:creator>`)
+	assert.Contains(t, doc, `<pubDate>Fri, 02 Jan 2026 08:00:00 +0000</pubDate>`)
+}
+
+func TestJSON(t *testing.T) {
+	data, err := Write(testFeed(), JSON)
+	require.Nil(t, err)
+	feed := new(jsonFeed)
+	require.Nil(t, json.Unmarshal(data, feed))
+	assert.Equal(t, "https://jsonfeed.org/version/1.1", feed.Version)
+	require.Len(t, feed.Items, 1)
+	assert.Equal(t, "<b>crispy</b>", feed.Items[0].ContentText)
+	assert.Equal(t, []jsonAuthor{{Name: "alice"}}, feed.Items[0].Authors)
+	assert.Equal(t, "2026-01-02T08:00:00Z", feed.Items[0].DatePublished)
+
+	// a feed without items has an empty list of items
+	data, err = Write(&Feed{Title: "empty"}, JSON)
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"items": []`)
+}
@@ -0,0 +1,82 @@
+package field
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorType is a machine readable value providing more detail about why a field is invalid.
+type ErrorType string
+
+const (
+	ErrorTypeRequired     ErrorType = "FieldValueRequired"
+	ErrorTypeInvalid      ErrorType = "FieldValueInvalid"
+	ErrorTypeNotSupported ErrorType = "FieldValueNotSupported"
+	ErrorTypeDuplicate    ErrorType = "FieldValueDuplicate"
+)
+
+// Error is an implementation of the 'error' interface, which represents a field-level validation error.
+type Error struct {
+	Type     ErrorType   `json:"type"`
+	Field    string      `json:"field"`
+	BadValue interface{} `json:"badValue,omitempty"`
+	Detail   string      `json:"detail"`
+}
+
+func (e *Error) Error() string {
+	if e.BadValue == nil {
+		return fmt.Sprintf("%s: %s", e.Field, e.Detail)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Field, e.Detail, e.BadValue)
+}
+
+// Required returns a *Error indicating "value required".
+func Required(field *Path, detail string) *Error {
+	if detail == "" {
+		detail = "required value"
+	}
+	return &Error{Type: ErrorTypeRequired, Field: field.String(), Detail: detail}
+}
+
+// Invalid returns a *Error indicating "invalid value".
+func Invalid(field *Path, value interface{}, detail string) *Error {
+	return &Error{Type: ErrorTypeInvalid, Field: field.String(), BadValue: value, Detail: detail}
+}
+
+// NotSupported returns a *Error indicating "unsupported value".
+func NotSupported(field *Path, value interface{}, validValues []string) *Error {
+	detail := "unsupported value"
+	if len(validValues) > 0 {
+		detail = "supported values: " + strings.Join(validValues, ", ")
+	}
+	return &Error{Type: ErrorTypeNotSupported, Field: field.String(), BadValue: value, Detail: detail}
+}
+
+// Duplicate returns a *Error indicating "duplicate value".
+func Duplicate(field *Path, value interface{}) *Error {
+	return &Error{Type: ErrorTypeDuplicate, Field: field.String(), BadValue: value, Detail: "duplicate value"}
+}
+
+// ErrorList holds a set of Errors.
+type ErrorList []*Error
+
+// ToAggregate converts the ErrorList into an error, it returns nil if the list is empty.
+func (list ErrorList) ToAggregate() error {
+	if len(list) == 0 {
+		return nil
+	}
+	return &AggregateError{Errors: list}
+}
+
+// AggregateError is the error of a non empty ErrorList.
+type AggregateError struct {
+	Errors ErrorList
+}
+
+func (agg *AggregateError) Error() string {
+	msgs := make([]string, len(agg.Errors))
+	for i, err := range agg.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
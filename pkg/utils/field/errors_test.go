@@ -0,0 +1,31 @@
+package field
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPath(t *testing.T) {
+	assert.Equal(t, "rules", NewPath("rules").String())
+	assert.Equal(t, "rules[0].resource", NewPath("rules").Index(0).Child("resource").String())
+	assert.Equal(t, "spec.labels[app]", NewPath("spec", "labels").Key("app").String())
+}
+
+func TestErrorList(t *testing.T) {
+	var list ErrorList
+	assert.Nil(t, list.ToAggregate())
+
+	list = append(list,
+		Required(NewPath("name"), ""),
+		NotSupported(NewPath("rules").Index(1).Child("operation"), "eidt", []string{"edit", "view"}),
+	)
+
+	err := list.ToAggregate()
+	assert.EqualError(t, err, "name: required value; rules[1].operation: supported values: edit, view: eidt")
+
+	var agg *AggregateError
+	assert.True(t, errors.As(err, &agg))
+	assert.Len(t, agg.Errors, 2)
+}
@@ -0,0 +1,62 @@
+// Package frontmatter reads and writes the YAML front matter of Markdown files, like the posts of Hugo.
+// The front matter is the YAML between two lines of three dashes at the start of the file.
+package frontmatter
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v2"
+)
+
+const delimiter = "---"
+
+// Split returns the front matter and the content of src, the front matter is nil if src has none.
+func Split(src []byte) (matter, content []byte) {
+	src = bytes.TrimPrefix(src, []byte("\xef\xbb\xbf"))
+	line, rest, ok := cutLine(src)
+	if !ok || string(line) != delimiter {
+		return nil, src
+	}
+
+	for start := rest; len(rest) > 0; {
+		line, next, _ := cutLine(rest)
+		if string(line) == delimiter {
+			return start[:len(start)-len(rest)], next
+		}
+		rest = next
+	}
+	return nil, src
+}
+
+// Unmarshal decodes the front matter of src into v and returns the content, v is not changed if src has no front matter.
+func Unmarshal(src []byte, v interface{}) ([]byte, error) {
+	matter, content := Split(src)
+	if matter == nil {
+		return content, nil
+	}
+	return content, yaml.Unmarshal(matter, v)
+}
+
+// Marshal returns the Markdown file of the front matter v and the content.
+func Marshal(v interface{}, content []byte) ([]byte, error) {
+	matter, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(delimiter + "\n")
+	b.Write(matter)
+	b.WriteString(delimiter + "\n")
+	b.Write(content)
+	return b.Bytes(), nil
+}
+
+// cutLine returns the first line of src without its line ending and the rest, ok is false if src is empty.
+func cutLine(src []byte) (line, rest []byte, ok bool) {
+	if len(src) == 0 {
+		return nil, nil, false
+	}
+	line, rest, _ = bytes.Cut(src, []byte("\n"))
+	return bytes.TrimRight(line, " \t\r"), rest, true
+}
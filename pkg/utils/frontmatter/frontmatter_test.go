@@ -0,0 +1,57 @@
+package frontmatter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type page struct {
+	Title string     `yaml:"title"`
+	Tags  []string   `yaml:"tags,omitempty"`
+	Date  *time.Time `yaml:"date,omitempty"`
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		matter  string
+		content string
+	}{
+		{name: "front matter", src: "---\ntitle: a\n---\n# A\n", matter: "title: a\n", content: "# A\n"},
+		{name: "crlf and bom", src: "\xef\xbb\xbf---\r\ntitle: a\r\n---\r\nbody", matter: "title: a\r\n", content: "body"},
+		{name: "empty", src: "---\n---\nbody", matter: "", content: "body"},
+		{name: "no front matter", src: "# A\n---\n", content: "# A\n---\n"},
+		{name: "not closed", src: "---\ntitle: a\n", content: "---\ntitle: a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matter, content := Split([]byte(tt.src))
+			assert.Equal(t, tt.matter, string(matter))
+			assert.Equal(t, tt.content, string(content))
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	date := time.Date(2019, 3, 5, 10, 0, 0, 0, time.UTC)
+	data, err := Marshal(&page{Title: "Hello", Tags: []string{"go"}, Date: &date}, []byte("# Hello\n"))
+	require.Nil(t, err)
+	assert.Equal(t, "---\ntitle: Hello\ntags:\n- go\ndate: 2019-03-05T10:00:00Z\n---\n# Hello\n", string(data))
+
+	p := &page{}
+	content, err := Unmarshal(data, p)
+	require.Nil(t, err)
+	assert.Equal(t, "# Hello\n", string(content))
+	assert.Equal(t, "Hello", p.Title)
+	assert.Equal(t, []string{"go"}, p.Tags)
+	assert.True(t, date.Equal(*p.Date))
+
+	// dates of hugo posts may have no time
+	_, err = Unmarshal([]byte("---\ndate: 2019-03-05\n---\n"), p)
+	require.Nil(t, err)
+	assert.Equal(t, "2019-03-05", p.Date.Format("2006-01-02"))
+}
@@ -0,0 +1,271 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902) documents.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type PatchType string
+
+const (
+	MergePatchType PatchType = "application/merge-patch+json"
+	JSONPatchType  PatchType = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalidPatch is wrapped by the errors of malformed patches and paths that can not be applied.
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrTestFailed is returned if a test operation does not match the document.
+	ErrTestFailed = errors.New("test operation failed")
+)
+
+// Apply applies the patch of the type to the json document.
+func Apply(patchType PatchType, doc, patch []byte) ([]byte, error) {
+	switch patchType {
+	case MergePatchType:
+		return MergePatch(doc, patch)
+	case JSONPatchType:
+		return JSONPatch(doc, patch)
+	default:
+		return nil, fmt.Errorf("%w: unsupported patch type %s", ErrInvalidPatch, patchType)
+	}
+}
+
+// MergePatch applies a JSON Merge Patch, null removes a member and objects are merged recursively.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := decode(doc, &target); err != nil {
+		return nil, fmt.Errorf("invalid document: %v", err)
+	}
+	if err := decode(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergeValue(target, p))
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergeValue(t[k], v)
+	}
+	return t
+}
+
+// JSONPatch applies the operations of a JSON Patch in order, the document is not changed if any operation fails.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := decode(doc, &target); err != nil {
+		return nil, fmt.Errorf("invalid document: %v", err)
+	}
+
+	var operations []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	for i, raw := range operations {
+		op, err := parseOperation(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+		if target, err = op.apply(target); err != nil {
+			if errors.Is(err, ErrTestFailed) {
+				return nil, fmt.Errorf("%w: operation %d: %s", ErrTestFailed, i, op.path)
+			}
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+type operation struct {
+	op    string
+	path  string
+	from  string
+	value interface{}
+}
+
+func parseOperation(raw map[string]json.RawMessage) (*operation, error) {
+	op := &operation{}
+	if err := decodeString(raw, "op", &op.op); err != nil {
+		return nil, err
+	}
+	if err := decodeString(raw, "path", &op.path); err != nil {
+		return nil, err
+	}
+
+	switch op.op {
+	case "add", "replace", "test":
+		value, ok := raw["value"]
+		if !ok {
+			return nil, fmt.Errorf("%s requires value", op.op)
+		}
+		if err := decode(value, &op.value); err != nil {
+			return nil, err
+		}
+	case "move", "copy":
+		if err := decodeString(raw, "from", &op.from); err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.op)
+	}
+	return op, nil
+}
+
+func (op *operation) apply(doc interface{}) (interface{}, error) {
+	path, err := parsePointer(op.path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.op {
+	case "add":
+		return add(doc, path, op.value)
+	case "remove":
+		doc, _, err := remove(doc, path)
+		return doc, err
+	case "replace":
+		if _, err := get(doc, path); err != nil {
+			return nil, err
+		}
+		doc, _, err := remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, op.value)
+	case "move":
+		from, err := parsePointer(op.from)
+		if err != nil {
+			return nil, err
+		}
+		if from.isPrefixOf(path) && len(from) < len(path) {
+			return nil, fmt.Errorf("can not move %s into its child %s", op.from, op.path)
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		from, err := parsePointer(op.from)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(value))
+	case "test":
+		value, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(value, op.value) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.op)
+}
+
+func decode(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if d.More() {
+		return errors.New("unexpected data after json value")
+	}
+	return nil
+}
+
+func decodeString(raw map[string]json.RawMessage, key string, s *string) error {
+	value, ok := raw[key]
+	if !ok {
+		return fmt.Errorf("missing %s", key)
+	}
+	if err := json.Unmarshal(value, s); err != nil {
+		return fmt.Errorf("%s must be a string", key)
+	}
+	return nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = deepCopy(item)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = deepCopy(item)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		// numbers are equal if their values are equal, like 1 and 1.0
+		fx, errx := x.Float64()
+		fy, erry := y.Float64()
+		return errx == nil && erry == nil && fx == fy
+	default:
+		return a == b
+	}
+}
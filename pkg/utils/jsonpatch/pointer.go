@@ -0,0 +1,174 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pointer is a parsed JSON Pointer (RFC 6901), an empty pointer refers to the whole document.
+type pointer []string
+
+func parsePointer(s string) (pointer, error) {
+	if s == "" {
+		return pointer{}, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("path %q must start with /", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func (p pointer) String() string {
+	if len(p) == 0 {
+		return ""
+	}
+	tokens := make([]string, len(p))
+	for i, token := range p {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+	}
+	return "/" + strings.Join(tokens, "/")
+}
+
+func (p pointer) isPrefixOf(other pointer) bool {
+	if len(p) > len(other) {
+		return false
+	}
+	for i := range p {
+		if p[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses the index of an array, "-" is the end of the array which is only valid for add.
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	// leading zeros and signs are not allowed
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.IndexFunc(token, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func get(doc interface{}, path pointer) (interface{}, error) {
+	value := doc
+	for i, token := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("path %s not found", path[:i+1])
+			}
+			value = child
+		case []interface{}:
+			idx, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, fmt.Errorf("path %s: %v", path[:i+1], err)
+			}
+			value = v[idx]
+		default:
+			return nil, fmt.Errorf("path %s not found", path[:i+1])
+		}
+	}
+	return value, nil
+}
+
+// add sets the member of an object or inserts into an array, the parent must exist.
+func add(doc interface{}, path pointer, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch v := parent.(type) {
+		case map[string]interface{}:
+			v[token] = value
+			return v, nil
+		case []interface{}:
+			idx, err := arrayIndex(token, len(v), true)
+			if err != nil {
+				return nil, fmt.Errorf("path %s: %v", path, err)
+			}
+			v = append(v, nil)
+			copy(v[idx+1:], v[idx:])
+			v[idx] = value
+			return v, nil
+		default:
+			return nil, fmt.Errorf("parent of path %s is not an object or array", path)
+		}
+	})
+}
+
+// remove deletes the value at path and returns it.
+func remove(doc interface{}, path pointer) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	var removed interface{}
+	doc, err := updateParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch v := parent.(type) {
+		case map[string]interface{}:
+			value, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("path %s not found", path)
+			}
+			removed = value
+			delete(v, token)
+			return v, nil
+		case []interface{}:
+			idx, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, fmt.Errorf("path %s: %v", path, err)
+			}
+			removed = v[idx]
+			return append(v[:idx], v[idx+1:]...), nil
+		default:
+			return nil, fmt.Errorf("path %s not found", path)
+		}
+	})
+	return doc, removed, err
+}
+
+// updateParent replaces the parent of the last token with the result of fn,
+// arrays may be reallocated so every container on the path is set again.
+func updateParent(doc interface{}, path pointer, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	child, err := get(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = updateParent(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		v[path[0]] = child
+	case []interface{}:
+		idx, _ := arrayIndex(path[0], len(v), false)
+		v[idx] = child
+	}
+	return doc, nil
+}
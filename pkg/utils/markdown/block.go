@@ -0,0 +1,490 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parse parses the blocks of the source and the inlines of their text.
+func parse(src string) *node {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\x00", "�")
+	return &node{kind: documentNode, children: parseBlocks(strings.Split(src, "\n"))}
+}
+
+// parseBlocks parses the lines as a sequence of blocks, containers like quotes and list items
+// parse their lines without their markers.
+func parseBlocks(lines []string) []*node {
+	var blocks []*node
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if isBlank(line) {
+			i++
+			continue
+		}
+
+		var block *node
+		n := 1
+		switch {
+		case indent(line) >= 4:
+			block, n = parseIndentedCode(lines[i:])
+		case isFence(line):
+			block, n = parseFencedCode(lines[i:])
+		case isHeading(line):
+			block = parseHeading(line)
+		case isRule(line):
+			block = &node{kind: ruleNode}
+		case isQuote(line):
+			block, n = parseQuote(lines[i:])
+		case isListItem(line):
+			block, n = parseList(lines[i:])
+		case i+1 < len(lines) && isTable(line, lines[i+1]):
+			block, n = parseTable(lines[i:])
+		default:
+			block, n = parseParagraph(lines[i:])
+		}
+		blocks = append(blocks, block)
+		i += n
+	}
+	return blocks
+}
+
+// parseIndentedCode parses a code block indented by 4 spaces.
+func parseIndentedCode(lines []string) (*node, int) {
+	n := 0
+	for n < len(lines) && (isBlank(lines[n]) || indent(lines[n]) >= 4) {
+		n++
+	}
+	end := n
+	for isBlank(lines[end-1]) {
+		end--
+	}
+
+	code := make([]string, end)
+	for i := range code {
+		code[i] = stripIndent(lines[i], 4)
+	}
+	return &node{kind: codeBlockNode, text: strings.Join(code, "\n") + "\n"}, n
+}
+
+// parseFencedCode parses a code block fenced by ``` or ~~~, the first word of the info string is the language.
+func parseFencedCode(lines []string) (*node, int) {
+	ind := indent(lines[0])
+	line := strings.TrimLeft(lines[0], " \t")
+	fence := line[:runLength(line, line[0])]
+	info := strings.TrimSpace(line[len(fence):])
+
+	block := &node{kind: codeBlockNode}
+	if fields := strings.Fields(info); len(fields) > 0 {
+		block.lang = unescape(fields[0])
+	}
+
+	var code []string
+	n := 1
+	for ; n < len(lines); n++ {
+		if isFenceEnd(lines[n], fence) {
+			n++
+			break
+		}
+		code = append(code, stripIndent(lines[n], ind))
+	}
+	if len(code) > 0 {
+		block.text = strings.Join(code, "\n") + "\n"
+	}
+	return block, n
+}
+
+func parseHeading(line string) *node {
+	line = strings.TrimLeft(line, " \t")
+	level := runLength(line, '#')
+	text := strings.TrimSpace(line[level:])
+	// an optional closing sequence of # is not part of the heading
+	if trimmed := strings.TrimRight(text, "#"); trimmed == "" || strings.HasSuffix(trimmed, " ") || strings.HasSuffix(trimmed, "\t") {
+		text = strings.TrimSpace(trimmed)
+	}
+	return &node{kind: headingNode, level: level, children: parseInline(text)}
+}
+
+// parseQuote parses the lines starting with > and their lazy continuation lines.
+func parseQuote(lines []string) (*node, int) {
+	var inner []string
+	n := 0
+	for ; n < len(lines); n++ {
+		line := lines[n]
+		if isQuote(line) {
+			line = strings.TrimLeft(line, " \t")[1:]
+			if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+				line = line[1:]
+			}
+			inner = append(inner, line)
+			continue
+		}
+		if isBlank(line) || isBlank(lines[n-1]) || interrupts(line) {
+			break
+		}
+		inner = append(inner, line)
+	}
+	return &node{kind: quoteNode, children: parseBlocks(inner)}, n
+}
+
+// listMarker is the marker of a list item, content is the column of the content of the item.
+type listMarker struct {
+	ordered bool
+	// delimiter is the bullet of unordered lists and . or ) of ordered lists
+	delimiter byte
+	start     int
+	content   int
+	// text is the first line of the item without the marker
+	text string
+}
+
+func parseListMarker(line string) (listMarker, bool) {
+	ind := indent(line)
+	if ind >= 4 {
+		return listMarker{}, false
+	}
+	rest := strings.TrimLeft(line, " \t")
+
+	m := listMarker{}
+	width := 1
+	switch {
+	case rest == "":
+		return m, false
+	case rest[0] == '-' || rest[0] == '*' || rest[0] == '+':
+		m.delimiter = rest[0]
+	default:
+		digits := 0
+		for digits < len(rest) && digits < 9 && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits == len(rest) || (rest[digits] != '.' && rest[digits] != ')') {
+			return m, false
+		}
+		m.ordered, m.delimiter = true, rest[digits]
+		m.start, _ = strconv.Atoi(rest[:digits])
+		width = digits + 1
+	}
+
+	after := rest[width:]
+	if after != "" && after[0] != ' ' && after[0] != '\t' {
+		return m, false
+	}
+	spaces := indent(after)
+	switch {
+	case isBlank(after):
+		m.content, m.text = ind+width+1, ""
+	case spaces > 4:
+		// the content is an indented code block
+		m.content, m.text = ind+width+1, stripIndent(after, 1)
+	default:
+		m.content, m.text = ind+width+spaces, strings.TrimLeft(after, " \t")
+	}
+	return m, true
+}
+
+// parseList parses the items of a list, which have markers of the same type.
+func parseList(lines []string) (*node, int) {
+	first, _ := parseListMarker(lines[0])
+	list := &node{kind: listNode, ordered: first.ordered, start: first.start, tight: true}
+
+	n := 0
+	for n < len(lines) {
+		m, ok := parseListMarker(lines[n])
+		if !ok || m.ordered != first.ordered || m.delimiter != first.delimiter || isRule(lines[n]) {
+			break
+		}
+
+		item := []string{m.text}
+		i := n + 1
+	continuation:
+		for ; i < len(lines); i++ {
+			line := lines[i]
+			switch {
+			case isBlank(line):
+				item = append(item, "")
+			case indent(line) >= m.content:
+				item = append(item, stripIndent(line, m.content))
+			case !isBlank(lines[i-1]) && !interrupts(line):
+				// a lazy continuation line of a paragraph
+				item = append(item, line)
+			default:
+				break continuation
+			}
+		}
+		// blank lines at the end of an item separate it from the next item
+		blanks := 0
+		for len(item) > 1 && item[len(item)-1] == "" {
+			item = item[:len(item)-1]
+			blanks++
+		}
+		children := parseBlocks(item)
+		if blanks > 0 && i < len(lines) {
+			if next, ok := parseListMarker(lines[i]); ok && next.ordered == first.ordered && next.delimiter == first.delimiter {
+				list.tight = false
+			}
+		}
+		if len(children) > 1 && hasBlankBetween(item) {
+			list.tight = false
+		}
+		list.children = append(list.children, &node{kind: itemNode, children: children})
+		n = i
+	}
+	return list, n
+}
+
+// hasBlankBetween reports whether there is a blank line between the lines of an item, outside of code fences.
+func hasBlankBetween(lines []string) bool {
+	fenced := false
+	for _, line := range lines {
+		if isFence(line) {
+			fenced = !fenced
+		}
+		if !fenced && line == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTable parses a table of a header row, a delimiter row and the rows until a blank line or another block.
+func parseTable(lines []string) (*node, int) {
+	header := splitRow(lines[0])
+	aligns := splitRow(lines[1])
+	for i, a := range aligns {
+		a = strings.TrimSpace(a)
+		switch {
+		case strings.HasPrefix(a, ":") && strings.HasSuffix(a, ":"):
+			aligns[i] = "center"
+		case strings.HasSuffix(a, ":"):
+			aligns[i] = "right"
+		case strings.HasPrefix(a, ":"):
+			aligns[i] = "left"
+		default:
+			aligns[i] = ""
+		}
+	}
+
+	table := &node{kind: tableNode, children: []*node{tableRow(header, aligns, true)}}
+	n := 2
+	for ; n < len(lines) && !isBlank(lines[n]) && !interrupts(lines[n]); n++ {
+		table.children = append(table.children, tableRow(splitRow(lines[n]), aligns, false))
+	}
+	return table, n
+}
+
+// tableRow returns a row with a cell for each column, missing cells are empty and extra cells are ignored.
+func tableRow(cells, aligns []string, header bool) *node {
+	row := &node{kind: rowNode, header: header}
+	for i, align := range aligns {
+		cell := &node{kind: cellNode, header: header, align: align}
+		if i < len(cells) {
+			cell.children = parseInline(strings.TrimSpace(cells[i]))
+		}
+		row.children = append(row.children, cell)
+	}
+	return row
+}
+
+// splitRow splits a table row by the pipes which are not escaped, without the leading and trailing pipe.
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, cell.String())
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, cell.String())
+}
+
+// parseParagraph parses the lines of a paragraph, or a heading underlined by = or -.
+func parseParagraph(lines []string) (*node, int) {
+	var text []string
+	n := 0
+	for ; n < len(lines); n++ {
+		line := lines[n]
+		if isBlank(line) {
+			break
+		}
+		if n > 0 {
+			if level := setextLevel(line); level > 0 {
+				return &node{kind: headingNode, level: level, children: parseInline(strings.TrimSpace(strings.Join(text, "\n")))}, n + 1
+			}
+			if interrupts(line) {
+				break
+			}
+		}
+		text = append(text, strings.TrimLeft(line, " \t"))
+	}
+	return &node{kind: paragraphNode, children: parseInline(strings.TrimRight(strings.Join(text, "\n"), " \t"))}, n
+}
+
+// interrupts reports whether the line starts a block which ends a paragraph.
+func interrupts(line string) bool {
+	if isFence(line) || isHeading(line) || isRule(line) || isQuote(line) {
+		return true
+	}
+	// empty items and ordered lists which do not start from 1 do not interrupt paragraphs
+	m, ok := parseListMarker(line)
+	return ok && m.text != "" && (!m.ordered || m.start == 1)
+}
+
+func isBlank(line string) bool {
+	return strings.TrimLeft(line, " \t") == ""
+}
+
+// indent returns the column of the first character which is not a space, tabs stop at multiples of 4.
+func indent(line string) int {
+	col := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			col++
+		case '\t':
+			col += 4 - col%4
+		default:
+			return col
+		}
+	}
+	return col
+}
+
+// stripIndent removes n columns of indentation, a tab which spans past n is replaced by its remaining spaces.
+func stripIndent(line string, n int) string {
+	col := 0
+	for i := 0; i < len(line); i++ {
+		if col >= n {
+			return line[i:]
+		}
+		switch line[i] {
+		case ' ':
+			col++
+		case '\t':
+			width := 4 - col%4
+			if col+width > n {
+				return strings.Repeat(" ", col+width-n) + line[i+1:]
+			}
+			col += width
+		default:
+			return line[i:]
+		}
+	}
+	return ""
+}
+
+// runLength returns the number of c at the start of s.
+func runLength(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+func isFence(line string) bool {
+	if indent(line) >= 4 {
+		return false
+	}
+	line = strings.TrimLeft(line, " \t")
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return false
+	}
+	n := runLength(line, line[0])
+	return n >= 3 && !(line[0] == '`' && strings.Contains(line[n:], "`"))
+}
+
+func isFenceEnd(line, fence string) bool {
+	if indent(line) >= 4 {
+		return false
+	}
+	line = strings.TrimLeft(line, " \t")
+	n := runLength(line, fence[0])
+	return n >= len(fence) && isBlank(line[n:])
+}
+
+func isHeading(line string) bool {
+	if indent(line) >= 4 {
+		return false
+	}
+	line = strings.TrimLeft(line, " \t")
+	n := runLength(line, '#')
+	return n >= 1 && n <= 6 && (n == len(line) || line[n] == ' ' || line[n] == '\t')
+}
+
+// isRule reports whether the line is a thematic break of 3 or more -, * or _.
+func isRule(line string) bool {
+	if indent(line) >= 4 {
+		return false
+	}
+	line = strings.TrimSpace(line)
+	if line == "" || (line[0] != '-' && line[0] != '*' && line[0] != '_') {
+		return false
+	}
+	n := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case line[0]:
+			n++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+	return n >= 3
+}
+
+func isQuote(line string) bool {
+	return indent(line) < 4 && strings.HasPrefix(strings.TrimLeft(line, " \t"), ">")
+}
+
+func isListItem(line string) bool {
+	_, ok := parseListMarker(line)
+	return ok
+}
+
+// setextLevel returns 1 or 2 if the line underlines a heading with = or -, otherwise 0.
+func setextLevel(line string) int {
+	if indent(line) >= 4 {
+		return 0
+	}
+	line = strings.TrimSpace(line)
+	if line == "" || (line[0] != '=' && line[0] != '-') || runLength(line, line[0]) != len(line) {
+		return 0
+	}
+	if line[0] == '=' {
+		return 1
+	}
+	return 2
+}
+
+// isTable reports whether the line is the header row of a table, followed by a delimiter row with as many cells.
+func isTable(line, next string) bool {
+	if !strings.Contains(line, "|") || indent(line) >= 4 || interrupts(line) {
+		return false
+	}
+	cells := splitRow(next)
+	if len(cells) != len(splitRow(line)) || !strings.Contains(next, "-") {
+		return false
+	}
+	for _, cell := range cells {
+		cell = strings.Trim(strings.TrimSpace(cell), ":")
+		if cell == "" || runLength(cell, '-') != len(cell) {
+			return false
+		}
+	}
+	return true
+}
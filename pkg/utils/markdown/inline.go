@@ -0,0 +1,432 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const asciiPunctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+var (
+	autolinkRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>\x00-\x1f]*$`)
+	emailRe    = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+)
+
+// inline is an element of the list of inlines of a text, delimiter runs and brackets are text until they are matched.
+type inline struct {
+	node       *node
+	prev, next *inline
+	// delim is *, _ or ~ for delimiter runs, length is the length of the run and count the delimiters left
+	delim       byte
+	length      int
+	count       int
+	open, close bool
+}
+
+// bracket is a [ or ![ which may start a link or an image, links can not contain links.
+type bracket struct {
+	elem   *inline
+	image  bool
+	active bool
+}
+
+type inlineParser struct {
+	src        string
+	pos        int
+	head, tail *inline
+	brackets   []*bracket
+	text       strings.Builder
+}
+
+// parseInline parses the inlines of the text of a paragraph, heading or table cell.
+func parseInline(src string) []*node {
+	p := &inlineParser{src: src}
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; c {
+		case '\\':
+			p.backslash()
+		case '`':
+			p.codeSpan()
+		case '*', '_', '~':
+			p.delimiterRun(c)
+		case '[':
+			p.openBracket(false, 1)
+		case '!':
+			if strings.HasPrefix(p.src[p.pos:], "![") {
+				p.openBracket(true, 2)
+			} else {
+				p.text.WriteByte(c)
+				p.pos++
+			}
+		case ']':
+			p.closeBracket()
+		case '<':
+			p.autolink()
+		case '\n':
+			p.lineBreak()
+		default:
+			p.text.WriteByte(c)
+			p.pos++
+		}
+	}
+	p.flush()
+	p.processEmphasis(nil)
+	return p.nodes(p.head, nil)
+}
+
+// flush appends the pending text.
+func (p *inlineParser) flush() {
+	if p.text.Len() > 0 {
+		p.append(&node{kind: textNode, text: p.text.String()})
+		p.text.Reset()
+	}
+}
+
+func (p *inlineParser) append(n *node) *inline {
+	e := &inline{node: n, prev: p.tail}
+	if p.tail == nil {
+		p.head = e
+	} else {
+		p.tail.next = e
+	}
+	p.tail = e
+	return e
+}
+
+// backslash escapes punctuation, a backslash at the end of a line is a hard line break.
+func (p *inlineParser) backslash() {
+	if p.pos+1 < len(p.src) {
+		next := p.src[p.pos+1]
+		if strings.IndexByte(asciiPunctuation, next) >= 0 {
+			p.text.WriteByte(next)
+			p.pos += 2
+			return
+		}
+		if next == '\n' {
+			p.flush()
+			p.append(&node{kind: breakNode})
+			p.pos += 2
+			p.skipIndent()
+			return
+		}
+	}
+	p.text.WriteByte('\\')
+	p.pos++
+}
+
+// codeSpan parses a code span between backtick runs of the same length.
+func (p *inlineParser) codeSpan() {
+	n := runLength(p.src[p.pos:], '`')
+	start := p.pos + n
+	for i := start; i < len(p.src); {
+		if p.src[i] != '`' {
+			i++
+			continue
+		}
+		m := runLength(p.src[i:], '`')
+		if m == n {
+			code := strings.ReplaceAll(p.src[start:i], "\n", " ")
+			if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			p.flush()
+			p.append(&node{kind: codeNode, text: code})
+			p.pos = i + m
+			return
+		}
+		i += m
+	}
+	p.text.WriteString(p.src[p.pos:start])
+	p.pos = start
+}
+
+// delimiterRun appends a run of *, _ or ~, whether it opens or closes emphasis depends on the characters around it.
+func (p *inlineParser) delimiterRun(c byte) {
+	n := runLength(p.src[p.pos:], c)
+	if c == '~' && n > 2 {
+		p.text.WriteString(p.src[p.pos : p.pos+n])
+		p.pos += n
+		return
+	}
+
+	prev, next := ' ', ' '
+	if p.pos > 0 {
+		prev, _ = utf8.DecodeLastRuneInString(p.src[:p.pos])
+	}
+	if p.pos+n < len(p.src) {
+		next, _ = utf8.DecodeRuneInString(p.src[p.pos+n:])
+	}
+	left := !unicode.IsSpace(next) && (!isPunct(next) || unicode.IsSpace(prev) || isPunct(prev))
+	right := !unicode.IsSpace(prev) && (!isPunct(prev) || unicode.IsSpace(next) || isPunct(next))
+
+	p.flush()
+	e := p.append(&node{kind: textNode})
+	e.delim, e.length, e.count = c, n, n
+	e.open, e.close = left, right
+	if c == '_' {
+		// _ does not emphasize within words
+		e.open = left && (!right || isPunct(prev))
+		e.close = right && (!left || isPunct(next))
+	}
+	p.pos += n
+}
+
+func (p *inlineParser) openBracket(image bool, n int) {
+	p.flush()
+	e := p.append(&node{kind: textNode, text: p.src[p.pos : p.pos+n]})
+	p.brackets = append(p.brackets, &bracket{elem: e, image: image, active: true})
+	p.pos += n
+}
+
+// closeBracket makes a link or image of the inlines after the last bracket if a destination follows.
+func (p *inlineParser) closeBracket() {
+	p.pos++
+	if len(p.brackets) == 0 {
+		p.text.WriteByte(']')
+		return
+	}
+	b := p.brackets[len(p.brackets)-1]
+	p.brackets = p.brackets[:len(p.brackets)-1]
+	dest, title, end, ok := parseDestination(p.src, p.pos)
+	if !b.active || !ok {
+		p.text.WriteByte(']')
+		return
+	}
+
+	p.flush()
+	p.pos = end
+	p.processEmphasis(b.elem)
+	link := &node{kind: linkNode, url: dest, title: title, children: p.nodes(b.elem.next, nil)}
+	if b.image {
+		link.kind = imageNode
+	} else {
+		for _, o := range p.brackets {
+			if !o.image {
+				o.active = false
+			}
+		}
+	}
+	b.elem.node, b.elem.next = link, nil
+	p.tail = b.elem
+}
+
+// autolink parses an absolute url or an email between < and >, other html is text.
+func (p *inlineParser) autolink() {
+	if end := strings.IndexByte(p.src[p.pos:], '>'); end > 0 {
+		inner := p.src[p.pos+1 : p.pos+end]
+		url := ""
+		switch {
+		case autolinkRe.MatchString(inner):
+			url = inner
+		case emailRe.MatchString(inner):
+			url = "mailto:" + inner
+		}
+		if url != "" {
+			p.flush()
+			p.append(&node{kind: linkNode, url: url, children: []*node{{kind: textNode, text: inner}}})
+			p.pos += end + 1
+			return
+		}
+	}
+	p.text.WriteByte('<')
+	p.pos++
+}
+
+// lineBreak appends a soft line break, or a hard line break if the line ends with 2 spaces.
+func (p *inlineParser) lineBreak() {
+	text := p.text.String()
+	trimmed := strings.TrimRight(text, " ")
+	p.text.Reset()
+	p.text.WriteString(trimmed)
+	p.flush()
+	if len(text)-len(trimmed) >= 2 {
+		p.append(&node{kind: breakNode})
+	} else {
+		p.append(&node{kind: softBreakNode})
+	}
+	p.pos++
+	p.skipIndent()
+}
+
+func (p *inlineParser) skipIndent() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// processEmphasis matches the delimiter runs after bottom, or all delimiter runs if bottom is nil,
+// the inlines between matched runs become emphasis, strong emphasis or strikethrough.
+func (p *inlineParser) processEmphasis(bottom *inline) {
+	start := p.head
+	if bottom != nil {
+		start = bottom.next
+	}
+	for closer := start; closer != nil; closer = closer.next {
+		if closer.delim == 0 || !closer.close || closer.count == 0 {
+			continue
+		}
+		var opener *inline
+		for o := closer.prev; o != nil && o != bottom; o = o.prev {
+			if o.delim == closer.delim && o.open && o.count > 0 && matches(o, closer) {
+				opener = o
+				break
+			}
+		}
+		if opener == nil {
+			continue
+		}
+
+		use, k := 1, emphasisNode
+		switch {
+		case closer.delim == '~':
+			use, k = closer.count, strikeNode
+		case opener.count >= 2 && closer.count >= 2:
+			use, k = 2, strongNode
+		}
+		e := &inline{node: &node{kind: k, children: p.nodes(opener.next, closer)}, prev: opener, next: closer}
+		opener.next, closer.prev = e, e
+		opener.count -= use
+		closer.count -= use
+		// the delimiters left in the closer may close another opener
+		if closer.count > 0 {
+			closer = e
+		}
+	}
+}
+
+// matches reports whether the runs can be matched, runs of ~ must have the same length
+// and runs which can both open and close can not match if their lengths sum to a multiple of 3.
+func matches(opener, closer *inline) bool {
+	if closer.delim == '~' {
+		return opener.count == closer.count
+	}
+	if (opener.close || closer.open) && (opener.length+closer.length)%3 == 0 && !(opener.length%3 == 0 && closer.length%3 == 0) {
+		return false
+	}
+	return true
+}
+
+// nodes returns the nodes of the inlines from from until to, delimiters which are left are text.
+func (p *inlineParser) nodes(from, to *inline) []*node {
+	var nodes []*node
+	for e := from; e != nil && e != to; e = e.next {
+		n := e.node
+		if e.delim != 0 {
+			if e.count == 0 {
+				continue
+			}
+			n = &node{kind: textNode, text: strings.Repeat(string(e.delim), e.count)}
+		}
+		if last := len(nodes) - 1; n.kind == textNode && last >= 0 && nodes[last].kind == textNode {
+			nodes[last] = &node{kind: textNode, text: nodes[last].text + n.text}
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// parseDestination parses the destination and optional title of a link in parentheses at pos,
+// it returns the position after the closing parenthesis.
+func parseDestination(src string, pos int) (string, string, int, bool) {
+	if pos >= len(src) || src[pos] != '(' {
+		return "", "", 0, false
+	}
+	i := skipSpace(src, pos+1)
+
+	var dest string
+	if i < len(src) && src[i] == '<' {
+		j := i + 1
+		for ; j < len(src) && src[j] != '>' && src[j] != '<' && src[j] != '\n'; j++ {
+			if src[j] == '\\' && j+1 < len(src) {
+				j++
+			}
+		}
+		if j >= len(src) || src[j] != '>' {
+			return "", "", 0, false
+		}
+		dest, i = src[i+1:j], j+1
+	} else {
+		depth, j := 0, i
+	loop:
+		for ; j < len(src); j++ {
+			switch c := src[j]; {
+			case c == '\\' && j+1 < len(src) && strings.IndexByte(asciiPunctuation, src[j+1]) >= 0:
+				j++
+			case c == '(':
+				depth++
+			case c == ')':
+				if depth == 0 {
+					break loop
+				}
+				depth--
+			case c <= ' ':
+				break loop
+			}
+		}
+		dest, i = src[i:j], j
+	}
+
+	var title string
+	if t := skipSpace(src, i); t > i && t < len(src) && (src[t] == '"' || src[t] == '\'' || src[t] == '(') {
+		end := src[t]
+		if end == '(' {
+			end = ')'
+		}
+		j := t + 1
+		for ; j < len(src) && src[j] != end; j++ {
+			if src[j] == '\\' && j+1 < len(src) {
+				j++
+			}
+		}
+		if j >= len(src) {
+			return "", "", 0, false
+		}
+		title, i = src[t+1:j], j+1
+	}
+
+	i = skipSpace(src, i)
+	if i >= len(src) || src[i] != ')' {
+		return "", "", 0, false
+	}
+	return unescape(dest), unescape(title), i + 1, true
+}
+
+// skipSpace skips spaces and tabs and at most one line break.
+func skipSpace(src string, i int) int {
+	newline := false
+	for ; i < len(src); i++ {
+		switch src[i] {
+		case ' ', '\t':
+		case '\n':
+			if newline {
+				return i
+			}
+			newline = true
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// unescape removes the backslashes before punctuation.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(asciiPunctuation, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
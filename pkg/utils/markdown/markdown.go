@@ -0,0 +1,113 @@
+// Package markdown renders Markdown to safe HTML.
+//
+// It supports the blocks and inlines of CommonMark without reference links and html,
+// plus tables and strikethrough of GitHub Flavored Markdown. Raw html is escaped and
+// links and images only keep urls of safe schemes, so the html can be embedded as it is.
+package markdown
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Options configures the rendering.
+type Options struct {
+	// HeadingIDs sets the ids of headings, which are their anchors in the table of contents
+	HeadingIDs bool
+}
+
+// Heading is an entry of the table of contents, ID is the anchor of the heading.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// Result is a rendered document.
+type Result struct {
+	HTML string
+	// TOC is the table of contents, the headings in document order. It is empty without heading ids
+	TOC []Heading
+	// Text is the plain text of the paragraphs, list items and quotes, without headings, code blocks and tables
+	Text string
+}
+
+// Render parses the source and renders it to html.
+func Render(src string, opts Options) *Result {
+	doc := parse(src)
+	r := newRenderer(opts)
+	r.render(doc)
+	return &Result{
+		HTML: r.b.String(),
+		TOC:  r.toc,
+		Text: strings.Join(strings.Fields(plainText(doc)), " "),
+	}
+}
+
+// Summary returns the text cut to at most n characters at a word boundary, a cut text ends with an ellipsis.
+func Summary(text string, n int) string {
+	if n <= 0 || utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)[:n-1]
+	if i := lastSpace(runes); i > len(runes)/2 {
+		runes = runes[:i]
+	}
+	return strings.TrimRight(string(runes), " ") + "…"
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == ' ' {
+			return i
+		}
+	}
+	return -1
+}
+
+type kind int
+
+const (
+	documentNode kind = iota
+	paragraphNode
+	headingNode
+	codeBlockNode
+	quoteNode
+	listNode
+	itemNode
+	ruleNode
+	tableNode
+	rowNode
+	cellNode
+	textNode
+	softBreakNode
+	breakNode
+	codeNode
+	emphasisNode
+	strongNode
+	strikeNode
+	linkNode
+	imageNode
+)
+
+// node is a node of the syntax tree of a document.
+type node struct {
+	kind     kind
+	children []*node
+	// text of text nodes, code spans and code blocks
+	text string
+	// level of headings
+	level int
+	// lang is the language of code blocks
+	lang string
+	// lists are ordered from start, tight lists have no paragraphs in their items
+	ordered bool
+	start   int
+	tight   bool
+	// header rows and the alignments of cells
+	header bool
+	align  string
+	// url and title of links and images
+	url   string
+	title string
+}
@@ -0,0 +1,124 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		html string
+	}{
+		{
+			name: "paragraphs",
+			src:  "Some **bold**, _em_, ~~del~~ and `code`.\n\nline  \nbreak",
+			html: "<p>Some <strong>bold</strong>, <em>em</em>, <del>del</del> and <code>code</code>.</p>\n<p>line<br>\nbreak</p>\n",
+		},
+		{
+			name: "nested emphasis",
+			src:  "***both*** *a **b** c*",
+			html: "<p><em><strong>both</strong></em> <em>a <strong>b</strong> c</em></p>\n",
+		},
+		{
+			name: "code block",
+			src:  "```go\nif a < b {}\n```\n\n    indented",
+			html: "<pre><code class=\"language-go\">if a &lt; b {}\n</code></pre>\n<pre><code>indented\n</code></pre>\n",
+		},
+		{
+			name: "lists",
+			src:  "- a\n- b\n\n3. x\n\n4. y",
+			html: "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol start=\"3\">\n<li>\n<p>x</p>\n</li>\n<li>\n<p>y</p>\n</li>\n</ol>\n",
+		},
+		{
+			name: "quote and rule",
+			src:  "> quote\n\n---",
+			html: "<blockquote>\n<p>quote</p>\n</blockquote>\n<hr>\n",
+		},
+		{
+			name: "table",
+			src:  "| a | b |\n|:--|--:|\n| 1 | 2 |",
+			html: "<table>\n<thead>\n<tr>\n<th align=\"left\">a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td align=\"left\">1</td>\n<td align=\"right\">2</td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name: "links",
+			src:  "[rel](/posts/1 \"title\") [abs](https://example.com) <https://a.b> <x@y.z> ![img](/a.png)",
+			html: "<p><a href=\"/posts/1\" title=\"title\">rel</a> <a href=\"https://example.com\" rel=\"nofollow noopener\">abs</a> " +
+				"<a href=\"https://a.b\" rel=\"nofollow noopener\">https://a.b</a> <a href=\"mailto:x@y.z\">x@y.z</a> <img src=\"/a.png\" alt=\"img\"></p>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.html, Render(tt.src, Options{}).HTML)
+		})
+	}
+}
+
+func TestRenderUnsafe(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		html string
+	}{
+		{
+			name: "html",
+			src:  "<script>alert(1)</script>",
+			html: "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n",
+		},
+		{
+			name: "javascript link",
+			src:  "[click](javascript:alert(1)) [tab](<java\tscript:alert(1)>) [case](JavaScript:alert(1))",
+			html: "<p>click tab case</p>\n",
+		},
+		{
+			name: "image",
+			src:  "![a](javascript:alert(1)) ![b](mailto:x@y.z) ![c](data:text/html,x)",
+			html: "<p>a b c</p>\n",
+		},
+		{
+			name: "attributes",
+			src:  "[a](/x\"onclick=\"alert(1) \"t\"onmouseover=\"x\")",
+			html: "<p>[a](/x&#34;onclick=&#34;alert(1) &#34;t&#34;onmouseover=&#34;x&#34;)</p>\n",
+		},
+		{
+			name: "code language",
+			src:  "```\"><script>\nx\n```",
+			html: "<pre><code class=\"language-script\">x\n</code></pre>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.html, Render(tt.src, Options{}).HTML)
+		})
+	}
+}
+
+func TestHeadings(t *testing.T) {
+	r := Render("# Hello *world*\n\ntext\n\n## Hello world\n\nSetext\n---\n\n## !!", Options{HeadingIDs: true})
+	assert.Equal(t, "<h1 id=\"hello-world\">Hello <em>world</em></h1>\n<p>text</p>\n"+
+		"<h2 id=\"hello-world-1\">Hello world</h2>\n<h2 id=\"setext\">Setext</h2>\n<h2 id=\"section\">!!</h2>\n", r.HTML)
+	assert.Equal(t, []Heading{
+		{Level: 1, Text: "Hello world", ID: "hello-world"},
+		{Level: 2, Text: "Hello world", ID: "hello-world-1"},
+		{Level: 2, Text: "Setext", ID: "setext"},
+		{Level: 2, Text: "!!", ID: "section"},
+	}, r.TOC)
+
+	r = Render("# Title", Options{})
+	assert.Equal(t, "<h1>Title</h1>\n", r.HTML)
+	assert.Empty(t, r.TOC)
+}
+
+func TestText(t *testing.T) {
+	r := Render("# Title\n\nSome **bold**\ntext.\n\n```\ncode\n```\n\n- item\n\n> [quote](/q)", Options{})
+	assert.Equal(t, "Some bold text. item quote", r.Text)
+}
+
+func TestSummary(t *testing.T) {
+	assert.Equal(t, "short", Summary("short", 10))
+	assert.Equal(t, "Привет…", Summary("Привет мир и всё", 10))
+	assert.Equal(t, "abcdefghi…", Summary("abcdefghijklmnop", 10))
+}
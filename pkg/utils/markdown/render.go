@@ -0,0 +1,289 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// safeSchemes are the schemes of urls kept in links, images only keep http and https urls.
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+type renderer struct {
+	b    strings.Builder
+	opts Options
+	toc  []Heading
+	// ids counts the headings with the same id
+	ids map[string]int
+}
+
+func newRenderer(opts Options) *renderer {
+	return &renderer{opts: opts, ids: map[string]int{}}
+}
+
+func (r *renderer) render(n *node) {
+	switch n.kind {
+	case documentNode:
+		r.blocks(n.children, false)
+	case paragraphNode:
+		r.b.WriteString("<p>")
+		r.inlines(n.children)
+		r.b.WriteString("</p>\n")
+	case headingNode:
+		r.heading(n)
+	case codeBlockNode:
+		r.b.WriteString("<pre><code")
+		if lang := language(n.lang); lang != "" {
+			fmt.Fprintf(&r.b, ` class="language-%s"`, lang)
+		}
+		r.b.WriteString(">")
+		r.b.WriteString(html.EscapeString(n.text))
+		r.b.WriteString("</code></pre>\n")
+	case quoteNode:
+		r.b.WriteString("<blockquote>\n")
+		r.blocks(n.children, false)
+		r.b.WriteString("</blockquote>\n")
+	case listNode:
+		r.list(n)
+	case ruleNode:
+		r.b.WriteString("<hr>\n")
+	case tableNode:
+		r.table(n)
+	}
+}
+
+// blocks renders blocks, the paragraphs of tight lists are rendered without p.
+func (r *renderer) blocks(blocks []*node, tight bool) {
+	for i, block := range blocks {
+		if tight && block.kind == paragraphNode {
+			r.inlines(block.children)
+			if i < len(blocks)-1 {
+				r.b.WriteString("\n")
+			}
+			continue
+		}
+		r.render(block)
+	}
+}
+
+func (r *renderer) heading(n *node) {
+	fmt.Fprintf(&r.b, "<h%d", n.level)
+	if r.opts.HeadingIDs {
+		text := strings.Join(strings.Fields(plainText(n)), " ")
+		id := r.id(text)
+		r.toc = append(r.toc, Heading{Level: n.level, Text: text, ID: id})
+		fmt.Fprintf(&r.b, ` id="%s"`, html.EscapeString(id))
+	}
+	r.b.WriteString(">")
+	r.inlines(n.children)
+	fmt.Fprintf(&r.b, "</h%d>\n", n.level)
+}
+
+// id returns the unique id of a heading, it is the text in lower case with dashes for spaces
+// and a number for the headings with the same text.
+func (r *renderer) id(text string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '-':
+			b.WriteRune(c)
+		case unicode.IsSpace(c):
+			b.WriteByte('-')
+		}
+	}
+	base := b.String()
+	if base == "" {
+		base = "section"
+	}
+
+	id := base
+	n := r.ids[id]
+	r.ids[id] = n + 1
+	if n > 0 {
+		id = base + "-" + strconv.Itoa(n)
+		// a heading may already have the numbered id
+		for r.ids[id] > 0 {
+			n++
+			id = base + "-" + strconv.Itoa(n)
+		}
+		r.ids[id] = 1
+	}
+	return id
+}
+
+func (r *renderer) list(n *node) {
+	tag := "ul"
+	if n.ordered {
+		tag = "ol"
+	}
+	r.b.WriteString("<" + tag)
+	if n.ordered && n.start != 1 {
+		fmt.Fprintf(&r.b, ` start="%d"`, n.start)
+	}
+	r.b.WriteString(">\n")
+	for _, item := range n.children {
+		r.b.WriteString("<li>")
+		if !n.tight && len(item.children) > 0 {
+			r.b.WriteString("\n")
+		}
+		r.blocks(item.children, n.tight)
+		r.b.WriteString("</li>\n")
+	}
+	r.b.WriteString("</" + tag + ">\n")
+}
+
+func (r *renderer) table(n *node) {
+	r.b.WriteString("<table>\n")
+	for i, row := range n.children {
+		switch {
+		case row.header:
+			r.b.WriteString("<thead>\n")
+		case i == 1:
+			r.b.WriteString("<tbody>\n")
+		}
+		r.b.WriteString("<tr>\n")
+		for _, cell := range row.children {
+			tag := "td"
+			if cell.header {
+				tag = "th"
+			}
+			r.b.WriteString("<" + tag)
+			if cell.align != "" {
+				fmt.Fprintf(&r.b, ` align="%s"`, cell.align)
+			}
+			r.b.WriteString(">")
+			r.inlines(cell.children)
+			r.b.WriteString("</" + tag + ">\n")
+		}
+		r.b.WriteString("</tr>\n")
+		switch {
+		case row.header:
+			r.b.WriteString("</thead>\n")
+		case i == len(n.children)-1:
+			r.b.WriteString("</tbody>\n")
+		}
+	}
+	r.b.WriteString("</table>\n")
+}
+
+func (r *renderer) inlines(nodes []*node) {
+	for _, n := range nodes {
+		r.inline(n)
+	}
+}
+
+func (r *renderer) inline(n *node) {
+	switch n.kind {
+	case textNode:
+		r.b.WriteString(html.EscapeString(n.text))
+	case softBreakNode:
+		r.b.WriteString("\n")
+	case breakNode:
+		r.b.WriteString("<br>\n")
+	case codeNode:
+		r.b.WriteString("<code>" + html.EscapeString(n.text) + "</code>")
+	case emphasisNode:
+		r.wrap("em", n.children)
+	case strongNode:
+		r.wrap("strong", n.children)
+	case strikeNode:
+		r.wrap("del", n.children)
+	case linkNode:
+		url, ok := safeURL(n.url, false)
+		if !ok {
+			// the text of a link with an unsafe url is kept
+			r.inlines(n.children)
+			return
+		}
+		fmt.Fprintf(&r.b, `<a href="%s"`, html.EscapeString(url))
+		if n.title != "" {
+			fmt.Fprintf(&r.b, ` title="%s"`, html.EscapeString(n.title))
+		}
+		if strings.Contains(url, "://") {
+			r.b.WriteString(` rel="nofollow noopener"`)
+		}
+		r.b.WriteString(">")
+		r.inlines(n.children)
+		r.b.WriteString("</a>")
+	case imageNode:
+		alt := html.EscapeString(plainText(n))
+		url, ok := safeURL(n.url, true)
+		if !ok {
+			r.b.WriteString(alt)
+			return
+		}
+		fmt.Fprintf(&r.b, `<img src="%s" alt="%s"`, html.EscapeString(url), alt)
+		if n.title != "" {
+			fmt.Fprintf(&r.b, ` title="%s"`, html.EscapeString(n.title))
+		}
+		r.b.WriteString(">")
+	}
+}
+
+func (r *renderer) wrap(tag string, children []*node) {
+	r.b.WriteString("<" + tag + ">")
+	r.inlines(children)
+	r.b.WriteString("</" + tag + ">")
+}
+
+// safeURL returns the url without control characters, which browsers ignore, if it is relative
+// or its scheme is safe. Urls of images must be relative, http or https.
+func safeURL(url string, image bool) (string, bool) {
+	url = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url)
+
+	if i := strings.IndexAny(url, ":/?#"); i > 0 && url[i] == ':' {
+		scheme := strings.ToLower(url[:i])
+		if !safeSchemes[scheme] || (image && scheme == "mailto") {
+			return "", false
+		}
+	} else if i == 0 && url[0] == ':' {
+		return "", false
+	}
+	return url, true
+}
+
+// language returns the language of a code block with the characters allowed in a class name.
+func language(lang string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_+#.-", r)) {
+			return r
+		}
+		return -1
+	}, lang)
+}
+
+// plainText returns the text of the children of the node, the text of paragraphs is separated by line breaks.
+// Headings, code blocks, rules and tables in the children have no text.
+func plainText(n *node) string {
+	var b strings.Builder
+	var walk func(n *node)
+	walk = func(n *node) {
+		switch n.kind {
+		case textNode, codeNode:
+			b.WriteString(n.text)
+		case softBreakNode, breakNode:
+			b.WriteByte(' ')
+		case paragraphNode:
+			for _, c := range n.children {
+				walk(c)
+			}
+			b.WriteByte('\n')
+		case headingNode, codeBlockNode, ruleNode, tableNode:
+		default:
+			for _, c := range n.children {
+				walk(c)
+			}
+		}
+	}
+	for _, c := range n.children {
+		walk(c)
+	}
+	return b.String()
+}
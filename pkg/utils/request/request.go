@@ -1,6 +1,7 @@
 package request
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -19,8 +20,16 @@ const (
 	UpdateOperation = "update"
 	PatchOperation  = "patch"
 	DeleteOperation = "delete"
+	WatchOperation  = "watch"
 )
 
+// BatchPath is the path of batches under an api version, like /api/v1/batch.
+// A batch is not a resource request, each of its operations is authorized by itself.
+const BatchPath = "batch"
+
+// OpenAPIPath is the path of the openapi document under an api version, like /api/v2/openapi.json.
+const OpenAPIPath = "openapi.json"
+
 type RequestInfoResolver interface {
 	NewRequestInfo(req *http.Request) (*RequestInfo, error)
 }
@@ -46,6 +55,8 @@ type RequestInfo struct {
 
 type RequestInfoFactory struct {
 	APIPrefixes set.String
+	// SubresourceAliases maps subresources like posts/comments to the subresource checked by rules, like comment.
+	SubresourceAliases map[string]string
 }
 
 // TODO write an integration test against the swagger doc to test the RequestInfo and match up behavior to responses
@@ -66,6 +77,8 @@ type RequestInfoFactory struct {
 // /api/{version}/watch/namespaces/{namespace}/{resource}
 //
 // NonResource paths
+// /api/{version}/batch
+// /api/{version}/openapi.json
 // /apis/{api-group}/{version}
 // /apis/{api-group}
 // /apis
@@ -94,20 +107,38 @@ func (r *RequestInfoFactory) NewRequestInfo(req *http.Request) (*RequestInfo, er
 	requestInfo.APIPrefix = currentParts[0]
 	currentParts = currentParts[1:]
 
+	if len(currentParts) == 2 && (currentParts[1] == BatchPath || currentParts[1] == OpenAPIPath) {
+		// return a non-resource request
+		return &requestInfo, nil
+	}
+
 	requestInfo.IsResourceRequest = true
 	requestInfo.APIVersion = currentParts[0]
 	currentParts = currentParts[1:]
 
-	switch req.Method {
-	case "POST":
+	// handle input of form /watch/{resource}, only GET is allowed for watch
+	if currentParts[0] == WatchOperation {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			return &requestInfo, fmt.Errorf("method %s is not allowed for watch", req.Method)
+		}
+		requestInfo.Verb = WatchOperation
+		currentParts = currentParts[1:]
+		if len(currentParts) == 0 {
+			return &requestInfo, fmt.Errorf("missing resource to watch")
+		}
+	}
+
+	switch {
+	case requestInfo.Verb == WatchOperation:
+	case req.Method == "POST":
 		requestInfo.Verb = CreateOperation
-	case "GET", "HEAD":
+	case req.Method == "GET" || req.Method == "HEAD":
 		requestInfo.Verb = GetOperation
-	case "PUT":
+	case req.Method == "PUT":
 		requestInfo.Verb = UpdateOperation
-	case "PATCH":
+	case req.Method == "PATCH":
 		requestInfo.Verb = PatchOperation
-	case "DELETE":
+	case req.Method == "DELETE":
 		requestInfo.Verb = DeleteOperation
 	default:
 		requestInfo.Verb = ""
@@ -143,6 +174,10 @@ func (r *RequestInfoFactory) NewRequestInfo(req *http.Request) (*RequestInfo, er
 		requestInfo.Resource = requestInfo.Parts[0]
 	}
 
+	if alias, ok := r.SubresourceAliases[requestInfo.Resource+"/"+requestInfo.Subresource]; ok {
+		requestInfo.Subresource = alias
+	}
+
 	// if there's no name on the request and we thought it was a get before, then the actual verb is a list or a watch
 	if len(requestInfo.Name) == 0 && requestInfo.Verb == GetOperation {
 		requestInfo.Verb = ListOperation
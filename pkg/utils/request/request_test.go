@@ -9,7 +9,7 @@ import (
 )
 
 func TestRequestInfo(t *testing.T) {
-	resolver := RequestInfoFactory{set.NewString("api")}
+	resolver := RequestInfoFactory{APIPrefixes: set.NewString("api"), SubresourceAliases: map[string]string{"posts/comments": "comment"}}
 	baseAddr := "http://localhost:8080"
 
 	testCases := []struct {
@@ -41,6 +41,39 @@ func TestRequestInfo(t *testing.T) {
 			Name:              "1",
 			Parts:             []string{"jobs", "1", "log"},
 		}},
+		{"watch resource", "GET", "/api/v1/watch/users", false, &RequestInfo{
+			IsResourceRequest: true,
+			Verb:              "watch",
+			APIPrefix:         "api",
+			APIVersion:        "v1",
+			Namespace:         "root",
+			Resource:          "users",
+			Parts:             []string{"users"},
+		}},
+		{"watch namespaced resource", "GET", "/api/v1/watch/namespaces/ns1/posts", false, &RequestInfo{
+			IsResourceRequest: true,
+			Verb:              "watch",
+			APIPrefix:         "api",
+			APIVersion:        "v1",
+			Namespace:         "ns1",
+			Resource:          "posts",
+			Parts:             []string{"posts"},
+		}},
+		{"watch without resource", "GET", "/api/v1/watch", true, nil},
+		{"watch with post", "POST", "/api/v1/watch/users", true, nil},
+		{"batch", "POST", "/api/v1/batch", false, &RequestInfo{Verb: "post", APIPrefix: "api"}},
+		{"openapi", "GET", "/api/v2/openapi.json", false, &RequestInfo{Verb: "get", APIPrefix: "api"}},
+		{"subresource alias", "DELETE", "/api/v2/posts/1/comments/2", false, &RequestInfo{
+			IsResourceRequest: true,
+			Verb:              "delete",
+			APIPrefix:         "api",
+			APIVersion:        "v2",
+			Namespace:         "root",
+			Resource:          "posts",
+			Subresource:       "comment",
+			Name:              "1",
+			Parts:             []string{"posts", "1", "comments", "2"},
+		}},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,139 @@
+// Package validation validates api payloads by their struct tags, like `validate:"required,max=100,name"`,
+// the errors are returned as field errors of an apierrors Invalid error.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrorFunc returns the field error of an invalid value.
+type ErrorFunc func(path *field.Path, value interface{}) *field.Error
+
+const tagName = "validate"
+
+var (
+	// nameRegexp matches names of groups and roles, like system:authenticated or ns-dev-admin
+	nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._:-]*[a-zA-Z0-9])?$`)
+	// userNameRegexp matches names of users, which can not contain colons
+	userNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]*[a-zA-Z0-9])?$`)
+
+	validate   = validator.New()
+	errorFuncs = map[string]ErrorFunc{}
+)
+
+func init() {
+	validate.SetTagName(tagName)
+	validate.RegisterTagNameFunc(jsonName)
+
+	RegisterValidation("name", matches(nameRegexp), invalid(
+		"must consist of alphanumeric characters, '-', '_', '.' or ':', and must start and end with an alphanumeric character"))
+	RegisterValidation("username", matches(userNameRegexp), invalid(
+		"must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character"))
+}
+
+// RegisterValidation adds a validator of the tag, errorFunc returns the field error of invalid values.
+// Validators must be registered before validating, like in the constructors of services.
+func RegisterValidation(tag string, fn validator.Func, errorFunc ErrorFunc) {
+	if err := validate.RegisterValidation(tag, fn); err != nil {
+		panic(err)
+	}
+	errorFuncs[tag] = errorFunc
+}
+
+// Struct validates the tags of obj, it returns an Invalid error with the field errors of all invalid fields.
+func Struct(obj interface{}) error {
+	err := validate.Struct(obj)
+	if err == nil {
+		return nil
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return apierrors.NewBadRequest(err)
+	}
+	return apierrors.NewInvalid(FieldErrors(verrs))
+}
+
+// FieldErrors converts validation errors to field errors, the paths are the json names without the struct name.
+func FieldErrors(verrs validator.ValidationErrors) field.ErrorList {
+	errs := make(field.ErrorList, 0, len(verrs))
+	for _, e := range verrs {
+		errs = append(errs, fieldError(e))
+	}
+	return errs
+}
+
+func fieldError(e validator.FieldError) *field.Error {
+	path := e.Namespace()
+	if i := strings.Index(path, "."); i >= 0 {
+		path = path[i+1:]
+	}
+	fp := field.NewPath(path)
+
+	if errorFunc, ok := errorFuncs[e.Tag()]; ok {
+		return errorFunc(fp, e.Value())
+	}
+
+	switch e.Tag() {
+	case "required", "required_if":
+		return field.Required(fp, "")
+	case "oneof":
+		return field.NotSupported(fp, e.Value(), strings.Fields(e.Param()))
+	case "min", "max", "len":
+		// the value is not returned, it may be a password or a long content
+		return field.Invalid(fp, nil, lengthDetail(e))
+	case "email":
+		return field.Invalid(fp, e.Value(), "must be a valid email address")
+	}
+	return field.Invalid(fp, e.Value(), fmt.Sprintf("failed on the %s validation", e.Tag()))
+}
+
+func lengthDetail(e validator.FieldError) string {
+	var detail string
+	switch e.Tag() {
+	case "min":
+		detail = "must be at least " + e.Param()
+	case "max":
+		detail = "must be at most " + e.Param()
+	default:
+		detail = "must be exactly " + e.Param()
+	}
+
+	switch e.Kind() {
+	case reflect.String:
+		return detail + " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return detail + " items"
+	}
+	return detail
+}
+
+func matches(re *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return re.MatchString(fl.Field().String())
+	}
+}
+
+func invalid(detail string) ErrorFunc {
+	return func(path *field.Path, value interface{}) *field.Error {
+		return field.Invalid(path, value, detail)
+	}
+}
+
+// jsonName returns the json name of a struct field, so the paths of errors are the paths of the payload.
+func jsonName(f reflect.StructField) string {
+	name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,71 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/eastygh/webm-nas/pkg/apierrors"
+	"github.com/eastygh/webm-nas/pkg/model"
+	"github.com/eastygh/webm-nas/pkg/utils/field"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      interface{}
+		expected field.ErrorList
+	}{
+		{
+			name: "valid user",
+			obj:  &model.CreatedUser{Name: "alice", Password: "123456", Email: "alice@example.com"},
+		},
+		{
+			name: "invalid user",
+			obj:  &model.CreatedUser{Name: "system:alice", Password: "123", Email: "alice"},
+			expected: field.ErrorList{
+				field.Invalid(field.NewPath("name"), "system:alice", "must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character"),
+				field.Invalid(field.NewPath("password"), nil, "must be at least 6 characters"),
+				field.Invalid(field.NewPath("email"), "alice", "must be a valid email address"),
+			},
+		},
+		{
+			name: "valid group",
+			obj:  &model.CreatedGroup{Name: "system:authenticated"},
+		},
+		{
+			name:     "group without name",
+			obj:      &model.CreatedGroup{},
+			expected: field.ErrorList{field.Required(field.NewPath("name"), "")},
+		},
+		{
+			name: "valid role",
+			obj: &model.Role{Name: "ns-dev-admin", Scope: model.NamespaceScope, Namespace: "dev", Rules: model.Rules{
+				{Resource: "posts", Operation: model.EditOperation},
+			}},
+		},
+		{
+			name: "invalid role",
+			obj:  &model.Role{Name: "-editor", Scope: model.NamespaceScope},
+			expected: field.ErrorList{
+				field.Invalid(field.NewPath("name"), "-editor", "must consist of alphanumeric characters, '-', '_', '.' or ':', and must start and end with an alphanumeric character"),
+				field.Required(field.NewPath("namespace"), ""),
+			},
+		},
+		{
+			name:     "unsupported scope",
+			obj:      &model.Role{Name: "editor", Scope: "global"},
+			expected: field.ErrorList{field.NotSupported(field.NewPath("scope"), model.Scope("global"), []string{"cluster", "namespace"})},
+		},
+	}
+
+	for _, test := range tests {
+		err := Struct(test.obj)
+		if test.expected == nil {
+			assert.NoError(t, err, test.name)
+			continue
+		}
+		assert.True(t, apierrors.IsInvalid(err), test.name)
+		assert.Equal(t, test.expected, apierrors.FromError(err).Details, test.name)
+	}
+}
@@ -0,0 +1,205 @@
+package watch
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	Created  EventType = "create"
+	Updated  EventType = "update"
+	Deleted  EventType = "delete"
+	Bookmark EventType = "bookmark"
+)
+
+const (
+	defaultHistorySize = 1024
+	watcherBufferSize  = 64
+)
+
+// ErrResourceVersionTooOld means the events after the resource version are no longer kept,
+// the client should list the resource again and watch from the new resource version.
+var ErrResourceVersionTooOld = errors.New("resource version too old")
+
+// Event is a change of a resource object, ResourceVersion increases with every event.
+type Event struct {
+	Type            EventType   `json:"type"`
+	Resource        string      `json:"resource"`
+	Name            string      `json:"name"`
+	ResourceVersion uint64      `json:"resourceVersion"`
+	Object          interface{} `json:"object,omitempty"`
+}
+
+// Publisher is used by services to publish changes of resources.
+type Publisher interface {
+	Publish(resource string, eventType EventType, name string, object interface{})
+}
+
+// Broadcaster keeps recent events in memory and fans them out to watchers.
+type Broadcaster struct {
+	lock     sync.Mutex
+	version  uint64
+	history  []Event
+	size     int
+	watchers map[*Watcher]struct{}
+	closed   bool
+}
+
+func NewBroadcaster(historySize int) *Broadcaster {
+	if historySize <= 0 {
+		historySize = defaultHistorySize
+	}
+	return &Broadcaster{
+		// versions are seeded by time, so versions of a previous process are always too old
+		version:  uint64(time.Now().UnixMicro()),
+		size:     historySize,
+		watchers: make(map[*Watcher]struct{}),
+	}
+}
+
+func (b *Broadcaster) Publish(resource string, eventType EventType, name string, object interface{}) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.version++
+	event := Event{
+		Type:            eventType,
+		Resource:        resource,
+		Name:            name,
+		ResourceVersion: b.version,
+		Object:          object,
+	}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+
+	for w := range b.watchers {
+		if w.resource == resource && !w.send(event) {
+			delete(b.watchers, w)
+		}
+	}
+}
+
+// ResourceVersion returns the version of the latest event.
+func (b *Broadcaster) ResourceVersion() uint64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.version
+}
+
+// Watch returns a watcher of the resource, events after resourceVersion are replayed first,
+// a zero resourceVersion watches from now on.
+func (b *Broadcaster) Watch(resource string, resourceVersion uint64) (*Watcher, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return nil, errors.New("broadcaster is closed")
+	}
+
+	var replay []Event
+	if resourceVersion != 0 && resourceVersion < b.version {
+		if len(b.history) == 0 || b.history[0].ResourceVersion > resourceVersion+1 {
+			return nil, ErrResourceVersionTooOld
+		}
+		for _, event := range b.history {
+			if event.ResourceVersion > resourceVersion && event.Resource == resource {
+				replay = append(replay, event)
+			}
+		}
+	} else if resourceVersion > b.version {
+		return nil, ErrResourceVersionTooOld
+	}
+
+	start := resourceVersion
+	if start == 0 {
+		start = b.version
+	}
+	w := &Watcher{
+		resource: resource,
+		version:  start,
+		result:   make(chan Event, len(replay)+watcherBufferSize),
+		stop:     b.stop,
+	}
+	for _, event := range replay {
+		w.result <- event
+	}
+	b.watchers[w] = struct{}{}
+
+	return w, nil
+}
+
+// Close stops all watchers, no more events are published.
+func (b *Broadcaster) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.closed = true
+	for w := range b.watchers {
+		w.close()
+		delete(b.watchers, w)
+	}
+}
+
+func (b *Broadcaster) stop(w *Watcher) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.watchers[w]; ok {
+		w.close()
+		delete(b.watchers, w)
+	}
+}
+
+// Watcher receives the events of one resource.
+type Watcher struct {
+	resource string
+	version  uint64
+	result   chan Event
+	stop     func(*Watcher)
+	once     sync.Once
+}
+
+// ResultChan returns the events, it is closed when the watcher is stopped
+// or the watcher is too slow to receive events.
+func (w *Watcher) ResultChan() <-chan Event {
+	return w.result
+}
+
+// ResourceVersion returns the version the watcher starts from,
+// all events received later have greater versions.
+func (w *Watcher) ResourceVersion() uint64 {
+	return w.version
+}
+
+// Stop stops the watcher and releases its resources.
+func (w *Watcher) Stop() {
+	w.stop(w)
+}
+
+// send is called with the broadcaster lock held, a slow watcher is closed
+// instead of blocking the publisher.
+func (w *Watcher) send(event Event) bool {
+	select {
+	case w.result <- event:
+		return true
+	default:
+		w.close()
+		return false
+	}
+}
+
+func (w *Watcher) close() {
+	w.once.Do(func() {
+		close(w.result)
+	})
+}
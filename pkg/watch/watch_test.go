@@ -0,0 +1,69 @@
+package watch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster(3)
+
+	w, err := b.Watch("users", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, b.ResourceVersion(), w.ResourceVersion())
+
+	b.Publish("users", Created, "1", nil)
+	b.Publish("posts", Created, "1", nil)
+	b.Publish("users", Updated, "1", nil)
+
+	event := <-w.ResultChan()
+	assert.Equal(t, Created, event.Type)
+	first := event.ResourceVersion
+	event = <-w.ResultChan()
+	assert.Equal(t, Updated, event.Type)
+	assert.Equal(t, first+2, event.ResourceVersion)
+
+	w.Stop()
+	_, ok := <-w.ResultChan()
+	assert.False(t, ok)
+
+	// resume after the first event
+	w, err = b.Watch("users", first)
+	assert.NoError(t, err)
+	assert.Equal(t, first, w.ResourceVersion())
+	event = <-w.ResultChan()
+	assert.Equal(t, first+2, event.ResourceVersion)
+	w.Stop()
+
+	// history only keeps 3 events
+	b.Publish("users", Deleted, "1", nil)
+	b.Publish("users", Created, "2", nil)
+	_, err = b.Watch("users", first)
+	assert.ErrorIs(t, err, ErrResourceVersionTooOld)
+
+	_, err = b.Watch("users", b.ResourceVersion()+10)
+	assert.ErrorIs(t, err, ErrResourceVersionTooOld)
+
+	w, err = b.Watch("users", b.ResourceVersion())
+	assert.NoError(t, err)
+	b.Close()
+	_, ok = <-w.ResultChan()
+	assert.False(t, ok)
+}
+
+func TestSlowWatcher(t *testing.T) {
+	b := NewBroadcaster(0)
+	w, err := b.Watch("posts", 0)
+	assert.NoError(t, err)
+
+	for i := 0; i < watcherBufferSize+2; i++ {
+		b.Publish("posts", Created, "1", nil)
+	}
+
+	count := 0
+	for range w.ResultChan() {
+		count++
+	}
+	assert.Equal(t, watcherBufferSize, count)
+}